@@ -5,12 +5,19 @@ import (
 	"flag"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/repository"
 	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/infrastructure/d2"
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/infrastructure/icons"
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/infrastructure/logging"
 	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/infrastructure/mcp"
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/infrastructure/metrics"
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/infrastructure/workspace"
 	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/presentation/handler"
 	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/usecase"
 	mcptypes "github.com/mark3labs/mcp-go/mcp"
@@ -34,6 +41,7 @@ type transportConfig struct {
 	EndpointPath      string
 	HeartbeatInterval int
 	Stateless         bool
+	MetricsPath       string
 }
 
 // toolRegistration bundles a tool definition with its handler for batch registration.
@@ -43,9 +51,6 @@ type toolRegistration struct {
 }
 
 func main() {
-	// Disable D2 logs.
-	os.Setenv("D2_LOG_LEVEL", "NONE")
-
 	// Parse command line flags.
 	var (
 		transport         string
@@ -56,6 +61,10 @@ func main() {
 		endpointPath      string
 		heartbeatInterval int
 		stateless         bool
+		metricsPath       string
+		logFile           string
+		logLevel          string
+		watchDir          string
 	)
 	flag.StringVar(&transport, "transport", "stdio", "Transport mode: stdio, sse, or streamable")
 	flag.StringVar(&addr, "addr", ":3000", "Address to listen on for SSE/Streamable HTTP transport")
@@ -65,6 +74,10 @@ func main() {
 	flag.StringVar(&endpointPath, "endpoint-path", "/mcp", "Endpoint path for Streamable HTTP transport")
 	flag.IntVar(&heartbeatInterval, "heartbeat-interval", 30, "Heartbeat interval in seconds for Streamable HTTP")
 	flag.BoolVar(&stateless, "stateless", false, "Enable stateless mode for Streamable HTTP")
+	flag.StringVar(&metricsPath, "metrics-path", "/metrics", "Path for Prometheus metrics on the SSE/Streamable HTTP listener")
+	flag.StringVar(&logFile, "log-file", "", "Log file path, or 'stderr' (default: d2mcp.log in the temp dir for stdio, stderr otherwise)")
+	flag.StringVar(&logLevel, "log-level", "info", "Log level: debug, info, warn, or error")
+	flag.StringVar(&watchDir, "watch-dir", "", "Load every .d2 file in this directory as a diagram and reload on changes (disabled if empty)")
 	flag.Parse()
 
 	// Validate transport mode.
@@ -73,22 +86,47 @@ func main() {
 		os.Exit(1)
 	}
 
-	configureLogging(transport, addr)
+	logger, closeLog, err := configureLogging(transport, logFile, logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to configure logging: %v\n", err)
+		os.Exit(1)
+	}
+	defer closeLog.Close()
+
+	ctx := logging.WithContext(context.Background(), logger)
 
-	ctx := context.Background()
+	// Initialize metrics.
+	m := metrics.New()
+
+	// Initialize the embedded badge pack.
+	iconRepo, err := icons.NewRepository()
+	if err != nil {
+		fatal(logger, "Failed to load badge pack", err)
+	}
 
 	// Initialize domain layer.
-	oracleRepo := d2.NewD2OracleRepository()
+	oracleRepo := logging.NewOracleRepository(d2.NewD2OracleRepository(d2.WithObserver(m), d2.WithIcons(iconRepo)))
 	diagramUseCase := usecase.NewDiagramUseCase(oracleRepo)
 	oracleUseCase := usecase.NewOracleUseCase(oracleRepo)
+	iconUseCase := usecase.NewIconUseCase(iconRepo)
+
+	// Sync diagrams with a working directory, if requested.
+	var workspaceUseCase *usecase.WorkspaceUseCase
+	if watchDir != "" {
+		ws, err := configureWorkspace(ctx, logger, watchDir, oracleRepo)
+		if err != nil {
+			fatal(logger, "Failed to set up watch directory", err)
+		}
+		workspaceUseCase = usecase.NewWorkspaceUseCase(oracleRepo, ws)
+	}
 
 	// Initialize MCP server with transport.
-	srv, err := mcp.NewServer(ServerName, ServerVersion)
+	srv, err := mcp.NewServer(ServerName, ServerVersion, server.WithHooks(m.Hooks()))
 	if err != nil {
-		log.Fatalf("Failed to create MCP server: %v", err)
+		fatal(logger, "Failed to create MCP server", err)
 	}
 
-	configureTransport(srv, transportConfig{
+	configureTransport(logger, srv, transportConfig{
 		Transport:         transport,
 		Addr:              addr,
 		BaseURL:           baseURL,
@@ -97,41 +135,80 @@ func main() {
 		EndpointPath:      endpointPath,
 		HeartbeatInterval: heartbeatInterval,
 		Stateless:         stateless,
-	})
+		MetricsPath:       metricsPath,
+	}, m)
+	srv.WithHTTPHandler("/icons/", http.StripPrefix("/icons", iconRepo.Handler()))
 
 	// Register all tools.
-	tools := buildToolRegistrations(diagramUseCase, oracleUseCase)
+	tools := buildToolRegistrations(diagramUseCase, oracleUseCase, iconUseCase, workspaceUseCase, m)
 	for _, t := range tools {
-		if err := srv.RegisterTool(t.tool, t.handler); err != nil {
-			log.Fatalf("Failed to register tool '%s': %v", t.tool.Name, err)
+		h := logging.Middleware(logger, t.tool.Name, m.Middleware(t.tool.Name, t.handler))
+		if err := srv.RegisterTool(t.tool, h); err != nil {
+			fatal(logger, fmt.Sprintf("Failed to register tool '%s'", t.tool.Name), err)
 		}
 	}
+	m.SetReady(true)
 
 	// Start the server.
-	log.Printf("Starting %s v%s (%s transport)...", ServerName, ServerVersion, transport)
+	logger.Info("Starting server", "name", ServerName, "version", ServerVersion, "transport", transport, "addr", addr)
 	if err := srv.Start(ctx); err != nil {
-		log.Fatalf("Server error: %v", err)
+		fatal(logger, "Server error", err)
 	}
 }
 
-// configureLogging sets up the log output based on transport mode.
-// In stdio mode, logs go to a file to avoid interfering with stdio communication.
-func configureLogging(transport, addr string) {
-	if transport == "stdio" {
-		logFile, err := os.OpenFile("/tmp/d2mcp.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if err != nil {
-			log.SetOutput(io.Discard)
-		} else {
-			log.SetOutput(logFile)
-		}
-	} else {
-		log.SetOutput(os.Stderr)
-		log.Printf("Starting in %s mode on %s", transport, addr)
+// configureLogging creates the JSON logger and installs it as the default.
+// In stdio mode, logs go to a file by default to avoid interfering with stdio communication.
+func configureLogging(transport, logFile, logLevel string) (*slog.Logger, io.Closer, error) {
+	switch {
+	case logFile == "stderr":
+		logFile = ""
+	case logFile == "" && transport == "stdio":
+		logFile = filepath.Join(os.TempDir(), "d2mcp.log")
+	}
+
+	logger, closer, err := logging.New(logging.Config{File: logFile, Level: logLevel})
+	if err != nil {
+		return nil, nil, err
 	}
+	slog.SetDefault(logger)
+	return logger, closer, nil
+}
+
+// configureWorkspace loads the .d2 files of the watch directory and starts watching it for changes.
+func configureWorkspace(ctx context.Context, logger *slog.Logger, dir string, oracleRepo repository.OracleRepository) (*workspace.Workspace, error) {
+	ws, err := workspace.New(dir, oracleRepo)
+	if err != nil {
+		return nil, err
+	}
+	n, err := ws.LoadAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Watch directory loaded", "dir", ws.Root(), "diagrams", n)
+
+	go func() {
+		if err := ws.Watch(ctx); err != nil {
+			logger.Error("Watching directory failed", slog.Any("error", err))
+		}
+	}()
+	return ws, nil
+}
+
+// fatal logs the error and exits.
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, slog.Any("error", err))
+	os.Exit(1)
 }
 
 // configureTransport sets the transport and its configuration on the server.
-func configureTransport(srv *mcp.Server, cfg transportConfig) {
+// For HTTP transports, the metrics and health endpoints are mounted on the same listener.
+func configureTransport(logger *slog.Logger, srv *mcp.Server, cfg transportConfig, m *metrics.Metrics) {
+	if cfg.Transport != "stdio" {
+		srv.WithHTTPHandler(cfg.MetricsPath, m.Handler())
+		srv.WithHTTPHandler("/healthz", m.HealthHandler())
+		srv.WithHTTPHandler("/readyz", m.ReadyHandler())
+	}
+
 	switch cfg.Transport {
 	case "sse":
 		baseURL := cfg.BaseURL
@@ -151,8 +228,11 @@ func configureTransport(srv *mcp.Server, cfg transportConfig) {
 			KeepAliveInterval: time.Duration(cfg.KeepAlive) * time.Second,
 		})
 
-		log.Printf("  SSE stream: %s%s/sse", baseURL, cfg.BasePath)
-		log.Printf("  Messages:   %s%s/message", baseURL, cfg.BasePath)
+		logger.Info("SSE transport configured",
+			"sse", baseURL+cfg.BasePath+"/sse",
+			"messages", baseURL+cfg.BasePath+"/message",
+			"metrics", baseURL+cfg.MetricsPath,
+		)
 
 	case "streamable":
 		srv.WithTransport(mcp.TransportStreamableHTTP)
@@ -163,10 +243,11 @@ func configureTransport(srv *mcp.Server, cfg transportConfig) {
 			Stateless:         cfg.Stateless,
 		})
 
-		log.Printf("  Endpoint: http://localhost%s%s", cfg.Addr, cfg.EndpointPath)
-		if cfg.Stateless {
-			log.Printf("  Mode: stateless")
-		}
+		logger.Info("Streamable HTTP transport configured",
+			"endpoint", fmt.Sprintf("http://localhost%s%s", cfg.Addr, cfg.EndpointPath),
+			"metrics", fmt.Sprintf("http://localhost%s%s", cfg.Addr, cfg.MetricsPath),
+			"stateless", cfg.Stateless,
+		)
 
 	default:
 		srv.WithTransport(mcp.TransportStdio)
@@ -174,10 +255,10 @@ func configureTransport(srv *mcp.Server, cfg transportConfig) {
 }
 
 // buildToolRegistrations creates all handler instances and returns their tool registrations.
-func buildToolRegistrations(diagramUC *usecase.DiagramUseCase, oracleUC *usecase.OracleUseCase) []toolRegistration {
+func buildToolRegistrations(diagramUC *usecase.DiagramUseCase, oracleUC *usecase.OracleUseCase, iconUC *usecase.IconUseCase, workspaceUC *usecase.WorkspaceUseCase, m *metrics.Metrics) []toolRegistration {
 	createHandler := handler.NewCreateHandler(diagramUC)
-	exportHandler := handler.NewExportHandler(diagramUC)
-	renderArtifactHandler := handler.NewRenderArtifactHandler(diagramUC)
+	exportHandler := handler.NewExportHandler(diagramUC).WithArtifactFailureHook(m.ArtifactFailure)
+	renderArtifactHandler := handler.NewRenderArtifactHandler(diagramUC).WithArtifactFailureHook(m.ArtifactFailure)
 	oracleCreate := handler.NewOracleCreateHandler(oracleUC)
 	oracleSet := handler.NewOracleSetHandler(oracleUC)
 	oracleDelete := handler.NewOracleDeleteHandler(oracleUC)
@@ -185,8 +266,11 @@ func buildToolRegistrations(diagramUC *usecase.DiagramUseCase, oracleUC *usecase
 	oracleRename := handler.NewOracleRenameHandler(oracleUC)
 	oracleGet := handler.NewOracleGetHandler(oracleUC)
 	oracleSerialize := handler.NewOracleSerializeHandler(oracleUC)
+	oraclePin := handler.NewOraclePinHandler(oracleUC)
+	iconSearch := handler.NewIconSearchHandler(iconUC)
+	describe := handler.NewDescribeHandler(oracleUC)
 
-	return []toolRegistration{
+	tools := []toolRegistration{
 		{createHandler.GetTool(), createHandler.GetHandler()},
 		{exportHandler.GetTool(), exportHandler.GetHandler()},
 		{renderArtifactHandler.GetTool(), renderArtifactHandler.GetHandler()},
@@ -197,5 +281,16 @@ func buildToolRegistrations(diagramUC *usecase.DiagramUseCase, oracleUC *usecase
 		{oracleRename.GetTool(), oracleRename.GetHandler()},
 		{oracleGet.GetTool(), oracleGet.GetHandler()},
 		{oracleSerialize.GetTool(), oracleSerialize.GetHandler()},
+		{oraclePin.GetTool(), oraclePin.GetHandler()},
+		{iconSearch.GetTool(), iconSearch.GetHandler()},
+		{describe.GetTool(), describe.GetHandler()},
+	}
+
+	// File sync is only available with a watch directory.
+	if workspaceUC != nil {
+		commitToFile := handler.NewCommitToFileHandler(workspaceUC)
+		tools = append(tools, toolRegistration{commitToFile.GetTool(), commitToFile.GetHandler()})
 	}
+
+	return tools
 }
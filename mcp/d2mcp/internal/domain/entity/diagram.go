@@ -16,6 +16,23 @@ const (
 	FormatSVG ExportFormat = "svg"
 )
 
+// ExportOptions contains optional settings for diagram export.
+type ExportOptions struct {
+	// Accessible injects <title> and <desc> elements for the diagram, its shapes and edges.
+	Accessible bool
+	// Title is the accessible title of the diagram. Defaults to the diagram ID.
+	Title string
+	// StableLayout keeps shapes at their positions from the previous export;
+	// only new or moved shapes are placed by the layout engine.
+	StableLayout bool
+}
+
+// Position is the top-left corner of a shape in diagram coordinates.
+type Position struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
 // Theme represents a D2 diagram theme.
 type Theme struct {
 	ID   int
@@ -0,0 +1,17 @@
+package entity
+
+// IconScheme is the URL scheme used to reference icons from the embedded badge pack.
+const IconScheme = "pack://"
+
+// Icon represents an icon from the embedded offline badge pack.
+type Icon struct {
+	Pack     string   `json:"pack"`
+	Name     string   `json:"name"`
+	Title    string   `json:"title"`
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+// Ref returns the pack reference of the icon (e.g. pack://aws/lambda).
+func (i *Icon) Ref() string {
+	return IconScheme + i.Pack + "/" + i.Name
+}
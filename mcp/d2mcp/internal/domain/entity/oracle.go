@@ -23,6 +23,16 @@ const (
 	OracleRename OracleOperationType = "rename"
 )
 
+// SerializeMode defines how a diagram is converted back to D2 text
+type SerializeMode string
+
+const (
+	// SerializePreserve keeps the original text and patches only the edited nodes.
+	SerializePreserve SerializeMode = "preserve"
+	// SerializeFormat re-formats the whole diagram with d2fmt.
+	SerializeFormat SerializeMode = "format"
+)
+
 // OracleResult represents the result of an oracle operation
 type OracleResult struct {
 	Success  bool
@@ -0,0 +1,178 @@
+package entity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DisplayName returns the label of the object, or its ID if it has no label.
+func (o *GraphObject) DisplayName() string {
+	if o.Label != "" {
+		return o.Label
+	}
+	return o.ID
+}
+
+// Arrow returns the arrow symbol describing the direction of the edge.
+func (e *GraphEdge) Arrow() string {
+	src, _ := e.Attributes["srcArrow"].(bool)
+	dst, _ := e.Attributes["dstArrow"].(bool)
+	switch {
+	case src && dst:
+		return "↔"
+	case src:
+		return "←"
+	case dst:
+		return "→"
+	default:
+		return "—"
+	}
+}
+
+// Children returns the objects directly inside the given parent, sorted by ID.
+// An empty parent returns the top-level objects.
+func (g *DiagramGraph) Children(parent string) []*GraphObject {
+	var children []*GraphObject
+	for _, obj := range g.Objects {
+		if obj.Parent == parent {
+			children = append(children, obj)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].ID < children[j].ID })
+	return children
+}
+
+// SortedEdges returns all edges sorted by source, destination and ID.
+func (g *DiagramGraph) SortedEdges() []*GraphEdge {
+	edges := make([]*GraphEdge, 0, len(g.Edges))
+	for _, edge := range g.Edges {
+		edges = append(edges, edge)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		if edges[i].To != edges[j].To {
+			return edges[i].To < edges[j].To
+		}
+		return edges[i].Label < edges[j].Label
+	})
+	return edges
+}
+
+// Summary returns a one-line summary of the graph (e.g. "3 shapes (1 container), 2 connections").
+func (g *DiagramGraph) Summary() string {
+	containers := 0
+	for _, obj := range g.Objects {
+		if len(g.Children(obj.ID)) > 0 {
+			containers++
+		}
+	}
+
+	summary := plural(len(g.Objects), "shape", "shapes")
+	if containers > 0 {
+		summary += fmt.Sprintf(" (%s)", plural(containers, "container", "containers"))
+	}
+	return summary + ", " + plural(len(g.Edges), "connection", "connections")
+}
+
+// DescribeObject returns a short natural-language description of an object.
+func (g *DiagramGraph) DescribeObject(obj *GraphObject) string {
+	var parts []string
+
+	kind := obj.Shape
+	if kind == "" {
+		kind = "rectangle"
+	}
+	if children := g.Children(obj.ID); len(children) > 0 {
+		parts = append(parts, fmt.Sprintf("%s container with %s", kind, plural(len(children), "shape", "shapes")))
+	} else {
+		parts = append(parts, kind)
+	}
+
+	if parent, ok := g.Objects[obj.Parent]; ok {
+		parts = append(parts, "inside "+parent.DisplayName())
+	}
+
+	var outgoing, incoming []string
+	for _, edge := range g.SortedEdges() {
+		if edge.From == obj.ID {
+			outgoing = append(outgoing, g.objectName(edge.To))
+		}
+		if edge.To == obj.ID {
+			incoming = append(incoming, g.objectName(edge.From))
+		}
+	}
+	if len(outgoing) > 0 {
+		parts = append(parts, "connects to "+strings.Join(outgoing, ", "))
+	}
+	if len(incoming) > 0 {
+		parts = append(parts, "connected from "+strings.Join(incoming, ", "))
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// DescribeEdge returns a short natural-language description of an edge.
+func (g *DiagramGraph) DescribeEdge(edge *GraphEdge) string {
+	desc := fmt.Sprintf("%s %s %s", g.objectName(edge.From), edge.Arrow(), g.objectName(edge.To))
+	if edge.Label != "" {
+		desc += ": " + edge.Label
+	}
+	return desc
+}
+
+// Outline returns a Markdown outline of the graph listing containers, shapes and connections.
+func (g *DiagramGraph) Outline(title string) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "# %s\n\n", title)
+	fmt.Fprintf(&sb, "%s.\n", g.Summary())
+
+	if len(g.Objects) > 0 {
+		sb.WriteString("\n## Shapes\n\n")
+		g.writeObjects(&sb, "", 0)
+	}
+
+	if len(g.Edges) > 0 {
+		sb.WriteString("\n## Connections\n\n")
+		for _, edge := range g.SortedEdges() {
+			fmt.Fprintf(&sb, "- %s\n", g.DescribeEdge(edge))
+		}
+	}
+
+	return sb.String()
+}
+
+// writeObjects writes the objects below parent as a nested Markdown list.
+func (g *DiagramGraph) writeObjects(sb *strings.Builder, parent string, depth int) {
+	for _, obj := range g.Children(parent) {
+		indent := strings.Repeat("  ", depth)
+		details := "`" + obj.ID + "`"
+		if obj.Shape != "" && obj.Shape != "rectangle" {
+			details += ", " + obj.Shape
+		}
+		if len(g.Children(obj.ID)) > 0 {
+			details += ", container"
+		}
+		fmt.Fprintf(sb, "%s- **%s** (%s)\n", indent, obj.DisplayName(), details)
+		g.writeObjects(sb, obj.ID, depth+1)
+	}
+}
+
+// objectName returns the display name of the object with the given ID.
+func (g *DiagramGraph) objectName(id string) string {
+	if obj, ok := g.Objects[id]; ok {
+		return obj.DisplayName()
+	}
+	return id
+}
+
+// plural formats a count with the singular or plural noun.
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, pluralForm)
+}
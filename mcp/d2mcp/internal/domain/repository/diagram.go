@@ -16,5 +16,5 @@ type DiagramRepository interface {
 	Create(ctx context.Context, diagram *entity.Diagram) error
 
 	// Export exports the diagram to the specified format.
-	Export(ctx context.Context, diagramID string, format entity.ExportFormat) (io.Reader, error)
+	Export(ctx context.Context, diagramID string, format entity.ExportFormat, opts entity.ExportOptions) (io.Reader, error)
 }
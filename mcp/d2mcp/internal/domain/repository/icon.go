@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
+)
+
+// IconRepository defines the interface for the embedded offline badge pack.
+type IconRepository interface {
+	// Search returns icons matching the query, optionally restricted to a pack.
+	Search(ctx context.Context, query string, pack string, limit int) ([]*entity.Icon, error)
+
+	// Resolve returns the icon and its SVG data for a pack reference (e.g. pack://aws/lambda).
+	Resolve(ctx context.Context, ref string) (*entity.Icon, []byte, error)
+}
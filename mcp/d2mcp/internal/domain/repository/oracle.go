@@ -25,6 +25,12 @@ type OracleRepository interface {
 	// RenameElement renames a shape or connection
 	RenameElement(ctx context.Context, diagramID string, boardPath []string, key, newName string) (*entity.OracleResult, error)
 
+	// PinElement pins a shape at a position, or where it was last rendered if position is nil
+	PinElement(ctx context.Context, diagramID string, boardPath []string, key string, position *entity.Position) (*entity.OracleResult, error)
+
+	// UnpinElement removes a pinned position from a shape
+	UnpinElement(ctx context.Context, diagramID string, boardPath []string, key string) (*entity.OracleResult, error)
+
 	// GetObject retrieves object information
 	GetObject(ctx context.Context, diagramID string, boardPath []string, objectID string) (*entity.GraphObject, error)
 
@@ -37,6 +43,9 @@ type OracleRepository interface {
 	// LoadDiagram loads a diagram from D2 text
 	LoadDiagram(ctx context.Context, diagramID string, content string) error
 
+	// GetGraph retrieves the full graph structure of a diagram
+	GetGraph(ctx context.Context, diagramID string) (*entity.DiagramGraph, error)
+
 	// SerializeDiagram converts the current graph state back to D2 text
-	SerializeDiagram(ctx context.Context, diagramID string) (string, error)
+	SerializeDiagram(ctx context.Context, diagramID string, mode entity.SerializeMode) (string, error)
 }
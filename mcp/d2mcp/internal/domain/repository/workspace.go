@@ -0,0 +1,11 @@
+package repository
+
+import (
+	"context"
+)
+
+// WorkspaceRepository maps diagrams to .d2 files in a working directory.
+type WorkspaceRepository interface {
+	// Write stores D2 text in the file backing the diagram and returns its relative path.
+	Write(ctx context.Context, diagramID string, content string) (string, error)
+}
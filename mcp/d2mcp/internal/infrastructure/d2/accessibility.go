@@ -0,0 +1,81 @@
+package d2
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+
+	"oss.terrastruct.com/d2/lib/svg"
+
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
+)
+
+// injectAccessibility adds <title> and <desc> elements to the rendered SVG:
+// one pair for the diagram as a whole and one per shape and edge group.
+func injectAccessibility(out []byte, graph *entity.DiagramGraph, title string) []byte {
+	if graph == nil {
+		return out
+	}
+
+	for id, obj := range graph.Objects {
+		out = injectIntoGroup(out, id, obj.DisplayName(), graph.DescribeObject(obj))
+	}
+	for id, edge := range graph.Edges {
+		out = injectIntoGroup(out, id, graph.DescribeEdge(edge), "Connection "+graph.DescribeEdge(edge))
+	}
+
+	return injectIntoRoot(out, title, graph.Summary())
+}
+
+// injectIntoRoot marks the outer <svg> element as an image and adds the diagram title and description.
+func injectIntoRoot(out []byte, title, desc string) []byte {
+	start := bytes.Index(out, []byte("<svg "))
+	if start < 0 {
+		return out
+	}
+	end := bytes.IndexByte(out[start:], '>')
+	if end < 0 {
+		return out
+	}
+	end += start + 1
+
+	var buf bytes.Buffer
+	buf.Write(out[:start])
+	buf.WriteString(`<svg role="img" aria-labelledby="d2-title d2-desc" `)
+	buf.Write(out[start+len("<svg ") : end])
+	fmt.Fprintf(&buf, `<title id="d2-title">%s</title><desc id="d2-desc">%s</desc>`, html.EscapeString(title), html.EscapeString(desc))
+	buf.Write(out[end:])
+	return buf.Bytes()
+}
+
+// injectIntoGroup adds a title and description to the group d2svg renders for the given element ID.
+// d2svg identifies each shape and connection group by the base64-encoded, escaped element ID.
+func injectIntoGroup(out []byte, id, title, desc string) []byte {
+	class := base64.URLEncoding.EncodeToString([]byte(svg.EscapeText(id)))
+	prefix := []byte(`<g class="` + class)
+
+	offset := 0
+	for {
+		idx := bytes.Index(out[offset:], prefix)
+		if idx < 0 {
+			return out
+		}
+		idx += offset
+		next := idx + len(prefix)
+		if next < len(out) && (out[next] == '"' || out[next] == ' ') {
+			end := bytes.IndexByte(out[next:], '>')
+			if end < 0 {
+				return out
+			}
+			end += next + 1
+
+			var buf bytes.Buffer
+			buf.Write(out[:end])
+			fmt.Fprintf(&buf, "<title>%s</title><desc>%s</desc>", html.EscapeString(title), html.EscapeString(desc))
+			buf.Write(out[end:])
+			return buf.Bytes()
+		}
+		offset = next
+	}
+}
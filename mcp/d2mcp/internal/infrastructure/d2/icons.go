@@ -0,0 +1,88 @@
+package d2
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"oss.terrastruct.com/d2/d2target"
+
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
+)
+
+// isIconRef reports whether the value references an icon from the embedded badge pack.
+func isIconRef(value string) bool {
+	return strings.HasPrefix(value, entity.IconScheme)
+}
+
+// validateIconRef checks that a pack reference set on an icon attribute exists.
+func (r *D2Repository) validateIconRef(ctx context.Context, key string, tag, value *string) error {
+	if value == nil || !isIconRef(*value) {
+		return nil
+	}
+	if !strings.HasSuffix(key, ".icon") && key != "icon" && (tag == nil || *tag != "icon") {
+		return nil
+	}
+	if r.icons == nil {
+		return fmt.Errorf("badge pack is not available for %s", *value)
+	}
+	if _, _, err := r.icons.Resolve(ctx, *value); err != nil {
+		return err
+	}
+	return nil
+}
+
+// inlineIcons replaces pack references on shapes and connections with data URIs,
+// recursing into layers, scenarios and steps.
+func (r *D2Repository) inlineIcons(ctx context.Context, diagram *d2target.Diagram) error {
+	if diagram == nil {
+		return nil
+	}
+
+	for i := range diagram.Shapes {
+		icon, err := r.resolveIconURL(ctx, diagram.Shapes[i].Icon)
+		if err != nil {
+			return err
+		}
+		diagram.Shapes[i].Icon = icon
+	}
+	for i := range diagram.Connections {
+		icon, err := r.resolveIconURL(ctx, diagram.Connections[i].Icon)
+		if err != nil {
+			return err
+		}
+		diagram.Connections[i].Icon = icon
+	}
+
+	for _, boards := range [][]*d2target.Diagram{diagram.Layers, diagram.Scenarios, diagram.Steps} {
+		for _, board := range boards {
+			if err := r.inlineIcons(ctx, board); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveIconURL converts a pack reference into a data URI. Other URLs are returned unchanged.
+func (r *D2Repository) resolveIconURL(ctx context.Context, icon *url.URL) (*url.URL, error) {
+	if icon == nil || !isIconRef(icon.String()) {
+		return icon, nil
+	}
+	if r.icons == nil {
+		return nil, fmt.Errorf("badge pack is not available for %s", icon.String())
+	}
+
+	_, data, err := r.icons.Resolve(ctx, icon.String())
+	if err != nil {
+		return nil, err
+	}
+
+	dataURI, err := url.Parse("data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build data URI for %s: %w", icon.String(), err)
+	}
+	return dataURI, nil
+}
@@ -0,0 +1,414 @@
+package d2
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"oss.terrastruct.com/d2/d2graph"
+	"oss.terrastruct.com/d2/d2target"
+	"oss.terrastruct.com/d2/lib/geo"
+
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
+)
+
+// layoutMemory remembers the shape positions of a diagram between renders.
+type layoutMemory struct {
+	mu        sync.Mutex
+	positions map[string]entity.Position
+}
+
+// snapshot returns a copy of the remembered positions.
+func (m *layoutMemory) snapshot() map[string]entity.Position {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	positions := make(map[string]entity.Position, len(m.positions))
+	for id, pos := range m.positions {
+		positions[id] = pos
+	}
+	return positions
+}
+
+// position returns the remembered position of a shape.
+func (m *layoutMemory) position(id string) (entity.Position, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pos, ok := m.positions[id]
+	return pos, ok
+}
+
+// record replaces the remembered positions with those of a rendered diagram.
+func (m *layoutMemory) record(diagram *d2target.Diagram) {
+	positions := make(map[string]entity.Position, len(diagram.Shapes))
+	for _, shape := range diagram.Shapes {
+		positions[shape.ID] = entity.Position{X: shape.Pos.X, Y: shape.Pos.Y}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.positions = positions
+}
+
+// remap renames remembered positions using the ID deltas of an oracle edit.
+func (m *layoutMemory) remap(idDeltas map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	moved := make(map[string]entity.Position, len(idDeltas))
+	for oldID := range idDeltas {
+		if pos, ok := m.positions[oldID]; ok {
+			moved[oldID] = pos
+			delete(m.positions, oldID)
+		}
+	}
+	for oldID, pos := range moved {
+		m.positions[idDeltas[oldID]] = pos
+	}
+}
+
+// forget drops the remembered positions of the given shapes and their descendants,
+// so that they are placed by the layout engine again.
+func (m *layoutMemory) forget(ids ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id := range m.positions {
+		for _, forgotten := range ids {
+			if id == forgotten || strings.HasPrefix(id, forgotten+".") {
+				delete(m.positions, id)
+				break
+			}
+		}
+	}
+}
+
+// lockedLayout wraps a layout engine so that it honors the top and left
+// keywords, which the engines bundled with d2mcp ignore. Remembered positions
+// are fed in as top and left of the objects that have neither, so that unchanged
+// shapes keep their place. After the engine ran, locked objects are moved to
+// their positions and the others are moved clear of them.
+func lockedLayout(engine d2graph.LayoutGraph, remembered map[string]entity.Position) d2graph.LayoutGraph {
+	return func(ctx context.Context, g *d2graph.Graph) error {
+		// Nested diagrams such as grids are laid out as subgraphs whose IDs are
+		// relative to their container; only the top-level graph is locked.
+		if g.RootLevel != 0 {
+			return engine(ctx, g)
+		}
+		for _, obj := range g.Objects {
+			pos, ok := remembered[obj.AbsID()]
+			if !ok || obj.Top != nil || obj.Left != nil {
+				continue
+			}
+			obj.Top = &d2graph.Scalar{Value: strconv.Itoa(pos.Y)}
+			obj.Left = &d2graph.Scalar{Value: strconv.Itoa(pos.X)}
+		}
+		if err := engine(ctx, g); err != nil {
+			return err
+		}
+		applyLocks(g)
+		return nil
+	}
+}
+
+// lockGap is the space kept between a moved shape and the locked ones.
+const lockGap = 40
+
+// applyLocks moves the objects with top or left (with their descendants) to
+// their positions. The other top-level objects follow the average movement of
+// the locked ones, so that they keep their place relative to them, and are then
+// moved the shortest way out of any overlap. Connections whose endpoints were
+// moved apart are re-routed.
+func applyLocks(g *d2graph.Graph) {
+	objects := append([]*d2graph.Object(nil), g.Objects...)
+	// Move containers before their children so that explicit child positions win.
+	sort.SliceStable(objects, func(a, b int) bool {
+		return objects[a].Level() < objects[b].Level()
+	})
+
+	deltas := make(map[*d2graph.Object]geo.Point)
+	move := func(obj *d2graph.Object, dx, dy float64) {
+		if dx == 0 && dy == 0 {
+			return
+		}
+		obj.MoveWithDescendants(dx, dy)
+		for _, o := range append([]*d2graph.Object{obj}, descendants(obj)...) {
+			d := deltas[o]
+			deltas[o] = geo.Point{X: d.X + dx, Y: d.Y + dy}
+		}
+	}
+
+	locked := make(map[*d2graph.Object]bool)
+	for _, obj := range objects {
+		if (obj.Top == nil && obj.Left == nil) || obj.TopLeft == nil {
+			continue
+		}
+		x, y := obj.TopLeft.X, obj.TopLeft.Y
+		if obj.Left != nil {
+			if v, err := strconv.ParseFloat(obj.Left.Value, 64); err == nil {
+				x = v
+			}
+		}
+		if obj.Top != nil {
+			if v, err := strconv.ParseFloat(obj.Top.Value, 64); err == nil {
+				y = v
+			}
+		}
+		move(obj, x-obj.TopLeft.X, y-obj.TopLeft.Y)
+		for top := obj; top.Parent != nil; top = top.Parent {
+			if top.Parent == g.Root {
+				locked[top] = true
+			}
+		}
+	}
+	if len(locked) == 0 {
+		return
+	}
+
+	// Free shapes are placed in layout order, each clear of the ones placed before.
+	var placed []*geo.Box
+	var free []*d2graph.Object
+	var shift geo.Point
+	for _, obj := range g.Root.ChildrenArray {
+		if obj.TopLeft == nil {
+			continue
+		}
+		if locked[obj] {
+			placed = append(placed, treeBox(obj))
+			shift.X += deltas[obj].X / float64(len(locked))
+			shift.Y += deltas[obj].Y / float64(len(locked))
+		} else {
+			free = append(free, obj)
+		}
+	}
+	sort.SliceStable(free, func(a, b int) bool {
+		if free[a].TopLeft.Y != free[b].TopLeft.Y {
+			return free[a].TopLeft.Y < free[b].TopLeft.Y
+		}
+		return free[a].TopLeft.X < free[b].TopLeft.X
+	})
+	for _, obj := range free {
+		move(obj, math.Round(shift.X), math.Round(shift.Y))
+		box := treeBox(obj)
+		dx, dy := clearOf(box, placed)
+		move(obj, dx, dy)
+		placed = append(placed, geo.NewBox(geo.NewPoint(box.TopLeft.X+dx, box.TopLeft.Y+dy), box.Width, box.Height))
+	}
+
+	for _, e := range g.Edges {
+		src, dst := deltas[e.Src], deltas[e.Dst]
+		switch {
+		case src == dst:
+			// Both ends moved together; keep the route.
+			e.Move(src.X, src.Y)
+		case e.Src.TopLeft != nil && e.Dst.TopLeft != nil:
+			e.Route = straightRoute(objectBox(e.Src), objectBox(e.Dst))
+			e.IsCurve = false
+		}
+	}
+}
+
+// descendants returns all objects nested in obj.
+func descendants(obj *d2graph.Object) []*d2graph.Object {
+	var out []*d2graph.Object
+	for _, child := range obj.ChildrenArray {
+		out = append(out, child)
+		out = append(out, descendants(child)...)
+	}
+	return out
+}
+
+// objectBox returns the box of an object.
+func objectBox(obj *d2graph.Object) *geo.Box {
+	return geo.NewBox(geo.NewPoint(obj.TopLeft.X, obj.TopLeft.Y), obj.Width, obj.Height)
+}
+
+// treeBox returns the box around an object and its descendants.
+func treeBox(obj *d2graph.Object) *geo.Box {
+	minX, minY := obj.TopLeft.X, obj.TopLeft.Y
+	maxX, maxY := minX+obj.Width, minY+obj.Height
+	for _, d := range descendants(obj) {
+		if d.TopLeft == nil {
+			continue
+		}
+		minX, minY = math.Min(minX, d.TopLeft.X), math.Min(minY, d.TopLeft.Y)
+		maxX, maxY = math.Max(maxX, d.TopLeft.X+d.Width), math.Max(maxY, d.TopLeft.Y+d.Height)
+	}
+	return geo.NewBox(geo.NewPoint(minX, minY), maxX-minX, maxY-minY)
+}
+
+// overlaps reports whether two boxes come closer than lockGap.
+func overlaps(a, b *geo.Box) bool {
+	return a.TopLeft.X < b.TopLeft.X+b.Width+lockGap && b.TopLeft.X < a.TopLeft.X+a.Width+lockGap &&
+		a.TopLeft.Y < b.TopLeft.Y+b.Height+lockGap && b.TopLeft.Y < a.TopLeft.Y+a.Height+lockGap
+}
+
+// clearOf returns the shortest shift that moves box clear of all placed boxes.
+// It tries moving past each overlapped box in the four directions, and falls
+// back to below all of them.
+func clearOf(box *geo.Box, placed []*geo.Box) (float64, float64) {
+	fits := func(dx, dy float64) bool {
+		moved := geo.NewBox(geo.NewPoint(box.TopLeft.X+dx, box.TopLeft.Y+dy), box.Width, box.Height)
+		for _, p := range placed {
+			if overlaps(moved, p) {
+				return false
+			}
+		}
+		return true
+	}
+	if fits(0, 0) {
+		return 0, 0
+	}
+
+	type shift struct{ dx, dy float64 }
+	var candidates []shift
+	bottom := math.Inf(-1)
+	for _, p := range placed {
+		bottom = math.Max(bottom, p.TopLeft.Y+p.Height)
+		if !overlaps(box, p) {
+			continue
+		}
+		candidates = append(candidates,
+			shift{0, p.TopLeft.Y - lockGap - box.Height - box.TopLeft.Y},
+			shift{0, p.TopLeft.Y + p.Height + lockGap - box.TopLeft.Y},
+			shift{p.TopLeft.X - lockGap - box.Width - box.TopLeft.X, 0},
+			shift{p.TopLeft.X + p.Width + lockGap - box.TopLeft.X, 0},
+		)
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return math.Hypot(candidates[a].dx, candidates[a].dy) < math.Hypot(candidates[b].dx, candidates[b].dy)
+	})
+	for _, c := range candidates {
+		if fits(c.dx, c.dy) {
+			return c.dx, c.dy
+		}
+	}
+	return 0, bottom + lockGap - box.TopLeft.Y
+}
+
+// pinnedPositions returns the positions set with the top and left keywords, in
+// absolute diagram coordinates. lockedLayout applies them during layout; they
+// are applied again after it for shapes of nested diagrams, which it skips.
+func pinnedPositions(graph *d2graph.Graph) map[string]entity.Position {
+	pins := make(map[string]entity.Position)
+	if graph == nil {
+		return pins
+	}
+	for _, obj := range graph.Objects {
+		if obj.Top == nil && obj.Left == nil {
+			continue
+		}
+		var pos entity.Position
+		if obj.TopLeft != nil {
+			pos = entity.Position{X: int(math.Round(obj.TopLeft.X)), Y: int(math.Round(obj.TopLeft.Y))}
+		}
+		if obj.Left != nil {
+			pos.X, _ = strconv.Atoi(obj.Left.Value)
+		}
+		if obj.Top != nil {
+			pos.Y, _ = strconv.Atoi(obj.Top.Value)
+		}
+		pins[obj.AbsID()] = pos
+	}
+	return pins
+}
+
+// applyPositions moves rendered shapes (with their descendants) to the target
+// positions and re-routes the connections whose endpoints were moved apart. It
+// is the fallback for the shapes that lockedLayout could not place, such as
+// those inside grids.
+func applyPositions(diagram *d2target.Diagram, targets map[string]entity.Position) {
+	if len(targets) == 0 {
+		return
+	}
+
+	// Move containers before their children so that explicit child positions win.
+	order := make([]int, len(diagram.Shapes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return diagram.Shapes[order[a]].Level < diagram.Shapes[order[b]].Level
+	})
+
+	deltas := make(map[string]geo.Point)
+	for _, i := range order {
+		shape := &diagram.Shapes[i]
+		target, ok := targets[shape.ID]
+		if !ok {
+			continue
+		}
+		dx, dy := target.X-shape.Pos.X, target.Y-shape.Pos.Y
+		if dx == 0 && dy == 0 {
+			continue
+		}
+		for j := range diagram.Shapes {
+			s := &diagram.Shapes[j]
+			if s.ID == shape.ID || strings.HasPrefix(s.ID, shape.ID+".") {
+				s.Pos.X += dx
+				s.Pos.Y += dy
+				d := deltas[s.ID]
+				deltas[s.ID] = geo.Point{X: d.X + float64(dx), Y: d.Y + float64(dy)}
+			}
+		}
+	}
+	if len(deltas) == 0 {
+		return
+	}
+
+	shapes := make(map[string]*d2target.Shape, len(diagram.Shapes))
+	for i := range diagram.Shapes {
+		shapes[diagram.Shapes[i].ID] = &diagram.Shapes[i]
+	}
+
+	for i := range diagram.Connections {
+		conn := &diagram.Connections[i]
+		src, dst := deltas[conn.Src], deltas[conn.Dst]
+		switch {
+		case src == dst:
+			// Both ends moved together; keep the route.
+			for _, p := range conn.Route {
+				p.X += src.X
+				p.Y += src.Y
+			}
+		case shapes[conn.Src] != nil && shapes[conn.Dst] != nil:
+			conn.Route = straightRoute(shapeBox(shapes[conn.Src]), shapeBox(shapes[conn.Dst]))
+			conn.IsCurve = false
+		}
+	}
+}
+
+// straightRoute returns a straight route between the borders of two boxes.
+func straightRoute(src, dst *geo.Box) []*geo.Point {
+	from, to := src.Center(), dst.Center()
+	return []*geo.Point{
+		clipToBox(from, to, src),
+		clipToBox(to, from, dst),
+	}
+}
+
+// shapeBox returns the box of a rendered shape.
+func shapeBox(s *d2target.Shape) *geo.Box {
+	return geo.NewBox(geo.NewPoint(float64(s.Pos.X), float64(s.Pos.Y)), float64(s.Width), float64(s.Height))
+}
+
+// clipToBox returns the point where the line from center towards target leaves the box.
+func clipToBox(center, target *geo.Point, b *geo.Box) *geo.Point {
+	dx, dy := target.X-center.X, target.Y-center.Y
+	if dx == 0 && dy == 0 {
+		return geo.NewPoint(center.X, center.Y)
+	}
+	t := math.Inf(1)
+	if dx != 0 {
+		t = math.Min(t, b.Width/2/math.Abs(dx))
+	}
+	if dy != 0 {
+		t = math.Min(t, b.Height/2/math.Abs(dy))
+	}
+	t = math.Min(t, 1)
+	return geo.NewPoint(center.X+dx*t, center.Y+dy*t)
+}
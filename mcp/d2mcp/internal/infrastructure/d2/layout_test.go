@@ -0,0 +1,62 @@
+package d2
+
+import (
+	"context"
+	"testing"
+
+	"oss.terrastruct.com/d2/d2graph"
+	"oss.terrastruct.com/d2/d2layouts/d2dagrelayout"
+	"oss.terrastruct.com/d2/d2lib"
+	"oss.terrastruct.com/d2/d2target"
+	"oss.terrastruct.com/d2/lib/textmeasure"
+
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
+)
+
+// compileLocked lays out D2 text with the remembered positions locked.
+func compileLocked(t *testing.T, content string, remembered map[string]entity.Position) *d2target.Diagram {
+	t.Helper()
+	ruler, err := textmeasure.NewRuler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diagram *d2target.Diagram
+	err = withD2Logger(context.Background(), func(ctx context.Context) error {
+		diagram, _, err = d2lib.Compile(ctx, content, &d2lib.CompileOptions{
+			Ruler: ruler,
+			LayoutResolver: func(string) (d2graph.LayoutGraph, error) {
+				return lockedLayout(d2dagrelayout.DefaultLayout, remembered), nil
+			},
+		}, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	return diagram
+}
+
+func TestLockedLayout_NewShapesDoNotOverlap(t *testing.T) {
+	before := compileLocked(t, "a -> b\nb -> c\nc -> d", nil)
+	remembered := make(map[string]entity.Position)
+	for _, s := range before.Shapes {
+		remembered[s.ID] = entity.Position{X: s.Pos.X, Y: s.Pos.Y}
+	}
+
+	// New shapes inserted into the chain, where the locked shapes leave no room.
+	after := compileLocked(t, "a -> b\nb -> c\nc -> d\nb -> x\nx -> y\ny -> c\nn1 -> n2", remembered)
+
+	for _, s := range after.Shapes {
+		if want, ok := remembered[s.ID]; ok && (s.Pos.X != want.X || s.Pos.Y != want.Y) {
+			t.Errorf("shape %s at %v, want %v", s.ID, s.Pos, want)
+		}
+	}
+	for i, a := range after.Shapes {
+		for _, b := range after.Shapes[i+1:] {
+			if a.Pos.X < b.Pos.X+b.Width && b.Pos.X < a.Pos.X+a.Width &&
+				a.Pos.Y < b.Pos.Y+b.Height && b.Pos.Y < a.Pos.Y+a.Height {
+				t.Errorf("shapes %s %v and %s %v overlap", a.ID, a.Pos, b.ID, b.Pos)
+			}
+		}
+	}
+}
@@ -34,12 +34,10 @@ type D2OracleRepository struct {
 }
 
 // NewD2OracleRepository creates a new D2 repository with Oracle support
-func NewD2OracleRepository() repository.OracleRepository {
+func NewD2OracleRepository(opts ...Option) repository.OracleRepository {
 	return &D2OracleRepository{
-		D2Repository: &D2Repository{
-			diagrams: make(map[string]*diagramData),
-		},
-		sessions: make(map[string]*OracleSession),
+		D2Repository: newD2Repository(opts...),
+		sessions:     make(map[string]*OracleSession),
 	}
 }
 
@@ -56,16 +54,23 @@ func (r *D2OracleRepository) LoadDiagram(ctx context.Context, diagramID string,
 		return fmt.Errorf("failed to compile diagram: %w", err)
 	}
 
-	r.diagrams[diagramID] = &diagramData{
+	data := &diagramData{
 		content: content,
 		graph:   graph,
 	}
+	// Keep the remembered layout when a diagram is reloaded
+	if previous, exists := r.diagrams[diagramID]; exists {
+		data.layout.positions = previous.layout.snapshot()
+	}
+	r.diagrams[diagramID] = data
+	r.notifyDiagramCount()
 
 	return nil
 }
 
-// SerializeDiagram converts the current graph state back to D2 text
-func (r *D2OracleRepository) SerializeDiagram(ctx context.Context, diagramID string) (string, error) {
+// SerializeDiagram converts the current graph state back to D2 text.
+// In preserve mode the original text is returned with edits patched in.
+func (r *D2OracleRepository) SerializeDiagram(ctx context.Context, diagramID string, mode entity.SerializeMode) (string, error) {
 	r.mu.RLock()
 	data, exists := r.diagrams[diagramID]
 	r.mu.RUnlock()
@@ -74,6 +79,11 @@ func (r *D2OracleRepository) SerializeDiagram(ctx context.Context, diagramID str
 		return "", fmt.Errorf("diagram %s not found", diagramID)
 	}
 
+	// The stored content is kept in sync with every edit
+	if mode == entity.SerializePreserve {
+		return data.content, nil
+	}
+
 	// Check if there's an active session with a modified graph
 	r.sessionMu.RLock()
 	session, hasSession := r.sessions[diagramID]
@@ -122,15 +132,15 @@ func (r *D2OracleRepository) CreateElement(ctx context.Context, diagramID string
 	// Update stored graph
 	data.graph = newGraph
 
-	// Serialize back to D2 text
+	// Patch the original D2 text
 	if newGraph.AST != nil {
-		data.content = d2format.Format(newGraph.AST)
+		data.content = patchContent(data.content, newGraph.AST)
 	}
 
 	return &entity.OracleResult{
 		Success: true,
 		NewKey:  newKey,
-		Graph:   r.graphToEntity(newGraph),
+		Graph:   graphToEntity(newGraph),
 	}, nil
 }
 
@@ -144,6 +154,11 @@ func (r *D2OracleRepository) SetAttribute(ctx context.Context, diagramID string,
 		return nil, fmt.Errorf("diagram %s not found", diagramID)
 	}
 
+	// Reject references to icons that are not in the badge pack.
+	if err := r.validateIconRef(ctx, key, tag, value); err != nil {
+		return nil, err
+	}
+
 	session := r.getOrCreateSession(diagramID, data.graph)
 
 	// Use d2oracle to set attribute
@@ -157,14 +172,14 @@ func (r *D2OracleRepository) SetAttribute(ctx context.Context, diagramID string,
 	session.LastModified = time.Now()
 	data.graph = newGraph
 
-	// Serialize back to D2 text
+	// Patch the original D2 text
 	if newGraph.AST != nil {
-		data.content = d2format.Format(newGraph.AST)
+		data.content = patchContent(data.content, newGraph.AST)
 	}
 
 	return &entity.OracleResult{
 		Success: true,
-		Graph:   r.graphToEntity(newGraph),
+		Graph:   graphToEntity(newGraph),
 	}, nil
 }
 
@@ -179,6 +194,7 @@ func (r *D2OracleRepository) DeleteElement(ctx context.Context, diagramID string
 	}
 
 	session := r.getOrCreateSession(diagramID, data.graph)
+	source := data.content
 
 	// Check if this is a connection deletion (contains "->")
 	isConnection := strings.Contains(key, "->")
@@ -232,15 +248,20 @@ func (r *D2OracleRepository) DeleteElement(ctx context.Context, diagramID string
 	session.LastModified = time.Now()
 	data.graph = newGraph
 
-	// Serialize back to D2 text
+	// Patch the original D2 text
 	if newGraph.AST != nil {
-		data.content = d2format.Format(newGraph.AST)
+		data.content = patchContent(source, newGraph.AST)
+	}
+
+	// Children of a deleted container keep their positions under their new IDs
+	if len(boardPath) == 0 {
+		data.layout.remap(idDeltas)
 	}
 
 	return &entity.OracleResult{
 		Success:  true,
 		IDDeltas: idDeltas,
-		Graph:    r.graphToEntity(newGraph),
+		Graph:    graphToEntity(newGraph),
 	}, nil
 }
 
@@ -256,25 +277,37 @@ func (r *D2OracleRepository) MoveElement(ctx context.Context, diagramID string,
 
 	session := r.getOrCreateSession(diagramID, data.graph)
 
+	// Get ID deltas before the move; only the root board has a remembered layout
+	var idDeltas map[string]string
+	if len(boardPath) == 0 {
+		idDeltas, _ = d2oracle.MoveIDDeltas(session.Graph, key, newKey, includeDescendants)
+	}
+
 	// Use d2oracle to move element
 	newGraph, err := d2oracle.Move(session.Graph, boardPath, key, newKey, includeDescendants)
 	if err != nil {
 		return nil, fmt.Errorf("failed to move element: %w", err)
 	}
 
+	// The moved element is placed by the layout engine again
+	if len(boardPath) == 0 {
+		data.layout.remap(idDeltas)
+		data.layout.forget(newKey)
+	}
+
 	// Update session and stored graph
 	session.Graph = newGraph
 	session.LastModified = time.Now()
 	data.graph = newGraph
 
-	// Serialize back to D2 text
+	// Patch the original D2 text
 	if newGraph.AST != nil {
-		data.content = d2format.Format(newGraph.AST)
+		data.content = patchContent(data.content, newGraph.AST)
 	}
 
 	return &entity.OracleResult{
 		Success: true,
-		Graph:   r.graphToEntity(newGraph),
+		Graph:   graphToEntity(newGraph),
 	}, nil
 }
 
@@ -302,21 +335,26 @@ func (r *D2OracleRepository) RenameElement(ctx context.Context, diagramID string
 		return nil, fmt.Errorf("failed to rename element: %w", err)
 	}
 
+	// Renamed elements keep their positions
+	if len(boardPath) == 0 {
+		data.layout.remap(idDeltas)
+	}
+
 	// Update session and stored graph
 	session.Graph = newGraph
 	session.LastModified = time.Now()
 	data.graph = newGraph
 
-	// Serialize back to D2 text
+	// Patch the original D2 text
 	if newGraph.AST != nil {
-		data.content = d2format.Format(newGraph.AST)
+		data.content = patchContent(data.content, newGraph.AST)
 	}
 
 	return &entity.OracleResult{
 		Success:  true,
 		NewKey:   newRenamedKey,
 		IDDeltas: idDeltas,
-		Graph:    r.graphToEntity(newGraph),
+		Graph:    graphToEntity(newGraph),
 	}, nil
 }
 
@@ -336,7 +374,7 @@ func (r *D2OracleRepository) GetObject(ctx context.Context, diagramID string, bo
 		return nil, fmt.Errorf("object %s not found", objectID)
 	}
 
-	return r.objectToEntity(obj), nil
+	return objectToEntity(obj), nil
 }
 
 // GetEdge retrieves edge information
@@ -355,7 +393,7 @@ func (r *D2OracleRepository) GetEdge(ctx context.Context, diagramID string, boar
 		return nil, fmt.Errorf("edge %s not found", edgeID)
 	}
 
-	return r.edgeToEntity(edge), nil
+	return edgeToEntity(edge), nil
 }
 
 // GetChildren retrieves child element IDs
@@ -376,6 +414,22 @@ func (r *D2OracleRepository) GetChildren(ctx context.Context, diagramID string,
 	return childrenIDs, nil
 }
 
+// GetGraph retrieves the full graph structure of a diagram
+func (r *D2OracleRepository) GetGraph(ctx context.Context, diagramID string) (*entity.DiagramGraph, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	data, exists := r.diagrams[diagramID]
+	if !exists {
+		return nil, fmt.Errorf("diagram %s not found", diagramID)
+	}
+
+	graph := graphToEntity(data.graph)
+	graph.ID = diagramID
+	graph.Content = data.content
+	return graph, nil
+}
+
 // Helper methods
 
 func (r *D2OracleRepository) getOrCreateSession(diagramID string, graph *d2graph.Graph) *OracleSession {
@@ -448,15 +502,13 @@ func (r *D2OracleRepository) deleteConnectionWorkaround(ctx context.Context, dia
 	}
 
 	// Update the diagram data directly
-	r.diagrams[diagramID] = &diagramData{
-		content: newD2,
-		graph:   graph,
-	}
+	data.content = newD2
+	data.graph = graph
 
 	return nil
 }
 
-func (r *D2OracleRepository) graphToEntity(graph *d2graph.Graph) *entity.DiagramGraph {
+func graphToEntity(graph *d2graph.Graph) *entity.DiagramGraph {
 	if graph == nil {
 		return nil
 	}
@@ -466,29 +518,29 @@ func (r *D2OracleRepository) graphToEntity(graph *d2graph.Graph) *entity.Diagram
 		Edges:   make(map[string]*entity.GraphEdge),
 	}
 
-	// Convert objects
+	// Convert objects, keyed by absolute ID so nested objects do not collide
 	for _, obj := range graph.Objects {
-		diagramGraph.Objects[obj.ID] = r.objectToEntity(obj)
+		diagramGraph.Objects[obj.AbsID()] = objectToEntity(obj)
 	}
 
-	// Convert edges
+	// Convert edges, keyed by absolute edge ID so parallel edges are kept
 	for _, edge := range graph.Edges {
-		edgeEntity := r.edgeToEntity(edge)
+		edgeEntity := edgeToEntity(edge)
 		if edgeEntity != nil {
-			diagramGraph.Edges[edgeEntity.ID] = edgeEntity
+			diagramGraph.Edges[edge.AbsID()] = edgeEntity
 		}
 	}
 
 	return diagramGraph
 }
 
-func (r *D2OracleRepository) objectToEntity(obj *d2graph.Object) *entity.GraphObject {
+func objectToEntity(obj *d2graph.Object) *entity.GraphObject {
 	if obj == nil {
 		return nil
 	}
 
 	graphObj := &entity.GraphObject{
-		ID:         obj.ID,
+		ID:         obj.AbsID(),
 		Label:      obj.Label.Value,
 		Attributes: make(map[string]interface{}),
 	}
@@ -498,7 +550,7 @@ func (r *D2OracleRepository) objectToEntity(obj *d2graph.Object) *entity.GraphOb
 	}
 
 	if obj.Parent != nil {
-		graphObj.Parent = obj.Parent.ID
+		graphObj.Parent = obj.Parent.AbsID()
 	}
 
 	// Convert key attributes
@@ -514,11 +566,17 @@ func (r *D2OracleRepository) objectToEntity(obj *d2graph.Object) *entity.GraphOb
 	if obj.Attributes.Style.Stroke != nil && obj.Attributes.Style.Stroke.Value != "" {
 		graphObj.Attributes["stroke"] = obj.Attributes.Style.Stroke.Value
 	}
+	if obj.Attributes.Top != nil {
+		graphObj.Attributes["top"] = obj.Attributes.Top.Value
+	}
+	if obj.Attributes.Left != nil {
+		graphObj.Attributes["left"] = obj.Attributes.Left.Value
+	}
 
 	return graphObj
 }
 
-func (r *D2OracleRepository) edgeToEntity(edge *d2graph.Edge) *entity.GraphEdge {
+func edgeToEntity(edge *d2graph.Edge) *entity.GraphEdge {
 	if edge == nil {
 		return nil
 	}
@@ -526,7 +584,7 @@ func (r *D2OracleRepository) edgeToEntity(edge *d2graph.Edge) *entity.GraphEdge
 	// Generate ID from source and destination
 	edgeID := fmt.Sprintf("%d", edge.Index)
 	if edge.Src != nil && edge.Dst != nil {
-		edgeID = fmt.Sprintf("%s->%s", edge.Src.ID, edge.Dst.ID)
+		edgeID = fmt.Sprintf("%s->%s", edge.Src.AbsID(), edge.Dst.AbsID())
 	}
 
 	graphEdge := &entity.GraphEdge{
@@ -536,11 +594,11 @@ func (r *D2OracleRepository) edgeToEntity(edge *d2graph.Edge) *entity.GraphEdge
 	}
 
 	if edge.Src != nil {
-		graphEdge.From = edge.Src.ID
+		graphEdge.From = edge.Src.AbsID()
 	}
 
 	if edge.Dst != nil {
-		graphEdge.To = edge.Dst.ID
+		graphEdge.To = edge.Dst.AbsID()
 	}
 
 	// Convert key attributes
@@ -2,7 +2,13 @@ package d2
 
 import (
 	"context"
+	"io"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/infrastructure/icons"
 )
 
 func TestD2OracleRepository_LoadAndSerialize(t *testing.T) {
@@ -25,7 +31,7 @@ server -> database: connect`
 	}
 
 	// Test serializing back
-	serialized, err := repo.SerializeDiagram(ctx, diagramID)
+	serialized, err := repo.SerializeDiagram(ctx, diagramID, entity.SerializeFormat)
 	if err != nil {
 		t.Fatalf("SerializeDiagram() error = %v", err)
 	}
@@ -87,7 +93,7 @@ func TestD2OracleRepository_CreateElement(t *testing.T) {
 	}
 
 	// Verify the diagram has the created elements
-	serialized, err := repo.SerializeDiagram(ctx, diagramID)
+	serialized, err := repo.SerializeDiagram(ctx, diagramID, entity.SerializeFormat)
 	if err != nil {
 		t.Fatalf("SerializeDiagram() error = %v", err)
 	}
@@ -423,7 +429,7 @@ func TestD2OracleRepository_ComplexWorkflow(t *testing.T) {
 	}
 
 	// 6. Verify final structure
-	serialized, err := repo.SerializeDiagram(ctx, diagramID)
+	serialized, err := repo.SerializeDiagram(ctx, diagramID, entity.SerializeFormat)
 	if err != nil {
 		t.Fatalf("SerializeDiagram() error = %v", err)
 	}
@@ -452,3 +458,304 @@ func TestD2OracleRepository_ComplexWorkflow(t *testing.T) {
 func stringPtr(s string) *string {
 	return &s
 }
+
+func TestD2OracleRepository_PackIcons(t *testing.T) {
+	iconRepo, err := icons.NewRepository()
+	if err != nil {
+		t.Fatalf("icons.NewRepository() error = %v", err)
+	}
+	repo := NewD2OracleRepository(WithIcons(iconRepo))
+	ctx := context.Background()
+
+	if err := repo.LoadDiagram(ctx, "icons", "api -> db"); err != nil {
+		t.Fatalf("LoadDiagram() error = %v", err)
+	}
+
+	// Unknown icons are rejected.
+	if _, err := repo.SetAttribute(ctx, "icons", nil, "api.icon", nil, stringPtr("pack://aws/unknown")); err == nil {
+		t.Error("SetAttribute() should fail for unknown pack icon")
+	}
+
+	// Known icons are inlined as data URIs on export.
+	if _, err := repo.SetAttribute(ctx, "icons", nil, "api.icon", nil, stringPtr("pack://aws/lambda")); err != nil {
+		t.Fatalf("SetAttribute() error = %v", err)
+	}
+	reader, err := repo.Export(ctx, "icons", entity.FormatSVG, entity.ExportOptions{})
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	svg, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read SVG: %v", err)
+	}
+	if strings.Contains(string(svg), "pack://") {
+		t.Error("Export() left a pack:// reference in the SVG")
+	}
+	if !strings.Contains(string(svg), "data:image/svg+xml;base64,") {
+		t.Error("Export() did not inline the icon as a data URI")
+	}
+}
+
+func TestD2OracleRepository_AccessibleExport(t *testing.T) {
+	repo := NewD2OracleRepository()
+	ctx := context.Background()
+
+	content := "c: Cluster {a: Alpha}\nc.a -> b: calls"
+	if err := repo.LoadDiagram(ctx, "a11y", content); err != nil {
+		t.Fatalf("LoadDiagram() error = %v", err)
+	}
+
+	reader, err := repo.Export(ctx, "a11y", entity.FormatSVG, entity.ExportOptions{Accessible: true, Title: "Service map"})
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read SVG: %v", err)
+	}
+	svg := string(data)
+
+	for _, want := range []string{
+		`role="img"`,
+		`<title id="d2-title">Service map</title>`,
+		`<title>Cluster</title>`,
+		`<title>Alpha</title><desc>rectangle; inside Cluster; connects to b</desc>`,
+		`<title>Alpha → b: calls</title>`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("Export() SVG missing %q", want)
+		}
+	}
+}
+
+func TestD2OracleRepository_GetGraphOutline(t *testing.T) {
+	repo := NewD2OracleRepository()
+	ctx := context.Background()
+
+	content := "c: Cluster {a: Alpha}\nc.a -> b: calls\nb -> c.a"
+	if err := repo.LoadDiagram(ctx, "outline", content); err != nil {
+		t.Fatalf("LoadDiagram() error = %v", err)
+	}
+
+	graph, err := repo.GetGraph(ctx, "outline")
+	if err != nil {
+		t.Fatalf("GetGraph() error = %v", err)
+	}
+
+	if _, ok := graph.Objects["c.a"]; !ok {
+		t.Error("GetGraph() should key nested objects by absolute ID")
+	}
+	if len(graph.Edges) != 2 {
+		t.Errorf("GetGraph() returned %d edges, want 2", len(graph.Edges))
+	}
+
+	outline := graph.Outline("outline")
+	for _, want := range []string{
+		"3 shapes (1 container), 2 connections.",
+		"- **Cluster** (`c`, container)\n  - **Alpha** (`c.a`)",
+		"- Alpha → b: calls",
+		"- b → Alpha",
+	} {
+		if !strings.Contains(outline, want) {
+			t.Errorf("Outline() missing %q in:\n%s", want, outline)
+		}
+	}
+
+	if _, err := repo.GetGraph(ctx, "non-existent"); err == nil {
+		t.Error("GetGraph() should fail for non-existent diagram")
+	}
+}
+
+func TestD2OracleRepository_SerializePreserve(t *testing.T) {
+	content := `# Architecture overview
+server: Web Server {
+  # public entry point
+  shape:   rectangle
+  api
+}
+
+db: {shape: cylinder}   # primary store
+
+# traffic
+server -> db: queries
+`
+
+	tests := []struct {
+		name string
+		edit func(ctx context.Context, repo *D2OracleRepository) error
+		want string
+	}{
+		{
+			name: "create shape",
+			edit: func(ctx context.Context, repo *D2OracleRepository) error {
+				_, err := repo.CreateElement(ctx, "preserve", nil, "cache")
+				return err
+			},
+			want: strings.Replace(content, "server -> db: queries\n", "server -> db: queries\ncache\n", 1),
+		},
+		{
+			name: "create nested shape",
+			edit: func(ctx context.Context, repo *D2OracleRepository) error {
+				_, err := repo.CreateElement(ctx, "preserve", nil, "server.worker")
+				return err
+			},
+			want: strings.Replace(content, "  api\n", "  api\n  worker\n", 1),
+		},
+		{
+			name: "set attribute",
+			edit: func(ctx context.Context, repo *D2OracleRepository) error {
+				_, err := repo.SetAttribute(ctx, "preserve", nil, "(server -> db)[0]", nil, stringPtr("reads"))
+				return err
+			},
+			want: strings.Replace(content, "server -> db: queries", "server -> db: reads", 1),
+		},
+		{
+			name: "delete shape",
+			edit: func(ctx context.Context, repo *D2OracleRepository) error {
+				_, err := repo.DeleteElement(ctx, "preserve", nil, "server.api")
+				return err
+			},
+			want: strings.Replace(content, "  api\n", "", 1),
+		},
+		{
+			name: "set attribute in single-line map",
+			edit: func(ctx context.Context, repo *D2OracleRepository) error {
+				_, err := repo.SetAttribute(ctx, "preserve", nil, "db.shape", nil, stringPtr("queue"))
+				return err
+			},
+			want: strings.Replace(content, "db: {shape: cylinder}", "db: {shape: queue}", 1),
+		},
+		{
+			name: "rename shape",
+			edit: func(ctx context.Context, repo *D2OracleRepository) error {
+				_, err := repo.RenameElement(ctx, "preserve", nil, "db", "store")
+				return err
+			},
+			want: strings.NewReplacer("db: {", "store: {", "server -> db", "server -> store").Replace(content),
+		},
+		{
+			name: "rename container",
+			edit: func(ctx context.Context, repo *D2OracleRepository) error {
+				_, err := repo.RenameElement(ctx, "preserve", nil, "server", "web")
+				return err
+			},
+			want: strings.NewReplacer("server: Web", "web: Web", "server -> db", "web -> db").Replace(content),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewD2OracleRepository().(*D2OracleRepository)
+			ctx := context.Background()
+			if err := repo.LoadDiagram(ctx, "preserve", content); err != nil {
+				t.Fatalf("LoadDiagram() error = %v", err)
+			}
+			if err := tt.edit(ctx, repo); err != nil {
+				t.Fatalf("edit error = %v", err)
+			}
+
+			got, err := repo.SerializeDiagram(ctx, "preserve", entity.SerializePreserve)
+			if err != nil {
+				t.Fatalf("SerializeDiagram() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SerializeDiagram() =\n%s\nwant:\n%s", got, tt.want)
+			}
+
+			formatted, err := repo.SerializeDiagram(ctx, "preserve", entity.SerializeFormat)
+			if err != nil {
+				t.Fatalf("SerializeDiagram() error = %v", err)
+			}
+			if formatted == got {
+				t.Error("SerializeDiagram() format mode should re-format the diagram")
+			}
+		})
+	}
+}
+
+func TestD2OracleRepository_StableLayout(t *testing.T) {
+	repo := NewD2OracleRepository().(*D2OracleRepository)
+	ctx := context.Background()
+
+	if err := repo.LoadDiagram(ctx, "stable", "a -> b\nb -> c"); err != nil {
+		t.Fatalf("LoadDiagram() error = %v", err)
+	}
+	if _, err := repo.Export(ctx, "stable", entity.FormatSVG, entity.ExportOptions{}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	before := repo.diagrams["stable"].layout.snapshot()
+
+	// A new shape in front of the chain shifts the fresh layout.
+	if _, err := repo.CreateElement(ctx, "stable", nil, "z -> a"); err != nil {
+		t.Fatalf("CreateElement() error = %v", err)
+	}
+	if _, err := repo.RenameElement(ctx, "stable", nil, "c", "d"); err != nil {
+		t.Fatalf("RenameElement() error = %v", err)
+	}
+	if _, err := repo.Export(ctx, "stable", entity.FormatSVG, entity.ExportOptions{StableLayout: true}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	after := repo.diagrams["stable"].layout.snapshot()
+
+	for oldID, newID := range map[string]string{"a": "a", "b": "b", "c": "d"} {
+		if after[newID] != before[oldID] {
+			t.Errorf("shape %s moved from %v to %v", newID, before[oldID], after[newID])
+		}
+	}
+	// The new shape stays above a instead of overlapping it.
+	if z, ok := after["z"]; !ok || z.Y >= after["a"].Y {
+		t.Errorf("new shape z at %v should be placed above a at %v", z, after["a"])
+	}
+}
+
+func TestD2OracleRepository_PinElement(t *testing.T) {
+	repo := NewD2OracleRepository().(*D2OracleRepository)
+	ctx := context.Background()
+
+	if err := repo.LoadDiagram(ctx, "pin", "a -> b"); err != nil {
+		t.Fatalf("LoadDiagram() error = %v", err)
+	}
+
+	if _, err := repo.PinElement(ctx, "pin", nil, "a", nil); err == nil {
+		t.Error("PinElement() should fail without a position before the first export")
+	}
+
+	result, err := repo.PinElement(ctx, "pin", nil, "a", &entity.Position{X: 300, Y: 200})
+	if err != nil {
+		t.Fatalf("PinElement() error = %v", err)
+	}
+	if got := result.Graph.Objects["a"].Attributes["top"]; got != "200" {
+		t.Errorf("PinElement() top = %v, want 200", got)
+	}
+
+	if _, err := repo.Export(ctx, "pin", entity.FormatSVG, entity.ExportOptions{}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if pos, _ := repo.diagrams["pin"].layout.position("a"); pos != (entity.Position{X: 300, Y: 200}) {
+		t.Errorf("pinned shape rendered at %v, want {300 200}", pos)
+	}
+
+	// Pin b where it was rendered.
+	rendered, _ := repo.diagrams["pin"].layout.position("b")
+	result, err = repo.PinElement(ctx, "pin", nil, "b", nil)
+	if err != nil {
+		t.Fatalf("PinElement() error = %v", err)
+	}
+	if got := result.Graph.Objects["b"].Attributes["left"]; got != strconv.Itoa(rendered.X) {
+		t.Errorf("PinElement() left = %v, want %d", got, rendered.X)
+	}
+
+	if _, err := repo.UnpinElement(ctx, "pin", nil, "a"); err != nil {
+		t.Fatalf("UnpinElement() error = %v", err)
+	}
+	content, err := repo.SerializeDiagram(ctx, "pin", entity.SerializePreserve)
+	if err != nil {
+		t.Fatalf("SerializeDiagram() error = %v", err)
+	}
+	if strings.Contains(content, "a.top") || strings.Contains(content, "a.left") {
+		t.Errorf("UnpinElement() left position in source:\n%s", content)
+	}
+	if _, err := repo.UnpinElement(ctx, "pin", nil, "a"); err == nil {
+		t.Error("UnpinElement() should fail for a shape that is not pinned")
+	}
+}
@@ -0,0 +1,116 @@
+package d2
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"oss.terrastruct.com/d2/d2graph"
+	"oss.terrastruct.com/d2/d2oracle"
+
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
+)
+
+// PinElement pins a shape with the top and left keywords. Without a position,
+// the shape is pinned where it was placed by the last export.
+func (r *D2OracleRepository) PinElement(ctx context.Context, diagramID string, boardPath []string, key string, position *entity.Position) (*entity.OracleResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, exists := r.diagrams[diagramID]
+	if !exists {
+		return nil, fmt.Errorf("diagram %s not found", diagramID)
+	}
+
+	session := r.getOrCreateSession(diagramID, data.graph)
+
+	obj := d2oracle.GetObj(session.Graph, boardPath, key)
+	if obj == nil {
+		return nil, fmt.Errorf("object %s not found", key)
+	}
+
+	if position == nil {
+		pos, ok := data.layout.position(obj.AbsID())
+		if !ok || len(boardPath) > 0 {
+			return nil, fmt.Errorf("no recorded position for %s: export the diagram first or pass a position", key)
+		}
+		position = &pos
+	}
+
+	// Positions are non-negative in D2
+	top := strconv.Itoa(max(position.Y, 0))
+	left := strconv.Itoa(max(position.X, 0))
+
+	newGraph, err := d2oracle.Set(session.Graph, boardPath, key+".top", nil, &top)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pin element: %w", err)
+	}
+	newGraph, err = d2oracle.Set(newGraph, boardPath, key+".left", nil, &left)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pin element: %w", err)
+	}
+
+	r.commitGraph(data, session, newGraph)
+
+	return &entity.OracleResult{
+		Success: true,
+		Graph:   graphToEntity(newGraph),
+	}, nil
+}
+
+// UnpinElement removes the top and left keywords from a shape.
+func (r *D2OracleRepository) UnpinElement(ctx context.Context, diagramID string, boardPath []string, key string) (*entity.OracleResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, exists := r.diagrams[diagramID]
+	if !exists {
+		return nil, fmt.Errorf("diagram %s not found", diagramID)
+	}
+
+	session := r.getOrCreateSession(diagramID, data.graph)
+
+	obj := d2oracle.GetObj(session.Graph, boardPath, key)
+	if obj == nil {
+		return nil, fmt.Errorf("object %s not found", key)
+	}
+	if obj.Top == nil && obj.Left == nil {
+		return nil, fmt.Errorf("object %s is not pinned", key)
+	}
+
+	newGraph := session.Graph
+	for _, attr := range []*d2graph.Scalar{obj.Top, obj.Left} {
+		if attr == nil {
+			continue
+		}
+		field := "top"
+		if attr == obj.Left {
+			field = "left"
+		}
+		var err error
+		newGraph, err = d2oracle.Delete(newGraph, boardPath, key+"."+field)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unpin element: %w", err)
+		}
+	}
+
+	r.commitGraph(data, session, newGraph)
+
+	return &entity.OracleResult{
+		Success: true,
+		Graph:   graphToEntity(newGraph),
+	}, nil
+}
+
+// commitGraph stores the result of an oracle edit and patches the D2 text.
+// Note: The caller must hold the mutex lock.
+func (r *D2OracleRepository) commitGraph(data *diagramData, session *OracleSession, newGraph *d2graph.Graph) {
+	session.Graph = newGraph
+	session.LastModified = time.Now()
+	data.graph = newGraph
+
+	if newGraph.AST != nil {
+		data.content = patchContent(data.content, newGraph.AST)
+	}
+}
@@ -0,0 +1,362 @@
+package d2
+
+import (
+	"sort"
+	"strings"
+
+	"oss.terrastruct.com/d2/d2ast"
+	"oss.terrastruct.com/d2/d2format"
+	"oss.terrastruct.com/d2/d2parser"
+)
+
+// childIndent is the indentation added for nodes inside a newly opened map.
+const childIndent = "  "
+
+// textEdit replaces source[start:end] with text.
+type textEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+// patchContent rewrites source so that it parses to newAST, touching only the
+// nodes that differ. Comments and formatting of unchanged nodes are kept.
+// If the patch cannot be applied safely, the fully formatted AST is returned.
+func patchContent(source string, newAST *d2ast.Map) string {
+	if newAST == nil {
+		return source
+	}
+	formatted := d2format.Format(newAST)
+
+	oldAST, err := d2parser.Parse("", strings.NewReader(source), nil)
+	if err != nil {
+		return formatted
+	}
+	if d2format.Format(oldAST) == formatted {
+		return source
+	}
+
+	p := &patcher{source: source}
+	p.diffMap(oldAST, newAST, "", true)
+	patched := applyEdits(source, p.edits)
+
+	// Verify the patch; the formatter is the source of truth.
+	check, err := d2parser.Parse("", strings.NewReader(patched), nil)
+	if err != nil || d2format.Format(check) != formatted {
+		return formatted
+	}
+	return patched
+}
+
+// patcher collects text edits that turn an old AST into a new one.
+type patcher struct {
+	source string
+	edits  []textEdit
+}
+
+// diffMap matches the nodes of two maps and records edits for the differences.
+func (p *patcher) diffMap(oldMap, newMap *d2ast.Map, indent string, root bool) {
+	oldNodes := unboxNodes(oldMap)
+	newNodes := unboxNodes(newMap)
+
+	// Empty maps have no anchor to insert after.
+	if len(oldNodes) == 0 {
+		if len(newNodes) == 0 {
+			return
+		}
+		if root {
+			text := p.nodesText(newNodes, "")
+			if p.source != "" && !strings.HasSuffix(p.source, "\n") {
+				text = "\n" + text
+			}
+			p.edits = append(p.edits, textEdit{start: len(p.source), end: len(p.source), text: text})
+			return
+		}
+		r := oldMap.Range
+		text := "{\n" + p.nodesText(newNodes, indent+childIndent) + indent + "}"
+		p.edits = append(p.edits, textEdit{start: r.Start.Byte, end: r.End.Byte, text: text})
+		return
+	}
+
+	nodeIndent := p.lineIndent(oldNodes[0].GetRange().Start.Byte)
+	matches := matchNodes(oldNodes, newNodes)
+	matches = append(matches, [2]int{len(oldNodes), len(newNodes)})
+
+	prevOld, prevNew := -1, -1
+	for _, m := range matches {
+		p.diffGap(oldNodes, newNodes, prevOld, m[0], prevNew, m[1], nodeIndent)
+		prevOld, prevNew = m[0], m[1]
+	}
+}
+
+// diffGap handles the unmatched nodes between two matched anchors.
+func (p *patcher) diffGap(oldNodes, newNodes []d2ast.MapNode, prevOld, nextOld, prevNew, nextNew int, indent string) {
+	used := make(map[int]bool)
+	paired := make(map[int]bool)
+
+	// Pair keys that kept their key path first, then remaining keys in order
+	// so that renamed keys are rewritten in place.
+	for _, match := range []func(a, b d2ast.MapNode) bool{sameHead, bothKeys} {
+		for j := prevNew + 1; j < nextNew; j++ {
+			if paired[j] {
+				continue
+			}
+			for i := prevOld + 1; i < nextOld; i++ {
+				if !used[i] && match(oldNodes[i], newNodes[j]) {
+					used[i] = true
+					paired[j] = true
+					p.modifyNode(oldNodes[i], newNodes[j], indent)
+					break
+				}
+			}
+		}
+	}
+
+	var inserted []d2ast.MapNode
+	for j := prevNew + 1; j < nextNew; j++ {
+		if !paired[j] {
+			inserted = append(inserted, newNodes[j])
+		}
+	}
+
+	for i := prevOld + 1; i < nextOld; i++ {
+		if !used[i] {
+			p.deleteNode(oldNodes[i])
+		}
+	}
+
+	if len(inserted) == 0 {
+		return
+	}
+	text := p.nodesText(inserted, indent)
+	var pos int
+	if prevOld >= 0 {
+		end, newline := p.lineEnd(oldNodes[prevOld].GetRange().End.Byte)
+		pos = end
+		if !newline {
+			text = "\n" + strings.TrimSuffix(text, "\n")
+		}
+	} else {
+		pos = p.lineStart(oldNodes[0].GetRange().Start.Byte)
+	}
+	p.edits = append(p.edits, textEdit{start: pos, end: pos, text: text})
+}
+
+// modifyNode records the edit for a key that was changed in place.
+// Multi-line maps are diffed recursively so that nested comments survive.
+func (p *patcher) modifyNode(oldNode, newNode d2ast.MapNode, indent string) {
+	oldKey, _ := oldNode.(*d2ast.Key)
+	newKey, _ := newNode.(*d2ast.Key)
+	if oldKey != nil && newKey != nil && oldKey.Value.Map != nil && newKey.Value.Map != nil {
+		r := oldKey.Value.Map.Range
+		if r.Start.Line != r.End.Line {
+			if !sameHead(oldKey, newKey) {
+				// Rewrite only the key, keeping the original spacing before the brace.
+				start := oldKey.Range.Start.Byte
+				head := p.source[start:r.Start.Byte]
+				spacing := head[len(strings.TrimRight(head, " \t")):]
+				p.edits = append(p.edits, textEdit{
+					start: start,
+					end:   r.Start.Byte,
+					text:  mapKeyPrefix(newKey) + spacing,
+				})
+			}
+			p.diffMap(oldKey.Value.Map, newKey.Value.Map, indent, false)
+			return
+		}
+	}
+
+	r := oldNode.GetRange()
+	p.edits = append(p.edits, textEdit{
+		start: r.Start.Byte,
+		end:   r.End.Byte,
+		text:  indentText(d2format.Format(newNode), indent, false),
+	})
+}
+
+// deleteNode removes a node, including its line if nothing else is on it.
+func (p *patcher) deleteNode(node d2ast.MapNode) {
+	r := node.GetRange()
+	start, end := r.Start.Byte, r.End.Byte
+
+	lineStart := p.lineStart(start)
+	lineEnd, _ := p.lineEnd(end)
+	before := p.source[lineStart:start]
+	after := strings.TrimSpace(p.source[end:lineEnd])
+	if strings.TrimSpace(before) == "" && (after == "" || after == ";") {
+		p.edits = append(p.edits, textEdit{start: lineStart, end: lineEnd})
+		return
+	}
+
+	// Other nodes share the line; drop the node and its separator.
+	for end < len(p.source) && (p.source[end] == ' ' || p.source[end] == '\t') {
+		end++
+	}
+	if end < len(p.source) && p.source[end] == ';' {
+		end++
+		for end < len(p.source) && (p.source[end] == ' ' || p.source[end] == '\t') {
+			end++
+		}
+	}
+	p.edits = append(p.edits, textEdit{start: start, end: end})
+}
+
+// nodesText formats nodes as indented lines, each terminated by a newline.
+func (p *patcher) nodesText(nodes []d2ast.MapNode, indent string) string {
+	var sb strings.Builder
+	for _, n := range nodes {
+		sb.WriteString(indentText(d2format.Format(n), indent, true))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// lineStart returns the offset of the first byte of the line containing pos.
+func (p *patcher) lineStart(pos int) int {
+	return strings.LastIndexByte(p.source[:pos], '\n') + 1
+}
+
+// lineEnd returns the offset just past the newline ending the line containing pos,
+// and whether such a newline exists.
+func (p *patcher) lineEnd(pos int) (int, bool) {
+	i := strings.IndexByte(p.source[pos:], '\n')
+	if i < 0 {
+		return len(p.source), false
+	}
+	return pos + i + 1, true
+}
+
+// lineIndent returns the leading whitespace of the line containing pos.
+func (p *patcher) lineIndent(pos int) string {
+	start := p.lineStart(pos)
+	line := p.source[start:pos]
+	if strings.TrimSpace(line) != "" {
+		return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	}
+	return line
+}
+
+// unboxNodes returns the nodes of a map.
+func unboxNodes(m *d2ast.Map) []d2ast.MapNode {
+	if m == nil {
+		return nil
+	}
+	nodes := make([]d2ast.MapNode, 0, len(m.Nodes))
+	for _, box := range m.Nodes {
+		if n := box.Unbox(); n != nil {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// matchNodes pairs identical nodes using the longest common subsequence of their formatted text.
+func matchNodes(oldNodes, newNodes []d2ast.MapNode) [][2]int {
+	oldText := make([]string, len(oldNodes))
+	for i, n := range oldNodes {
+		oldText[i] = d2format.Format(n)
+	}
+	newText := make([]string, len(newNodes))
+	for j, n := range newNodes {
+		newText[j] = d2format.Format(n)
+	}
+
+	lcs := make([][]int, len(oldText)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newText)+1)
+	}
+	for i := len(oldText) - 1; i >= 0; i-- {
+		for j := len(newText) - 1; j >= 0; j-- {
+			if oldText[i] == newText[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var matches [][2]int
+	for i, j := 0, 0; i < len(oldText) && j < len(newText); {
+		switch {
+		case oldText[i] == newText[j]:
+			matches = append(matches, [2]int{i, j})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return matches
+}
+
+// sameHead reports whether two nodes are keys with the same key path, edges and primary value.
+func sameHead(a, b d2ast.MapNode) bool {
+	ka, ok := a.(*d2ast.Key)
+	if !ok {
+		return false
+	}
+	kb, ok := b.(*d2ast.Key)
+	if !ok {
+		return false
+	}
+	return d2format.Format(keyHead(ka)) == d2format.Format(keyHead(kb))
+}
+
+// bothKeys reports whether both nodes are keys.
+func bothKeys(a, b d2ast.MapNode) bool {
+	_, okA := a.(*d2ast.Key)
+	_, okB := b.(*d2ast.Key)
+	return okA && okB
+}
+
+// mapKeyPrefix returns the formatted text of a key up to the opening brace of its map,
+// without trailing whitespace.
+func mapKeyPrefix(k *d2ast.Key) string {
+	head := keyHead(k)
+	head.Value = d2ast.MakeValueBox(&d2ast.Map{})
+	return strings.TrimRight(strings.TrimSuffix(d2format.Format(head), "{}"), " ")
+}
+
+// keyHead returns a copy of the key without its value.
+func keyHead(k *d2ast.Key) *d2ast.Key {
+	return &d2ast.Key{
+		Ampersand:    k.Ampersand,
+		NotAmpersand: k.NotAmpersand,
+		Key:          k.Key,
+		Edges:        k.Edges,
+		EdgeIndex:    k.EdgeIndex,
+		EdgeKey:      k.EdgeKey,
+		Primary:      k.Primary,
+	}
+}
+
+// indentText prefixes lines of text with indent. The first line is only
+// indented when first is set, for text that starts a new line.
+func indentText(text, indent string, first bool) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" || (i == 0 && !first) {
+			continue
+		}
+		lines[i] = indent + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// applyEdits applies non-overlapping edits to source.
+func applyEdits(source string, edits []textEdit) string {
+	// Apply from the end so earlier offsets stay valid; at the same offset,
+	// replacements go first so that an insertion lands in front of the rest.
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start > edits[j].start
+		}
+		return edits[i].end > edits[j].end
+	})
+	for _, e := range edits {
+		source = source[:e.start] + e.text + source[e.end:]
+	}
+	return source
+}
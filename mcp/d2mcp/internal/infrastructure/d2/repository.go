@@ -6,9 +6,9 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"oss.terrastruct.com/d2/d2compiler"
 	"oss.terrastruct.com/d2/d2graph"
@@ -20,57 +20,111 @@ import (
 
 	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
 	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/repository"
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/infrastructure/logging"
 )
 
+// defaultLayoutEngine is the layout engine every diagram is laid out with.
+const defaultLayoutEngine = "dagre"
+
+// Observer receives instrumentation events from the repository.
+type Observer interface {
+	// ObserveRender records the duration of a render with the given layout engine.
+	ObserveRender(engine string, duration time.Duration, err error)
+	// SetDiagramCount records the number of diagrams currently held in memory.
+	SetDiagramCount(n int)
+}
+
+// Option configures a D2 repository.
+type Option func(*D2Repository)
+
+// WithObserver sets an observer that is notified about renders and diagram changes.
+func WithObserver(observer Observer) Option {
+	return func(r *D2Repository) {
+		r.observer = observer
+	}
+}
+
+// WithIcons sets the badge pack used to resolve pack:// icon references.
+func WithIcons(icons repository.IconRepository) Option {
+	return func(r *D2Repository) {
+		r.icons = icons
+	}
+}
+
 // D2Repository implements the DiagramRepository interface using D2.
 type D2Repository struct {
 	diagrams map[string]*diagramData
 	mu       sync.RWMutex
+	observer Observer
+	icons    repository.IconRepository
 }
 
 // diagramData holds the D2 graph and related data.
 type diagramData struct {
 	content string
 	graph   *d2graph.Graph
+	layout  layoutMemory
 }
 
 // NewD2Repository creates a new D2 repository instance.
-func NewD2Repository() repository.DiagramRepository {
-	return &D2Repository{
+func NewD2Repository(opts ...Option) repository.DiagramRepository {
+	return newD2Repository(opts...)
+}
+
+// newD2Repository creates the concrete repository and applies the options.
+func newD2Repository(opts ...Option) *D2Repository {
+	r := &D2Repository{
 		diagrams: make(map[string]*diagramData),
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
-// withSilentD2 executes a function with D2 logging disabled.
-func withSilentD2(ctx context.Context, fn func(context.Context) error) error {
-	// Create a null logger to prevent D2 from logging.
-	nullLogger := slog.New(slog.NewTextHandler(io.Discard, nil))
-	ctx = log.With(ctx, nullLogger)
-
-	// Temporarily redirect stderr to prevent any output.
-	oldStderr := os.Stderr
-	os.Stderr, _ = os.Open(os.DevNull)
-	defer func() {
-		os.Stderr = oldStderr
-	}()
+// notifyDiagramCount reports the current number of diagrams to the observer.
+// Note: The caller must hold the mutex lock.
+func (r *D2Repository) notifyDiagramCount() {
+	if r.observer != nil {
+		r.observer.SetDiagramCount(len(r.diagrams))
+	}
+}
 
-	return fn(ctx)
+// withD2Logger executes a function with D2's own logs routed to the request-scoped logger.
+func withD2Logger(ctx context.Context, fn func(context.Context) error) error {
+	logger := logging.FromContext(ctx).With(slog.String("component", "d2"))
+	return fn(log.With(ctx, logger))
 }
 
 // Render renders D2 text into a diagram with specified format.
 // returns an io.Reader for the rendered output.
 func (r *D2Repository) Render(ctx context.Context, content string, format entity.ExportFormat, theme *entity.Theme) (io.Reader, error) {
+	return r.render(ctx, content, format, theme, entity.ExportOptions{}, nil)
+}
+
+// render compiles, lays out and renders D2 text, applying the export options.
+// If layout is set, the resulting positions are recorded in it, and reused
+// for unchanged shapes when a stable layout is requested.
+func (r *D2Repository) render(ctx context.Context, content string, format entity.ExportFormat, theme *entity.Theme, opts entity.ExportOptions, layout *layoutMemory) (io.Reader, error) {
 	var result io.Reader
-	err := withSilentD2(ctx, func(ctx context.Context) error {
+	start := time.Now()
+	err := withD2Logger(ctx, func(ctx context.Context) error {
 		// Create ruler for text measurement.
 		ruler, err := textmeasure.NewRuler()
 		if err != nil {
 			return fmt.Errorf("failed to create ruler: %w", err)
 		}
 
-		// Create layout resolver.
-		layoutResolver := func(engine string) (d2graph.LayoutGraph, error) {
-			return d2dagrelayout.DefaultLayout, nil
+		// Remembered positions of a stable layout are fed into the layout.
+		var remembered map[string]entity.Position
+		if layout != nil && opts.StableLayout {
+			remembered = layout.snapshot()
+		}
+
+		// Create layout resolver. Only dagre is bundled, so it lays out every
+		// diagram whatever engine the D2 source requests.
+		layoutResolver := func(string) (d2graph.LayoutGraph, error) {
+			return lockedLayout(d2dagrelayout.DefaultLayout, remembered), nil
 		}
 
 		// Create compile options.
@@ -92,11 +146,29 @@ func (r *D2Repository) Render(ctx context.Context, content string, format entity
 		}
 
 		// Compile the D2 script.
-		diagram, _, err := d2lib.Compile(ctx, content, compileOpts, renderOpts)
+		diagram, graph, err := d2lib.Compile(ctx, content, compileOpts, renderOpts)
 		if err != nil {
 			return fmt.Errorf("failed to compile D2 script: %w", err)
 		}
 
+		// Shapes the layout could not lock are moved afterwards; pins take precedence.
+		targets := make(map[string]entity.Position, len(remembered))
+		for id, pos := range remembered {
+			targets[id] = pos
+		}
+		for id, pos := range pinnedPositions(graph) {
+			targets[id] = pos
+		}
+		applyPositions(diagram, targets)
+		if layout != nil {
+			layout.record(diagram)
+		}
+
+		// Inline icons from the embedded badge pack.
+		if err := r.inlineIcons(ctx, diagram); err != nil {
+			return fmt.Errorf("failed to resolve icons: %w", err)
+		}
+
 		// Render based on format.
 		switch format {
 		case entity.FormatSVG, "":
@@ -104,6 +176,9 @@ func (r *D2Repository) Render(ctx context.Context, content string, format entity
 			if err != nil {
 				return fmt.Errorf("failed to render SVG: %w", err)
 			}
+			if opts.Accessible {
+				svg = injectAccessibility(svg, graphToEntity(graph), opts.Title)
+			}
 			result = bytes.NewReader(svg)
 			return nil
 
@@ -112,6 +187,10 @@ func (r *D2Repository) Render(ctx context.Context, content string, format entity
 		}
 	})
 
+	if r.observer != nil {
+		r.observer.ObserveRender(defaultLayoutEngine, time.Since(start), err)
+	}
+
 	return result, err
 }
 
@@ -132,13 +211,14 @@ func (r *D2Repository) Create(ctx context.Context, diagram *entity.Diagram) erro
 		content: diagram.Content,
 		graph:   graph,
 	}
+	r.notifyDiagramCount()
 
 	return nil
 }
 
 // Export exports the diagram to the specified format.
 func (r *D2Repository) Export(ctx context.Context,
-	diagramID string, format entity.ExportFormat) (io.Reader, error) {
+	diagramID string, format entity.ExportFormat, opts entity.ExportOptions) (io.Reader, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -151,6 +231,11 @@ func (r *D2Repository) Export(ctx context.Context,
 	// TODO: Implement proper graph serialization to D2 text
 	currentContent := data.content
 
+	// Default the accessible title to the diagram ID
+	if opts.Title == "" {
+		opts.Title = diagramID
+	}
+
 	// Render the current state
-	return r.Render(ctx, currentContent, format, nil)
+	return r.render(ctx, currentContent, format, nil, opts, &data.layout)
 }
@@ -3,7 +3,10 @@ package d2
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
 )
@@ -61,7 +64,7 @@ func TestD2Repository_CreateAndExport(t *testing.T) {
 	}
 
 	// Test Export
-	reader, err := repo.Export(ctx, diagram.ID, entity.FormatSVG)
+	reader, err := repo.Export(ctx, diagram.ID, entity.FormatSVG, entity.ExportOptions{})
 	if err != nil {
 		t.Fatalf("Export() error = %v", err)
 	}
@@ -70,7 +73,7 @@ func TestD2Repository_CreateAndExport(t *testing.T) {
 	}
 
 	// Test Export non-existent diagram
-	_, err = repo.Export(ctx, "non-existent", entity.FormatSVG)
+	_, err = repo.Export(ctx, "non-existent", entity.FormatSVG, entity.ExportOptions{})
 	if err == nil {
 		t.Error("Export() should fail for non-existent diagram")
 	}
@@ -99,7 +102,7 @@ func TestD2Repository_ConcurrentAccess(t *testing.T) {
 	// Concurrent reads/exports
 	for i := 0; i < 5; i++ {
 		go func(i int) {
-			_, err := repo.Export(ctx, fmt.Sprintf("concurrent-test-%d", i), entity.FormatSVG)
+			_, err := repo.Export(ctx, fmt.Sprintf("concurrent-test-%d", i), entity.FormatSVG, entity.ExportOptions{})
 			if err != nil {
 				errors <- err
 			}
@@ -131,3 +134,54 @@ func TestD2Repository_ConcurrentAccess(t *testing.T) {
 		// No errors
 	}
 }
+
+// recordingObserver records instrumentation events for assertions.
+type recordingObserver struct {
+	mu           sync.Mutex
+	engines      []string
+	diagramCount int
+}
+
+func (o *recordingObserver) ObserveRender(engine string, duration time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.engines = append(o.engines, engine)
+}
+
+func (o *recordingObserver) SetDiagramCount(n int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.diagramCount = n
+}
+
+func TestD2Repository_Observer(t *testing.T) {
+	observer := &recordingObserver{}
+	repo := NewD2Repository(WithObserver(observer))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		diagram := &entity.Diagram{
+			ID:      fmt.Sprintf("observed-%d", i),
+			Content: "a -> b",
+		}
+		if err := repo.Create(ctx, diagram); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	if observer.diagramCount != 2 {
+		t.Errorf("SetDiagramCount() = %d, want 2", observer.diagramCount)
+	}
+
+	if _, err := repo.Render(ctx, "a -> b", entity.FormatSVG, nil); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	// A requested engine that is not bundled is still laid out with dagre.
+	elk := "vars: {\n  d2-config: {\n    layout-engine: elk\n  }\n}\na -> b"
+	if _, err := repo.Render(ctx, elk, entity.FormatSVG, nil); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	want := []string{defaultLayoutEngine, defaultLayoutEngine}
+	if !slices.Equal(observer.engines, want) {
+		t.Errorf("ObserveRender() engines = %v, want %v", observer.engines, want)
+	}
+}
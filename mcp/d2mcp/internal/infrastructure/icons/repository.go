@@ -0,0 +1,151 @@
+package icons
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/repository"
+)
+
+// The badges are simple colored squares labeled with the abbreviation of a
+// service, drawn for this project. They are not the vendors' official icons,
+// whose licenses do not allow bundling them here.
+//
+//go:embed badges
+var packFS embed.FS
+
+// Repository implements the IconRepository interface using the embedded badge pack.
+type Repository struct {
+	icons []*entity.Icon
+	byRef map[string]*entity.Icon
+	files fs.FS
+}
+
+// NewRepository loads the embedded badge pack manifest.
+func NewRepository() (*Repository, error) {
+	files, err := fs.Sub(packFS, "badges")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open badge pack: %w", err)
+	}
+
+	data, err := fs.ReadFile(files, "manifest.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read icon manifest: %w", err)
+	}
+
+	var icons []*entity.Icon
+	if err := json.Unmarshal(data, &icons); err != nil {
+		return nil, fmt.Errorf("failed to parse icon manifest: %w", err)
+	}
+
+	byRef := make(map[string]*entity.Icon, len(icons))
+	for _, icon := range icons {
+		byRef[icon.Ref()] = icon
+	}
+
+	return &Repository{
+		icons: icons,
+		byRef: byRef,
+		files: files,
+	}, nil
+}
+
+// Ensure Repository implements the interface.
+var _ repository.IconRepository = (*Repository)(nil)
+
+// Search returns icons matching the query, ordered by relevance.
+func (r *Repository) Search(ctx context.Context, query string, pack string, limit int) ([]*entity.Icon, error) {
+	terms := strings.Fields(strings.ToLower(query))
+
+	type scored struct {
+		icon  *entity.Icon
+		score int
+	}
+	var matches []scored
+	for _, icon := range r.icons {
+		if pack != "" && icon.Pack != pack {
+			continue
+		}
+		score := scoreIcon(icon, terms)
+		if score > 0 {
+			matches = append(matches, scored{icon: icon, score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	result := make([]*entity.Icon, len(matches))
+	for i, m := range matches {
+		result[i] = m.icon
+	}
+	return result, nil
+}
+
+// scoreIcon ranks an icon against the search terms. Every term must match;
+// exact name matches rank above title and keyword matches.
+func scoreIcon(icon *entity.Icon, terms []string) int {
+	if len(terms) == 0 {
+		return 1
+	}
+
+	total := 0
+	for _, term := range terms {
+		best := 0
+		switch {
+		case icon.Name == term:
+			best = 10
+		case strings.HasPrefix(icon.Name, term):
+			best = 6
+		case icon.Pack == term:
+			best = 4
+		case strings.Contains(strings.ToLower(icon.Title), term):
+			best = 4
+		}
+		for _, kw := range icon.Keywords {
+			switch {
+			case kw == term && best < 5:
+				best = 5
+			case strings.Contains(kw, term) && best < 2:
+				best = 2
+			}
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total
+}
+
+// Resolve returns the icon and its SVG data for a pack reference.
+func (r *Repository) Resolve(ctx context.Context, ref string) (*entity.Icon, []byte, error) {
+	icon, ok := r.byRef[ref]
+	if !ok {
+		return nil, nil, fmt.Errorf("icon %s not found", ref)
+	}
+
+	data, err := fs.ReadFile(r.files, path.Join(icon.Pack, icon.Name+".svg"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read icon %s: %w", ref, err)
+	}
+	return icon, data, nil
+}
+
+// Handler returns an HTTP handler serving the icon SVGs (e.g. /aws/lambda.svg).
+func (r *Repository) Handler() http.Handler {
+	return http.FileServer(http.FS(r.files))
+}
@@ -0,0 +1,78 @@
+package icons
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRepository_Search(t *testing.T) {
+	repo, err := NewRepository()
+	if err != nil {
+		t.Fatalf("NewRepository() error = %v", err)
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		query    string
+		pack     string
+		wantRef  string
+		wantNone bool
+	}{
+		{name: "exact name", query: "lambda", wantRef: "pack://aws/lambda"},
+		{name: "keyword within pack", query: "queue", pack: "gcp", wantRef: "pack://gcp/pubsub"},
+		{name: "multiple terms", query: "kubernetes ingress", wantRef: "pack://k8s/ingress"},
+		{name: "no match", query: "teleporter", wantNone: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			icons, err := repo.Search(ctx, tt.query, tt.pack, 5)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if tt.wantNone {
+				if len(icons) != 0 {
+					t.Errorf("Search() returned %d icons, want none", len(icons))
+				}
+				return
+			}
+			if len(icons) == 0 {
+				t.Fatal("Search() returned no icons")
+			}
+			if icons[0].Ref() != tt.wantRef {
+				t.Errorf("Search() first result = %s, want %s", icons[0].Ref(), tt.wantRef)
+			}
+			for _, icon := range icons {
+				if tt.pack != "" && icon.Pack != tt.pack {
+					t.Errorf("Search() returned icon from pack %s, want %s", icon.Pack, tt.pack)
+				}
+			}
+		})
+	}
+}
+
+func TestRepository_Resolve(t *testing.T) {
+	repo, err := NewRepository()
+	if err != nil {
+		t.Fatalf("NewRepository() error = %v", err)
+	}
+	ctx := context.Background()
+
+	// Every manifest entry must have an SVG file.
+	for _, icon := range repo.icons {
+		_, data, err := repo.Resolve(ctx, icon.Ref())
+		if err != nil {
+			t.Errorf("Resolve(%s) error = %v", icon.Ref(), err)
+			continue
+		}
+		if !strings.Contains(string(data), "<svg") {
+			t.Errorf("Resolve(%s) did not return SVG data", icon.Ref())
+		}
+	}
+
+	if _, _, err := repo.Resolve(ctx, "pack://aws/unknown"); err == nil {
+		t.Error("Resolve() should fail for unknown icon")
+	}
+}
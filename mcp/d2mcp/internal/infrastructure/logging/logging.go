@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Config contains the logging configuration.
+type Config struct {
+	// File is the path of the log file. If empty, logs are written to stderr.
+	File string
+	// Level is the minimum log level: debug, info, warn or error.
+	Level string
+}
+
+// New creates a JSON logger for the given configuration.
+// The returned closer releases the log file, if one was opened.
+func New(cfg Config) (*slog.Logger, io.Closer, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var out io.WriteCloser = nopCloser{os.Stderr}
+	if cfg.File != "" {
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		out = f
+	}
+
+	logger := slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: level}))
+	return logger, out, nil
+}
+
+// ParseLevel converts a level name into a slog.Level.
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("invalid log level: %s. Must be 'debug', 'info', 'warn', or 'error'", level)
+	}
+}
+
+// nopCloser wraps a writer that must not be closed (e.g. stderr).
+type nopCloser struct {
+	io.Writer
+}
+
+// Close implements io.Closer.
+func (nopCloser) Close() error { return nil }
+
+type loggerKey struct{}
+
+// WithContext returns a copy of ctx carrying the given logger.
+func WithContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the request-scoped logger, or the default logger if none is set.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Middleware wraps a tool handler with a request-scoped logger carrying the tool name,
+// diagram ID and MCP session ID, and logs the outcome and duration of each call.
+func Middleware(logger *slog.Logger, toolName string, next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		attrs := []any{slog.String("tool", toolName)}
+		if diagramID := diagramIDFromRequest(request); diagramID != "" {
+			attrs = append(attrs, slog.String("diagram_id", diagramID))
+		}
+		if session := server.ClientSessionFromContext(ctx); session != nil {
+			attrs = append(attrs, slog.String("session_id", session.SessionID()))
+		}
+		reqLogger := logger.With(attrs...)
+		ctx = WithContext(ctx, reqLogger)
+
+		start := time.Now()
+		result, err := next(ctx, request)
+		duration := slog.Duration("duration", time.Since(start))
+
+		switch {
+		case err != nil:
+			reqLogger.ErrorContext(ctx, "tool call failed", duration, slog.Any("error", err))
+		case result != nil && result.IsError:
+			reqLogger.WarnContext(ctx, "tool call returned error", duration, slog.String("error", resultText(result)))
+		default:
+			reqLogger.InfoContext(ctx, "tool call completed", duration)
+		}
+
+		return result, err
+	}
+}
+
+// diagramIDFromRequest extracts the diagram ID from the arguments used by the d2 tools.
+func diagramIDFromRequest(request mcp.CallToolRequest) string {
+	for _, key := range []string{"diagram_id", "diagramId", "id"} {
+		if id := mcp.ParseString(request, key, ""); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// resultText returns the text content of a tool result.
+func resultText(result *mcp.CallToolResult) string {
+	var parts []string
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			parts = append(parts, text.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    slog.Level
+		wantErr bool
+	}{
+		{input: "debug", want: slog.LevelDebug},
+		{input: "", want: slog.LevelInfo},
+		{input: "WARN", want: slog.LevelWarn},
+		{input: "error", want: slog.LevelError},
+		{input: "verbose", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseLevel(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLevel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	var scoped *slog.Logger
+	handler := Middleware(logger, "d2_export", func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		scoped = FromContext(ctx)
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]any{"diagramId": "arch"}
+	if _, err := handler(context.Background(), request); err != nil {
+		t.Fatalf("Middleware() error = %v", err)
+	}
+
+	if scoped == nil || scoped == logger {
+		t.Error("Middleware() did not attach a request-scoped logger to the context")
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to decode log entry: %v", err)
+	}
+	if entry["tool"] != "d2_export" {
+		t.Errorf("log entry tool = %v, want d2_export", entry["tool"])
+	}
+	if entry["diagram_id"] != "arch" {
+		t.Errorf("log entry diagram_id = %v, want arch", entry["diagram_id"])
+	}
+	if _, ok := entry["duration"]; !ok {
+		t.Error("log entry is missing duration")
+	}
+}
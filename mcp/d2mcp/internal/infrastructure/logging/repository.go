@@ -0,0 +1,167 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/repository"
+)
+
+// OracleRepository decorates an OracleRepository and logs every call
+// with the request-scoped logger from the context.
+type OracleRepository struct {
+	next repository.OracleRepository
+}
+
+// NewOracleRepository wraps the given repository with logging.
+func NewOracleRepository(next repository.OracleRepository) repository.OracleRepository {
+	return &OracleRepository{next: next}
+}
+
+// logCall logs the outcome of a repository call with consistent fields.
+func logCall(ctx context.Context, op string, diagramID string, start time.Time, err error, attrs ...any) {
+	logger := FromContext(ctx)
+	attrs = append(attrs,
+		slog.String("op", op),
+		slog.Duration("duration", time.Since(start)),
+	)
+	if diagramID != "" {
+		attrs = append(attrs, slog.String("diagram_id", diagramID))
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "repository call failed", append(attrs, slog.Any("error", err))...)
+		return
+	}
+	logger.DebugContext(ctx, "repository call completed", attrs...)
+}
+
+// Render renders D2 text into a diagram with specified format.
+func (r *OracleRepository) Render(ctx context.Context, content string, format entity.ExportFormat, theme *entity.Theme) (io.Reader, error) {
+	start := time.Now()
+	result, err := r.next.Render(ctx, content, format, theme)
+	logCall(ctx, "render", "", start, err, slog.String("format", string(format)), slog.Int("content_length", len(content)))
+	return result, err
+}
+
+// Create creates a new diagram programmatically.
+func (r *OracleRepository) Create(ctx context.Context, diagram *entity.Diagram) error {
+	start := time.Now()
+	err := r.next.Create(ctx, diagram)
+	logCall(ctx, "create", diagram.ID, start, err)
+	return err
+}
+
+// Export exports the diagram to the specified format.
+func (r *OracleRepository) Export(ctx context.Context, diagramID string, format entity.ExportFormat, opts entity.ExportOptions) (io.Reader, error) {
+	start := time.Now()
+	result, err := r.next.Export(ctx, diagramID, format, opts)
+	logCall(ctx, "export", diagramID, start, err, slog.String("format", string(format)), slog.Bool("accessible", opts.Accessible))
+	return result, err
+}
+
+// CreateElement creates a new shape or connection.
+func (r *OracleRepository) CreateElement(ctx context.Context, diagramID string, boardPath []string, key string) (*entity.OracleResult, error) {
+	start := time.Now()
+	result, err := r.next.CreateElement(ctx, diagramID, boardPath, key)
+	logCall(ctx, "create_element", diagramID, start, err, slog.String("key", key))
+	return result, err
+}
+
+// SetAttribute sets attributes on a shape or connection.
+func (r *OracleRepository) SetAttribute(ctx context.Context, diagramID string, boardPath []string, key string, tag, value *string) (*entity.OracleResult, error) {
+	start := time.Now()
+	result, err := r.next.SetAttribute(ctx, diagramID, boardPath, key, tag, value)
+	logCall(ctx, "set_attribute", diagramID, start, err, slog.String("key", key))
+	return result, err
+}
+
+// DeleteElement deletes a shape or connection.
+func (r *OracleRepository) DeleteElement(ctx context.Context, diagramID string, boardPath []string, key string) (*entity.OracleResult, error) {
+	start := time.Now()
+	result, err := r.next.DeleteElement(ctx, diagramID, boardPath, key)
+	logCall(ctx, "delete_element", diagramID, start, err, slog.String("key", key))
+	return result, err
+}
+
+// MoveElement moves a shape to a new container.
+func (r *OracleRepository) MoveElement(ctx context.Context, diagramID string, boardPath []string, key, newKey string, includeDescendants bool) (*entity.OracleResult, error) {
+	start := time.Now()
+	result, err := r.next.MoveElement(ctx, diagramID, boardPath, key, newKey, includeDescendants)
+	logCall(ctx, "move_element", diagramID, start, err, slog.String("key", key), slog.String("new_key", newKey))
+	return result, err
+}
+
+// RenameElement renames a shape or connection.
+func (r *OracleRepository) RenameElement(ctx context.Context, diagramID string, boardPath []string, key, newName string) (*entity.OracleResult, error) {
+	start := time.Now()
+	result, err := r.next.RenameElement(ctx, diagramID, boardPath, key, newName)
+	logCall(ctx, "rename_element", diagramID, start, err, slog.String("key", key), slog.String("new_name", newName))
+	return result, err
+}
+
+// PinElement pins a shape at a position.
+func (r *OracleRepository) PinElement(ctx context.Context, diagramID string, boardPath []string, key string, position *entity.Position) (*entity.OracleResult, error) {
+	start := time.Now()
+	result, err := r.next.PinElement(ctx, diagramID, boardPath, key, position)
+	logCall(ctx, "pin_element", diagramID, start, err, slog.String("key", key))
+	return result, err
+}
+
+// UnpinElement removes a pinned position from a shape.
+func (r *OracleRepository) UnpinElement(ctx context.Context, diagramID string, boardPath []string, key string) (*entity.OracleResult, error) {
+	start := time.Now()
+	result, err := r.next.UnpinElement(ctx, diagramID, boardPath, key)
+	logCall(ctx, "unpin_element", diagramID, start, err, slog.String("key", key))
+	return result, err
+}
+
+// GetObject retrieves object information.
+func (r *OracleRepository) GetObject(ctx context.Context, diagramID string, boardPath []string, objectID string) (*entity.GraphObject, error) {
+	start := time.Now()
+	result, err := r.next.GetObject(ctx, diagramID, boardPath, objectID)
+	logCall(ctx, "get_object", diagramID, start, err, slog.String("key", objectID))
+	return result, err
+}
+
+// GetEdge retrieves edge information.
+func (r *OracleRepository) GetEdge(ctx context.Context, diagramID string, boardPath []string, edgeID string) (*entity.GraphEdge, error) {
+	start := time.Now()
+	result, err := r.next.GetEdge(ctx, diagramID, boardPath, edgeID)
+	logCall(ctx, "get_edge", diagramID, start, err, slog.String("key", edgeID))
+	return result, err
+}
+
+// GetChildren retrieves child element IDs.
+func (r *OracleRepository) GetChildren(ctx context.Context, diagramID string, boardPath []string, parentID string) ([]string, error) {
+	start := time.Now()
+	result, err := r.next.GetChildren(ctx, diagramID, boardPath, parentID)
+	logCall(ctx, "get_children", diagramID, start, err, slog.String("key", parentID))
+	return result, err
+}
+
+// LoadDiagram loads a diagram from D2 text.
+func (r *OracleRepository) LoadDiagram(ctx context.Context, diagramID string, content string) error {
+	start := time.Now()
+	err := r.next.LoadDiagram(ctx, diagramID, content)
+	logCall(ctx, "load_diagram", diagramID, start, err, slog.Int("content_length", len(content)))
+	return err
+}
+
+// GetGraph retrieves the full graph structure of a diagram.
+func (r *OracleRepository) GetGraph(ctx context.Context, diagramID string) (*entity.DiagramGraph, error) {
+	start := time.Now()
+	result, err := r.next.GetGraph(ctx, diagramID)
+	logCall(ctx, "get_graph", diagramID, start, err)
+	return result, err
+}
+
+// SerializeDiagram converts the current graph state back to D2 text.
+func (r *OracleRepository) SerializeDiagram(ctx context.Context, diagramID string, mode entity.SerializeMode) (string, error) {
+	start := time.Now()
+	result, err := r.next.SerializeDiagram(ctx, diagramID, mode)
+	logCall(ctx, "serialize_diagram", diagramID, start, err, slog.String("mode", string(mode)))
+	return result, err
+}
@@ -3,6 +3,7 @@ package mcp
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -43,19 +44,22 @@ type Server struct {
 	transport            TransportType
 	sseConfig            *SSEConfig
 	streamableHTTPConfig *StreamableHTTPConfig
+	httpHandlers         map[string]http.Handler
 }
 
 // NewServer creates a new MCP server instance with default stdio transport.
-func NewServer(name string, version string) (*Server, error) {
+func NewServer(name string, version string, opts ...server.ServerOption) (*Server, error) {
 	// Create MCP server.
 	mcpServer := server.NewMCPServer(
 		name,
 		version,
+		opts...,
 	)
 
 	return &Server{
-		mcpServer: mcpServer,
-		transport: TransportStdio,
+		mcpServer:    mcpServer,
+		transport:    TransportStdio,
+		httpHandlers: make(map[string]http.Handler),
 	}, nil
 }
 
@@ -77,6 +81,13 @@ func (s *Server) WithStreamableHTTPConfig(config *StreamableHTTPConfig) *Server
 	return s
 }
 
+// WithHTTPHandler registers an additional handler (e.g. metrics or health checks)
+// on the HTTP listener. It is ignored for stdio transport.
+func (s *Server) WithHTTPHandler(path string, handler http.Handler) *Server {
+	s.httpHandlers[path] = handler
+	return s
+}
+
 // RegisterTool registers a tool with the MCP server.
 func (s *Server) RegisterTool(tool mcp.Tool, handler server.ToolHandlerFunc) error {
 	s.mcpServer.AddTool(tool, handler)
@@ -120,8 +131,15 @@ func (s *Server) startSSE(ctx context.Context) error {
 		opts = append(opts, server.WithKeepAliveInterval(s.sseConfig.KeepAliveInterval))
 	}
 
+	// Serve the SSE endpoints alongside any additional handlers
+	mux := http.NewServeMux()
+	httpServer := &http.Server{Addr: s.sseConfig.Addr, Handler: mux}
+	opts = append(opts, server.WithHTTPServer(httpServer))
+
 	// Create and start SSE server
 	sseServer := server.NewSSEServer(s.mcpServer, opts...)
+	mux.Handle("/", sseServer)
+	s.mountHTTPHandlers(mux)
 	return sseServer.Start(s.sseConfig.Addr)
 }
 
@@ -143,11 +161,29 @@ func (s *Server) startStreamableHTTP(ctx context.Context) error {
 		opts = append(opts, server.WithStateLess(true))
 	}
 
+	// Serve the MCP endpoint alongside any additional handlers
+	mux := http.NewServeMux()
+	httpServer := &http.Server{Addr: s.streamableHTTPConfig.Addr, Handler: mux}
+	opts = append(opts, server.WithStreamableHTTPServer(httpServer))
+
 	// Create and start Streamable HTTP server
 	streamableServer := server.NewStreamableHTTPServer(s.mcpServer, opts...)
+	endpointPath := s.streamableHTTPConfig.EndpointPath
+	if endpointPath == "" {
+		endpointPath = "/mcp"
+	}
+	mux.Handle(endpointPath, streamableServer)
+	s.mountHTTPHandlers(mux)
 	return streamableServer.Start(s.streamableHTTPConfig.Addr)
 }
 
+// mountHTTPHandlers adds the additional handlers to the given mux.
+func (s *Server) mountHTTPHandlers(mux *http.ServeMux) {
+	for path, handler := range s.httpHandlers {
+		mux.Handle(path, handler)
+	}
+}
+
 // GetMCPServer returns the underlying MCP server instance.
 func (s *Server) GetMCPServer() *server.MCPServer {
 	return s.mcpServer
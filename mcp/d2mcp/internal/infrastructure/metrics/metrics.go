@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "d2mcp"
+
+// Metrics holds the Prometheus collectors exposed by the server.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	toolCalls        *prometheus.CounterVec
+	toolDuration     *prometheus.HistogramVec
+	renderDuration   *prometheus.HistogramVec
+	activeSessions   prometheus.Gauge
+	activeDiagrams   prometheus.Gauge
+	artifactFailures *prometheus.CounterVec
+
+	ready atomic.Bool
+}
+
+// New creates a new Metrics instance with its own registry.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		toolCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tool_calls_total",
+			Help:      "Number of tool calls by tool name and outcome.",
+		}, []string{"tool", "status"}),
+		toolDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "tool_call_duration_seconds",
+			Help:      "Latency of tool calls by tool name.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"tool"}),
+		renderDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "render_duration_seconds",
+			Help:      "Duration of D2 compile, layout and render by layout engine.",
+			Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}, []string{"engine", "status"}),
+		activeSessions: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active_sessions",
+			Help:      "Number of connected MCP client sessions.",
+		}),
+		activeDiagrams: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active_diagrams",
+			Help:      "Number of diagrams held in memory.",
+		}),
+		artifactFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "artifact_failures_total",
+			Help:      "Number of failed calls to the artifact service by operation.",
+		}, []string{"operation"}),
+	}
+
+	m.registry.MustRegister(
+		m.toolCalls,
+		m.toolDuration,
+		m.renderDuration,
+		m.activeSessions,
+		m.activeDiagrams,
+		m.artifactFailures,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Middleware wraps a tool handler and records call counts and latencies.
+func (m *Metrics) Middleware(toolName string, next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		start := time.Now()
+		result, err := next(ctx, request)
+		m.toolDuration.WithLabelValues(toolName).Observe(time.Since(start).Seconds())
+
+		status := "ok"
+		if err != nil || (result != nil && result.IsError) {
+			status = "error"
+		}
+		m.toolCalls.WithLabelValues(toolName, status).Inc()
+
+		return result, err
+	}
+}
+
+// Hooks returns MCP server hooks that track active client sessions.
+func (m *Metrics) Hooks() *server.Hooks {
+	hooks := &server.Hooks{}
+	hooks.AddOnRegisterSession(func(ctx context.Context, session server.ClientSession) {
+		m.activeSessions.Inc()
+	})
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		m.activeSessions.Dec()
+	})
+	return hooks
+}
+
+// ObserveRender records the duration of a render with the given layout engine.
+func (m *Metrics) ObserveRender(engine string, duration time.Duration, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	m.renderDuration.WithLabelValues(engine, status).Observe(duration.Seconds())
+}
+
+// SetDiagramCount records the number of diagrams currently held in memory.
+func (m *Metrics) SetDiagramCount(n int) {
+	m.activeDiagrams.Set(float64(n))
+}
+
+// ArtifactFailure records a failed artifact service call for the given operation.
+func (m *Metrics) ArtifactFailure(operation string) {
+	m.artifactFailures.WithLabelValues(operation).Inc()
+}
+
+// SetReady marks the server as ready (or not ready) to accept tool calls.
+func (m *Metrics) SetReady(ready bool) {
+	m.ready.Store(ready)
+}
+
+// Handler returns the HTTP handler serving the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// HealthHandler returns a liveness handler that reports OK while the process is running.
+func (m *Metrics) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// ReadyHandler returns a readiness handler that reports OK once all tools are registered.
+func (m *Metrics) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.ready.Load() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ready\n"))
+	})
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestMetrics_Middleware(t *testing.T) {
+	m := New()
+
+	ok := m.Middleware("d2_create", func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("done"), nil
+	})
+	failing := m.Middleware("d2_export", func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultError("boom"), nil
+	})
+
+	if _, err := ok(context.Background(), mcp.CallToolRequest{}); err != nil {
+		t.Fatalf("Middleware() error = %v", err)
+	}
+	if _, err := failing(context.Background(), mcp.CallToolRequest{}); err != nil {
+		t.Fatalf("Middleware() error = %v", err)
+	}
+	m.ArtifactFailure("write")
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		`d2mcp_tool_calls_total{status="ok",tool="d2_create"} 1`,
+		`d2mcp_tool_calls_total{status="error",tool="d2_export"} 1`,
+		`d2mcp_tool_call_duration_seconds_count{tool="d2_create"} 1`,
+		`d2mcp_artifact_failures_total{operation="write"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+}
+
+func TestMetrics_ReadyHandler(t *testing.T) {
+	m := New()
+
+	rec := httptest.NewRecorder()
+	m.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("ReadyHandler() before ready = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	m.SetReady(true)
+	rec = httptest.NewRecorder()
+	m.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("ReadyHandler() after ready = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,344 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/repository"
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/infrastructure/logging"
+)
+
+// Extension is the file extension of D2 diagrams.
+const Extension = ".d2"
+
+// debounce is how long to wait for further events before reloading a file,
+// since editors often write a file in several steps.
+const debounce = 100 * time.Millisecond
+
+// Workspace keeps the diagrams of an oracle repository in sync with the .d2 files
+// of a working directory. Diagram IDs are file paths relative to the directory,
+// with forward slashes and without the extension (e.g. "services/api").
+type Workspace struct {
+	root   string
+	oracle repository.OracleRepository
+
+	mu sync.Mutex
+	// known holds the last content loaded from or written to each file,
+	// so that our own writes and no-op saves do not trigger a reload.
+	known  map[string]string
+	timers map[string]*time.Timer
+}
+
+// New creates a workspace for the given directory.
+func New(root string, oracle repository.OracleRepository) (*Workspace, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve watch directory: %w", err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open watch directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("watch directory %s is not a directory", abs)
+	}
+
+	return &Workspace{
+		root:   abs,
+		oracle: oracle,
+		known:  make(map[string]string),
+		timers: make(map[string]*time.Timer),
+	}, nil
+}
+
+// Root returns the absolute path of the working directory.
+func (w *Workspace) Root() string {
+	return w.root
+}
+
+// LoadAll loads every .d2 file below the working directory and returns the number of diagrams.
+// Files that fail to compile are logged and skipped.
+func (w *Workspace) LoadAll(ctx context.Context) (int, error) {
+	loaded := 0
+	err := filepath.WalkDir(w.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != w.root && isHidden(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isDiagramFile(path) {
+			return nil
+		}
+		if err := w.load(ctx, path); err != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "failed to load diagram file", slog.String("path", path), slog.Any("error", err))
+			return nil
+		}
+		loaded++
+		return nil
+	})
+	if err != nil {
+		return loaded, fmt.Errorf("failed to scan watch directory: %w", err)
+	}
+	return loaded, nil
+}
+
+// Watch reloads diagrams when their files change, until the context is cancelled.
+func (w *Workspace) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := w.addDirs(watcher, w.root); err != nil {
+		return err
+	}
+
+	logger := logging.FromContext(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			w.stopTimers()
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			w.handleEvent(ctx, watcher, event)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.WarnContext(ctx, "file watcher error", slog.Any("error", err))
+		}
+	}
+}
+
+// handleEvent reacts to a single file system event.
+func (w *Workspace) handleEvent(ctx context.Context, watcher *fsnotify.Watcher, event fsnotify.Event) {
+	logger := logging.FromContext(ctx)
+
+	// Watch new directories and pick up the diagrams inside them.
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if isHidden(info.Name()) {
+				return
+			}
+			if err := w.addDirs(watcher, event.Name); err != nil {
+				logger.WarnContext(ctx, "failed to watch directory", slog.String("path", event.Name), slog.Any("error", err))
+			}
+			_ = filepath.WalkDir(event.Name, func(path string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() && isDiagramFile(path) {
+					w.scheduleReload(ctx, path)
+				}
+				return nil
+			})
+			return
+		}
+	}
+
+	if !isDiagramFile(event.Name) {
+		return
+	}
+	switch {
+	case event.Has(fsnotify.Write), event.Has(fsnotify.Create):
+		w.scheduleReload(ctx, event.Name)
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		// The diagram stays in memory and can be written back with d2_commit_to_file.
+		logger.InfoContext(ctx, "diagram file removed", slog.String("path", event.Name))
+	}
+}
+
+// scheduleReload reloads a file once no further events arrived for the debounce period.
+func (w *Workspace) scheduleReload(ctx context.Context, path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if timer, ok := w.timers[path]; ok {
+		timer.Stop()
+	}
+	w.timers[path] = time.AfterFunc(debounce, func() {
+		w.mu.Lock()
+		delete(w.timers, path)
+		w.mu.Unlock()
+
+		w.reload(ctx, path)
+	})
+}
+
+// reload loads a changed file, unless its content is already known.
+func (w *Workspace) reload(ctx context.Context, path string) {
+	logger := logging.FromContext(ctx).With(slog.String("path", path))
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logger.WarnContext(ctx, "failed to read diagram file", slog.Any("error", err))
+		}
+		return
+	}
+
+	w.mu.Lock()
+	known, seen := w.known[path]
+	w.mu.Unlock()
+	if seen && known == string(content) {
+		return
+	}
+
+	id, _ := w.diagramID(path)
+	if seen {
+		if current, err := w.oracle.SerializeDiagram(ctx, id, entity.SerializePreserve); err == nil && current != known {
+			logger.WarnContext(ctx, "file changed on disk; discarding uncommitted edits", slog.String("diagram_id", id))
+		}
+	}
+
+	if err := w.load(ctx, path); err != nil {
+		logger.WarnContext(ctx, "failed to reload diagram file", slog.Any("error", err))
+		return
+	}
+	logger.InfoContext(ctx, "diagram reloaded", slog.String("diagram_id", id))
+}
+
+// load reads a file and loads it as a diagram.
+func (w *Workspace) load(ctx context.Context, path string) error {
+	id, err := w.diagramID(path)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read diagram file: %w", err)
+	}
+	if err := w.oracle.LoadDiagram(ctx, id, string(content)); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.known[path] = string(content)
+	w.mu.Unlock()
+	return nil
+}
+
+// Write stores D2 text in the file backing the diagram and returns its relative path.
+// The file is replaced atomically so that watchers never see a partial write.
+func (w *Workspace) Write(ctx context.Context, diagramID string, content string) (string, error) {
+	path, err := w.Path(diagramID)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write diagram file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write diagram file: %w", err)
+	}
+	if info, err := os.Stat(path); err == nil {
+		_ = os.Chmod(tmp.Name(), info.Mode().Perm())
+	} else {
+		_ = os.Chmod(tmp.Name(), 0644)
+	}
+
+	// Record the content before the rename so the resulting event is ignored.
+	w.mu.Lock()
+	w.known[path] = content
+	w.mu.Unlock()
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to replace diagram file: %w", err)
+	}
+
+	rel, _ := filepath.Rel(w.root, path)
+	logging.FromContext(ctx).InfoContext(ctx, "diagram committed to file", slog.String("diagram_id", diagramID), slog.String("path", rel))
+	return filepath.ToSlash(rel), nil
+}
+
+// Path returns the absolute file path for a diagram ID.
+// IDs that would resolve outside the working directory are rejected.
+func (w *Workspace) Path(diagramID string) (string, error) {
+	if diagramID == "" || filepath.IsAbs(diagramID) || strings.HasPrefix(diagramID, "/") {
+		return "", fmt.Errorf("invalid diagram ID for a file: %q", diagramID)
+	}
+	path := filepath.Join(w.root, filepath.FromSlash(diagramID)+Extension)
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("diagram ID %q resolves outside the watch directory", diagramID)
+	}
+	return path, nil
+}
+
+// diagramID returns the diagram ID for a file path.
+func (w *Workspace) diagramID(path string) (string, error) {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("file %s is outside the watch directory", path)
+	}
+	return strings.TrimSuffix(filepath.ToSlash(rel), Extension), nil
+}
+
+// addDirs watches a directory and all its non-hidden subdirectories.
+func (w *Workspace) addDirs(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != w.root && isHidden(d.Name()) {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// stopTimers cancels pending reloads.
+func (w *Workspace) stopTimers() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for path, timer := range w.timers {
+		timer.Stop()
+		delete(w.timers, path)
+	}
+}
+
+// isDiagramFile reports whether a path is a .d2 file that is not hidden.
+func isDiagramFile(path string) bool {
+	return filepath.Ext(path) == Extension && !isHidden(filepath.Base(path))
+}
+
+// isHidden reports whether a file or directory name is hidden (e.g. .git).
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
@@ -0,0 +1,184 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/infrastructure/d2"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorkspace_LoadAll(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "overview.d2"), "a -> b")
+	writeFile(t, filepath.Join(dir, "services", "api.d2"), "# API\napi -> db")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "not a diagram")
+	writeFile(t, filepath.Join(dir, ".git", "ignored.d2"), "x")
+	writeFile(t, filepath.Join(dir, "broken.d2"), "a -> {")
+
+	repo := d2.NewD2OracleRepository()
+	ws, err := New(dir, repo)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	n, err := ws.LoadAll(context.Background())
+	if err != nil {
+		t.Fatalf("LoadAll() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("LoadAll() loaded %d diagrams, want 2", n)
+	}
+
+	content, err := repo.SerializeDiagram(context.Background(), "services/api", entity.SerializePreserve)
+	if err != nil {
+		t.Fatalf("SerializeDiagram() error = %v", err)
+	}
+	if content != "# API\napi -> db" {
+		t.Errorf("SerializeDiagram() = %q", content)
+	}
+	if _, err := repo.SerializeDiagram(context.Background(), ".git/ignored", entity.SerializePreserve); err == nil {
+		t.Error("LoadAll() should skip hidden directories")
+	}
+}
+
+func TestWorkspace_Path(t *testing.T) {
+	dir := t.TempDir()
+	ws, err := New(dir, d2.NewD2OracleRepository())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		want    string
+		wantErr bool
+	}{
+		{name: "top level", id: "overview", want: filepath.Join(dir, "overview.d2")},
+		{name: "nested", id: "services/api", want: filepath.Join(dir, "services", "api.d2")},
+		{name: "parent escape", id: "../outside", wantErr: true},
+		{name: "nested escape", id: "services/../../outside", wantErr: true},
+		{name: "absolute", id: "/etc/passwd", wantErr: true},
+		{name: "empty", id: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ws.Path(tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Path() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Path() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkspace_Write(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "services", "api.d2")
+	writeFile(t, path, "# API\napi -> db\n")
+
+	repo := d2.NewD2OracleRepository()
+	ws, err := New(dir, repo)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx := context.Background()
+	if _, err := ws.LoadAll(ctx); err != nil {
+		t.Fatalf("LoadAll() error = %v", err)
+	}
+
+	if _, err := repo.CreateElement(ctx, "services/api", nil, "cache"); err != nil {
+		t.Fatalf("CreateElement() error = %v", err)
+	}
+	content, err := repo.SerializeDiagram(ctx, "services/api", entity.SerializePreserve)
+	if err != nil {
+		t.Fatalf("SerializeDiagram() error = %v", err)
+	}
+
+	rel, err := ws.Write(ctx, "services/api", content)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if rel != "services/api.d2" {
+		t.Errorf("Write() path = %q, want services/api.d2", rel)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "# API\napi -> db\ncache\n" {
+		t.Errorf("file content = %q", data)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Write() left %d files behind, want 1", len(entries))
+	}
+}
+
+func TestWorkspace_Watch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "overview.d2")
+	writeFile(t, path, "a -> b")
+
+	repo := d2.NewD2OracleRepository()
+	ws, err := New(dir, repo)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := ws.LoadAll(ctx); err != nil {
+		t.Fatalf("LoadAll() error = %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- ws.Watch(ctx) }()
+	// Give the watcher time to register the directories.
+	time.Sleep(100 * time.Millisecond)
+
+	writeFile(t, path, "a -> b\nb -> c")
+	writeFile(t, filepath.Join(dir, "services", "api.d2"), "api -> db")
+
+	waitFor := func(id, want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			got, err := repo.SerializeDiagram(ctx, id, entity.SerializePreserve)
+			if err == nil && strings.TrimSpace(got) == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("diagram %s = %q (err %v), want %q", id, got, err, want)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	waitFor("overview", "a -> b\nb -> c")
+	waitFor("services/api", "api -> db")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Watch() error = %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/usecase"
+)
+
+// CommitToFileHandler handles the d2_commit_to_file tool.
+type CommitToFileHandler struct {
+	useCase *usecase.WorkspaceUseCase
+}
+
+// NewCommitToFileHandler creates a new commit-to-file handler.
+func NewCommitToFileHandler(useCase *usecase.WorkspaceUseCase) *CommitToFileHandler {
+	return &CommitToFileHandler{
+		useCase: useCase,
+	}
+}
+
+// GetTool returns the MCP tool definition.
+func (h *CommitToFileHandler) GetTool() mcp.Tool {
+	return mcp.NewTool(
+		"d2_commit_to_file",
+		mcp.WithDescription("Write the Oracle edits of a diagram back to its .d2 file in the watched directory, so humans and agents share the same diagram source. Diagram IDs are file paths relative to the watched directory without the .d2 extension (e.g. 'services/api' for services/api.d2). Comments and formatting of the file are kept; only the edited parts change. A diagram created with d2_create is written to a new file named after its ID."),
+		mcp.WithString("diagram_id", mcp.Description("ID of the diagram to write, e.g. 'services/api'"), mcp.Required()),
+	)
+}
+
+// GetHandler returns the tool handler function.
+func (h *CommitToFileHandler) GetHandler() server.ToolHandlerFunc {
+	return h.Handle
+}
+
+// Handle processes the commit request.
+func (h *CommitToFileHandler) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract arguments.
+	diagramID := mcp.ParseString(request, "diagram_id", "")
+
+	path, err := h.useCase.CommitToFile(ctx, diagramID)
+	if err != nil {
+		return mcp.NewToolResultErrorFromErr("Failed to commit diagram to file", err), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Diagram %s written to %s", diagramID, path)), nil
+}
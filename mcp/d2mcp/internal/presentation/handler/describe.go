@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/usecase"
+)
+
+// DescribeHandler handles the d2_describe tool.
+type DescribeHandler struct {
+	useCase *usecase.OracleUseCase
+}
+
+// NewDescribeHandler creates a new describe handler.
+func NewDescribeHandler(useCase *usecase.OracleUseCase) *DescribeHandler {
+	return &DescribeHandler{
+		useCase: useCase,
+	}
+}
+
+// GetTool returns the MCP tool definition.
+func (h *DescribeHandler) GetTool() mcp.Tool {
+	return mcp.NewTool(
+		"d2_describe",
+		mcp.WithDescription("Describe a diagram in natural language without rendering it. Returns a Markdown outline listing containers, nested shapes and all connections with their labels. Use this to confirm what a diagram shows, to write alt text, or to include a textual summary of the diagram in reports."),
+		mcp.WithString("diagram_id", mcp.Description("ID of the diagram to describe"), mcp.Required()),
+	)
+}
+
+// GetHandler returns the tool handler function.
+func (h *DescribeHandler) GetHandler() server.ToolHandlerFunc {
+	return h.Handle
+}
+
+// Handle processes the describe request.
+func (h *DescribeHandler) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract arguments.
+	diagramID := mcp.ParseString(request, "diagram_id", "")
+
+	outline, err := h.useCase.DescribeDiagram(ctx, diagramID)
+	if err != nil {
+		return mcp.NewToolResultErrorFromErr("Failed to describe diagram", err), nil
+	}
+
+	return mcp.NewToolResultText(outline), nil
+}
@@ -5,18 +5,24 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"log/slog"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 
 	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/infrastructure/logging"
 	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/usecase"
 	"github.com/hmsoft0815/mlcartifact"
 )
 
+// ArtifactFailureFunc is called when a call to the artifact service fails.
+type ArtifactFailureFunc func(operation string)
+
 // ExportHandler handles diagram export operations.
 type ExportHandler struct {
-	useCase *usecase.DiagramUseCase
+	useCase         *usecase.DiagramUseCase
+	onArtifactError ArtifactFailureFunc
 }
 
 // NewExportHandler creates a new export handler.
@@ -26,12 +32,29 @@ func NewExportHandler(useCase *usecase.DiagramUseCase) *ExportHandler {
 	}
 }
 
+// WithArtifactFailureHook sets a callback that is invoked when the artifact service fails.
+func (h *ExportHandler) WithArtifactFailureHook(fn ArtifactFailureFunc) *ExportHandler {
+	h.onArtifactError = fn
+	return h
+}
+
+// artifactFailed logs a failed artifact service call and reports it to the hook, if any.
+func (h *ExportHandler) artifactFailed(ctx context.Context, operation string, err error) {
+	logging.FromContext(ctx).WarnContext(ctx, "artifact service call failed", slog.String("operation", operation), slog.Any("error", err))
+	if h.onArtifactError != nil {
+		h.onArtifactError(operation)
+	}
+}
+
 // GetTool returns the MCP tool definition.
 func (h *ExportHandler) GetTool() mcp.Tool {
 	return mcp.NewTool(
 		"d2_export",
 		mcp.WithDescription("Export an existing diagram to SVG format. The diagram must first be created using d2_create. Supports exporting all D2 features including SQL tables, UML classes, sequence diagrams, code blocks, and markdown-rich documentation."),
 		mcp.WithString("diagramId", mcp.Description("ID of the diagram to export"), mcp.Required()),
+		mcp.WithBoolean("accessible", mcp.Description("Inject <title> and <desc> elements for the diagram and every shape and connection so screen readers can describe the SVG"), mcp.DefaultBool(false)),
+		mcp.WithString("title", mcp.Description("Accessible title of the diagram (defaults to the diagram ID). Only used when accessible is true.")),
+		mcp.WithBoolean("stable_layout", mcp.Description("Keep shapes where the previous export placed them; only new or moved shapes are positioned by the layout engine. Pinned shapes (see d2_oracle_pin) always keep their position."), mcp.DefaultBool(false)),
 	)
 }
 
@@ -48,8 +71,14 @@ func (h *ExportHandler) Handle(ctx context.Context, request mcp.CallToolRequest)
 		return mcp.NewToolResultError("diagramId is required"), nil
 	}
 
+	opts := entity.ExportOptions{
+		Accessible:   mcp.ParseBoolean(request, "accessible", false),
+		Title:        mcp.ParseString(request, "title", ""),
+		StableLayout: mcp.ParseBoolean(request, "stable_layout", false),
+	}
+
 	// 1. Export the diagram as SVG using UseCase
-	reader, err := h.useCase.ExportDiagram(ctx, diagramID, entity.FormatSVG)
+	reader, err := h.useCase.ExportDiagram(ctx, diagramID, entity.FormatSVG, opts)
 	if err != nil {
 		return mcp.NewToolResultErrorFromErr("Failed to export diagram", err), nil
 	}
@@ -78,6 +107,9 @@ func (h *ExportHandler) Handle(ctx context.Context, request mcp.CallToolRequest)
 			})
 			return imgResult, nil
 		}
+		h.artifactFailed(ctx, "write", err)
+	} else {
+		h.artifactFailed(ctx, "connect", err)
 	}
 
 	// Fallback to just base64 if artifact service is unavailable
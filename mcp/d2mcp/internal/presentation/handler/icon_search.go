@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/usecase"
+)
+
+// IconSearchHandler handles the d2_icon_search tool.
+type IconSearchHandler struct {
+	useCase *usecase.IconUseCase
+}
+
+// NewIconSearchHandler creates a new icon search handler.
+func NewIconSearchHandler(useCase *usecase.IconUseCase) *IconSearchHandler {
+	return &IconSearchHandler{
+		useCase: useCase,
+	}
+}
+
+// GetTool returns the MCP tool definition.
+func (h *IconSearchHandler) GetTool() mcp.Tool {
+	return mcp.NewTool(
+		"d2_icon_search",
+		mcp.WithDescription("Search the built-in offline badge pack by keyword: simple colored badges labeled with the abbreviation of an AWS, GCP or Kubernetes service (not the vendors' official icons). Use this when you want to mark the services in architecture diagrams. Returns matching icons with their 'pack://' references. Apply an icon with d2_oracle_set, e.g. key 'api.icon' and value 'pack://aws/lambda'. The icon is inlined into the SVG on export, so no network access is needed."),
+		mcp.WithString("query", mcp.Description("Keywords to search for. Examples: 'lambda', 'database', 'queue', 'kubernetes ingress'")),
+		mcp.WithString("pack", mcp.Description("Optional group to restrict the search to: 'aws', 'gcp' or 'k8s'")),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of icons to return"), mcp.DefaultNumber(10)),
+	)
+}
+
+// GetHandler returns the tool handler function.
+func (h *IconSearchHandler) GetHandler() server.ToolHandlerFunc {
+	return h.Handle
+}
+
+// iconResult is the JSON representation of an icon search result.
+type iconResult struct {
+	Ref      string   `json:"ref"`
+	Title    string   `json:"title"`
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+// Handle processes the icon search request.
+func (h *IconSearchHandler) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract arguments.
+	query := mcp.ParseString(request, "query", "")
+	pack := mcp.ParseString(request, "pack", "")
+	limit := mcp.ParseInt(request, "limit", 10)
+
+	icons, err := h.useCase.SearchIcons(ctx, query, pack, limit)
+	if err != nil {
+		return mcp.NewToolResultErrorFromErr("Failed to search icons", err), nil
+	}
+
+	if len(icons) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("No icons found for '%s'", query)), nil
+	}
+
+	results := make([]iconResult, len(icons))
+	for i, icon := range icons {
+		results[i] = iconResult{
+			Ref:      icon.Ref(),
+			Title:    icon.Title,
+			Keywords: icon.Keywords,
+		}
+	}
+
+	jsonData, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format icon results"), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Found %d icon(s). Set one with d2_oracle_set (key '<shape>.icon', value '<ref>'):\n%s", len(icons), string(jsonData))), nil
+}
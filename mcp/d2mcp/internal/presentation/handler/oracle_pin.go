@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/usecase"
+)
+
+// OraclePinHandler handles the d2_oracle_pin tool.
+type OraclePinHandler struct {
+	useCase *usecase.OracleUseCase
+}
+
+// NewOraclePinHandler creates a new Oracle pin handler.
+func NewOraclePinHandler(useCase *usecase.OracleUseCase) *OraclePinHandler {
+	return &OraclePinHandler{
+		useCase: useCase,
+	}
+}
+
+// GetTool returns the MCP tool definition.
+func (h *OraclePinHandler) GetTool() mcp.Tool {
+	return mcp.NewTool(
+		"d2_oracle_pin",
+		mcp.WithDescription("Pin or unpin a shape so it keeps its position across edits and exports. Pinning writes 'top' and 'left' into the D2 source; without explicit coordinates the shape is pinned where the last d2_export placed it. Unpinning removes them so the layout engine places the shape again. Use together with d2_export's stable_layout option to keep reviewers oriented while a diagram evolves."),
+		mcp.WithString("diagram_id", mcp.Description("ID of the diagram to modify"), mcp.Required()),
+		mcp.WithString("key", mcp.Description("Key of the shape to pin (e.g., 'server', 'Network.DMZ.proxy')"), mcp.Required()),
+		mcp.WithBoolean("pinned", mcp.Description("true to pin the shape, false to unpin it"), mcp.DefaultBool(true)),
+		mcp.WithNumber("top", mcp.Description("Optional Y coordinate of the shape's top edge in diagram coordinates. Requires left."), mcp.Min(0)),
+		mcp.WithNumber("left", mcp.Description("Optional X coordinate of the shape's left edge in diagram coordinates. Requires top."), mcp.Min(0)),
+	)
+}
+
+// GetHandler returns the tool handler function.
+func (h *OraclePinHandler) GetHandler() server.ToolHandlerFunc {
+	return h.Handle
+}
+
+// Handle processes the pin request.
+func (h *OraclePinHandler) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract arguments.
+	diagramID := mcp.ParseString(request, "diagram_id", "")
+	key := mcp.ParseString(request, "key", "")
+	pinned := mcp.ParseBoolean(request, "pinned", true)
+	top := mcp.ParseInt(request, "top", -1)
+	left := mcp.ParseInt(request, "left", -1)
+
+	op := &entity.OracleOperation{
+		DiagramID: diagramID,
+		Key:       key,
+		BoardPath: []string{}, // For now, single board support
+	}
+
+	if !pinned {
+		if _, err := h.useCase.UnpinElement(ctx, op); err != nil {
+			return mcp.NewToolResultErrorFromErr("Failed to unpin element", err), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Element unpinned successfully: %s", key)), nil
+	}
+
+	var position *entity.Position
+	switch {
+	case top >= 0 && left >= 0:
+		position = &entity.Position{X: left, Y: top}
+	case top >= 0 || left >= 0:
+		return mcp.NewToolResultError("Both top and left are required to pin at an explicit position"), nil
+	}
+
+	result, err := h.useCase.PinElement(ctx, op, position)
+	if err != nil {
+		return mcp.NewToolResultErrorFromErr("Failed to pin element", err), nil
+	}
+
+	response := fmt.Sprintf("Element pinned successfully: %s", key)
+	if result.Graph != nil {
+		if obj, ok := result.Graph.Objects[key]; ok {
+			response = fmt.Sprintf("Element pinned successfully: %s (top: %v, left: %v)", key, obj.Attributes["top"], obj.Attributes["left"])
+		}
+	}
+	return mcp.NewToolResultText(response), nil
+}
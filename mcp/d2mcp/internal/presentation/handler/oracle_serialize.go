@@ -6,6 +6,7 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
 	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/usecase"
 )
 
@@ -27,6 +28,7 @@ func (h *OracleSerializeHandler) GetTool() mcp.Tool {
 		"d2_oracle_serialize",
 		mcp.WithDescription("Export the current state of an Oracle-edited diagram as D2 text. Use this when you need to: see the complete D2 syntax after incremental changes, save diagram source for version control, share diagram definition with others, debug complex diagrams, or transition from Oracle API to direct D2 text editing. Returns the exact D2 code that would produce the current diagram, including all shapes, connections, special elements (sql_table, class), styles, and content. This is THE way to get the textual representation after using Oracle API tools."),
 		mcp.WithString("diagram_id", mcp.Description("ID of the diagram to get D2 text for"), mcp.Required()),
+		mcp.WithString("mode", mcp.Description("'preserve' keeps the original text, comments and formatting and patches in only the edited nodes, so a diff shows just the edits. 'format' re-formats the whole diagram with d2fmt"), mcp.DefaultString("preserve")),
 	)
 }
 
@@ -39,8 +41,9 @@ func (h *OracleSerializeHandler) GetHandler() server.ToolHandlerFunc {
 func (h *OracleSerializeHandler) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	// Extract arguments.
 	diagramID := mcp.ParseString(request, "diagram_id", "")
+	mode := mcp.ParseString(request, "mode", string(entity.SerializePreserve))
 
-	content, err := h.useCase.SerializeDiagram(ctx, diagramID)
+	content, err := h.useCase.SerializeDiagram(ctx, diagramID, entity.SerializeMode(mode))
 	if err != nil {
 		return mcp.NewToolResultErrorFromErr("Failed to serialize diagram", err), nil
 	}
@@ -5,18 +5,21 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"log/slog"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 
 	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/infrastructure/logging"
 	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/usecase"
 	"github.com/hmsoft0815/mlcartifact"
 )
 
 // RenderArtifactHandler handles rendering a D2 source artifact to an SVG artifact.
 type RenderArtifactHandler struct {
-	useCase *usecase.DiagramUseCase
+	useCase         *usecase.DiagramUseCase
+	onArtifactError ArtifactFailureFunc
 }
 
 // NewRenderArtifactHandler creates a new handler.
@@ -26,6 +29,20 @@ func NewRenderArtifactHandler(useCase *usecase.DiagramUseCase) *RenderArtifactHa
 	}
 }
 
+// WithArtifactFailureHook sets a callback that is invoked when the artifact service fails.
+func (h *RenderArtifactHandler) WithArtifactFailureHook(fn ArtifactFailureFunc) *RenderArtifactHandler {
+	h.onArtifactError = fn
+	return h
+}
+
+// artifactFailed logs a failed artifact service call and reports it to the hook, if any.
+func (h *RenderArtifactHandler) artifactFailed(ctx context.Context, operation string, err error) {
+	logging.FromContext(ctx).WarnContext(ctx, "artifact service call failed", slog.String("operation", operation), slog.Any("error", err))
+	if h.onArtifactError != nil {
+		h.onArtifactError(operation)
+	}
+}
+
 // GetTool returns the MCP tool definition.
 func (h *RenderArtifactHandler) GetTool() mcp.Tool {
 	return mcp.NewTool(
@@ -50,12 +67,14 @@ func (h *RenderArtifactHandler) Handle(ctx context.Context, request mcp.CallTool
 	// 1. Fetch D2 source from artifact service
 	cli, err := mlcartifact.NewClient()
 	if err != nil {
+		h.artifactFailed(ctx, "connect", err)
 		return mcp.NewToolResultErrorFromErr("Failed to connect to artifact service", err), nil
 	}
 	defer cli.Close()
 
 	res, err := cli.Read(ctx, artifactID)
 	if err != nil {
+		h.artifactFailed(ctx, "read", err)
 		return mcp.NewToolResultErrorFromErr("Failed to read D2 artifact", err), nil
 	}
 
@@ -74,6 +93,7 @@ func (h *RenderArtifactHandler) Handle(ctx context.Context, request mcp.CallTool
 	filename := fmt.Sprintf("%s.svg", res.Filename)
 	writeRes, err := cli.Write(ctx, filename, data, mlcartifact.WithSource("d2mcp"))
 	if err != nil {
+		h.artifactFailed(ctx, "write", err)
 		// Return image anyway but report error
 		imgResult := mcp.NewToolResultImage("svg", base64.StdEncoding.EncodeToString(data), "image/svg+xml")
 		imgResult.Content = append(imgResult.Content, mcp.NewTextContent("\nWarning: Failed to save as artifact: "+err.Error()))
@@ -47,7 +47,7 @@ func (h *SaveHandler) Handle(ctx context.Context, request mcp.CallToolRequest) (
 	}
 
 	// Export the diagram as SVG.
-	reader, err := h.useCase.ExportDiagram(ctx, diagramID, entity.FormatSVG)
+	reader, err := h.useCase.ExportDiagram(ctx, diagramID, entity.FormatSVG, entity.ExportOptions{})
 	if err != nil {
 		return mcp.NewToolResultErrorFromErr("Failed to export diagram", err), nil
 	}
@@ -49,7 +49,7 @@ func (uc *DiagramUseCase) CreateDiagram(ctx context.Context, diagram *entity.Dia
 }
 
 // ExportDiagram exports the diagram to the specified format.
-func (uc *DiagramUseCase) ExportDiagram(ctx context.Context, diagramID string, format entity.ExportFormat) (io.Reader, error) {
+func (uc *DiagramUseCase) ExportDiagram(ctx context.Context, diagramID string, format entity.ExportFormat, opts entity.ExportOptions) (io.Reader, error) {
 	// Validate input.
 	if diagramID == "" {
 		return nil, &ValidationError{Message: "diagram ID is required"}
@@ -60,7 +60,7 @@ func (uc *DiagramUseCase) ExportDiagram(ctx context.Context, diagramID string, f
 		format = entity.FormatSVG
 	}
 
-	return uc.repo.Export(ctx, diagramID, format)
+	return uc.repo.Export(ctx, diagramID, format, opts)
 }
 
 // Create creates a diagram with the given ID and optional content.
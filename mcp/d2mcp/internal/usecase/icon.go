@@ -0,0 +1,32 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/repository"
+)
+
+// defaultIconSearchLimit is the number of icons returned when no limit is given.
+const defaultIconSearchLimit = 10
+
+// IconUseCase implements the business logic for badge pack operations.
+type IconUseCase struct {
+	repo repository.IconRepository
+}
+
+// NewIconUseCase creates a new icon usecase instance.
+func NewIconUseCase(repo repository.IconRepository) *IconUseCase {
+	return &IconUseCase{repo: repo}
+}
+
+// SearchIcons returns icons matching the query.
+func (uc *IconUseCase) SearchIcons(ctx context.Context, query string, pack string, limit int) ([]*entity.Icon, error) {
+	if query == "" && pack == "" {
+		return nil, &ValidationError{Message: "query or pack is required"}
+	}
+	if limit <= 0 {
+		limit = defaultIconSearchLimit
+	}
+	return uc.repo.Search(ctx, query, pack, limit)
+}
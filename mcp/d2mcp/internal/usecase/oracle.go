@@ -129,13 +129,62 @@ func (uc *OracleUseCase) LoadDiagram(ctx context.Context, diagramID string, cont
 	return uc.repo.LoadDiagram(ctx, diagramID, content)
 }
 
+// DescribeDiagram produces a Markdown outline of the diagram's containers, shapes and connections
+func (uc *OracleUseCase) DescribeDiagram(ctx context.Context, diagramID string) (string, error) {
+	if diagramID == "" {
+		return "", &ValidationError{Message: "diagram ID is required"}
+	}
+
+	graph, err := uc.repo.GetGraph(ctx, diagramID)
+	if err != nil {
+		return "", err
+	}
+	return graph.Outline(diagramID), nil
+}
+
+// PinElement pins a shape so that it keeps its position across edits.
+// A nil position pins the shape where it was placed by the last export.
+func (uc *OracleUseCase) PinElement(ctx context.Context, op *entity.OracleOperation, position *entity.Position) (*entity.OracleResult, error) {
+	if op.DiagramID == "" {
+		return nil, &ValidationError{Message: "diagram ID is required"}
+	}
+	if op.Key == "" {
+		return nil, &ValidationError{Message: "element key is required"}
+	}
+	if position != nil && (position.X < 0 || position.Y < 0) {
+		return nil, &ValidationError{Message: "position must not be negative"}
+	}
+
+	return uc.repo.PinElement(ctx, op.DiagramID, op.BoardPath, op.Key, position)
+}
+
+// UnpinElement lets the layout engine place a pinned shape again.
+func (uc *OracleUseCase) UnpinElement(ctx context.Context, op *entity.OracleOperation) (*entity.OracleResult, error) {
+	if op.DiagramID == "" {
+		return nil, &ValidationError{Message: "diagram ID is required"}
+	}
+	if op.Key == "" {
+		return nil, &ValidationError{Message: "element key is required"}
+	}
+
+	return uc.repo.UnpinElement(ctx, op.DiagramID, op.BoardPath, op.Key)
+}
+
 // SerializeDiagram converts the current graph state back to D2 text
-func (uc *OracleUseCase) SerializeDiagram(ctx context.Context, diagramID string) (string, error) {
+func (uc *OracleUseCase) SerializeDiagram(ctx context.Context, diagramID string, mode entity.SerializeMode) (string, error) {
 	if diagramID == "" {
 		return "", &ValidationError{Message: "diagram ID is required"}
 	}
 
-	return uc.repo.SerializeDiagram(ctx, diagramID)
+	switch mode {
+	case "":
+		mode = entity.SerializePreserve
+	case entity.SerializePreserve, entity.SerializeFormat:
+	default:
+		return "", &ValidationError{Message: fmt.Sprintf("invalid serialize mode: %s. Must be 'preserve' or 'format'", mode)}
+	}
+
+	return uc.repo.SerializeDiagram(ctx, diagramID, mode)
 }
 
 // ExecuteOperation executes a single Oracle operation based on its type
@@ -26,11 +26,15 @@ type mockOracleRepository struct {
 	getChildrenCalled   bool
 	loadDiagramCalled   bool
 	serializeCalled     bool
+	getGraphCalled      bool
+	pinElementCalled    bool
+	unpinElementCalled  bool
 
 	// Mock data
 	mockObject   *entity.GraphObject
 	mockEdge     *entity.GraphEdge
 	mockChildren []string
+	mockGraph    *entity.DiagramGraph
 }
 
 func (m *mockOracleRepository) Render(ctx context.Context, content string, format entity.ExportFormat, theme *entity.Theme) (io.Reader, error) {
@@ -41,7 +45,7 @@ func (m *mockOracleRepository) Create(ctx context.Context, diagram *entity.Diagr
 	return nil
 }
 
-func (m *mockOracleRepository) Export(ctx context.Context, diagramID string, format entity.ExportFormat) (io.Reader, error) {
+func (m *mockOracleRepository) Export(ctx context.Context, diagramID string, format entity.ExportFormat, opts entity.ExportOptions) (io.Reader, error) {
 	return nil, nil
 }
 
@@ -95,6 +99,22 @@ func (m *mockOracleRepository) RenameElement(ctx context.Context, diagramID stri
 	}, nil
 }
 
+func (m *mockOracleRepository) PinElement(ctx context.Context, diagramID string, boardPath []string, key string, position *entity.Position) (*entity.OracleResult, error) {
+	m.pinElementCalled = true
+	if m.shouldFail {
+		return nil, errors.New(m.failMsg)
+	}
+	return &entity.OracleResult{Success: true}, nil
+}
+
+func (m *mockOracleRepository) UnpinElement(ctx context.Context, diagramID string, boardPath []string, key string) (*entity.OracleResult, error) {
+	m.unpinElementCalled = true
+	if m.shouldFail {
+		return nil, errors.New(m.failMsg)
+	}
+	return &entity.OracleResult{Success: true}, nil
+}
+
 func (m *mockOracleRepository) GetObject(ctx context.Context, diagramID string, boardPath []string, objectID string) (*entity.GraphObject, error) {
 	m.getObjectCalled = true
 	if m.shouldFail {
@@ -145,7 +165,21 @@ func (m *mockOracleRepository) LoadDiagram(ctx context.Context, diagramID string
 	return nil
 }
 
-func (m *mockOracleRepository) SerializeDiagram(ctx context.Context, diagramID string) (string, error) {
+func (m *mockOracleRepository) GetGraph(ctx context.Context, diagramID string) (*entity.DiagramGraph, error) {
+	m.getGraphCalled = true
+	if m.shouldFail {
+		return nil, errors.New(m.failMsg)
+	}
+	if m.mockGraph != nil {
+		return m.mockGraph, nil
+	}
+	return &entity.DiagramGraph{
+		Objects: map[string]*entity.GraphObject{},
+		Edges:   map[string]*entity.GraphEdge{},
+	}, nil
+}
+
+func (m *mockOracleRepository) SerializeDiagram(ctx context.Context, diagramID string, mode entity.SerializeMode) (string, error) {
 	m.serializeCalled = true
 	if m.shouldFail {
 		return "", errors.New(m.failMsg)
@@ -493,7 +527,7 @@ func TestOracleUseCase_LoadAndSerialize(t *testing.T) {
 		mockRepo := &mockOracleRepository{}
 		uc := NewOracleUseCase(mockRepo)
 
-		content, err := uc.SerializeDiagram(context.Background(), "test")
+		content, err := uc.SerializeDiagram(context.Background(), "test", entity.SerializePreserve)
 		if err != nil {
 			t.Errorf("SerializeDiagram() error = %v", err)
 		}
@@ -509,14 +543,124 @@ func TestOracleUseCase_LoadAndSerialize(t *testing.T) {
 		mockRepo := &mockOracleRepository{}
 		uc := NewOracleUseCase(mockRepo)
 
-		_, err := uc.SerializeDiagram(context.Background(), "")
+		_, err := uc.SerializeDiagram(context.Background(), "", entity.SerializePreserve)
 		if err == nil {
 			t.Error("SerializeDiagram() should fail with empty ID")
 		}
 	})
+
+	t.Run("serialize with invalid mode", func(t *testing.T) {
+		mockRepo := &mockOracleRepository{}
+		uc := NewOracleUseCase(mockRepo)
+
+		_, err := uc.SerializeDiagram(context.Background(), "test", entity.SerializeMode("pretty"))
+		if err == nil {
+			t.Error("SerializeDiagram() should fail with invalid mode")
+		}
+		if mockRepo.serializeCalled {
+			t.Error("SerializeDiagram() should not call repository with invalid mode")
+		}
+	})
 }
 
 // Helper function
 func stringPtr(s string) *string {
 	return &s
 }
+
+func TestOracleUseCase_DescribeDiagram(t *testing.T) {
+	mockRepo := &mockOracleRepository{
+		mockGraph: &entity.DiagramGraph{
+			Objects: map[string]*entity.GraphObject{
+				"api": {ID: "api", Label: "API"},
+				"db":  {ID: "db", Label: "Database", Shape: "cylinder"},
+			},
+			Edges: map[string]*entity.GraphEdge{
+				"(api -> db)[0]": {From: "api", To: "db", Label: "queries", Attributes: map[string]interface{}{"dstArrow": true}},
+			},
+		},
+	}
+	uc := NewOracleUseCase(mockRepo)
+
+	if _, err := uc.DescribeDiagram(context.Background(), ""); err == nil {
+		t.Error("DescribeDiagram() should fail without diagram ID")
+	}
+
+	outline, err := uc.DescribeDiagram(context.Background(), "arch")
+	if err != nil {
+		t.Fatalf("DescribeDiagram() error = %v", err)
+	}
+	if !mockRepo.getGraphCalled {
+		t.Error("DescribeDiagram() repository method not called")
+	}
+
+	want := "# arch\n\n2 shapes, 1 connection.\n\n## Shapes\n\n- **API** (`api`)\n- **Database** (`db`, cylinder)\n\n## Connections\n\n- API → Database: queries\n"
+	if outline != want {
+		t.Errorf("DescribeDiagram() =\n%s\nwant\n%s", outline, want)
+	}
+}
+
+func TestOracleUseCase_PinElement(t *testing.T) {
+	tests := []struct {
+		name       string
+		op         *entity.OracleOperation
+		position   *entity.Position
+		wantErr    bool
+		wantCalled bool
+	}{
+		{
+			name:       "pin at last position",
+			op:         &entity.OracleOperation{DiagramID: "test", Key: "server"},
+			wantCalled: true,
+		},
+		{
+			name:       "pin at explicit position",
+			op:         &entity.OracleOperation{DiagramID: "test", Key: "server"},
+			position:   &entity.Position{X: 10, Y: 20},
+			wantCalled: true,
+		},
+		{
+			name:     "negative position",
+			op:       &entity.OracleOperation{DiagramID: "test", Key: "server"},
+			position: &entity.Position{X: -1, Y: 20},
+			wantErr:  true,
+		},
+		{
+			name:    "missing key",
+			op:      &entity.OracleOperation{DiagramID: "test"},
+			wantErr: true,
+		},
+		{
+			name:    "missing diagram ID",
+			op:      &entity.OracleOperation{Key: "server"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &mockOracleRepository{}
+			uc := NewOracleUseCase(mockRepo)
+
+			_, err := uc.PinElement(context.Background(), tt.op, tt.position)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PinElement() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if mockRepo.pinElementCalled != tt.wantCalled {
+				t.Errorf("PinElement() repository called = %v, want %v", mockRepo.pinElementCalled, tt.wantCalled)
+			}
+		})
+	}
+
+	t.Run("unpin", func(t *testing.T) {
+		mockRepo := &mockOracleRepository{}
+		uc := NewOracleUseCase(mockRepo)
+
+		if _, err := uc.UnpinElement(context.Background(), &entity.OracleOperation{DiagramID: "test", Key: "server"}); err != nil {
+			t.Errorf("UnpinElement() error = %v", err)
+		}
+		if !mockRepo.unpinElementCalled {
+			t.Error("UnpinElement() repository method not called")
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/entity"
+	"github.com/hmsoft0815/mlcgo_mcp/mcp/d2mcp/internal/domain/repository"
+)
+
+// WorkspaceUseCase implements the business logic for syncing diagrams with .d2 files.
+type WorkspaceUseCase struct {
+	oracle    repository.OracleRepository
+	workspace repository.WorkspaceRepository
+}
+
+// NewWorkspaceUseCase creates a new workspace usecase instance.
+func NewWorkspaceUseCase(oracle repository.OracleRepository, workspace repository.WorkspaceRepository) *WorkspaceUseCase {
+	return &WorkspaceUseCase{
+		oracle:    oracle,
+		workspace: workspace,
+	}
+}
+
+// CommitToFile writes the current D2 text of a diagram to its file.
+// Comments and formatting of the file are preserved around the edits.
+func (uc *WorkspaceUseCase) CommitToFile(ctx context.Context, diagramID string) (string, error) {
+	if diagramID == "" {
+		return "", &ValidationError{Message: "diagram ID is required"}
+	}
+
+	content, err := uc.oracle.SerializeDiagram(ctx, diagramID, entity.SerializePreserve)
+	if err != nil {
+		return "", err
+	}
+
+	return uc.workspace.Write(ctx, diagramID, content)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mlcmcp/memory-server/internal/handlers"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Access kinds recorded in the audit log.
+const (
+	accessRead  = "read"
+	accessWrite = "write"
+)
+
+// auditEntry is one line of the audit log. It records who accessed which
+// entities, but not the facts themselves, so that the log holds no more personal
+// data than necessary.
+type auditEntry struct {
+	Time      string   `json:"time"`
+	Client    string   `json:"client,omitempty"`
+	Session   string   `json:"session,omitempty"`
+	Access    string   `json:"access"`
+	Method    string   `json:"method"`
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	Entities  []string `json:"entities,omitempty"`
+	OK        bool     `json:"ok"`
+}
+
+// Origins of the changes the handler reports to the audit hook, logged as the
+// name of the entry.
+const (
+	originScheduled = "scheduled"
+	originCLI       = "cli"
+)
+
+// auditLog writes an entry for every tool call, resource read and prompt, and
+// for the changes made without a request.
+type auditLog struct {
+	mu        sync.Mutex
+	w         io.Writer
+	namespace string
+	readOnly  map[string]bool
+}
+
+// openAuditLog appends to the file at path, or writes to stderr for "-".
+func openAuditLog(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return nopCloser{os.Stderr}, nil
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// newAuditLog returns an audit log for the tools, resolving calls without a
+// namespace argument to namespace.
+func newAuditLog(w io.Writer, namespace string, tools []memoryTool) *auditLog {
+	readOnly := make(map[string]bool)
+	for _, t := range tools {
+		readOnly[t.tool.Name] = t.tool.Annotations != nil && t.tool.Annotations.ReadOnlyHint
+	}
+	return &auditLog{w: w, namespace: namespace, readOnly: readOnly}
+}
+
+// middleware records the requests that read or write memory after they were handled.
+func (a *auditLog) middleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		res, err := next(ctx, method, req)
+
+		e := auditEntry{Method: method, Namespace: a.namespace, OK: err == nil}
+		var session *mcp.ServerSession
+		switch r := req.(type) {
+		case *mcp.CallToolRequest:
+			session = r.Session
+			e.Name = r.Params.Name
+			e.Access = accessWrite
+			if a.readOnly[e.Name] {
+				e.Access = accessRead
+			}
+			var args map[string]interface{}
+			json.Unmarshal(r.Params.Arguments, &args)
+			if ns, _ := args["namespace"].(string); ns != "" {
+				e.Namespace = ns
+			}
+			e.Entities = entitiesOf(args)
+			if result, ok := res.(*mcp.CallToolResult); ok && result.IsError {
+				e.OK = false
+			}
+		case *mcp.ReadResourceRequest:
+			session = r.Session
+			e.Name, e.Access = r.Params.URI, accessRead
+			if name, ok := strings.CutPrefix(r.Params.URI, entityURIPrefix); ok {
+				if name, err := url.PathUnescape(name); err == nil {
+					e.Entities = []string{name}
+				}
+			}
+		case *mcp.GetPromptRequest:
+			session = r.Session
+			e.Name, e.Access = r.Params.Name, accessRead
+			if ns := r.Params.Arguments["namespace"]; ns != "" {
+				e.Namespace = ns
+			}
+		default:
+			return res, err
+		}
+		if session != nil {
+			e.Session = session.ID()
+			if params := session.InitializeParams(); params != nil && params.ClientInfo != nil {
+				e.Client = params.ClientInfo.Name
+			}
+		}
+		a.write(e)
+		return res, err
+	}
+}
+
+// hook returns an audit hook for the handler that logs sweeps, consolidations
+// and imports as writes with the action as method and origin as name.
+func (a *auditLog) hook(origin string) func(handlers.AuditEvent) {
+	return func(e handlers.AuditEvent) {
+		a.write(auditEntry{Access: accessWrite, Method: e.Action, Name: origin, Namespace: e.Namespace, Entities: e.Entities, OK: e.OK})
+	}
+}
+
+// write appends an entry. Failures to write are ignored rather than failing the
+// request that was already handled.
+func (a *auditLog) write(e auditEntry) {
+	e.Time = time.Now().UTC().Format(time.RFC3339)
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.w.Write(append(line, '\n'))
+}
+
+// entitiesOf collects the entity names a tool call refers to.
+func entitiesOf(args map[string]interface{}) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(v interface{}) {
+		if s, ok := v.(string); ok && s != "" && !seen[s] {
+			seen[s] = true
+			names = append(names, s)
+		}
+	}
+	fields := func(m map[string]interface{}) {
+		for _, key := range []string{"entity", "entityName", "name", "oldName", "newName", "targetName", "from", "to"} {
+			add(m[key])
+		}
+		for _, key := range []string{"entityNames", "names", "sourceNames"} {
+			if arr, ok := m[key].([]interface{}); ok {
+				for _, v := range arr {
+					add(v)
+				}
+			}
+		}
+	}
+	fields(args)
+	for _, key := range []string{"entities", "observations", "deletions", "relations"} {
+		if arr, ok := args[key].([]interface{}); ok {
+			for _, v := range arr {
+				if m, ok := v.(map[string]interface{}); ok {
+					fields(m)
+				}
+			}
+		}
+	}
+	return names
+}
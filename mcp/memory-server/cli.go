@@ -0,0 +1,246 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mlcmcp/memory-server/internal/exchange"
+	"github.com/mlcmcp/memory-server/internal/handlers"
+	"github.com/mlcmcp/memory-server/internal/models"
+	"github.com/mlcmcp/memory-server/internal/ontology"
+	"github.com/mlcmcp/memory-server/internal/privacy"
+)
+
+// subcommands maps the subcommand names to their implementations.
+var subcommands = map[string]func(args []string, stdin io.Reader, stdout io.Writer) error{
+	"export": runExport,
+	"import": runImport,
+	"sweep":  runSweep,
+}
+
+// openHandler opens the database named by the -db and -namespace flags, with
+// the encryption key from the environment.
+func openHandler(dbFlag, namespace string) (*handlers.MemoryHandler, error) {
+	dbPath, err := resolveDBPath(dbFlag)
+	if err != nil {
+		return nil, err
+	}
+	h, err := handlers.NewMemoryHandler(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", dbPath, err)
+	}
+	h.SetDefaultNamespace(resolveNamespace(namespace))
+	if _, err := setupEncryption(h, ""); err != nil {
+		h.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// useAuditLog logs the changes a subcommand makes to the audit log at path, if
+// one is given. The returned closer closes the log.
+func useAuditLog(h *handlers.MemoryHandler, path string) (io.Closer, error) {
+	if path == "" {
+		return nopCloser{}, nil
+	}
+	w, err := openAuditLog(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the audit log: %w", err)
+	}
+	h.SetAuditHook(newAuditLog(w, "", nil).hook(originCLI))
+	return w, nil
+}
+
+// formatOf returns the -format flag or the format of the file name.
+func formatOf(flagValue, path string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	if path == "" || path == "-" {
+		return exchange.JSONL, nil
+	}
+	return exchange.FormatFromPath(path)
+}
+
+// runExport writes a namespace to a file or stdout.
+func runExport(args []string, _ io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: memory-server export [options]\n")
+		fmt.Fprintf(fs.Output(), "Options:\n")
+		fs.PrintDefaults()
+	}
+	dbFlag := fs.String("db", "", "Path to the SQLite database (default $MEMORY_DB or ~/.local/share/mcp-proxy/memory.db)")
+	namespace := fs.String("namespace", "", "Namespace to export (default $MEMORY_NAMESPACE or \"default\")")
+	format := fs.String("format", "", "Format: "+strings.Join(exchange.Formats, ", ")+" (default from the -o extension, else jsonl)")
+	out := fs.String("o", "", "Output file (default stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := formatOf(*format, *out)
+	if err != nil {
+		return err
+	}
+	h, err := openHandler(*dbFlag, *namespace)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	graph, err := h.Export("")
+	if err != nil {
+		return err
+	}
+	if *out == "" || *out == "-" {
+		return exchange.Encode(stdout, f, graph)
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := exchange.Encode(file, f, graph); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// runImport merges a file or stdin into a namespace.
+func runImport(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: memory-server import [options] <file|->\n")
+		fmt.Fprintf(fs.Output(), "Options:\n")
+		fs.PrintDefaults()
+	}
+	dbFlag := fs.String("db", "", "Path to the SQLite database (default $MEMORY_DB or ~/.local/share/mcp-proxy/memory.db)")
+	namespace := fs.String("namespace", "", "Namespace to import into (default $MEMORY_NAMESPACE or \"default\")")
+	format := fs.String("format", "", "Format: "+strings.Join(exchange.Formats, ", ")+" (default from the file extension, jsonl for stdin)")
+	strategy := fs.String("strategy", handlers.StrategySkip, "What to do with existing entities: "+strings.Join(handlers.Strategies, ", "))
+	source := fs.String("source", "import", "Source recorded for the imported facts")
+	redact := fs.String("redact", "", "What to do with sensitive data in the observations, e.g. all=mask")
+	ontologyPath := fs.String("ontology", "", "YAML or JSON file declaring the entity and relation types to check the import against")
+	auditPath := fs.String("audit-log", "", "Append a JSON line for the import to this file (- for stderr)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	policy, err := privacy.ParsePolicy(*redact)
+	if err != nil {
+		return err
+	}
+	var o *ontology.Ontology
+	if *ontologyPath != "" {
+		if o, err = ontology.Load(*ontologyPath); err != nil {
+			return fmt.Errorf("failed to load ontology: %w", err)
+		}
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("import needs one file, or - for stdin")
+	}
+	path := fs.Arg(0)
+
+	f, err := formatOf(*format, path)
+	if err != nil {
+		return err
+	}
+	r := stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	graph, err := exchange.Decode(r, f)
+	if err != nil {
+		return err
+	}
+
+	h, err := openHandler(*dbFlag, *namespace)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+	h.SetRedaction(policy)
+	h.SetOntology(o)
+	auditLog, err := useAuditLog(h, *auditPath)
+	if err != nil {
+		return err
+	}
+	defer auditLog.Close()
+
+	res, err := h.Import("", graph, *strategy, *source)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Imported %d entities (%d created, %d updated, %d skipped), %d observations and %d relations (%d existed)\n",
+		res.EntitiesCreated+res.EntitiesUpdated+res.EntitiesSkipped, res.EntitiesCreated, res.EntitiesUpdated, res.EntitiesSkipped,
+		res.ObservationsAdded, res.RelationsCreated, res.RelationsSkipped)
+	failed := 0
+	for _, issue := range res.Issues {
+		if issue.Status == models.StatusFailed {
+			failed++
+			fmt.Fprintf(stdout, "failed %s: %s\n", issue.Item, issue.Error)
+		} else {
+			fmt.Fprintf(stdout, "warning %s: %s\n", issue.Item, issue.Warning)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d items were not imported", failed)
+	}
+	return nil
+}
+
+// runSweep forgets expired observations and enforces retention policies once, or
+// reports what would be forgotten with -dry-run.
+func runSweep(args []string, _ io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: memory-server sweep [options]\n")
+		fmt.Fprintf(fs.Output(), "Options:\n")
+		fs.PrintDefaults()
+	}
+	dbFlag := fs.String("db", "", "Path to the SQLite database (default $MEMORY_DB or ~/.local/share/mcp-proxy/memory.db)")
+	retention := fs.String("retention", "", "Maximum age of observations per entity type, e.g. event=30d,session=24h")
+	dryRun := fs.Bool("dry-run", false, "Only report what would be forgotten")
+	auditPath := fs.String("audit-log", "", "Append a JSON line per namespace swept to this file (- for stderr)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	policies, err := handlers.ParseRetention(*retention)
+	if err != nil {
+		return err
+	}
+
+	h, err := openHandler(*dbFlag, "")
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+	h.SetRetention(policies)
+	auditLog, err := useAuditLog(h, *auditPath)
+	if err != nil {
+		return err
+	}
+	defer auditLog.Close()
+
+	res, err := h.Sweep(*dryRun)
+	if err != nil {
+		return err
+	}
+	for _, o := range res.Observations {
+		fmt.Fprintf(stdout, "%s\t%s\t%d\t%s\t%s\n", o.Namespace, o.EntityName, o.ID, o.Reason, o.Content)
+	}
+	verb := "Forgot"
+	if *dryRun {
+		verb = "Would forget"
+	}
+	fmt.Fprintf(stdout, "%s %d observations\n", verb, len(res.Observations))
+	return nil
+}
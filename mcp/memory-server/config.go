@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mlcmcp/memory-server/internal/handlers"
+	"github.com/mlcmcp/memory-server/internal/privacy"
+)
+
+// Environment variables that configure the server. The database and namespace
+// apply when their flags are not set; the token and the encryption key are kept
+// out of the command line.
+const (
+	envDB                = "MEMORY_DB"
+	envNamespace         = "MEMORY_NAMESPACE"
+	envAuthToken         = "MEMORY_AUTH_TOKEN"
+	envEncryptionKey     = "MEMORY_ENCRYPTION_KEY"
+	envEncryptionKeyFile = "MEMORY_ENCRYPTION_KEY_FILE"
+)
+
+// firstNonEmpty returns the first value that is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// resolveDBPath returns the database path from the -db flag, MEMORY_DB or the
+// default location, and creates its directory.
+func resolveDBPath(flagValue string) (string, error) {
+	path := firstNonEmpty(flagValue, os.Getenv(envDB))
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot determine the home directory (%w); set -db or %s", err, envDB)
+		}
+		path = filepath.Join(home, ".local", "share", "mcp-proxy", "memory.db")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("cannot create the database directory: %w", err)
+	}
+	return path, nil
+}
+
+// resolveNamespace returns the default namespace from the -namespace flag or MEMORY_NAMESPACE.
+func resolveNamespace(flagValue string) string {
+	return firstNonEmpty(flagValue, os.Getenv(envNamespace), handlers.DefaultNamespace)
+}
+
+// resolveCipher returns the cipher for the key in the file named by the
+// -encryption-key-file flag or MEMORY_ENCRYPTION_KEY_FILE, or in
+// MEMORY_ENCRYPTION_KEY. It returns nil if no key is configured.
+func resolveCipher(fileFlag string) (*privacy.Cipher, error) {
+	key := os.Getenv(envEncryptionKey)
+	if path := firstNonEmpty(fileFlag, os.Getenv(envEncryptionKeyFile)); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the encryption key: %w", err)
+		}
+		key = string(data)
+	}
+	if key == "" {
+		return nil, nil
+	}
+	raw, err := privacy.ParseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return privacy.NewCipher(raw)
+}
+
+// setupEncryption configures the handler with the encryption key, if any, and
+// encrypts the observations still stored in plain.
+func setupEncryption(h *handlers.MemoryHandler, fileFlag string) (int, error) {
+	c, err := resolveCipher(fileFlag)
+	if err != nil {
+		return 0, err
+	}
+	return h.UseCipher(c)
+}
@@ -4,7 +4,10 @@ go 1.24.0
 
 toolchain go1.24.2
 
-require github.com/modelcontextprotocol/go-sdk v1.3.0
+require (
+	github.com/modelcontextprotocol/go-sdk v1.3.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require golang.org/x/tools v0.41.0 // indirect
 
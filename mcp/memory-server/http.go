@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/auth"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// mcpPath is the endpoint of the streamable HTTP transport.
+const mcpPath = "/mcp"
+
+// httpHandler serves the MCP server over streamable HTTP at mcpPath. All clients
+// share the server and its database. If token is not empty, requests must carry
+// it as a bearer token.
+func httpHandler(server *mcp.Server, token string) http.Handler {
+	var h http.Handler = mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return server }, nil)
+	if token != "" {
+		h = auth.RequireBearerToken(staticToken(token), nil)(h)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(mcpPath, h)
+	return mux
+}
+
+// staticToken accepts a single shared token.
+func staticToken(token string) auth.TokenVerifier {
+	return func(_ context.Context, got string, _ *http.Request) (*auth.TokenInfo, error) {
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			return nil, auth.ErrInvalidToken
+		}
+		// The token does not expire; the expiration only satisfies the verifier.
+		return &auth.TokenInfo{Expiration: time.Now().Add(time.Hour)}, nil
+	}
+}
+
+// serveHTTP listens on addr until ctx is done, then shuts down gracefully.
+func serveHTTP(ctx context.Context, server *mcp.Server, addr, token string) error {
+	srv := &http.Server{Addr: addr, Handler: httpHandler(server, token), ReadHeaderTimeout: 10 * time.Second}
+	errs := make(chan error, 1)
+	go func() { errs <- srv.ListenAndServe() }()
+	if token == "" {
+		log.Printf("Serving MCP on http://%s%s without authentication", addr, mcpPath)
+	} else {
+		log.Printf("Serving MCP on http://%s%s", addr, mcpPath)
+	}
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		// Open event streams keep connections busy; close what is left after the timeout.
+		if err := srv.Shutdown(shutdown); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return srv.Close()
+		}
+		return nil
+	}
+}
@@ -0,0 +1,85 @@
+// Package embedding turns text into vectors for semantic search.
+package embedding
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Embedder converts texts into vectors. Implementations must return one
+// vector per input text, in order, all of the same dimension.
+type Embedder interface {
+	// Name identifies the model; vectors of different models are never compared.
+	Name() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// New returns the embedder for a kind: "hash" (offline), "http" (an
+// OpenAI-compatible /embeddings endpoint) or "none".
+func New(kind, url, model, apiKey string) (Embedder, error) {
+	switch kind {
+	case "", "none":
+		return nil, nil
+	case "hash":
+		return NewHashEmbedder(DefaultHashDim), nil
+	case "http":
+		if url == "" || model == "" {
+			return nil, fmt.Errorf("http embedder needs an endpoint URL and a model")
+		}
+		return NewHTTPEmbedder(url, model, apiKey), nil
+	default:
+		return nil, fmt.Errorf("unknown embedder %q (want hash, http or none)", kind)
+	}
+}
+
+// Normalize scales v to unit length in place, so that cosine similarity is a dot product.
+func Normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(1 / math.Sqrt(sum))
+	for i := range v {
+		v[i] *= norm
+	}
+}
+
+// Cosine returns the cosine similarity of two vectors.
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// Encode serializes a vector as little-endian float32 values for storage.
+func Encode(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return buf
+}
+
+// Decode is the inverse of Encode.
+func Decode(buf []byte) []float32 {
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v
+}
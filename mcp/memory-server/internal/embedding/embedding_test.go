@@ -0,0 +1,115 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHashEmbedder_Similarity(t *testing.T) {
+	e := NewHashEmbedder(DefaultHashDim)
+	vectors, err := e.Embed(context.Background(), []string{
+		"Oly drinks coffee every morning",
+		"morning coffees",
+		"the server runs on port 8080",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range vectors {
+		if len(v) != DefaultHashDim {
+			t.Fatalf("dimension = %d, want %d", len(v), DefaultHashDim)
+		}
+	}
+
+	related := Cosine(vectors[0], vectors[1])
+	unrelated := Cosine(vectors[0], vectors[2])
+	if related <= unrelated {
+		t.Errorf("Cosine(related) = %.3f, Cosine(unrelated) = %.3f", related, unrelated)
+	}
+	if self := Cosine(vectors[0], vectors[0]); self < 0.999 {
+		t.Errorf("Cosine(self) = %.3f, want 1", self)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	v := []float32{0.5, -1.25, 3}
+	got := Decode(Encode(v))
+	if len(got) != len(v) {
+		t.Fatalf("Decode() = %v", got)
+	}
+	for i := range v {
+		if got[i] != v[i] {
+			t.Errorf("Decode()[%d] = %v, want %v", i, got[i], v[i])
+		}
+	}
+}
+
+func TestHTTPEmbedder(t *testing.T) {
+	var gotAuth string
+	var gotReq embeddingRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			http.NotFound(w, r)
+			return
+		}
+		gotAuth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&gotReq)
+		// Return the vectors out of order; the index field decides.
+		w.Write([]byte(`{"data": [
+			{"index": 1, "embedding": [0, 2]},
+			{"index": 0, "embedding": [3, 4]}
+		]}`))
+	}))
+	defer server.Close()
+
+	e := NewHTTPEmbedder(server.URL+"/v1/", "nomic-embed-text", "secret")
+	vectors, err := e.Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if gotAuth != "Bearer secret" || gotReq.Model != "nomic-embed-text" || len(gotReq.Input) != 2 {
+		t.Errorf("request = %+v, auth %q", gotReq, gotAuth)
+	}
+	if vectors[0][0] != 0.6 || vectors[0][1] != 0.8 || vectors[1][1] != 1 {
+		t.Errorf("vectors = %v, want normalized [0.6 0.8] [0 1]", vectors)
+	}
+	if e.Name() != "http:nomic-embed-text" {
+		t.Errorf("Name() = %q", e.Name())
+	}
+}
+
+func TestHTTPEmbedder_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			http.Error(w, "model not loaded", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"data": []}`))
+	}))
+	defer server.Close()
+
+	if _, err := NewHTTPEmbedder(server.URL, "m", "").Embed(context.Background(), []string{"a"}); err == nil {
+		t.Error("Embed() should fail on an HTTP error")
+	}
+	if _, err := NewHTTPEmbedder(server.URL, "m", "key").Embed(context.Background(), []string{"a"}); err == nil {
+		t.Error("Embed() should fail when vectors are missing")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if e, err := New("none", "", "", ""); e != nil || err != nil {
+		t.Errorf("New(none) = %v, %v", e, err)
+	}
+	if e, err := New("hash", "", "", ""); err != nil || e.Name() != "hash-ngram-512" {
+		t.Errorf("New(hash) = %v, %v", e, err)
+	}
+	if _, err := New("http", "", "", ""); err == nil {
+		t.Error("New(http) without URL should fail")
+	}
+	if _, err := New("bert", "", "", ""); err == nil {
+		t.Error("New(bert) should fail")
+	}
+}
@@ -0,0 +1,66 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+// DefaultHashDim is the vector size of the offline embedder.
+const DefaultHashDim = 512
+
+// HashEmbedder is an offline embedder based on the hashing trick. Words and
+// their character trigrams are hashed into a fixed number of buckets, so that
+// texts sharing words or word parts ("coffee", "coffees") end up close together.
+// It needs no model files, but does not know synonyms; use an HTTP embedder for that.
+type HashEmbedder struct {
+	dim int
+}
+
+// NewHashEmbedder creates a hashing embedder with the given vector size.
+func NewHashEmbedder(dim int) *HashEmbedder {
+	return &HashEmbedder{dim: dim}
+}
+
+func (e *HashEmbedder) Name() string {
+	return fmt.Sprintf("hash-ngram-%d", e.dim)
+}
+
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = e.embed(text)
+	}
+	return out, nil
+}
+
+func (e *HashEmbedder) embed(text string) []float32 {
+	v := make([]float32, e.dim)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		// Whole words weigh more than their fragments.
+		e.add(v, "w:"+w, 2)
+		padded := []rune("<" + w + ">")
+		for j := 0; j+3 <= len(padded); j++ {
+			e.add(v, "t:"+string(padded[j:j+3]), 1)
+		}
+	}
+	Normalize(v)
+	return v
+}
+
+// add hashes a feature into a bucket. A second hash bit picks the sign so that
+// collisions cancel out instead of piling up.
+func (e *HashEmbedder) add(v []float32, feature string, weight float32) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+	if sum&(1<<63) != 0 {
+		weight = -weight
+	}
+	v[sum%uint64(e.dim)] += weight
+}
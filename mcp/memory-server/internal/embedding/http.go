@@ -0,0 +1,100 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPEmbedder calls an OpenAI-compatible embeddings endpoint, such as the ones
+// served by Ollama, llama.cpp, LM Studio or vLLM.
+type HTTPEmbedder struct {
+	url    string
+	model  string
+	apiKey string
+	client *http.Client
+}
+
+// NewHTTPEmbedder creates an embedder for a base URL (e.g. http://localhost:11434/v1).
+// The /embeddings path is appended unless the URL already ends with it.
+func NewHTTPEmbedder(baseURL, model, apiKey string) *HTTPEmbedder {
+	url := strings.TrimRight(baseURL, "/")
+	if !strings.HasSuffix(url, "/embeddings") {
+		url += "/embeddings"
+	}
+	return &HTTPEmbedder{
+		url:    url,
+		model:  model,
+		apiKey: apiKey,
+		client: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (e *HTTPEmbedder) Name() string {
+	return "http:" + e.model
+}
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+func (e *HTTPEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(embeddingRequest{Model: e.model, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("embedding request failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var res embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("invalid embedding response: %w", err)
+	}
+	if len(res.Data) != len(texts) {
+		return nil, fmt.Errorf("invalid embedding response: got %d vectors for %d texts", len(res.Data), len(texts))
+	}
+
+	out := make([][]float32, len(texts))
+	for _, d := range res.Data {
+		if d.Index < 0 || d.Index >= len(out) {
+			return nil, fmt.Errorf("invalid embedding response: index %d out of range", d.Index)
+		}
+		Normalize(d.Embedding)
+		out[d.Index] = d.Embedding
+	}
+	for i, v := range out {
+		if v == nil {
+			return nil, fmt.Errorf("invalid embedding response: no vector for text %d", i)
+		}
+	}
+	return out, nil
+}
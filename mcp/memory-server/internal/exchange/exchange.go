@@ -0,0 +1,95 @@
+// Package exchange reads and writes knowledge graphs in file formats shared with
+// other tools: the JSONL format of the reference memory server, JSON-LD and GraphML.
+package exchange
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+// Supported formats.
+const (
+	JSONL   = "jsonl"
+	JSONLD  = "jsonld"
+	GraphML = "graphml"
+)
+
+// Formats lists the supported formats.
+var Formats = []string{JSONL, JSONLD, GraphML}
+
+// FormatFromPath guesses the format from a file extension.
+func FormatFromPath(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return JSONL, nil
+	case ".jsonld", ".json":
+		return JSONLD, nil
+	case ".graphml", ".xml":
+		return GraphML, nil
+	default:
+		return "", fmt.Errorf("cannot tell the format of %q; use -format with one of %s", path, strings.Join(Formats, ", "))
+	}
+}
+
+// Encode writes a graph in the given format.
+func Encode(w io.Writer, format string, g models.KnowledgeGraph) error {
+	switch format {
+	case JSONL:
+		return encodeJSONL(w, g)
+	case JSONLD:
+		return encodeJSONLD(w, g)
+	case GraphML:
+		return encodeGraphML(w, g)
+	default:
+		return unknownFormat(format)
+	}
+}
+
+// Decode reads a graph in the given format. Every entity and relation must name
+// its endpoints.
+func Decode(r io.Reader, format string) (models.KnowledgeGraph, error) {
+	var g models.KnowledgeGraph
+	var err error
+	switch format {
+	case JSONL:
+		g, err = decodeJSONL(r)
+	case JSONLD:
+		g, err = decodeJSONLD(r)
+	case GraphML:
+		g, err = decodeGraphML(r)
+	default:
+		return g, unknownFormat(format)
+	}
+	if err != nil {
+		return g, fmt.Errorf("invalid %s: %w", format, err)
+	}
+	return g, validate(g)
+}
+
+func unknownFormat(format string) error {
+	return fmt.Errorf("unknown format %q (want %s)", format, strings.Join(Formats, ", "))
+}
+
+// validate checks that entities have names and relations have both endpoints.
+func validate(g models.KnowledgeGraph) error {
+	for i, e := range g.Entities {
+		if e.Name == "" {
+			return fmt.Errorf("entity %d has no name", i+1)
+		}
+	}
+	for i, r := range g.Relations {
+		if r.From == "" || r.To == "" {
+			return fmt.Errorf("relation %d has no from or to", i+1)
+		}
+	}
+	return nil
+}
+
+// newGraph returns an empty graph whose slices encode as [] rather than null.
+func newGraph() models.KnowledgeGraph {
+	return models.KnowledgeGraph{Entities: []models.Entity{}, Relations: []models.Relation{}}
+}
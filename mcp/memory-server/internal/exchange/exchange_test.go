@@ -0,0 +1,114 @@
+package exchange
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+func sampleGraph() models.KnowledgeGraph {
+	return models.KnowledgeGraph{
+		Entities: []models.Entity{
+			{Name: "Alice Smith", EntityType: "person", Observations: []string{"likes Go", `says "hi" & <bye>`}},
+			{Name: "Acme/Labs", EntityType: "company", Observations: []string{}},
+		},
+		Relations: []models.Relation{{From: "Alice Smith", To: "Acme/Labs", RelationType: "works_at"}},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, format, sampleGraph()); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			got, err := Decode(&buf, format)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if want := sampleGraph(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Decode() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestDecode_ReferenceJSONL(t *testing.T) {
+	// As written by the reference memory server.
+	doc := `{"type":"entity","name":"Alice","entityType":"person","observations":["likes Go"]}
+{"type":"entity","name":"Acme","entityType":"company","observations":[]}
+
+{"type":"relation","from":"Alice","to":"Acme","relationType":"works_at"}
+`
+	got, err := Decode(strings.NewReader(doc), JSONL)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(got.Entities) != 2 || got.Entities[0].Observations[0] != "likes Go" || len(got.Relations) != 1 || got.Relations[0].RelationType != "works_at" {
+		t.Errorf("Decode() = %+v", got)
+	}
+}
+
+func TestDecode_ForeignGraphML(t *testing.T) {
+	// Written by another tool: different key ids, no name attribute.
+	doc := `<?xml version="1.0"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="d0" for="node" attr.name="entityType" attr.type="string"/>
+  <key id="d1" for="node" attr.name="observations" attr.type="string"/>
+  <key id="d2" for="edge" attr.name="relationType" attr.type="string"/>
+  <graph edgedefault="directed">
+    <node id="Alice"><data key="d0">person</data><data key="d1">likes Go
+lives in Berlin</data></node>
+    <node id="Acme"/>
+    <edge source="Alice" target="Acme"><data key="d2">works_at</data></edge>
+  </graph>
+</graphml>`
+	got, err := Decode(strings.NewReader(doc), GraphML)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	want := models.KnowledgeGraph{
+		Entities: []models.Entity{
+			{Name: "Alice", EntityType: "person", Observations: []string{"likes Go", "lives in Berlin"}},
+			{Name: "Acme", Observations: []string{}},
+		},
+		Relations: []models.Relation{{From: "Alice", To: "Acme", RelationType: "works_at"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	for _, tc := range []struct{ format, doc, want string }{
+		{JSONL, `{"type":"entity","name":"Alice"`, "invalid jsonl"},
+		{JSONL, `{"type":"entity","entityType":"person"}`, "no name"},
+		{JSONL, `{"type":"relation","from":"Alice"}`, "no from or to"},
+		{JSONLD, `[]`, "invalid jsonld"},
+		{GraphML, `<graphml><graph><edge source="a" target="b"/></graph></graphml>`, "unknown node"},
+		{"csv", ``, "unknown format"},
+	} {
+		_, err := Decode(strings.NewReader(tc.doc), tc.format)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Decode(%s, %q) error = %v, want %q", tc.format, tc.doc, err, tc.want)
+		}
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"memory.jsonl": JSONL, "backup.NDJSON": JSONL, "graph.jsonld": JSONLD,
+		"graph.json": JSONLD, "graph.graphml": GraphML,
+	} {
+		if got, err := FormatFromPath(path); err != nil || got != want {
+			t.Errorf("FormatFromPath(%q) = %q, %v, want %q", path, got, err, want)
+		}
+	}
+	if _, err := FormatFromPath("memory.db"); err == nil {
+		t.Error("FormatFromPath(memory.db) should fail")
+	}
+}
@@ -0,0 +1,167 @@
+package exchange
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+const graphMLNamespace = "http://graphml.graphdrawing.org/xmlns"
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr,omitempty"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr,omitempty"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// graphMLKeys declares the attributes written. Observations are stored as a JSON
+// array in one string attribute, since GraphML has no list type.
+var graphMLKeys = []graphMLKey{
+	{ID: "name", For: "node", AttrName: "name", AttrType: "string"},
+	{ID: "entityType", For: "node", AttrName: "entityType", AttrType: "string"},
+	{ID: "observations", For: "node", AttrName: "observations", AttrType: "string"},
+	{ID: "relationType", For: "edge", AttrName: "relationType", AttrType: "string"},
+}
+
+func encodeGraphML(w io.Writer, g models.KnowledgeGraph) error {
+	doc := graphMLDocument{
+		Xmlns: graphMLNamespace,
+		Keys:  graphMLKeys,
+		Graph: graphMLGraph{ID: "memory", EdgeDefault: "directed"},
+	}
+	// Node ids are generated, since GraphML ids cannot hold arbitrary names.
+	ids := make(map[string]string, len(g.Entities))
+	for i, e := range g.Entities {
+		id := fmt.Sprintf("n%d", i)
+		ids[e.Name] = id
+		obs := e.Observations
+		if obs == nil {
+			obs = []string{}
+		}
+		data, err := json.Marshal(obs)
+		if err != nil {
+			return err
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: id, Data: []graphMLData{
+			{Key: "name", Value: e.Name},
+			{Key: "entityType", Value: e.EntityType},
+			{Key: "observations", Value: string(data)},
+		}})
+	}
+	for _, r := range g.Relations {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: ids[r.From], Target: ids[r.To],
+			Data: []graphMLData{{Key: "relationType", Value: r.RelationType}},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// decodeGraphML reads GraphML files written by this server and by other tools.
+// Attributes are matched by their attr.name; nodes without a name attribute are
+// named by their id, and observations that are not a JSON array are split into lines.
+func decodeGraphML(r io.Reader) (models.KnowledgeGraph, error) {
+	g := newGraph()
+	var doc graphMLDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return g, err
+	}
+
+	attr := make(map[string]string, len(doc.Keys))
+	for _, k := range doc.Keys {
+		attr[k.ID] = firstNonEmpty(k.AttrName, k.ID)
+	}
+	values := func(data []graphMLData) map[string]string {
+		m := make(map[string]string, len(data))
+		for _, d := range data {
+			m[firstNonEmpty(attr[d.Key], d.Key)] = d.Value
+		}
+		return m
+	}
+
+	names := make(map[string]string, len(doc.Graph.Nodes))
+	for _, n := range doc.Graph.Nodes {
+		v := values(n.Data)
+		e := models.Entity{Name: firstNonEmpty(v["name"], n.ID), EntityType: v["entityType"], Observations: []string{}}
+		if raw := strings.TrimSpace(v["observations"]); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &e.Observations); err != nil {
+				e.Observations = nonEmptyLines(raw)
+			}
+		}
+		names[n.ID] = e.Name
+		g.Entities = append(g.Entities, e)
+	}
+	for i, e := range doc.Graph.Edges {
+		from, ok := names[e.Source]
+		to, ok2 := names[e.Target]
+		if !ok || !ok2 {
+			return g, fmt.Errorf("edge %d refers to an unknown node", i+1)
+		}
+		g.Relations = append(g.Relations, models.Relation{From: from, To: to, RelationType: values(e.Data)["relationType"]})
+	}
+	return g, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func nonEmptyLines(s string) []string {
+	out := []string{}
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
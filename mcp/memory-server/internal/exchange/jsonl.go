@@ -0,0 +1,77 @@
+package exchange
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+// jsonlRecord is one line of the reference memory server's memory.jsonl: an entity
+// or a relation, told apart by type.
+type jsonlRecord struct {
+	Type         string   `json:"type"`
+	Name         string   `json:"name,omitempty"`
+	EntityType   string   `json:"entityType,omitempty"`
+	Observations []string `json:"observations,omitempty"`
+	From         string   `json:"from,omitempty"`
+	To           string   `json:"to,omitempty"`
+	RelationType string   `json:"relationType,omitempty"`
+}
+
+func encodeJSONL(w io.Writer, g models.KnowledgeGraph) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, e := range g.Entities {
+		obs := e.Observations
+		if obs == nil {
+			obs = []string{}
+		}
+		// Observations are written even when empty, as the reference server does.
+		rec := struct {
+			Type         string   `json:"type"`
+			Name         string   `json:"name"`
+			EntityType   string   `json:"entityType"`
+			Observations []string `json:"observations"`
+		}{"entity", e.Name, e.EntityType, obs}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	for _, r := range g.Relations {
+		rec := jsonlRecord{Type: "relation", From: r.From, To: r.To, RelationType: r.RelationType}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeJSONL(r io.Reader) (models.KnowledgeGraph, error) {
+	g := newGraph()
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var rec jsonlRecord
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			return g, nil
+		}
+		if err != nil {
+			return g, fmt.Errorf("record %d: %w", line, err)
+		}
+		switch rec.Type {
+		case "entity":
+			obs := rec.Observations
+			if obs == nil {
+				obs = []string{}
+			}
+			g.Entities = append(g.Entities, models.Entity{Name: rec.Name, EntityType: rec.EntityType, Observations: obs})
+		case "relation":
+			g.Relations = append(g.Relations, models.Relation{From: rec.From, To: rec.To, RelationType: rec.RelationType})
+		default:
+			return g, fmt.Errorf("record %d: unknown type %q (want entity or relation)", line, rec.Type)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+// jsonldContext maps the document's terms to IRIs. Entities are identified by
+// their escaped name relative to @base; relations are nodes of their own that
+// point at two entities.
+var jsonldContext = map[string]interface{}{
+	"@vocab":       "urn:mlcmcp:memory:",
+	"@base":        "urn:mlcmcp:memory:entity:",
+	"name":         "http://schema.org/name",
+	"observations": map[string]interface{}{"@container": "@list"},
+	"from":         map[string]interface{}{"@type": "@id"},
+	"to":           map[string]interface{}{"@type": "@id"},
+}
+
+type jsonldDocument struct {
+	Context interface{}  `json:"@context"`
+	Graph   []jsonldNode `json:"@graph"`
+}
+
+type jsonldNode struct {
+	ID           string    `json:"@id,omitempty"`
+	Type         string    `json:"@type"`
+	Name         string    `json:"name,omitempty"`
+	EntityType   string    `json:"entityType,omitempty"`
+	Observations []string  `json:"observations,omitempty"`
+	From         jsonldRef `json:"from,omitempty"`
+	To           jsonldRef `json:"to,omitempty"`
+	RelationType string    `json:"relationType,omitempty"`
+}
+
+// jsonldRef is a node reference, written compacted as a string and also read
+// in its expanded form {"@id": ...}.
+type jsonldRef string
+
+func (r *jsonldRef) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*r = jsonldRef(s)
+		return nil
+	}
+	var obj struct {
+		ID string `json:"@id"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("node reference must be a string or {\"@id\": ...}")
+	}
+	*r = jsonldRef(obj.ID)
+	return nil
+}
+
+func entityID(name string) string {
+	return url.PathEscape(name)
+}
+
+func encodeJSONLD(w io.Writer, g models.KnowledgeGraph) error {
+	doc := jsonldDocument{Context: jsonldContext, Graph: []jsonldNode{}}
+	for _, e := range g.Entities {
+		doc.Graph = append(doc.Graph, jsonldNode{
+			ID: entityID(e.Name), Type: "Entity", Name: e.Name, EntityType: e.EntityType, Observations: e.Observations,
+		})
+	}
+	for _, r := range g.Relations {
+		doc.Graph = append(doc.Graph, jsonldNode{
+			Type: "Relation", From: jsonldRef(entityID(r.From)), To: jsonldRef(entityID(r.To)), RelationType: r.RelationType,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func decodeJSONLD(r io.Reader) (models.KnowledgeGraph, error) {
+	g := newGraph()
+	var doc jsonldDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return g, err
+	}
+
+	// Relations refer to entities by @id; map them back to names.
+	names := make(map[string]string)
+	for _, n := range doc.Graph {
+		if n.Type == "Entity" && n.ID != "" {
+			names[n.ID] = n.Name
+		}
+	}
+	name := func(ref jsonldRef) string {
+		if n, ok := names[string(ref)]; ok {
+			return n
+		}
+		if n, err := url.PathUnescape(string(ref)); err == nil {
+			return n
+		}
+		return string(ref)
+	}
+
+	for i, n := range doc.Graph {
+		switch n.Type {
+		case "Entity":
+			obs := n.Observations
+			if obs == nil {
+				obs = []string{}
+			}
+			g.Entities = append(g.Entities, models.Entity{Name: n.Name, EntityType: n.EntityType, Observations: obs})
+		case "Relation":
+			g.Relations = append(g.Relations, models.Relation{From: name(n.From), To: name(n.To), RelationType: n.RelationType})
+		default:
+			return g, fmt.Errorf("node %d: unknown @type %q (want Entity or Relation)", i+1, n.Type)
+		}
+	}
+	return g, nil
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// aliasSchema maps normalized names to entities. Every entity has a row for its
+// own name; merged and renamed entities leave their old names behind as aliases.
+const aliasSchema = `
+CREATE TABLE aliases (
+	namespace TEXT NOT NULL,
+	alias_key TEXT NOT NULL,
+	alias TEXT NOT NULL,
+	entity_name TEXT NOT NULL,
+	PRIMARY KEY(namespace, alias_key),
+	FOREIGN KEY(namespace, entity_name) REFERENCES entities(namespace, name) ON DELETE CASCADE
+);
+CREATE INDEX aliases_entity ON aliases(namespace, entity_name);
+`
+
+// migrateAliases creates the alias table and registers the names of the existing
+// entities. Of names that only differ in case or spacing, the first in name order
+// owns the normalized name; the others stay reachable by their exact name.
+func migrateAliases(tx *sql.Tx) error {
+	if err := execAll(aliasSchema)(tx); err != nil {
+		return err
+	}
+	rows, err := tx.Query("SELECT namespace, name FROM entities ORDER BY namespace, name")
+	if err != nil {
+		return err
+	}
+	var entities [][2]string
+	for rows.Next() {
+		var ns, name string
+		if err := rows.Scan(&ns, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		entities = append(entities, [2]string{ns, name})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, e := range entities {
+		if err := addAlias(tx, e[0], e[1], e[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeName folds case and spacing, so that "Oly", "oly" and " OLY " name the
+// same entity.
+func normalizeName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// nearMatchThreshold is the share of words two names must have in common to be
+// flagged as possibly naming the same entity.
+const nearMatchThreshold = 0.5
+
+// nameVariants returns the normalized forms a name may go by: the whole name
+// and, for a name with a parenthesized part such as "Oliver (Oly)", the parts
+// inside and outside the parentheses.
+func nameVariants(name string) []string {
+	variants := []string{normalizeName(name)}
+	open := strings.Index(name, "(")
+	if open < 0 {
+		return variants
+	}
+	length := strings.Index(name[open:], ")")
+	if length < 0 {
+		return variants
+	}
+	for _, part := range []string{name[open+1 : open+length], name[:open] + name[open+length+1:]} {
+		if v := normalizeName(part); v != "" {
+			variants = append(variants, v)
+		}
+	}
+	return variants
+}
+
+// nearMatch reports whether two names that do not normalize the same may still
+// name the same entity: one of them is a parenthesized alias or the main part
+// of the other, or they share at least nearMatchThreshold of their words
+// (Jaccard similarity of the word sets).
+func nearMatch(a, b string) bool {
+	for _, va := range nameVariants(a) {
+		for _, vb := range nameVariants(b) {
+			if va == vb {
+				return true
+			}
+		}
+	}
+
+	words := make(map[string]bool)
+	for _, w := range strings.Fields(normalizeObservation(a)) {
+		words[w] = false
+	}
+	union := len(words)
+	shared := 0
+	for _, w := range strings.Fields(normalizeObservation(b)) {
+		seen, ok := words[w]
+		switch {
+		case !ok:
+			words[w] = true
+			union++
+		case !seen:
+			words[w] = true
+			shared++
+		}
+	}
+	return union > 0 && float64(shared)/float64(union) >= nearMatchThreshold
+}
+
+// similarEntities returns the other entities of a namespace whose name or one
+// of whose aliases nearly matches name, in name order.
+func similarEntities(q queryer, ns, name string) ([]string, error) {
+	rows, err := q.Query("SELECT alias, entity_name FROM aliases WHERE namespace = ? AND entity_name <> ? ORDER BY entity_name", ns, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var similar []string
+	for rows.Next() {
+		var alias, entity string
+		if err := rows.Scan(&alias, &entity); err != nil {
+			return nil, err
+		}
+		if (len(similar) == 0 || similar[len(similar)-1] != entity) && nearMatch(name, alias) {
+			similar = append(similar, entity)
+		}
+	}
+	return similar, rows.Err()
+}
+
+// normalizeObservation folds case, punctuation and spacing, so that "Likes Go."
+// and "likes go" are recognized as the same fact.
+func normalizeObservation(content string) string {
+	stripped := strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) {
+			return ' '
+		}
+		return unicode.ToLower(r)
+	}, content)
+	return strings.Join(strings.Fields(stripped), " ")
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// resolveEntity returns the entity a name refers to: the entity of that exact
+// name, or else the one whose name or alias normalizes the same.
+func resolveEntity(q queryer, ns, name string) (string, bool, error) {
+	var canonical sql.NullString
+	err := q.QueryRow(`SELECT COALESCE(
+		(SELECT name FROM entities WHERE namespace = ?1 AND name = ?2),
+		(SELECT entity_name FROM aliases WHERE namespace = ?1 AND alias_key = ?3))`,
+		ns, name, normalizeName(name)).Scan(&canonical)
+	return canonical.String, canonical.Valid, err
+}
+
+// resolveNames resolves each name, keeping the names that refer to no entity.
+func resolveNames(q queryer, ns string, names []string) ([]string, error) {
+	out := make([]string, len(names))
+	for i, name := range names {
+		canonical, ok, err := resolveEntity(q, ns, name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			canonical = name
+		}
+		out[i] = canonical
+	}
+	return out, nil
+}
+
+// ensureEntity returns the entity a name refers to, creating it with the given
+// type if there is none. It reports whether the entity was created.
+func ensureEntity(tx *sql.Tx, ns, name, entType string) (string, bool, error) {
+	canonical, ok, err := resolveEntity(tx, ns, name)
+	if err != nil || ok {
+		return canonical, false, err
+	}
+	if _, err := tx.Exec("INSERT INTO entities (namespace, name, type) VALUES (?, ?, ?)", ns, name, entType); err != nil {
+		return "", false, err
+	}
+	return name, true, addAlias(tx, ns, name, name)
+}
+
+// addAlias lets alias refer to an entity unless its normalized form already
+// refers to one.
+func addAlias(ex execer, ns, alias, entity string) error {
+	_, err := ex.Exec("INSERT OR IGNORE INTO aliases (namespace, alias_key, alias, entity_name) VALUES (?, ?, ?, ?)",
+		ns, normalizeName(alias), alias, entity)
+	return err
+}
+
+// nearDuplicate returns the current, unexpired observation of an entity that states the same
+// fact as content, ignoring case, punctuation and spacing.
+func (h *MemoryHandler) nearDuplicate(q queryer, ns, name, content string) (string, bool, error) {
+	rows, err := q.Query("SELECT content FROM observations WHERE namespace = ? AND entity_name = ? AND superseded_by IS NULL AND "+notExpired("expires_at")+" ORDER BY id",
+		ns, name, formatTime(time.Now()))
+	if err != nil {
+		return "", false, err
+	}
+	existing, err := scanStrings(rows)
+	if err != nil {
+		return "", false, err
+	}
+	key := normalizeObservation(content)
+	for _, e := range existing {
+		if e, err = h.unseal(e); err != nil {
+			return "", false, err
+		}
+		if e == content || (key != "" && normalizeObservation(e) == key) {
+			return e, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// addObservation stores a fact unless the entity already has it or a near
+// duplicate of it, which is then returned.
+func (h *MemoryHandler) addObservation(tx *sql.Tx, ns, name, content string, p provenance) (string, bool, error) {
+	dup, ok, err := h.nearDuplicate(tx, ns, name, content)
+	if err != nil || ok {
+		return dup, false, err
+	}
+	_, err = h.insertObservation(tx, ns, name, content, p)
+	return "", err == nil, err
+}
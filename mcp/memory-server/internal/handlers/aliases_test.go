@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+func TestNormalize(t *testing.T) {
+	for in, want := range map[string]string{"Oly": "oly", "  OLY ": "oly", "Oliver  (Oly)": "oliver (oly)", "Ölke": "ölke"} {
+		if got := normalizeName(in); got != want {
+			t.Errorf("normalizeName(%q) = %q, want %q", in, got, want)
+		}
+	}
+	for in, want := range map[string]string{"Likes Go.": "likes go", "likes  go": "likes go", "it's C++!": "it s c++", "...": ""} {
+		if got := normalizeObservation(in); got != want {
+			t.Errorf("normalizeObservation(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNearMatch(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"Oliver (Oly)", "Oly", true},
+		{"Oliver Smith (Oly)", "oly", true},
+		{"Oliver Smith (Oly)", "Oliver Smith", true},
+		{"Acme", "Acme Corp", true},
+		{"Acme Corp.", "ACME corp", true},
+		{"John Smith", "John Doe", false},
+		{"Oly", "Olga", false},
+		{"Berlin (", "Berlin", true},
+	}
+	for _, tt := range tests {
+		if got := nearMatch(tt.a, tt.b); got != tt.want {
+			t.Errorf("nearMatch(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := nearMatch(tt.b, tt.a); got != tt.want {
+			t.Errorf("nearMatch(%q, %q) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestCreateEntities_FlagsSimilarNames(t *testing.T) {
+	h := newTestHandler(t)
+	mustCall(t, h.CreateEntities, `{"entities": [
+		{"name": "Oly", "entityType": "person"},
+		{"name": "Olga", "entityType": "person"}
+	]}`)
+	mustCall(t, h.RenameEntity, `{"oldName": "Olga", "newName": "Olga Petrova"}`)
+
+	res := mustCall(t, h.CreateEntities, `{"entities": [
+		{"name": "Oliver (Oly)", "entityType": "person"},
+		{"name": "Olga", "entityType": "person"},
+		{"name": "Petrova", "entityType": "person"}
+	]}`).(models.CreateEntitiesResult)
+	want := []models.EntityStatus{
+		{Name: "Oliver (Oly)", ItemStatus: models.ItemStatus{Status: models.StatusCreated}, Similar: []string{"Oly"}},
+		{Name: "Olga Petrova", ItemStatus: models.ItemStatus{Status: models.StatusExists}},
+		{Name: "Petrova", ItemStatus: models.ItemStatus{Status: models.StatusCreated}, Similar: []string{"Olga Petrova"}},
+	}
+	if !reflect.DeepEqual(res.Results, want) {
+		t.Errorf("results = %+v, want %+v", res.Results, want)
+	}
+	// Flagged names are still separate entities until they are merged.
+	if got := readGraph(t, h).Entities; len(got) != 4 {
+		t.Errorf("entities = %+v", got)
+	}
+}
+
+func TestCreateEntities_ResolvesNames(t *testing.T) {
+	h := newTestHandler(t)
+	mustCall(t, h.CreateEntities, `{"entities": [{"name": "Oly", "entityType": "person", "observations": ["likes espresso"]}]}`)
+
+	res := mustCall(t, h.CreateEntities, `{"entities": [
+		{"name": "oly", "entityType": "person", "observations": []},
+		{"name": " OLY ", "entityType": "person", "observations": []}
+	]}`).(models.CreateEntitiesResult)
+	for _, r := range res.Results {
+		if r.Name != "Oly" || r.Status != models.StatusExists {
+			t.Errorf("result = %+v, want the existing Oly", r)
+		}
+	}
+
+	mustCall(t, h.CreateRelations, `{"relations": [{"from": "oly", "to": "Coffee", "relationType": "drinks"}]}`)
+	added := mustCall(t, h.AddObservations, `{"observations": [{"entityName": "OLY", "contents": ["lives in Bonn"]}]}`).(models.AddObservationsResult)
+	if added.Results[0].EntityName != "Oly" {
+		t.Errorf("AddObservations() = %+v", added)
+	}
+	mustCall(t, h.Memorize, `{"entity": "oly", "observation": "plays chess"}`)
+
+	graph := readGraph(t, h)
+	if len(graph.Entities) != 2 || !reflect.DeepEqual(graph.Entities[1].Observations, []string{"likes espresso", "lives in Bonn", "plays chess"}) {
+		t.Errorf("entities = %+v", graph.Entities)
+	}
+	if !reflect.DeepEqual(graph.Relations, []models.Relation{{From: "Oly", To: "Coffee", RelationType: "drinks"}}) {
+		t.Errorf("relations = %+v", graph.Relations)
+	}
+}
+
+func TestAddObservations_SkipsNearDuplicates(t *testing.T) {
+	h := newTestHandler(t)
+	mustCall(t, h.CreateEntities, `{"entities": [{"name": "Oly", "entityType": "person", "observations": ["likes espresso", "Likes espresso."]}]}`)
+
+	res := mustCall(t, h.AddObservations, `{"observations": [{"entityName": "Oly", "contents": ["likes espresso", "LIKES  ESPRESSO!", "likes tea"]}]}`).(models.AddObservationsResult)
+	want := []models.ObservationStatus{
+		{Content: "likes espresso", ItemStatus: models.ItemStatus{Status: models.StatusExists}},
+		{Content: "LIKES  ESPRESSO!", ItemStatus: models.ItemStatus{Status: models.StatusExists}, DuplicateOf: "likes espresso"},
+		{Content: "likes tea", ItemStatus: models.ItemStatus{Status: models.StatusCreated}},
+	}
+	if !reflect.DeepEqual(res.Results[0].Statuses, want) {
+		t.Errorf("statuses = %+v, want %+v", res.Results[0].Statuses, want)
+	}
+
+	// Memorizing the same fact again stores nothing.
+	mustCall(t, h.Memorize, `{"entity": "Oly", "observation": "likes tea."}`)
+	if got := readGraph(t, h).Entities[0].Observations; !reflect.DeepEqual(got, []string{"likes espresso", "likes tea"}) {
+		t.Errorf("observations = %v", got)
+	}
+}
+
+func TestMergeEntities_StoresAliases(t *testing.T) {
+	h := newTestHandler(t)
+	mustCall(t, h.CreateEntities, `{"entities": [
+		{"name": "Oly", "entityType": "person", "observations": ["likes espresso"]},
+		{"name": "Oliver (Oly)", "entityType": "person", "observations": ["Likes espresso", "lives in Bonn"]},
+		{"name": "O.", "entityType": "person", "observations": []}
+	]}`)
+	mustCall(t, h.MergeEntities, `{"targetName": "O.", "sourceNames": ["Oliver (Oly)"]}`)
+	res := mustCall(t, h.MergeEntities, `{"targetName": "Oly", "sourceNames": ["O."]}`).(models.MergeResult)
+
+	// The aliases of a merged entity move along.
+	if !reflect.DeepEqual(res.Entity.Aliases, []string{"O.", "Oliver (Oly)"}) {
+		t.Errorf("aliases = %v", res.Entity.Aliases)
+	}
+
+	// Old names keep working for writes and reads.
+	created := mustCall(t, h.CreateEntities, `{"entities": [{"name": "oliver (oly)", "entityType": "person", "observations": []}]}`).(models.CreateEntitiesResult)
+	if created.Results[0].Name != "Oly" || created.Results[0].Status != models.StatusExists {
+		t.Errorf("CreateEntities() = %+v", created.Results)
+	}
+	opened := mustCall(t, h.OpenNodes, `{"names": ["Oliver (Oly)"]}`).(models.KnowledgeGraph)
+	if len(opened.Entities) != 1 || opened.Entities[0].Name != "Oly" {
+		t.Errorf("OpenNodes() = %+v", opened)
+	}
+
+	// Deleting the entity frees its aliases.
+	mustCall(t, h.DeleteEntities, `{"entityNames": ["Oly"]}`)
+	created = mustCall(t, h.CreateEntities, `{"entities": [{"name": "O.", "entityType": "person", "observations": []}]}`).(models.CreateEntitiesResult)
+	if created.Results[0].Status != models.StatusCreated {
+		t.Errorf("CreateEntities() after delete = %+v", created.Results)
+	}
+}
+
+func TestRenameEntity_Aliases(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+
+	// Changing only the case is a rename, not a collision.
+	mustCall(t, h.RenameEntity, `{"oldName": "Acme", "newName": "ACME"}`)
+	mustCall(t, h.RenameEntity, `{"oldName": "ACME", "newName": "Acme Corp"}`)
+	acme := mustCall(t, h.OpenNodes, `{"names": ["acme"]}`).(models.KnowledgeGraph).Entities
+	if len(acme) != 1 || acme[0].Name != "Acme Corp" || !reflect.DeepEqual(acme[0].Aliases, []string{"ACME"}) {
+		t.Errorf("entities = %+v", acme)
+	}
+
+	if _, err := h.RenameEntity(args(t, `{"oldName": "Alice", "newName": "acme"}`)); err == nil {
+		t.Error("renaming onto another entity's alias should fail")
+	}
+}
+
+func TestDeletesAndCorrections_ResolveNames(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	mustCall(t, h.RenameEntity, `{"oldName": "Alice", "newName": "Alice Smith"}`)
+
+	// By case variant and by the alias the rename left behind.
+	res := mustCall(t, h.CorrectObservation, `{"entityName": "alice smith", "oldObservation": "lives in Berlin", "newObservation": "lives in Munich"}`).(models.CorrectionResult)
+	if res.EntityName != "Alice Smith" || res.Current.Content != "lives in Munich" {
+		t.Errorf("CorrectObservation() = %+v", res)
+	}
+	del := mustCall(t, h.DeleteObservations, `{"deletions": [{"entityName": "ALICE", "observations": ["likes Go"]}]}`).(models.DeleteResult)
+	if del.Deleted != 1 {
+		t.Errorf("DeleteObservations() deleted %d, want 1", del.Deleted)
+	}
+	del = mustCall(t, h.DeleteRelations, `{"relations": [{"from": "alice", "to": "ACME", "relationType": "works_at"}]}`).(models.DeleteResult)
+	if del.Deleted != 1 {
+		t.Errorf("DeleteRelations() deleted %d, want 1", del.Deleted)
+	}
+	del = mustCall(t, h.DeleteEntities, `{"entityNames": ["Alice", "acme"]}`).(models.DeleteResult)
+	if del.Deleted != 2 {
+		t.Errorf("DeleteEntities() deleted %d, want 2", del.Deleted)
+	}
+	if graph := readGraph(t, h); len(graph.Entities) != 0 {
+		t.Errorf("graph after delete = %+v", graph)
+	}
+}
+
+func TestMigrate_RegistersAliases(t *testing.T) {
+	path := copyFixture(t, "provenance.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Created before names were resolved.
+	_, err = db.Exec("INSERT INTO entities (namespace, name, type) VALUES ('default', 'oly', 'person')")
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := NewMemoryHandler(path)
+	if err != nil {
+		t.Fatalf("NewMemoryHandler() error = %v", err)
+	}
+	defer h.Close()
+
+	for name, want := range map[string]string{"Oly": "Oly", "oly": "oly", "OLY": "Oly", "coffee": "Coffee"} {
+		if got, ok, err := resolveEntity(h.db, "default", name); err != nil || !ok || got != want {
+			t.Errorf("resolveEntity(%q) = %q, %v, %v, want %q", name, got, ok, err, want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+// Actions reported to the audit hook.
+const (
+	AuditSweep       = "sweep"
+	AuditConsolidate = "consolidate"
+	AuditImport      = "import"
+)
+
+// AuditEvent is a change to memory that no tool call asked for: a sweep, a
+// consolidation of all namespaces or an import. Like the audit log of requests,
+// it names the entities but not the facts.
+type AuditEvent struct {
+	Action    string
+	Namespace string
+	Entities  []string
+	OK        bool
+}
+
+// SetAuditHook sets a function that is called after Sweep, ConsolidateAll and
+// Import changed a namespace or failed. A nil hook records nothing.
+func (h *MemoryHandler) SetAuditHook(hook func(AuditEvent)) {
+	h.auditHook = hook
+}
+
+// audit reports a change of a namespace to the audit hook.
+func (h *MemoryHandler) audit(action, ns string, entities []string, err error) {
+	if h.auditHook != nil {
+		h.auditHook(AuditEvent{Action: action, Namespace: ns, Entities: entities, OK: err == nil})
+	}
+}
+
+// auditForgotten reports forgotten observations per namespace, in the order the
+// namespaces first appear.
+func (h *MemoryHandler) auditForgotten(action string, observations []models.ForgottenObservation) {
+	var namespaces []string
+	entities := make(map[string][]string)
+	seen := make(map[[2]string]bool)
+	for _, o := range observations {
+		if _, ok := entities[o.Namespace]; !ok {
+			namespaces = append(namespaces, o.Namespace)
+		}
+		if key := [2]string{o.Namespace, o.EntityName}; !seen[key] {
+			seen[key] = true
+			entities[o.Namespace] = append(entities[o.Namespace], o.EntityName)
+		}
+	}
+	for _, ns := range namespaces {
+		h.audit(action, ns, entities[ns], nil)
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+func TestAuditHook(t *testing.T) {
+	h := newTestHandler(t)
+	var events []AuditEvent
+	h.SetAuditHook(func(e AuditEvent) { events = append(events, e) })
+
+	graph := models.KnowledgeGraph{
+		Entities:  []models.Entity{{Name: "Standup", EntityType: "event", Observations: []string{"is daily"}}},
+		Relations: []models.Relation{{From: "Oly", To: "Standup", RelationType: "attends"}},
+	}
+	if _, err := h.Import("", graph, "", "test"); err != nil {
+		t.Fatal(err)
+	}
+	// Tool calls are audited as requests, not by the hook.
+	mustCall(t, h.ImportGraph, `{"content": `+quote(`{"type":"entity","name":"Bob","entityType":"person"}`)+`}`)
+	mustCall(t, h.CreateEntities, `{"namespace": "work", "entities": [{"name": "Bob", "entityType": "person",
+		"observations": ["uses Go", "uses Rust", "uses Zig"]}]}`)
+	if _, _, err := h.ConsolidateAll(context.Background(), 3); err != nil {
+		t.Fatal(err)
+	}
+	h.SetRetention(map[string]time.Duration{"event": 0})
+	if _, err := h.Sweep(true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Sweep(false); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []AuditEvent{
+		{Action: AuditImport, Namespace: "default", Entities: []string{"Standup", "Oly"}, OK: true},
+		{Action: AuditConsolidate, Namespace: "work", Entities: []string{"Bob"}, OK: true},
+		{Action: AuditSweep, Namespace: "default", Entities: []string{"Standup"}, OK: true},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %+v, want %+v", events, want)
+	}
+}
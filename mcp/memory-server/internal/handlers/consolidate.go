@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+	"github.com/mlcmcp/memory-server/internal/summarize"
+)
+
+// pinnedSchema marks observations that consolidation must leave alone, such as
+// the summaries it wrote.
+const pinnedSchema = `
+ALTER TABLE observations ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0;
+`
+
+// defaultMinObservations is how many loose observations an entity needs before it
+// is consolidated, unless the entity is named explicitly.
+const defaultMinObservations = 20
+
+// errChanged reports that an entity changed while it was being summarized.
+var errChanged = errors.New("observations changed during consolidation")
+
+// SetSummarizer selects how observations are condensed. A nil summarizer selects
+// the extractive one.
+func (h *MemoryHandler) SetSummarizer(s summarize.Summarizer) {
+	if s == nil {
+		s = summarize.Extractive{}
+	}
+	h.summarizer = s
+}
+
+// Consolidate condenses the observations of entities into one summary each. The
+// summary is stored as a pinned observation and the originals are archived:
+// they are superseded by the summary, hidden from reads like corrected facts and
+// brought back if the summary is deleted. Without entityNames, the entities with
+// at least minObservations unpinned observations are consolidated. Facts that
+// expire are left alone so that the summary does not outlive them.
+func (h *MemoryHandler) Consolidate(args map[string]interface{}) (interface{}, error) {
+	ns := h.namespaceOf(args)
+	names := getStrings(args, "entityNames", "names")
+	minObs := defaultMinObservations
+	if len(names) > 0 {
+		minObs = 2
+	}
+	minObs = max(getInt(args, "minObservations", minObs), 2)
+
+	var err error
+	if len(names) > 0 {
+		if names, err = resolveNames(h.db, ns, names); err != nil {
+			return nil, err
+		}
+	}
+	return h.consolidate(context.Background(), ns, names, minObs)
+}
+
+// ConsolidateAll consolidates the entities of every namespace that have at least
+// minObservations unpinned observations, or the default number if minObs is 0.
+// It returns how many entities were consolidated and observations archived, and
+// reports each namespace it changed to the audit hook.
+func (h *MemoryHandler) ConsolidateAll(ctx context.Context, minObs int) (int, int, error) {
+	if minObs <= 0 {
+		minObs = defaultMinObservations
+	}
+	namespaces, err := h.db.Query("SELECT DISTINCT namespace FROM entities ORDER BY namespace")
+	if err != nil {
+		return 0, 0, err
+	}
+	list, err := scanStrings(namespaces)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	entities, archived := 0, 0
+	for _, ns := range list {
+		res, err := h.consolidate(ctx, ns, nil, max(minObs, 2))
+		var names []string
+		for _, c := range res.Consolidated {
+			names = append(names, c.EntityName)
+			entities++
+			archived += c.Archived
+		}
+		if err != nil || len(names) > 0 {
+			h.audit(AuditConsolidate, ns, names, err)
+		}
+		if err != nil {
+			return entities, archived, fmt.Errorf("namespace %q: %w", ns, err)
+		}
+	}
+	return entities, archived, nil
+}
+
+// consolidate summarizes the named entities, or all that qualify if names is nil.
+// The summarizer runs outside of transactions; an entity that changed meanwhile
+// is skipped and left for the next run.
+func (h *MemoryHandler) consolidate(ctx context.Context, ns string, names []string, minObs int) (models.ConsolidationResult, error) {
+	result := models.ConsolidationResult{Summarizer: h.summarizer.Name(), Consolidated: []models.ConsolidatedEntity{}, Skipped: []string{}}
+	if names == nil {
+		rows, err := h.db.Query(`SELECT entity_name FROM observations
+			WHERE namespace = ? AND superseded_by IS NULL AND pinned = 0 AND expires_at IS NULL
+			GROUP BY entity_name HAVING COUNT(*) >= ? ORDER BY entity_name`, ns, minObs)
+		if err != nil {
+			return result, err
+		}
+		if names, err = scanStrings(rows); err != nil {
+			return result, err
+		}
+	}
+
+	for _, name := range names {
+		ids, contents, err := h.looseObservations(ns, name)
+		if err != nil {
+			return result, err
+		}
+		if len(ids) < minObs {
+			result.Skipped = append(result.Skipped, name)
+			continue
+		}
+
+		groups := summarize.Group(contents)
+		summary, err := h.summarizer.Summarize(ctx, name, groups)
+		if err != nil {
+			return result, fmt.Errorf("failed to summarize %q: %w", name, err)
+		}
+
+		var entry models.ConsolidatedEntity
+		err = h.inTx(func(tx *sql.Tx) error {
+			id, err := h.insertObservation(tx, ns, name, summary, provenance{source: "summarizer:" + h.summarizer.Name()})
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec("UPDATE observations SET pinned = 1 WHERE id = ?", id); err != nil {
+				return err
+			}
+			in, args := int64InClause(ids)
+			res, err := tx.Exec("UPDATE observations SET superseded_by = ? WHERE superseded_by IS NULL AND pinned = 0 AND expires_at IS NULL AND id IN "+in,
+				append([]interface{}{id}, args...)...)
+			if err != nil {
+				return err
+			}
+			if n, _ := res.RowsAffected(); int(n) != len(ids) {
+				return errChanged
+			}
+			entry = models.ConsolidatedEntity{EntityName: name, Archived: len(ids), Groups: len(groups)}
+			entry.Summary, err = h.observationByID(tx, id)
+			return err
+		})
+		if errors.Is(err, errChanged) {
+			result.Skipped = append(result.Skipped, name)
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to store the summary of %q: %w", name, err)
+		}
+		result.Consolidated = append(result.Consolidated, entry)
+	}
+	return result, nil
+}
+
+// looseObservations returns the current observations of an entity that are
+// neither pinned nor expiring.
+func (h *MemoryHandler) looseObservations(ns, name string) ([]int64, []string, error) {
+	rows, err := h.db.Query(`SELECT id, content FROM observations
+		WHERE namespace = ? AND entity_name = ? AND superseded_by IS NULL AND pinned = 0 AND expires_at IS NULL ORDER BY id`, ns, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	var contents []string
+	for rows.Next() {
+		var id int64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			return nil, nil, err
+		}
+		if content, err = h.unseal(content); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+		contents = append(contents, content)
+	}
+	return ids, contents, rows.Err()
+}
+
+// int64InClause is inClause for ids.
+func int64InClause(values []int64) (string, []interface{}) {
+	strs := make([]string, len(values))
+	in, args := inClause(strs)
+	for i, v := range values {
+		args[i] = v
+	}
+	return in, args
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+// failingSummarizer fails every summary.
+type failingSummarizer struct{}
+
+func (failingSummarizer) Name() string { return "failing" }
+
+func (failingSummarizer) Summarize(context.Context, string, [][]string) (string, error) {
+	return "", context.DeadlineExceeded
+}
+
+func TestConsolidate_ArchivesObservations(t *testing.T) {
+	h := newTestHandler(t)
+	mustCall(t, h.CreateEntities, `{"entities": [{"name": "Oly", "entityType": "person",
+		"observations": ["likes tea", "likes coffee", "lives in Bonn"]}]}`)
+
+	res := mustCall(t, h.Consolidate, `{"entityNames": ["oly"]}`).(models.ConsolidationResult)
+	if res.Summarizer != "extractive" || len(res.Consolidated) != 1 {
+		t.Fatalf("Consolidate() = %+v", res)
+	}
+	c := res.Consolidated[0]
+	if c.EntityName != "Oly" || c.Archived != 3 || c.Groups != 2 || c.Summary.Content != "likes tea, coffee; lives in Bonn" ||
+		!c.Summary.Pinned || c.Summary.Source != "summarizer:extractive" {
+		t.Errorf("consolidated = %+v", c)
+	}
+
+	// Reads see the summary, the originals are kept as history.
+	if got := readGraph(t, h).Entities[0].Observations; !reflect.DeepEqual(got, []string{"likes tea, coffee; lives in Bonn"}) {
+		t.Errorf("observations = %v", got)
+	}
+	graph := mustCall(t, h.OpenNodes, `{"names": ["Oly"], "includeSuperseded": true, "provenance": true}`).(models.KnowledgeGraph)
+	details := graph.Entities[0].ObservationDetails
+	if len(details) != 4 || details[0].SupersededBy == nil || *details[0].SupersededBy != c.Summary.ID {
+		t.Errorf("details = %+v", details)
+	}
+
+	// The summary is pinned, so a second run has nothing to do.
+	mustCall(t, h.AddObservations, `{"observations": [{"entityName": "Oly", "contents": ["plays chess"]}]}`)
+	again := mustCall(t, h.Consolidate, `{"entityNames": ["Oly"]}`).(models.ConsolidationResult)
+	if len(again.Consolidated) != 0 || !reflect.DeepEqual(again.Skipped, []string{"Oly"}) {
+		t.Errorf("second Consolidate() = %+v", again)
+	}
+}
+
+func TestConsolidate_DeletingSummaryRestoresObservations(t *testing.T) {
+	h := newTestHandler(t)
+	mustCall(t, h.CreateEntities, `{"entities": [{"name": "Oly", "entityType": "person", "observations": ["likes tea", "likes coffee"]}]}`)
+	mustCall(t, h.Consolidate, `{"entityNames": ["Oly"]}`)
+	mustCall(t, h.DeleteObservations, `{"deletions": [{"entityName": "Oly", "observations": ["likes tea, coffee"]}]}`)
+
+	if got := readGraph(t, h).Entities[0].Observations; !reflect.DeepEqual(got, []string{"likes tea", "likes coffee"}) {
+		t.Errorf("observations = %v", got)
+	}
+}
+
+func TestConsolidate_Threshold(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	mustCall(t, h.CreateEntities, `{"entities": [{"name": "Oly", "entityType": "person",
+		"observations": ["likes tea", "likes coffee", "lives in Bonn", "plays chess"]}]}`)
+
+	res := mustCall(t, h.Consolidate, `{"minObservations": 3}`).(models.ConsolidationResult)
+	if len(res.Consolidated) != 1 || res.Consolidated[0].EntityName != "Oly" {
+		t.Errorf("Consolidate() = %+v, want only Oly", res)
+	}
+
+	// Entities in other namespaces are consolidated by ConsolidateAll.
+	mustCall(t, h.CreateEntities, `{"namespace": "work", "entities": [{"name": "Bob", "entityType": "person",
+		"observations": ["uses Go", "uses Rust", "uses Zig"]}]}`)
+	entities, archived, err := h.ConsolidateAll(context.Background(), 3)
+	if err != nil || entities != 1 || archived != 3 {
+		t.Errorf("ConsolidateAll() = %d, %d, %v, want 1, 3", entities, archived, err)
+	}
+}
+
+func TestConsolidate_SummarizerFailureChangesNothing(t *testing.T) {
+	h := newTestHandler(t)
+	mustCall(t, h.CreateEntities, `{"entities": [{"name": "Oly", "entityType": "person", "observations": ["likes tea", "likes coffee"]}]}`)
+	h.SetSummarizer(failingSummarizer{})
+
+	if _, err := h.Consolidate(args(t, `{"entityNames": ["Oly"]}`)); err == nil {
+		t.Error("Consolidate() should fail")
+	}
+	if got := readGraph(t, h).Entities[0].Observations; len(got) != 2 {
+		t.Errorf("observations = %v", got)
+	}
+}
+
+func TestConsolidate_LeavesExpiringFacts(t *testing.T) {
+	h := newTestHandler(t)
+	mustCall(t, h.CreateEntities, `{"entities": [{"name": "Oly", "entityType": "person", "observations": ["likes tea", "likes coffee"]}]}`)
+	mustCall(t, h.Memorize, `{"entity": "Oly", "observation": "is on vacation", "ttl": "7d"}`)
+
+	res := mustCall(t, h.Consolidate, `{"entityNames": ["Oly"]}`).(models.ConsolidationResult)
+	if len(res.Consolidated) != 1 || res.Consolidated[0].Archived != 2 {
+		t.Fatalf("Consolidate() = %+v", res)
+	}
+	if got := readGraph(t, h).Entities[0].Observations; !reflect.DeepEqual(got, []string{"is on vacation", "likes tea, coffee"}) {
+		t.Errorf("observations = %v", got)
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+// Sizes of the memory resources and the context prompt.
+const (
+	defaultRecentLimit  = 20
+	defaultContextLimit = 10
+)
+
+// Entity returns an entity of a namespace by name or alias, with its observations
+// and the relations starting at it. It reports false if there is no such entity.
+func (h *MemoryHandler) Entity(ns, name string) (models.KnowledgeGraph, bool, error) {
+	if ns == "" {
+		ns = h.namespace
+	}
+	canonical, ok, err := resolveEntity(h.db, ns, name)
+	if err != nil || !ok {
+		return models.KnowledgeGraph{}, false, err
+	}
+	graph, err := h.loadGraphWith(ns, []string{canonical}, graphOptions{provenance: true, outgoing: true})
+	return graph, len(graph.Entities) == 1, err
+}
+
+// Recent returns the most recently changed entities of a namespace, newest first,
+// with the relations between them. Entities whose last change is unknown come last.
+func (h *MemoryHandler) Recent(ns string, limit int) (models.KnowledgeGraph, error) {
+	if ns == "" {
+		ns = h.namespace
+	}
+	if limit <= 0 {
+		limit = defaultRecentLimit
+	}
+	rows, err := h.db.Query(`SELECT name FROM entities WHERE namespace = ?
+		ORDER BY updated_at IS NULL, updated_at DESC, name LIMIT ?`, ns, limit)
+	if err != nil {
+		return models.KnowledgeGraph{}, err
+	}
+	names, err := scanStrings(rows)
+	if err != nil {
+		return models.KnowledgeGraph{}, err
+	}
+	graph, err := h.loadGraphWith(ns, names, graphOptions{provenance: true})
+	if err != nil {
+		return graph, err
+	}
+
+	byName := make(map[string]models.Entity, len(graph.Entities))
+	for _, e := range graph.Entities {
+		byName[e.Name] = e
+	}
+	graph.Entities = graph.Entities[:0]
+	for _, name := range names {
+		if e, ok := byName[name]; ok {
+			graph.Entities = append(graph.Entities, e)
+		}
+	}
+	return graph, nil
+}
+
+// Context assembles the entities most relevant to a topic into a Markdown
+// preamble for the start of a conversation. Entities are ranked by semantic
+// search if it is enabled and by full-text search otherwise. It returns an empty
+// string if nothing is relevant.
+func (h *MemoryHandler) Context(ns, topic string, limit int) (string, error) {
+	if strings.TrimSpace(topic) == "" {
+		return "", fmt.Errorf("topic is required")
+	}
+	if limit <= 0 {
+		limit = defaultContextLimit
+	}
+	args := map[string]interface{}{"query": topic, "limit": float64(limit)}
+	if ns != "" {
+		args["namespace"] = ns
+	}
+	search := h.SearchNodes
+	if h.embedder != nil {
+		search = h.SemanticSearch
+	}
+	res, err := search(args)
+	if err != nil {
+		return "", err
+	}
+	result := res.(models.SearchResult)
+	if len(result.Entities) == 0 {
+		return "", nil
+	}
+	return formatContext(topic, result.KnowledgeGraph), nil
+}
+
+// formatContext renders entities, most relevant first, and their relations.
+func formatContext(topic string, graph models.KnowledgeGraph) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Relevant memory about %q:\n", topic)
+	for _, e := range graph.Entities {
+		fmt.Fprintf(&b, "\n## %s", e.Name)
+		if e.EntityType != "" {
+			fmt.Fprintf(&b, " (%s)", e.EntityType)
+		}
+		b.WriteString("\n")
+		if len(e.Aliases) > 0 {
+			fmt.Fprintf(&b, "Also known as: %s\n", strings.Join(e.Aliases, ", "))
+		}
+		for _, o := range e.Observations {
+			fmt.Fprintf(&b, "- %s\n", o)
+		}
+	}
+	if len(graph.Relations) > 0 {
+		b.WriteString("\n## Relations\n")
+		for _, r := range graph.Relations {
+			fmt.Fprintf(&b, "- %s %s %s\n", r.From, r.RelationType, r.To)
+		}
+	}
+	return b.String()
+}
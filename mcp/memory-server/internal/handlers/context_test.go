@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecent(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	mustCall(t, h.Memorize, `{"entity": "Bob", "observation": "likes Rust"}`)
+	if _, err := h.db.Exec("UPDATE entities SET updated_at = ? WHERE name = 'Alice'", formatTime(time.Now().Add(time.Hour))); err != nil {
+		t.Fatal(err)
+	}
+
+	graph, err := h.Recent("", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.Entities) != 2 || graph.Entities[0].Name != "Alice" || graph.Entities[0].UpdatedAt == "" {
+		t.Errorf("entities = %+v", graph.Entities)
+	}
+}
+
+func TestEntity(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+
+	graph, ok, err := h.Entity("", "alice")
+	if err != nil || !ok {
+		t.Fatalf("Entity() = %v, %v", ok, err)
+	}
+	if graph.Entities[0].Name != "Alice" || len(graph.Entities[0].ObservationDetails) != 2 || len(graph.Relations) != 1 {
+		t.Errorf("graph = %+v", graph)
+	}
+	if _, ok, err := h.Entity("", "Nobody"); ok || err != nil {
+		t.Errorf("Entity(Nobody) = %v, %v", ok, err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	mustCall(t, h.RenameEntity, `{"oldName": "Alice", "newName": "Alice Smith"}`)
+
+	text, err := h.Context("", "Go Berlin", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Relevant memory about \"Go Berlin\":\n\n## Alice Smith (person)\nAlso known as: Alice\n- likes Go\n- lives in Berlin\n"
+	if text != want {
+		t.Errorf("Context() = %q, want %q", text, want)
+	}
+	if text, err = h.Context("", "quantum", 0); text != "" || err != nil {
+		t.Errorf("Context(unrelated) = %q, %v", text, err)
+	}
+	if _, err := h.Context("", " ", 0); err == nil {
+		t.Error("Context() without a topic should fail")
+	}
+
+	// With semantic search, paraphrases find entities, and relations between them are listed.
+	h.SetEmbedder(&conceptEmbedder{})
+	text, err = h.Context("", "which city", 5)
+	if err != nil || !strings.HasPrefix(text, "Relevant memory about \"which city\":\n\n## Alice Smith") ||
+		!strings.HasSuffix(text, "## Relations\n- Alice Smith works_at Acme\n") {
+		t.Errorf("Context(semantic) = %q, %v", text, err)
+	}
+}
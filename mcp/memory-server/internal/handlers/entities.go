@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+// RenameEntity renames an entity and re-points its observations, relations and
+// aliases. The old name becomes an alias.
+func (h *MemoryHandler) RenameEntity(args map[string]interface{}) (interface{}, error) {
+	oldName := getField(args, "oldName", "old_name", "name")
+	newName := getField(args, "newName", "new_name")
+	if oldName == "" || newName == "" {
+		return nil, fmt.Errorf("oldName and newName are required")
+	}
+	if oldName == newName {
+		return models.RenameResult{OldName: oldName, NewName: newName}, nil
+	}
+	ns := h.namespaceOf(args)
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	entType, err := entityType(tx, ns, oldName)
+	if err != nil {
+		return nil, err
+	}
+	if existing, ok, err := resolveEntity(tx, ns, newName); err != nil {
+		return nil, err
+	} else if ok && existing != oldName {
+		return nil, fmt.Errorf("entity %q already exists; use merge_entities to combine them", existing)
+	}
+
+	// Insert the new row first so the references never dangle. The old name
+	// stays an alias unless only its case or spacing changed.
+	stmts := []string{
+		"INSERT INTO entities (namespace, name, type) VALUES (?4, ?2, ?3)",
+		"UPDATE observations SET entity_name = ?2 WHERE namespace = ?4 AND entity_name = ?1",
+		"UPDATE relations SET from_name = ?2 WHERE namespace = ?4 AND from_name = ?1",
+		"UPDATE relations SET to_name = ?2 WHERE namespace = ?4 AND to_name = ?1",
+		"UPDATE aliases SET entity_name = ?2 WHERE namespace = ?4 AND entity_name = ?1",
+		"DELETE FROM aliases WHERE namespace = ?4 AND alias_key = ?5",
+		"INSERT INTO aliases (namespace, alias_key, alias, entity_name) VALUES (?4, ?5, ?2, ?2)",
+		"DELETE FROM entities WHERE namespace = ?4 AND name = ?1",
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt, oldName, newName, entType, ns, normalizeName(newName)); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return models.RenameResult{OldName: oldName, NewName: newName}, nil
+}
+
+// MergeEntities merges duplicate entities into a target entity. Observations and
+// relations are moved to the target, duplicates are dropped and the sources are
+// deleted. The names and aliases of the sources become aliases of the target.
+func (h *MemoryHandler) MergeEntities(args map[string]interface{}) (interface{}, error) {
+	target := getField(args, "targetName", "target_name", "target")
+	sources := getStrings(args, "sourceNames", "source_names", "sources")
+	if target == "" || len(sources) == 0 {
+		return nil, fmt.Errorf("targetName and sourceNames are required")
+	}
+	ns := h.namespaceOf(args)
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := entityType(tx, ns, target); err != nil {
+		return nil, err
+	}
+
+	merged := []string{}
+	for _, source := range sources {
+		if source == target {
+			continue
+		}
+		if _, err := entityType(tx, ns, source); err != nil {
+			return nil, err
+		}
+
+		// Current facts the target already has are dropped, after re-pointing the
+		// facts they superseded to the target's copy. Relations between the source
+		// and the target would become self-references.
+		stmts := []string{
+			`UPDATE observations SET superseded_by = (
+				SELECT t.id FROM observations d
+				JOIN observations t ON t.namespace = ?3 AND t.entity_name = ?2 AND t.content = d.content AND t.superseded_by IS NULL
+				WHERE d.id = observations.superseded_by)
+			 WHERE superseded_by IN (
+				SELECT s.id FROM observations s
+				JOIN observations t ON t.namespace = ?3 AND t.entity_name = ?2 AND t.content = s.content AND t.superseded_by IS NULL
+				WHERE s.namespace = ?3 AND s.entity_name = ?1 AND s.superseded_by IS NULL)`,
+			`UPDATE observations SET entity_name = ?2
+			 WHERE namespace = ?3 AND entity_name = ?1
+			   AND (superseded_by IS NOT NULL
+			     OR content NOT IN (SELECT content FROM observations WHERE namespace = ?3 AND entity_name = ?2 AND superseded_by IS NULL))`,
+			`INSERT OR IGNORE INTO relations (namespace, from_name, to_name, type)
+			 SELECT ?3, ?2, to_name, type FROM relations WHERE namespace = ?3 AND from_name = ?1 AND to_name NOT IN (?1, ?2)`,
+			`INSERT OR IGNORE INTO relations (namespace, from_name, to_name, type)
+			 SELECT ?3, from_name, ?2, type FROM relations WHERE namespace = ?3 AND to_name = ?1 AND from_name NOT IN (?1, ?2)`,
+			"DELETE FROM observations WHERE namespace = ?3 AND entity_name = ?1",
+			"DELETE FROM relations WHERE namespace = ?3 AND (from_name = ?1 OR to_name = ?1)",
+			"UPDATE aliases SET entity_name = ?2 WHERE namespace = ?3 AND entity_name = ?1",
+			"DELETE FROM entities WHERE namespace = ?3 AND name = ?1",
+		}
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(stmt, source, target, ns); err != nil {
+				return nil, err
+			}
+		}
+		if err := addAlias(tx, ns, source, target); err != nil {
+			return nil, err
+		}
+		merged = append(merged, source)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	graph, err := h.loadGraph(ns, []string{target})
+	if err != nil {
+		return nil, err
+	}
+	return models.MergeResult{Entity: graph.Entities[0], Merged: merged}, nil
+}
+
+// entityType returns the type of an existing entity.
+func entityType(tx *sql.Tx, ns, name string) (string, error) {
+	var entType sql.NullString
+	err := tx.QueryRow("SELECT type FROM entities WHERE namespace = ? AND name = ?", ns, name).Scan(&entType)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("entity %q not found", name)
+	}
+	return entType.String, err
+}
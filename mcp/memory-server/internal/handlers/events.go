@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+	"github.com/mlcmcp/memory-server/internal/privacy"
+)
+
+// Kinds and actions of change events.
+const (
+	EventEntity      = "entity"
+	EventObservation = "observation"
+	EventRelation    = "relation"
+
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+	ActionDeleted = "deleted"
+)
+
+const (
+	defaultChangesLimit = 100
+	maxChangesLimit     = 1000
+	defaultSliceLimit   = 100
+	maxSliceLimit       = 1000
+)
+
+// eventTime is the trigger expression for the time of an event, formatted like
+// formatTime.
+const eventTime = `strftime('%Y-%m-%dT%H:%M:%SZ', 'now')`
+
+// eventSchema records every change to entities, observations and relations in
+// an append-only table. Each event holds the state after the change, so the
+// graph at any time is the replay of the events up to then. Relations are
+// identified by their endpoints and type, so changing one deletes the old
+// relation and creates a new one. Events are written by triggers, which also
+// catch deletions cascading from entities. Rows can only be changed to erase the
+// content of forgotten observations, or to encrypt it.
+//
+// Facts from before the table existed get a creation event dated by their
+// creation time if it is known, and by the upgrade otherwise.
+const eventSchema = `
+CREATE TABLE events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	namespace TEXT NOT NULL,
+	at TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	action TEXT NOT NULL,
+	entity_name TEXT NOT NULL,
+	entity_type TEXT,
+	obs_id INTEGER,
+	content TEXT,
+	superseded_by INTEGER,
+	to_name TEXT,
+	relation_type TEXT
+);
+CREATE INDEX events_time ON events(namespace, at);
+CREATE INDEX events_entity ON events(namespace, entity_name);
+CREATE INDEX events_observation ON events(obs_id) WHERE obs_id IS NOT NULL;
+
+INSERT INTO events (namespace, at, kind, action, entity_name, entity_type)
+SELECT namespace, COALESCE(
+	(SELECT MIN(o.created_at) FROM observations o WHERE o.namespace = e.namespace AND o.entity_name = e.name),
+	updated_at, ` + eventTime + `), 'entity', 'created', name, type
+FROM entities e;
+INSERT INTO events (namespace, at, kind, action, entity_name, obs_id, content, superseded_by)
+SELECT namespace, COALESCE(created_at, ` + eventTime + `), 'observation', 'created', entity_name, id, content, superseded_by
+FROM observations ORDER BY id;
+INSERT INTO events (namespace, at, kind, action, entity_name, to_name, relation_type)
+SELECT namespace, ` + eventTime + `, 'relation', 'created', from_name, to_name, type FROM relations;
+
+CREATE TRIGGER events_no_delete BEFORE DELETE ON events BEGIN
+	SELECT RAISE(ABORT, 'events are append-only');
+END;
+CREATE TRIGGER events_no_update BEFORE UPDATE OF id, namespace, at, kind, action, entity_name, entity_type, obs_id, superseded_by, to_name, relation_type ON events BEGIN
+	SELECT RAISE(ABORT, 'events are append-only');
+END;
+CREATE TRIGGER events_no_content_update BEFORE UPDATE OF content ON events
+WHEN new.content IS NOT NULL AND NOT (old.content NOT LIKE '` + privacy.Prefix + `%' AND new.content LIKE '` + privacy.Prefix + `%') BEGIN
+	SELECT RAISE(ABORT, 'events are append-only');
+END;
+
+CREATE TRIGGER entities_event_insert AFTER INSERT ON entities BEGIN
+	INSERT INTO events (namespace, at, kind, action, entity_name, entity_type)
+	VALUES (new.namespace, ` + eventTime + `, 'entity', 'created', new.name, new.type);
+END;
+CREATE TRIGGER entities_event_update AFTER UPDATE OF type ON entities WHEN old.type IS NOT new.type BEGIN
+	INSERT INTO events (namespace, at, kind, action, entity_name, entity_type)
+	VALUES (new.namespace, ` + eventTime + `, 'entity', 'updated', new.name, new.type);
+END;
+CREATE TRIGGER entities_event_delete AFTER DELETE ON entities BEGIN
+	INSERT INTO events (namespace, at, kind, action, entity_name, entity_type)
+	VALUES (old.namespace, ` + eventTime + `, 'entity', 'deleted', old.name, old.type);
+END;
+
+CREATE TRIGGER observations_event_insert AFTER INSERT ON observations BEGIN
+	INSERT INTO events (namespace, at, kind, action, entity_name, obs_id, content, superseded_by)
+	VALUES (new.namespace, ` + eventTime + `, 'observation', 'created', new.entity_name, new.id, new.content, new.superseded_by);
+END;
+CREATE TRIGGER observations_event_update AFTER UPDATE OF entity_name, content, superseded_by ON observations
+WHEN (old.entity_name IS NOT new.entity_name OR old.content IS NOT new.content OR old.superseded_by IS NOT new.superseded_by)
+	AND NOT (` + encryptedInPlace + `) BEGIN
+	INSERT INTO events (namespace, at, kind, action, entity_name, obs_id, content, superseded_by)
+	VALUES (new.namespace, ` + eventTime + `, 'observation', 'updated', new.entity_name, new.id, new.content, new.superseded_by);
+END;
+CREATE TRIGGER observations_event_delete AFTER DELETE ON observations BEGIN
+	INSERT INTO events (namespace, at, kind, action, entity_name, obs_id, content, superseded_by)
+	VALUES (old.namespace, ` + eventTime + `, 'observation', 'deleted', old.entity_name, old.id, old.content, old.superseded_by);
+END;
+
+CREATE TRIGGER relations_event_insert AFTER INSERT ON relations BEGIN
+	INSERT INTO events (namespace, at, kind, action, entity_name, to_name, relation_type)
+	VALUES (new.namespace, ` + eventTime + `, 'relation', 'created', new.from_name, new.to_name, new.type);
+END;
+CREATE TRIGGER relations_event_update AFTER UPDATE ON relations BEGIN
+	INSERT INTO events (namespace, at, kind, action, entity_name, to_name, relation_type)
+	VALUES (old.namespace, ` + eventTime + `, 'relation', 'deleted', old.from_name, old.to_name, old.type);
+	INSERT INTO events (namespace, at, kind, action, entity_name, to_name, relation_type)
+	VALUES (new.namespace, ` + eventTime + `, 'relation', 'created', new.from_name, new.to_name, new.type);
+END;
+CREATE TRIGGER relations_event_delete AFTER DELETE ON relations BEGIN
+	INSERT INTO events (namespace, at, kind, action, entity_name, to_name, relation_type)
+	VALUES (old.namespace, ` + eventTime + `, 'relation', 'deleted', old.from_name, old.to_name, old.type);
+END;
+`
+
+// eventColumns are the columns read by scanEvent.
+const eventColumns = "id, at, kind, action, entity_name, entity_type, obs_id, content, superseded_by, to_name, relation_type"
+
+// scanEvent reads the eventColumns of a row and decrypts the content.
+func (h *MemoryHandler) scanEvent(row rowScanner) (models.Event, error) {
+	var e models.Event
+	var name string
+	var entType, content, to, relType sql.NullString
+	var obsID, supersededBy sql.NullInt64
+	if err := row.Scan(&e.ID, &e.At, &e.Kind, &e.Action, &name, &entType, &obsID, &content, &supersededBy, &to, &relType); err != nil {
+		return e, err
+	}
+	switch e.Kind {
+	case EventRelation:
+		e.From, e.To, e.RelationType = name, to.String, relType.String
+	case EventObservation:
+		e.EntityName, e.ObservationID = name, obsID.Int64
+		e.Forgotten = !content.Valid
+		var err error
+		if e.Content, err = h.unseal(content.String); err != nil {
+			return e, err
+		}
+		if supersededBy.Valid {
+			e.SupersededBy = &supersededBy.Int64
+		}
+	default:
+		e.EntityName, e.EntityType = name, entType.String
+	}
+	return e, nil
+}
+
+// eventCursor encodes the position after an event.
+func eventCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// Changes returns the change feed of a namespace: the events in the order they
+// happened, optionally limited to a time range, to entities and to kinds. The
+// result always has a nextCursor, so that a client can later ask for the events
+// that happened since.
+func (h *MemoryHandler) Changes(args map[string]interface{}) (interface{}, error) {
+	ns := h.namespaceOf(args)
+	limit := clampInt(args, "limit", defaultChangesLimit, maxChangesLimit)
+
+	where := "namespace = ?"
+	qArgs := []interface{}{ns}
+	var after int64
+	if cursor := getField(args, "cursor"); cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err == nil {
+			after, err = strconv.ParseInt(string(raw), 10, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor %q", cursor)
+		}
+		where += " AND id > ?"
+		qArgs = append(qArgs, after)
+	}
+	now := time.Now()
+	for _, bound := range []struct{ arg, op string }{{"since", ">="}, {"until", "<="}} {
+		if s := getField(args, bound.arg); s != "" {
+			t, err := timeOrAge(s, now)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", bound.arg, err)
+			}
+			where += " AND at " + bound.op + " ?"
+			qArgs = append(qArgs, formatTime(t))
+		}
+	}
+	if names := getStrings(args, "entityNames", "names"); len(names) > 0 {
+		in, inArgs := inClause(names)
+		where += " AND (entity_name IN " + in + " OR to_name IN " + in + ")"
+		qArgs = append(qArgs, inArgs...)
+		qArgs = append(qArgs, inArgs...)
+	}
+	if kinds := getStrings(args, "kinds"); len(kinds) > 0 {
+		for _, k := range kinds {
+			if k != EventEntity && k != EventObservation && k != EventRelation {
+				return nil, fmt.Errorf("unknown kind %q (want entity, observation or relation)", k)
+			}
+		}
+		in, inArgs := inClause(kinds)
+		where += andIn("kind", in)
+		qArgs = append(qArgs, inArgs...)
+	}
+
+	rows, err := h.db.Query("SELECT "+eventColumns+" FROM events WHERE "+where+" ORDER BY id LIMIT ?", append(qArgs, limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := models.ChangesResult{Events: []models.Event{}}
+	for rows.Next() {
+		e, err := h.scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		result.Events = append(result.Events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(result.Events) > limit {
+		result.Events = result.Events[:limit]
+		result.HasMore = true
+	}
+	if n := len(result.Events); n > 0 {
+		after = result.Events[n-1].ID
+	}
+	result.NextCursor = eventCursor(after)
+	return result, nil
+}
+
+// sliceObservation is the state of an observation while replaying events.
+type sliceObservation struct {
+	entity    string
+	content   string
+	current   bool
+	forgotten bool
+}
+
+type sliceRelation struct {
+	from, to, relType string
+}
+
+// GraphAt returns the graph of a namespace as it was at a time, rebuilt from the
+// change events up to then: entities in name order with their current facts, and
+// the relations touching them. entityNames restricts it to entities by the names
+// they had then. Forgotten facts are left out.
+func (h *MemoryHandler) GraphAt(args map[string]interface{}) (interface{}, error) {
+	ns := h.namespaceOf(args)
+	atArg := getField(args, "at", "asOf")
+	if atArg == "" {
+		return nil, fmt.Errorf("at is required")
+	}
+	at, err := timeOrAge(atArg, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	limit := clampInt(args, "limit", defaultSliceLimit, maxSliceLimit)
+	names := getStrings(args, "entityNames", "names")
+
+	// Facts move between entities when these are renamed or merged, so the whole
+	// namespace is replayed even for a few entities.
+	rows, err := h.db.Query("SELECT "+eventColumns+" FROM events WHERE namespace = ? AND at <= ? ORDER BY id", ns, formatTime(at))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entities := make(map[string]string)
+	observations := make(map[int64]sliceObservation)
+	relations := make(map[sliceRelation]bool)
+	for rows.Next() {
+		e, err := h.scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		switch e.Kind {
+		case EventEntity:
+			if e.Action == ActionDeleted {
+				delete(entities, e.EntityName)
+			} else {
+				entities[e.EntityName] = e.EntityType
+			}
+		case EventObservation:
+			if e.Action == ActionDeleted {
+				delete(observations, e.ObservationID)
+			} else {
+				observations[e.ObservationID] = sliceObservation{entity: e.EntityName, content: e.Content,
+					current: e.SupersededBy == nil, forgotten: e.Forgotten}
+			}
+		case EventRelation:
+			relations[sliceRelation{e.From, e.To, e.RelationType}] = e.Action != ActionDeleted
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool)
+	for _, n := range names {
+		wanted[n] = true
+	}
+	var entNames []string
+	for name := range entities {
+		if len(names) == 0 || wanted[name] {
+			entNames = append(entNames, name)
+		}
+	}
+	sort.Strings(entNames)
+	result := models.GraphSlice{At: formatTime(at), KnowledgeGraph: models.KnowledgeGraph{Entities: []models.Entity{}, Relations: []models.Relation{}}}
+	if len(entNames) > limit {
+		entNames = entNames[:limit]
+		result.Truncated = true
+	}
+
+	index := make(map[string]int, len(entNames))
+	for i, name := range entNames {
+		index[name] = i
+		result.Entities = append(result.Entities, models.Entity{Name: name, EntityType: entities[name], Observations: []string{}})
+	}
+	ids := make([]int64, 0, len(observations))
+	for id := range observations {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		o := observations[id]
+		if i, ok := index[o.entity]; ok && o.current && !o.forgotten {
+			result.Entities[i].Observations = append(result.Entities[i].Observations, o.content)
+		}
+	}
+	for r, exists := range relations {
+		_, fromListed := index[r.from]
+		_, toListed := index[r.to]
+		if exists && (fromListed || toListed) {
+			result.Relations = append(result.Relations, models.Relation{From: r.from, To: r.to, RelationType: r.relType})
+		}
+	}
+	sort.Slice(result.Relations, func(i, j int) bool {
+		a, b := result.Relations[i], result.Relations[j]
+		return a.From+"\x00"+a.To+"\x00"+a.RelationType < b.From+"\x00"+b.To+"\x00"+b.RelationType
+	})
+	return result, nil
+}
+
+// forgetEvents erases the content of the events of forgotten observations, so
+// that forgetting also removes the facts from the history.
+func forgetEvents(tx *sql.Tx, ids []int64) error {
+	for start := 0; start < len(ids); start += deleteBatch {
+		in, args := int64InClause(ids[start:min(start+deleteBatch, len(ids))])
+		if _, err := tx.Exec("UPDATE events SET content = NULL WHERE content IS NOT NULL AND obs_id IN "+in, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
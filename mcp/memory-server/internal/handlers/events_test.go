@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+// backdate moves all events recorded so far to a past time, bypassing the
+// append-only guard.
+func backdate(t *testing.T, h *MemoryHandler, at string) {
+	t.Helper()
+	for _, stmt := range []string{
+		"DROP TRIGGER events_no_update",
+		"UPDATE events SET at = '" + at + "'",
+		`CREATE TRIGGER events_no_update BEFORE UPDATE OF id, namespace, at, kind, action, entity_name, entity_type, obs_id, superseded_by, to_name, relation_type ON events BEGIN
+			SELECT RAISE(ABORT, 'events are append-only');
+		END`,
+	} {
+		if _, err := h.db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// describe renders events compactly for comparison.
+func describe(events []models.Event) []string {
+	out := make([]string, len(events))
+	for i, e := range events {
+		switch e.Kind {
+		case EventRelation:
+			out[i] = fmt.Sprintf("%s relation %s %s %s", e.Action, e.From, e.RelationType, e.To)
+		case EventObservation:
+			out[i] = fmt.Sprintf("%s observation %s: %s", e.Action, e.EntityName, e.Content)
+			if e.SupersededBy != nil {
+				out[i] += " (superseded)"
+			}
+			if e.Forgotten {
+				out[i] += "(forgotten)"
+			}
+		default:
+			out[i] = fmt.Sprintf("%s entity %s: %s", e.Action, e.EntityName, e.EntityType)
+		}
+	}
+	return out
+}
+
+func TestChanges(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	first := mustCall(t, h.Changes, `{}`).(models.ChangesResult)
+	if len(first.Events) != 6 || first.HasMore {
+		t.Fatalf("Changes() = %v", describe(first.Events))
+	}
+
+	mustCall(t, h.CorrectObservation, `{"entityName": "Alice", "oldObservation": "lives in Berlin", "newObservation": "lives in Bonn"}`)
+	mustCall(t, h.RenameEntity, `{"oldName": "Acme", "newName": "Acme Corp"}`)
+	mustCall(t, h.DeleteRelations, `{"relations": [{"from": "Alice", "to": "Acme Corp", "relationType": "works_at"}]}`)
+
+	res := mustCall(t, h.Changes, `{"cursor": "`+first.NextCursor+`"}`).(models.ChangesResult)
+	want := []string{
+		"created observation Alice: lives in Bonn",
+		"updated observation Alice: lives in Berlin (superseded)",
+		"created entity Acme Corp: company",
+		"updated observation Acme Corp: builds rockets",
+		"deleted relation Alice works_at Acme",
+		"created relation Alice works_at Acme Corp",
+		"deleted entity Acme: company",
+		"deleted relation Alice works_at Acme Corp",
+	}
+	if got := describe(res.Events); !reflect.DeepEqual(got, want) {
+		t.Errorf("Changes() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	res = mustCall(t, h.Changes, `{"entityNames": ["Alice"], "kinds": ["relation"], "limit": 2}`).(models.ChangesResult)
+	if len(res.Events) != 2 || !res.HasMore || res.Events[0].RelationType != "works_at" {
+		t.Errorf("Changes() filtered = %v, hasMore %v", describe(res.Events), res.HasMore)
+	}
+	res = mustCall(t, h.Changes, `{"cursor": "`+res.NextCursor+`", "entityNames": ["Alice"], "kinds": ["relation"]}`).(models.ChangesResult)
+	if len(res.Events) != 2 || res.HasMore {
+		t.Errorf("Changes() next page = %v", describe(res.Events))
+	}
+	res = mustCall(t, h.Changes, `{"since": "2000-01-01T00:00:00Z", "until": "2000-12-31T00:00:00Z"}`).(models.ChangesResult)
+	if len(res.Events) != 0 || res.NextCursor == "" {
+		t.Errorf("Changes() of an empty range = %+v", res)
+	}
+
+	for _, a := range []string{`{"kinds": ["fact"]}`, `{"cursor": "!"}`, `{"since": "yesterday"}`} {
+		if _, err := h.Changes(args(t, a)); err == nil {
+			t.Errorf("Changes(%s) should fail", a)
+		}
+	}
+}
+
+func TestGraphAt(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	backdate(t, h, "2024-01-01T00:00:00Z")
+
+	mustCall(t, h.CorrectObservation, `{"entityName": "Alice", "oldObservation": "lives in Berlin", "newObservation": "lives in Bonn"}`)
+	mustCall(t, h.RenameEntity, `{"oldName": "Acme", "newName": "Acme Corp"}`)
+	mustCall(t, h.DeleteObservations, `{"deletions": [{"entityName": "Alice", "observations": ["likes Go"]}]}`)
+
+	then := mustCall(t, h.GraphAt, `{"at": "2024-06-01T00:00:00Z"}`).(models.GraphSlice)
+	want := models.KnowledgeGraph{
+		Entities: []models.Entity{
+			{Name: "Acme", EntityType: "company", Observations: []string{"builds rockets"}},
+			{Name: "Alice", EntityType: "person", Observations: []string{"likes Go", "lives in Berlin"}},
+		},
+		Relations: []models.Relation{{From: "Alice", To: "Acme", RelationType: "works_at"}},
+	}
+	if !reflect.DeepEqual(then.KnowledgeGraph, want) || then.At != "2024-06-01T00:00:00Z" {
+		t.Errorf("GraphAt(then) = %+v, want %+v", then, want)
+	}
+
+	now := mustCall(t, h.GraphAt, `{"at": "0s", "entityNames": ["Alice"]}`).(models.GraphSlice)
+	want = models.KnowledgeGraph{
+		Entities:  []models.Entity{{Name: "Alice", EntityType: "person", Observations: []string{"lives in Bonn"}}},
+		Relations: []models.Relation{{From: "Alice", To: "Acme Corp", RelationType: "works_at"}},
+	}
+	if !reflect.DeepEqual(now.KnowledgeGraph, want) {
+		t.Errorf("GraphAt(now) = %+v, want %+v", now.KnowledgeGraph, want)
+	}
+
+	before := mustCall(t, h.GraphAt, `{"at": "2023-01-01T00:00:00Z"}`).(models.GraphSlice)
+	if len(before.Entities) != 0 || len(before.Relations) != 0 {
+		t.Errorf("GraphAt(before) = %+v", before)
+	}
+	if limited := mustCall(t, h.GraphAt, `{"at": "2024-06-01T00:00:00Z", "limit": 1}`).(models.GraphSlice); len(limited.Entities) != 1 || !limited.Truncated {
+		t.Errorf("GraphAt(limit 1) = %+v", limited)
+	}
+	if _, err := h.GraphAt(args(t, `{}`)); err == nil {
+		t.Error("GraphAt() without a time should fail")
+	}
+}
+
+func TestEvents_AppendOnly(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	for _, stmt := range []string{
+		"DELETE FROM events",
+		"UPDATE events SET at = '2000-01-01T00:00:00Z'",
+		"UPDATE events SET content = 'likes Rust' WHERE content = 'likes Go'",
+	} {
+		if _, err := h.db.Exec(stmt); err == nil || !strings.Contains(err.Error(), "append-only") {
+			t.Errorf("%s: error = %v", stmt, err)
+		}
+	}
+}
+
+func TestForget_ErasesHistory(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	mustCall(t, h.CorrectObservation, `{"entityName": "Alice", "oldObservation": "lives in Berlin", "newObservation": "lives in Bonn"}`)
+	mustCall(t, h.Forget, `{"query": "Berlin"}`)
+	mustCall(t, h.Forget, `{"entityNames": ["Acme"]}`)
+
+	var stored int
+	h.db.QueryRow("SELECT COUNT(*) FROM events WHERE content LIKE '%Berlin%' OR content LIKE '%rockets%'").Scan(&stored)
+	if stored != 0 {
+		t.Errorf("%d events still hold forgotten facts", stored)
+	}
+	res := mustCall(t, h.Changes, `{"entityNames": ["Acme"], "kinds": ["observation"]}`).(models.ChangesResult)
+	want := []string{"created observation Acme: (forgotten)", "deleted observation Acme: (forgotten)"}
+	if got := describe(res.Events); !reflect.DeepEqual(got, want) {
+		t.Errorf("Changes() = %q, want %q", got, want)
+	}
+	slice := mustCall(t, h.GraphAt, `{"at": "0s", "entityNames": ["Alice"]}`).(models.GraphSlice)
+	if got := slice.Entities[0].Observations; !reflect.DeepEqual(got, []string{"likes Go", "lives in Bonn"}) {
+		t.Errorf("GraphAt() observations = %q", got)
+	}
+}
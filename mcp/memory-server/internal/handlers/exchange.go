@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"bytes"
+	"cmp"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/mlcmcp/memory-server/internal/exchange"
+	"github.com/mlcmcp/memory-server/internal/models"
+	"github.com/mlcmcp/memory-server/internal/ontology"
+)
+
+// Merge strategies for entities that exist already when importing.
+const (
+	// StrategySkip leaves existing entities unchanged.
+	StrategySkip = "skip"
+	// StrategyOverwrite replaces the type and observations of existing entities.
+	StrategyOverwrite = "overwrite"
+	// StrategyAppend adds the observations existing entities do not have yet.
+	StrategyAppend = "append"
+)
+
+// Strategies lists the merge strategies.
+var Strategies = []string{StrategySkip, StrategyOverwrite, StrategyAppend}
+
+// Export returns the current facts of a namespace. Superseded facts and
+// provenance are not exported.
+func (h *MemoryHandler) Export(ns string) (models.KnowledgeGraph, error) {
+	if ns == "" {
+		ns = h.namespace
+	}
+	return h.loadGraph(ns, nil)
+}
+
+// Import merges a graph into a namespace in one transaction. Entities that exist
+// already are merged with the strategy; relations are added if missing, creating
+// their entities with type "unknown". Imported observations are attributed to source.
+// Types are checked against the ontology like those of created entities and
+// relations: violations and relations stored reversed are reported as issues,
+// and items failing in strict mode are left out. The import is reported to the
+// audit hook.
+func (h *MemoryHandler) Import(ns string, graph models.KnowledgeGraph, strategy, source string) (models.ImportResult, error) {
+	if ns == "" {
+		ns = h.namespace
+	}
+	result, err := h.importGraph(ns, graph, strategy, source)
+	h.audit(AuditImport, ns, namesOf(graph), err)
+	return result, err
+}
+
+// namesOf lists the entities of a graph and the endpoints of its relations once.
+func namesOf(graph models.KnowledgeGraph) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, e := range graph.Entities {
+		add(e.Name)
+	}
+	for _, r := range graph.Relations {
+		add(r.From)
+		add(r.To)
+	}
+	return names
+}
+
+// importGraph is Import without the audit hook, for the tool, whose calls are
+// audited as requests.
+func (h *MemoryHandler) importGraph(ns string, graph models.KnowledgeGraph, strategy, source string) (models.ImportResult, error) {
+	if strategy == "" {
+		strategy = StrategySkip
+	}
+	result := models.ImportResult{Strategy: strategy}
+	if !isStrategy(strategy) {
+		return result, fmt.Errorf("unknown strategy %q (want %s)", strategy, strings.Join(Strategies, ", "))
+	}
+	prov := provenance{source: source}
+
+	err := h.inTx(func(tx *sql.Tx) error {
+		for _, e := range combineEntities(graph.Entities) {
+			item := fmt.Sprintf("entity %q", e.Name)
+			entType, warning, err := h.checkEntityType(cmp.Or(e.EntityType, ontology.Unknown))
+			if err != nil {
+				result.Issues = append(result.Issues, models.ImportIssue{Item: item, ItemStatus: models.Failed(err.Error())})
+				continue
+			}
+			if e.EntityType != "" {
+				e.EntityType = entType
+			}
+			observations, masked, err := h.redactAll(e.Observations)
+			if err != nil {
+				result.Issues = append(result.Issues, models.ImportIssue{Item: item, ItemStatus: models.Failed(err.Error())})
+				continue
+			}
+			e.Observations = observations
+			warning = joinWarnings(warning, masked)
+			created, err := h.importEntity(tx, ns, e, strategy, prov, &result)
+			if err != nil {
+				return fmt.Errorf("failed to import entity %q: %w", e.Name, err)
+			}
+			switch {
+			case created:
+				result.EntitiesCreated++
+			case strategy == StrategySkip:
+				result.EntitiesSkipped++
+			default:
+				result.EntitiesUpdated++
+			}
+			if warning != "" {
+				result.Issues = append(result.Issues, models.ImportIssue{Item: item, ItemStatus: importStatus(created, warning)})
+			}
+		}
+
+		for _, r := range graph.Relations {
+			if r.RelationType == "" {
+				r.RelationType = "related_to"
+			}
+			item := fmt.Sprintf("relation %s -%s-> %s", r.From, r.RelationType, r.To)
+			checked, warning, err := h.checkRelation(tx, ns, r)
+			if err != nil {
+				result.Issues = append(result.Issues, models.ImportIssue{Item: item, ItemStatus: models.Failed(err.Error())})
+				continue
+			}
+			if checked.From != r.From {
+				warning = joinWarnings(warning, fmt.Sprintf("stored reversed as %s -%s-> %s", checked.From, checked.RelationType, checked.To))
+			}
+			r = checked
+			for _, name := range []*string{&r.From, &r.To} {
+				canonical, created, err := ensureEntity(tx, ns, *name, "unknown")
+				if err != nil {
+					return err
+				}
+				if created {
+					result.EntitiesCreated++
+				}
+				*name = canonical
+			}
+			res, err := tx.Exec("INSERT OR IGNORE INTO relations (namespace, from_name, to_name, type) VALUES (?, ?, ?, ?)", ns, r.From, r.To, r.RelationType)
+			if err != nil {
+				return fmt.Errorf("failed to import relation %s -%s-> %s: %w", r.From, r.RelationType, r.To, err)
+			}
+			n, _ := res.RowsAffected()
+			if n > 0 {
+				result.RelationsCreated++
+			} else {
+				result.RelationsSkipped++
+			}
+			if warning != "" {
+				result.Issues = append(result.Issues, models.ImportIssue{Item: item, ItemStatus: importStatus(n > 0, warning)})
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// importEntity creates or merges one entity with redacted observations and
+// reports whether it was created.
+func (h *MemoryHandler) importEntity(tx *sql.Tx, ns string, e models.Entity, strategy string, prov provenance, result *models.ImportResult) (bool, error) {
+	entType := e.EntityType
+	if entType == "" {
+		entType = "unknown"
+	}
+	name, created, err := ensureEntity(tx, ns, e.Name, entType)
+	if err != nil {
+		return false, err
+	}
+	e.Name = name
+
+	switch {
+	case created:
+	case strategy == StrategySkip:
+		return false, nil
+	case strategy == StrategyOverwrite:
+		// Facts the import keeps stay with their provenance; everything else,
+		// including the history of corrections, is replaced.
+		replaced, args := "", []interface{}{ns, e.Name}
+		if len(e.Observations) > 0 {
+			in, inArgs := inClause(h.sealAll(e.Observations))
+			replaced = " AND (superseded_by IS NOT NULL OR content NOT IN " + in + ")"
+			args = append(args, inArgs...)
+		}
+		if _, err := tx.Exec("DELETE FROM observations WHERE namespace = ? AND entity_name = ?"+replaced, args...); err != nil {
+			return false, err
+		}
+		if e.EntityType != "" {
+			if _, err := tx.Exec("UPDATE entities SET type = ? WHERE namespace = ? AND name = ?", e.EntityType, ns, e.Name); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	for _, content := range e.Observations {
+		_, added, err := h.addObservation(tx, ns, e.Name, content, prov)
+		if err != nil {
+			return false, err
+		}
+		if added {
+			result.ObservationsAdded++
+		}
+	}
+	return created, nil
+}
+
+// importStatus is the status of an imported item with a warning.
+func importStatus(created bool, warning string) models.ItemStatus {
+	if created {
+		return models.ItemStatus{Status: models.StatusCreated, Warning: warning}
+	}
+	return models.ItemStatus{Status: models.StatusExists, Warning: warning}
+}
+
+// combineEntities merges entities listed more than once, keeping the first type
+// and the order of first appearance.
+func combineEntities(entities []models.Entity) []models.Entity {
+	var out []models.Entity
+	index := make(map[string]int)
+	for _, e := range entities {
+		i, ok := index[e.Name]
+		if !ok {
+			index[e.Name] = len(out)
+			e.Observations = append([]string(nil), e.Observations...)
+			out = append(out, e)
+			continue
+		}
+		if out[i].EntityType == "" {
+			out[i].EntityType = e.EntityType
+		}
+		out[i].Observations = append(out[i].Observations, e.Observations...)
+	}
+	return out
+}
+
+func isStrategy(s string) bool {
+	for _, v := range Strategies {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportGraph returns the namespace as a document in one of the exchange formats.
+func (h *MemoryHandler) ExportGraph(args map[string]interface{}) (interface{}, error) {
+	format := getField(args, "format")
+	if format == "" {
+		format = exchange.JSONL
+	}
+	graph, err := h.Export(h.namespaceOf(args))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := exchange.Encode(&buf, format, graph); err != nil {
+		return nil, err
+	}
+	return models.ExportResult{
+		Format:    format,
+		Content:   buf.String(),
+		Entities:  len(graph.Entities),
+		Relations: len(graph.Relations),
+	}, nil
+}
+
+// ImportGraph merges a document in one of the exchange formats into the namespace.
+func (h *MemoryHandler) ImportGraph(args map[string]interface{}) (interface{}, error) {
+	format := getField(args, "format")
+	if format == "" {
+		format = exchange.JSONL
+	}
+	content := getField(args, "content")
+	if content == "" {
+		return nil, fmt.Errorf("content is required")
+	}
+	graph, err := exchange.Decode(strings.NewReader(content), format)
+	if err != nil {
+		return nil, err
+	}
+	return h.importGraph(h.namespaceOf(args), graph, getField(args, "strategy"), getField(args, "source"))
+}
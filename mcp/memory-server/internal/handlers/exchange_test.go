@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+// importDoc updates Alice, adds Bob and relates Bob to Alice.
+const importDoc = `{"type":"entity","name":"Alice","entityType":"engineer","observations":["likes Go","speaks French"]}
+{"type":"entity","name":"Bob","entityType":"person","observations":["likes tea"]}
+{"type":"relation","from":"Bob","to":"Alice","relationType":"knows"}
+{"type":"relation","from":"Alice","to":"Acme","relationType":"works_at"}`
+
+// quote returns s as a JSON string literal.
+func quote(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+func TestImport_Strategies(t *testing.T) {
+	for _, tc := range []struct {
+		strategy   string
+		aliceType  string
+		aliceObs   []string
+		created    int
+		updated    int
+		skipped    int
+		obsAdded   int
+		relCreated int
+	}{
+		{"skip", "person", []string{"likes Go", "lives in Berlin"}, 1, 0, 1, 1, 1},
+		{"append", "person", []string{"likes Go", "lives in Berlin", "speaks French"}, 1, 1, 0, 2, 1},
+		{"overwrite", "engineer", []string{"likes Go", "speaks French"}, 1, 1, 0, 2, 1},
+	} {
+		t.Run(tc.strategy, func(t *testing.T) {
+			h := newTestHandler(t)
+			seed(t, h)
+
+			res := mustCall(t, h.ImportGraph, `{"format": "jsonl", "strategy": "`+tc.strategy+`", "content": `+quote(importDoc)+`}`).(models.ImportResult)
+			want := models.ImportResult{
+				Strategy: tc.strategy, EntitiesCreated: tc.created, EntitiesUpdated: tc.updated, EntitiesSkipped: tc.skipped,
+				ObservationsAdded: tc.obsAdded, RelationsCreated: tc.relCreated, RelationsSkipped: 1,
+			}
+			if !reflect.DeepEqual(res, want) {
+				t.Errorf("ImportGraph() = %+v, want %+v", res, want)
+			}
+
+			alice := mustCall(t, h.OpenNodes, `{"names": ["Alice"]}`).(models.KnowledgeGraph).Entities[0]
+			if alice.EntityType != tc.aliceType || !reflect.DeepEqual(alice.Observations, tc.aliceObs) {
+				t.Errorf("Alice = %+v", alice)
+			}
+		})
+	}
+}
+
+func TestImport_OverwriteKeepsProvenanceOfKeptFacts(t *testing.T) {
+	h := newTestHandler(t)
+	mustCall(t, h.CreateEntities, `{"entities": [{"name": "Alice", "entityType": "person", "observations": ["likes Go", "lives in Berlin"]}], "source": "chat"}`)
+	mustCall(t, h.CorrectObservation, `{"entityName": "Alice", "oldObservation": "lives in Berlin", "newObservation": "lives in Munich"}`)
+
+	mustCall(t, h.ImportGraph, `{"format": "jsonl", "strategy": "overwrite", "source": "backup", "content": `+
+		quote(`{"type":"entity","name":"Alice","entityType":"person","observations":["likes Go","lives in Bonn"]}`)+`}`)
+
+	details := mustCall(t, h.OpenNodes, `{"names": ["Alice"], "includeSuperseded": true, "provenance": true}`).(models.KnowledgeGraph).Entities[0].ObservationDetails
+	if len(details) != 2 || details[0].Content != "likes Go" || details[0].Source != "chat" ||
+		details[1].Content != "lives in Bonn" || details[1].Source != "backup" {
+		t.Errorf("details = %+v", details)
+	}
+
+	// An entity without observations in the import loses all of them.
+	mustCall(t, h.ImportGraph, `{"strategy": "overwrite", "content": `+quote(`{"type":"entity","name":"Alice","entityType":"person","observations":[]}`)+`}`)
+	if got := readGraph(t, h).Entities[0].Observations; len(got) != 0 {
+		t.Errorf("observations = %v, want none", got)
+	}
+}
+
+func TestImport_CombinesDuplicatesAndCreatesEndpoints(t *testing.T) {
+	h := newTestHandler(t)
+	doc := `{"type":"entity","name":"Alice","entityType":"person","observations":["likes Go"]}
+{"type":"entity","name":"Alice","entityType":"person","observations":["likes Go","lives in Berlin"]}
+{"type":"relation","from":"Alice","to":"Acme","relationType":"works_at"}
+{"type":"relation","from":"Alice","to":"Acme","relationType":"works_at"}`
+	res := mustCall(t, h.ImportGraph, `{"content": `+quote(doc)+`}`).(models.ImportResult)
+	if res.EntitiesCreated != 2 || res.ObservationsAdded != 2 || res.RelationsCreated != 1 || res.RelationsSkipped != 1 {
+		t.Errorf("ImportGraph() = %+v", res)
+	}
+
+	graph := readGraph(t, h)
+	if len(graph.Entities) != 2 || graph.Entities[0].Name != "Acme" || graph.Entities[0].EntityType != "unknown" {
+		t.Errorf("entities = %+v", graph.Entities)
+	}
+}
+
+func TestImport_Errors(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	before := readGraph(t, h)
+
+	graph := models.KnowledgeGraph{Entities: []models.Entity{
+		{Name: "Bob", EntityType: "person", Observations: []string{"likes tea"}},
+	}}
+	if _, err := h.Import("", graph, "replace", ""); err == nil || !strings.Contains(err.Error(), "unknown strategy") {
+		t.Errorf("Import() error = %v, want an unknown strategy error", err)
+	}
+	if _, err := h.ImportGraph(args(t, `{"format": "jsonl", "content": "{\"type\":\"relation\",\"from\":\"Bob\"}"}`)); err == nil {
+		t.Error("importing a relation without to should fail")
+	}
+	if got := readGraph(t, h); !reflect.DeepEqual(got, before) {
+		t.Errorf("graph changed after rejected imports: %+v", got)
+	}
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	for _, format := range []string{"jsonl", "jsonld", "graphml"} {
+		t.Run(format, func(t *testing.T) {
+			h := newTestHandler(t)
+			seed(t, h)
+			mustCall(t, h.CorrectObservation, `{"entityName": "Alice", "oldObservation": "lives in Berlin", "newObservation": "lives in Munich"}`)
+
+			exported := mustCall(t, h.ExportGraph, `{"format": "`+format+`"}`).(models.ExportResult)
+			if exported.Entities != 2 || exported.Relations != 1 || strings.Contains(exported.Content, "Berlin") {
+				t.Errorf("ExportGraph() = %+v", exported)
+			}
+
+			mustCall(t, h.ImportGraph, `{"format": "`+format+`", "namespace": "copy", "content": `+quote(exported.Content)+`}`)
+			if got, want := mustCall(t, h.ReadGraph, `{"namespace": "copy"}`).(models.GraphPage).KnowledgeGraph, readGraph(t, h); !reflect.DeepEqual(got, want) {
+				t.Errorf("imported graph = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
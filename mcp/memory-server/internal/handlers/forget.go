@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+// expirySchema lets facts expire and indexes observation times for retention.
+const expirySchema = `
+ALTER TABLE observations ADD COLUMN expires_at TEXT;
+CREATE INDEX observations_expires ON observations(expires_at) WHERE expires_at IS NOT NULL;
+CREATE INDEX observations_created ON observations(created_at);
+`
+
+// Reasons reported by sweeps, and by Forget for the facts a forgotten
+// observation had superseded.
+const (
+	ReasonExpired    = "expired"
+	ReasonRetention  = "retention"
+	ReasonSuperseded = "superseded"
+)
+
+// deleteBatch bounds the number of ids per DELETE statement.
+const deleteBatch = 500
+
+// formatTime formats t like the created_at column, so that times compare as strings.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// notExpired returns a condition on an expires_at column that leaves out
+// expired observations. Its argument is the current time from formatTime.
+func notExpired(column string) string {
+	return "(" + column + " IS NULL OR " + column + " > ?)"
+}
+
+// ParseAge parses a duration such as "90m", "72h" or "30d".
+func ParseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (want e.g. 90m, 72h or 30d)", s)
+	}
+	return d, nil
+}
+
+// timeOrAge parses an RFC 3339 time, or an age that is subtracted from now.
+func timeOrAge(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	age, err := ParseAge(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q (want RFC 3339 or an age such as 30d)", s)
+	}
+	return now.Add(-age), nil
+}
+
+// expiryOf reads the expiresAt (RFC 3339) and ttl (e.g. "7d") arguments. It
+// returns the zero time if neither is set, and fails for times not in the future.
+func expiryOf(m map[string]interface{}) (time.Time, error) {
+	expiresAt := getField(m, "expiresAt", "expires_at")
+	ttl := getField(m, "ttl")
+	switch {
+	case expiresAt != "" && ttl != "":
+		return time.Time{}, fmt.Errorf("set either expiresAt or ttl, not both")
+	case expiresAt != "":
+		t, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid expiresAt %q (want RFC 3339)", expiresAt)
+		}
+		if !t.After(time.Now()) {
+			return time.Time{}, fmt.Errorf("expiresAt %q is in the past", expiresAt)
+		}
+		return t, nil
+	case ttl != "":
+		d, err := ParseAge(ttl)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if d == 0 {
+			return time.Time{}, fmt.Errorf("ttl %q would expire the fact at once", ttl)
+		}
+		return time.Now().Add(d), nil
+	}
+	return time.Time{}, nil
+}
+
+// ParseRetention parses retention policies of the form "event=30d,session=24h":
+// observations of entities of a type are forgotten once they are older than its age.
+func ParseRetention(s string) (map[string]time.Duration, error) {
+	policies := make(map[string]time.Duration)
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		entType, age, ok := strings.Cut(part, "=")
+		entType = strings.TrimSpace(entType)
+		if !ok || entType == "" {
+			return nil, fmt.Errorf("invalid retention policy %q (want type=age)", part)
+		}
+		d, err := ParseAge(strings.TrimSpace(age))
+		if err != nil {
+			return nil, fmt.Errorf("retention of %q: %w", entType, err)
+		}
+		policies[entType] = d
+	}
+	return policies, nil
+}
+
+// SetRetention sets the maximum age of observations per entity type, enforced by Sweep.
+func (h *MemoryHandler) SetRetention(policies map[string]time.Duration) {
+	h.retention = policies
+}
+
+// getInt64s returns the numbers of an array argument; JSON numbers arrive as float64.
+func getInt64s(m map[string]interface{}, key string) []int64 {
+	var out []int64
+	if arr, ok := m[key].([]interface{}); ok {
+		for _, v := range arr {
+			if n, ok := v.(float64); ok {
+				out = append(out, int64(n))
+			}
+		}
+	}
+	return out
+}
+
+// Forget deletes entities, or the observations selected by observationIds, a
+// query and olderThan. Without further criteria, entityNames deletes the named
+// entities with everything attached to them; combined with other criteria it
+// restricts them to the observations of these entities. With dryRun nothing is
+// deleted. Forgotten observations are gone for good, including their history
+// and the facts they superseded, which would otherwise become current again.
+func (h *MemoryHandler) Forget(args map[string]interface{}) (interface{}, error) {
+	ns := h.namespaceOf(args)
+	names := getStrings(args, "entityNames", "names")
+	ids := getInt64s(args, "observationIds")
+	query := strings.TrimSpace(getField(args, "query"))
+	olderThan := getField(args, "olderThan", "older_than")
+	dryRun, _ := args["dryRun"].(bool)
+	if len(names) == 0 && len(ids) == 0 && query == "" && olderThan == "" {
+		return nil, fmt.Errorf("one of entityNames, observationIds, query or olderThan is required")
+	}
+
+	result := models.ForgetResult{DryRun: dryRun, Entities: []string{}, Observations: []models.ForgottenObservation{}}
+	err := h.inTx(func(tx *sql.Tx) error {
+		resolved, err := resolveNames(tx, ns, names)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 && query == "" && olderThan == "" {
+			in, inArgs := inClause(resolved)
+			rows, err := tx.Query("SELECT name FROM entities WHERE namespace = ? AND name IN "+in+" ORDER BY name",
+				append([]interface{}{ns}, inArgs...)...)
+			if err != nil {
+				return err
+			}
+			if result.Entities, err = scanStrings(rows); err != nil || dryRun {
+				return err
+			}
+			rows, err = tx.Query("SELECT id FROM observations WHERE namespace = ? AND entity_name IN "+in,
+				append([]interface{}{ns}, inArgs...)...)
+			if err != nil {
+				return err
+			}
+			obsIDs, err := scanInt64s(rows)
+			if err != nil {
+				return err
+			}
+			if _, err = tx.Exec("DELETE FROM entities WHERE namespace = ? AND name IN "+in, append([]interface{}{ns}, inArgs...)...); err != nil {
+				return err
+			}
+			return forgetEvents(tx, obsIDs)
+		}
+
+		where := "namespace = ?"
+		qArgs := []interface{}{ns}
+		if len(resolved) > 0 {
+			in, inArgs := inClause(resolved)
+			where += andIn("entity_name", in)
+			qArgs = append(qArgs, inArgs...)
+		}
+		if len(ids) > 0 {
+			in, inArgs := int64InClause(ids)
+			where += andIn("id", in)
+			qArgs = append(qArgs, inArgs...)
+		}
+		if query != "" {
+			// All words must match; forgetting everything that mentions any of them would be too much.
+			match := strings.Join(ftsTerms(query), " ")
+			if match == "" {
+				return fmt.Errorf("query has no words")
+			}
+			where += " AND id IN (SELECT obs_id FROM memory_fts WHERE memory_fts MATCH ? AND namespace = ? AND kind = 'observation')"
+			qArgs = append(qArgs, match, ns)
+		}
+		if olderThan != "" {
+			before, err := timeOrAge(olderThan, time.Now())
+			if err != nil {
+				return err
+			}
+			where += " AND created_at < ?"
+			qArgs = append(qArgs, formatTime(before))
+		}
+
+		rows, err := tx.Query("SELECT '', entity_name, id, content, '' FROM observations WHERE "+where+" ORDER BY id", qArgs...)
+		if err != nil {
+			return err
+		}
+		if result.Observations, err = h.scanForgotten(rows); err != nil {
+			return err
+		}
+		seen := make(map[int64]bool)
+		for _, o := range result.Observations {
+			seen[o.ID] = true
+		}
+
+		// The facts the selected observations superseded, and those they superseded in turn.
+		rows, err = tx.Query(`WITH RECURSIVE chain(id) AS (
+				SELECT id FROM observations WHERE superseded_by IN (SELECT id FROM observations WHERE `+where+`)
+				UNION
+				SELECT o.id FROM observations o JOIN chain c ON o.superseded_by = c.id
+			)
+			SELECT '', entity_name, id, content, ? FROM observations WHERE id IN (SELECT id FROM chain) ORDER BY id`,
+			append(qArgs, ReasonSuperseded)...)
+		if err != nil {
+			return err
+		}
+		superseded, err := h.scanForgotten(rows)
+		if err != nil {
+			return err
+		}
+		for _, o := range superseded {
+			if !seen[o.ID] {
+				seen[o.ID] = true
+				result.Observations = append(result.Observations, o)
+			}
+		}
+		if dryRun {
+			return nil
+		}
+		return deleteObservations(tx, result.Observations)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Sweep forgets the observations of all namespaces that have expired or that are
+// older than the retention of their entity type. Pinned observations, such as
+// summaries, are kept by retention. With dryRun nothing is deleted; otherwise
+// the sweep is reported to the audit hook.
+func (h *MemoryHandler) Sweep(dryRun bool) (models.ForgetResult, error) {
+	result := models.ForgetResult{DryRun: dryRun, Entities: []string{}, Observations: []models.ForgottenObservation{}}
+	now := time.Now()
+	err := h.inTx(func(tx *sql.Tx) error {
+		rows, err := tx.Query(`SELECT namespace, entity_name, id, content, ? FROM observations
+			WHERE expires_at <= ? ORDER BY id`, ReasonExpired, formatTime(now))
+		if err != nil {
+			return err
+		}
+		if result.Observations, err = h.scanForgotten(rows); err != nil {
+			return err
+		}
+		seen := make(map[int64]bool)
+		for _, o := range result.Observations {
+			seen[o.ID] = true
+		}
+
+		types := make([]string, 0, len(h.retention))
+		for t := range h.retention {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		for _, t := range types {
+			rows, err := tx.Query(`SELECT o.namespace, o.entity_name, o.id, o.content, ? FROM observations o
+				JOIN entities e ON e.namespace = o.namespace AND e.name = o.entity_name
+				WHERE e.type = ? AND o.pinned = 0 AND o.created_at <= ? ORDER BY o.id`,
+				ReasonRetention+":"+t, t, formatTime(now.Add(-h.retention[t])))
+			if err != nil {
+				return err
+			}
+			old, err := h.scanForgotten(rows)
+			if err != nil {
+				return err
+			}
+			for _, o := range old {
+				if !seen[o.ID] {
+					seen[o.ID] = true
+					result.Observations = append(result.Observations, o)
+				}
+			}
+		}
+		if dryRun {
+			return nil
+		}
+		return deleteObservations(tx, result.Observations)
+	})
+	switch {
+	case err != nil:
+		h.audit(AuditSweep, "", nil, err)
+	case !dryRun:
+		h.auditForgotten(AuditSweep, result.Observations)
+	}
+	return result, err
+}
+
+// scanForgotten reads namespace, entity name, id, content and reason rows and closes them.
+func (h *MemoryHandler) scanForgotten(rows *sql.Rows) ([]models.ForgottenObservation, error) {
+	defer rows.Close()
+	out := []models.ForgottenObservation{}
+	for rows.Next() {
+		var o models.ForgottenObservation
+		if err := rows.Scan(&o.Namespace, &o.EntityName, &o.ID, &o.Content, &o.Reason); err != nil {
+			return nil, err
+		}
+		var err error
+		if o.Content, err = h.unseal(o.Content); err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// deleteObservations deletes observations by id in batches and erases them from
+// the history.
+func deleteObservations(tx *sql.Tx, observations []models.ForgottenObservation) error {
+	ids := make([]int64, len(observations))
+	for i, o := range observations {
+		ids[i] = o.ID
+	}
+	for start := 0; start < len(ids); start += deleteBatch {
+		in, args := int64InClause(ids[start:min(start+deleteBatch, len(ids))])
+		if _, err := tx.Exec("DELETE FROM observations WHERE id IN "+in, args...); err != nil {
+			return err
+		}
+	}
+	return forgetEvents(tx, ids)
+}
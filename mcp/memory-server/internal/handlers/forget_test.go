@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+// contentsOf returns the contents of forgotten observations.
+func contentsOf(observations []models.ForgottenObservation) []string {
+	out := []string{}
+	for _, o := range observations {
+		out = append(out, o.Content)
+	}
+	return out
+}
+
+// age backdates the observations of an entity.
+func age(t *testing.T, h *MemoryHandler, name string, d time.Duration) {
+	t.Helper()
+	if _, err := h.db.Exec("UPDATE observations SET created_at = ? WHERE entity_name = ?", formatTime(time.Now().Add(-d)), name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expire lets the observations of an entity with the given content expire an
+// hour ago, which callers cannot do.
+func expire(t *testing.T, h *MemoryHandler, name, content string) {
+	t.Helper()
+	if _, err := h.db.Exec("UPDATE observations SET expires_at = ? WHERE entity_name = ? AND content = ?",
+		formatTime(time.Now().Add(-time.Hour)), name, content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseAgeAndRetention(t *testing.T) {
+	for in, want := range map[string]time.Duration{"90m": 90 * time.Minute, "72h": 72 * time.Hour, "7d": 7 * 24 * time.Hour} {
+		if got, err := ParseAge(in); err != nil || got != want {
+			t.Errorf("ParseAge(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "soon", "-1d", "1.5d"} {
+		if _, err := ParseAge(in); err == nil {
+			t.Errorf("ParseAge(%q) should fail", in)
+		}
+	}
+
+	got, err := ParseRetention("event=30d, session = 24h,")
+	if want := map[string]time.Duration{"event": 30 * 24 * time.Hour, "session": 24 * time.Hour}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRetention() = %v, %v, want %v", got, err, want)
+	}
+	if _, err := ParseRetention("event"); err == nil {
+		t.Error("ParseRetention() without an age should fail")
+	}
+}
+
+func TestExpiresAt(t *testing.T) {
+	h := newTestHandler(t)
+	mustCall(t, h.Memorize, `{"entity": "Oly", "observation": "is on vacation", "ttl": "7d"}`)
+	mustCall(t, h.AddObservations, `{"observations": [{"entityName": "Oly", "contents": ["flies to Rome"], "expiresAt": "2099-01-01T12:00:00+02:00"}]}`)
+	mustCall(t, h.AddObservations, `{"observations": [{"entityName": "Oly", "contents": ["likes tea"]}]}`)
+
+	details := mustCall(t, h.OpenNodes, `{"names": ["Oly"], "provenance": true}`).(models.KnowledgeGraph).Entities[0].ObservationDetails
+	expires, err := time.Parse(time.RFC3339, details[0].ExpiresAt)
+	if err != nil || expires.Sub(time.Now()) < 6*24*time.Hour {
+		t.Errorf("expiresAt = %q, want in 7 days", details[0].ExpiresAt)
+	}
+	if details[1].ExpiresAt != "2099-01-01T10:00:00Z" || details[2].ExpiresAt != "" {
+		t.Errorf("details = %+v", details)
+	}
+
+	for _, a := range []string{
+		`{"entity": "Oly", "observation": "x", "ttl": "soon"}`,
+		`{"entity": "Oly", "observation": "x", "expiresAt": "tomorrow"}`,
+		`{"entity": "Oly", "observation": "x", "ttl": "1d", "expiresAt": "2030-01-01T00:00:00Z"}`,
+		`{"entity": "Oly", "observation": "x", "expiresAt": "2020-01-01T00:00:00Z"}`,
+		`{"entity": "Oly", "observation": "x", "ttl": "0s"}`,
+	} {
+		if _, err := h.Memorize(args(t, a)); err == nil {
+			t.Errorf("Memorize(%s) should fail", a)
+		}
+	}
+	res := mustCall(t, h.AddObservations, `{"observations": [{"entityName": "Oly", "contents": ["x"], "expiresAt": "2020-01-01T00:00:00Z"}]}`).(models.AddObservationsResult)
+	if !res.HasFailures() || len(res.Results[0].AddedObservations) != 0 {
+		t.Errorf("AddObservations() with a past expiresAt = %+v", res.Results[0])
+	}
+}
+
+func TestExpiresAt_HidesExpiredFacts(t *testing.T) {
+	h := newTestHandler(t)
+	h.SetEmbedder(&conceptEmbedder{})
+	seed(t, h)
+	mustCall(t, h.AddObservations, `{"observations": [{"entityName": "Alice", "contents": ["drinks coffee"], "ttl": "1h"}]}`)
+	expire(t, h, "Alice", "drinks coffee")
+	expire(t, h, "Alice", "lives in Berlin")
+
+	if got := readGraph(t, h).Entities[1].Observations; !reflect.DeepEqual(got, []string{"likes Go"}) {
+		t.Errorf("ReadGraph() observations = %q", got)
+	}
+	if res := mustCall(t, h.SearchNodes, `{"query": "Berlin"}`).(models.SearchResult); res.Total != 0 {
+		t.Errorf("SearchNodes() = %+v", res.Matches)
+	}
+	if res := mustCall(t, h.SearchNodes, `{"query": "Berlin", "includeSuperseded": true}`).(models.SearchResult); res.Total != 0 {
+		t.Errorf("SearchNodes(includeSuperseded) = %+v", res.Matches)
+	}
+	if res := mustCall(t, h.SemanticSearch, `{"query": "beverage", "hybrid": false, "minScore": 0.5}`).(models.SearchResult); res.Total != 0 {
+		t.Errorf("SemanticSearch() = %+v", res.Matches)
+	}
+	if text, err := h.Context("", "Alice", 0); err != nil || strings.Contains(text, "Berlin") {
+		t.Errorf("Context() = %q, %v", text, err)
+	}
+
+	// An expired fact is no duplicate of a new one.
+	added := mustCall(t, h.AddObservations, `{"observations": [{"entityName": "Alice", "contents": ["Lives in Berlin"]}]}`).(models.AddObservationsResult)
+	if got := added.Results[0].AddedObservations; !reflect.DeepEqual(got, []string{"Lives in Berlin"}) {
+		t.Errorf("AddObservations() added %q", got)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	h := newTestHandler(t)
+	mustCall(t, h.CreateEntities, `{"entities": [
+		{"name": "Standup", "entityType": "event", "observations": ["was moved to 10:00", "is daily"]},
+		{"name": "Oly", "entityType": "person", "observations": ["likes tea"]}
+	]}`)
+	mustCall(t, h.AddObservations, `{"observations": [{"entityName": "Oly", "contents": ["is on vacation"], "ttl": "1h"}]}`)
+	expire(t, h, "Oly", "is on vacation")
+	age(t, h, "Standup", 48*time.Hour)
+	mustCall(t, h.Consolidate, `{"entityNames": ["Standup"]}`)
+	h.SetRetention(map[string]time.Duration{"event": 24 * time.Hour, "person": 24 * time.Hour})
+
+	report, err := h.Sweep(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The summary of the standup is pinned and kept; the archived originals are old.
+	if got := contentsOf(report.Observations); !reflect.DeepEqual(got, []string{"is on vacation", "was moved to 10:00", "is daily"}) {
+		t.Errorf("dry run = %v", got)
+	}
+	if report.Observations[0].Reason != ReasonExpired || report.Observations[1].Reason != "retention:event" || report.Observations[0].Namespace != "default" {
+		t.Errorf("dry run = %+v", report.Observations)
+	}
+	var stored int
+	h.db.QueryRow("SELECT COUNT(*) FROM observations WHERE entity_name = 'Oly'").Scan(&stored)
+	if stored != 2 {
+		t.Errorf("dry run deleted observations: %d left", stored)
+	}
+
+	if _, err := h.Sweep(false); err != nil {
+		t.Fatal(err)
+	}
+	graph := mustCall(t, h.ReadGraph, `{"includeSuperseded": true}`).(models.GraphPage)
+	if !reflect.DeepEqual(graph.Entities[0].Observations, []string{"likes tea"}) ||
+		!reflect.DeepEqual(graph.Entities[1].Observations, []string{"was moved to 10:00; is daily"}) {
+		t.Errorf("entities = %+v", graph.Entities)
+	}
+}
+
+func TestForget(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	mustCall(t, h.AddObservations, `{"observations": [{"entityName": "Alice", "contents": ["visited Acme in March", "visited Berlin"]}]}`)
+	age(t, h, "Acme", 40*24*time.Hour)
+
+	// A query matches observations containing all of its words.
+	res := mustCall(t, h.Forget, `{"query": "visited Berlin", "dryRun": true}`).(models.ForgetResult)
+	if !res.DryRun || !reflect.DeepEqual(contentsOf(res.Observations), []string{"visited Berlin"}) {
+		t.Errorf("Forget(query) = %+v", res)
+	}
+	mustCall(t, h.Forget, `{"query": "visited Berlin"}`)
+
+	res = mustCall(t, h.Forget, `{"olderThan": "30d"}`).(models.ForgetResult)
+	if got := contentsOf(res.Observations); len(got) != 1 || res.Observations[0].EntityName != "Acme" {
+		t.Errorf("Forget(olderThan) = %+v", res)
+	}
+
+	id := mustCall(t, h.OpenNodes, `{"names": ["Alice"], "provenance": true}`).(models.KnowledgeGraph).Entities[0].ObservationDetails[0].ID
+	res = mustCall(t, h.Forget, `{"observationIds": [`+strconv.FormatInt(id, 10)+`], "entityNames": ["Acme"]}`).(models.ForgetResult)
+	if len(res.Observations) != 0 {
+		t.Errorf("Forget() of another entity's observation = %+v", res)
+	}
+
+	res = mustCall(t, h.Forget, `{"entityNames": ["alice", "Nobody"]}`).(models.ForgetResult)
+	if !reflect.DeepEqual(res.Entities, []string{"Alice"}) {
+		t.Errorf("Forget(entityNames) = %+v", res)
+	}
+	graph := readGraph(t, h)
+	if len(graph.Entities) != 1 || len(graph.Relations) != 0 {
+		t.Errorf("graph = %+v", graph)
+	}
+
+	if _, err := h.Forget(args(t, `{}`)); err == nil {
+		t.Error("Forget() without criteria should fail")
+	}
+}
+
+func TestForget_CorrectedFact(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	mustCall(t, h.CorrectObservation, `{"entityName": "Alice", "oldObservation": "lives in Berlin", "newObservation": "lives in Munich"}`)
+	mustCall(t, h.CorrectObservation, `{"entityName": "Alice", "oldObservation": "lives in Munich", "newObservation": "lives in Hamburg"}`)
+
+	// The facts a forgotten correction replaced are forgotten with it.
+	res := mustCall(t, h.Forget, `{"query": "Hamburg", "dryRun": true}`).(models.ForgetResult)
+	want := []models.ForgottenObservation{
+		{EntityName: "Alice", ID: res.Observations[0].ID, Content: "lives in Hamburg"},
+		{EntityName: "Alice", ID: res.Observations[1].ID, Content: "lives in Berlin", Reason: ReasonSuperseded},
+		{EntityName: "Alice", ID: res.Observations[2].ID, Content: "lives in Munich", Reason: ReasonSuperseded},
+	}
+	if !reflect.DeepEqual(res.Observations, want) {
+		t.Errorf("Forget(dryRun) = %+v, want %+v", res.Observations, want)
+	}
+	mustCall(t, h.Forget, `{"query": "Hamburg"}`)
+
+	graph := mustCall(t, h.OpenNodes, `{"names": ["Alice"], "includeSuperseded": true}`).(models.KnowledgeGraph)
+	if got := graph.Entities[0].Observations; !reflect.DeepEqual(got, []string{"likes Go"}) {
+		t.Errorf("observations = %v", got)
+	}
+	for _, e := range mustCall(t, h.Changes, `{"kinds": ["observation"]}`).(models.ChangesResult).Events {
+		if strings.Contains(e.Content, "lives in") {
+			t.Errorf("history still has %+v", e)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+// graphOptions control which observations loadGraphWith returns.
+type graphOptions struct {
+	// includeSuperseded also returns facts that were corrected later.
+	includeSuperseded bool
+	// provenance fills Entity.ObservationDetails and Entity.UpdatedAt.
+	provenance bool
+	// outgoing returns the relations starting at the entities, wherever they end,
+	// instead of those between them.
+	outgoing bool
+}
+
+// graphOptionsOf reads the includeSuperseded and provenance arguments.
+func graphOptionsOf(args map[string]interface{}) graphOptions {
+	var opts graphOptions
+	opts.includeSuperseded, _ = args["includeSuperseded"].(bool)
+	opts.provenance, _ = args["provenance"].(bool)
+	return opts
+}
+
+// Paging of ReadGraph.
+const (
+	defaultReadGraphLimit = 100
+	maxReadGraphLimit     = 1000
+)
+
+// entityTimestamps records in entities.updated_at when an entity, its type or its
+// observations last changed. Entities of older databases get the time of their
+// newest observation, or none when it is unknown. The search index trigger is
+// narrowed to the indexed columns so that touching an entity does not reindex it.
+const entityTimestamps = `
+ALTER TABLE entities ADD COLUMN updated_at TEXT;
+UPDATE entities SET updated_at = (SELECT MAX(o.created_at) FROM observations o
+	WHERE o.namespace = entities.namespace AND o.entity_name = entities.name);
+CREATE INDEX entities_updated ON entities(namespace, updated_at);
+
+DROP TRIGGER entities_fts_update;
+CREATE TRIGGER entities_fts_update AFTER UPDATE OF namespace, name, type ON entities BEGIN
+	DELETE FROM memory_fts WHERE kind = 'entity' AND namespace = old.namespace AND entity_name = old.name;
+	INSERT INTO memory_fts (namespace, entity_name, kind, obs_id, content)
+	VALUES (new.namespace, new.name, 'entity', NULL, new.name || ' ' || COALESCE(new.type, ''));
+END;
+
+CREATE TRIGGER entities_touch_insert AFTER INSERT ON entities WHEN new.updated_at IS NULL BEGIN
+	UPDATE entities SET updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE namespace = new.namespace AND name = new.name;
+END;
+CREATE TRIGGER entities_touch_update AFTER UPDATE OF type ON entities BEGIN
+	UPDATE entities SET updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE namespace = new.namespace AND name = new.name;
+END;
+CREATE TRIGGER observations_touch_insert AFTER INSERT ON observations BEGIN
+	UPDATE entities SET updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE namespace = new.namespace AND name = new.entity_name;
+END;
+CREATE TRIGGER observations_touch_update AFTER UPDATE OF entity_name, content, superseded_by ON observations BEGIN
+	UPDATE entities SET updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE namespace = new.namespace AND name = new.entity_name;
+END;
+CREATE TRIGGER observations_touch_delete AFTER DELETE ON observations BEGIN
+	UPDATE entities SET updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE namespace = old.namespace AND name = old.entity_name;
+END;
+`
+
+// ReadGraph returns one page of the namespace: entities in name order with their
+// observations and the relations starting at them. Entities can be filtered by
+// type and by the time of their last change. A page takes five queries however
+// large it is.
+func (h *MemoryHandler) ReadGraph(args map[string]interface{}) (interface{}, error) {
+	ns := h.namespaceOf(args)
+	limit := clampInt(args, "limit", defaultReadGraphLimit, maxReadGraphLimit)
+
+	conds, params := []string{"namespace = ?"}, []interface{}{ns}
+	if cursor := getField(args, "cursor"); cursor != "" {
+		after, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor %q", cursor)
+		}
+		conds = append(conds, "name > ?")
+		params = append(params, string(after))
+	}
+	if types := getStrings(args, "entityTypes"); len(types) > 0 {
+		in, typeArgs := inClause(types)
+		conds = append(conds, "type IN "+in)
+		params = append(params, typeArgs...)
+	}
+	if since := getField(args, "updatedSince"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return nil, fmt.Errorf("updatedSince must be an RFC 3339 time such as 2024-05-01T00:00:00Z, got %q", since)
+		}
+		conds = append(conds, "updated_at >= ?")
+		params = append(params, t.UTC().Format(time.RFC3339))
+	}
+
+	// One extra row tells whether there is a next page.
+	rows, err := h.db.Query("SELECT name FROM entities WHERE "+strings.Join(conds, " AND ")+" ORDER BY name LIMIT ?", append(params, limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+	names, err := scanStrings(rows)
+	if err != nil {
+		return nil, err
+	}
+	var page models.GraphPage
+	if len(names) > limit {
+		names = names[:limit]
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(names[limit-1]))
+	}
+
+	opts := graphOptionsOf(args)
+	opts.outgoing = true
+	page.KnowledgeGraph, err = h.loadGraphWith(ns, names, opts)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// loadGraph loads the named entities of a namespace with their current observations
+// and the relations between them. A nil slice loads the whole namespace.
+func (h *MemoryHandler) loadGraph(ns string, names []string) (models.KnowledgeGraph, error) {
+	return h.loadGraphWith(ns, names, graphOptions{})
+}
+
+// loadGraphWith is loadGraph with options.
+func (h *MemoryHandler) loadGraphWith(ns string, names []string, opts graphOptions) (models.KnowledgeGraph, error) {
+	graph := models.KnowledgeGraph{Entities: []models.Entity{}, Relations: []models.Relation{}}
+	if names != nil && len(names) == 0 {
+		return graph, nil
+	}
+
+	filter, args := "", []interface{}{ns}
+	if names != nil {
+		var in string
+		in, args = inClause(names)
+		filter = in
+		args = append([]interface{}{ns}, args...)
+	}
+
+	rows, err := h.db.Query("SELECT name, type, updated_at FROM entities WHERE namespace = ?"+andIn("name", filter)+" ORDER BY name", args...)
+	if err != nil {
+		return graph, err
+	}
+	index := make(map[string]int)
+	for rows.Next() {
+		var e models.Entity
+		var entType, updatedAt sql.NullString
+		if err := rows.Scan(&e.Name, &entType, &updatedAt); err != nil {
+			rows.Close()
+			return graph, err
+		}
+		e.EntityType = entType.String
+		if opts.provenance {
+			e.UpdatedAt = updatedAt.String
+		}
+		e.Observations = []string{}
+		index[e.Name] = len(graph.Entities)
+		graph.Entities = append(graph.Entities, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return graph, err
+	}
+
+	current := " AND superseded_by IS NULL"
+	if opts.includeSuperseded {
+		current = ""
+	}
+	rows, err = h.db.Query("SELECT "+observationColumns+", entity_name FROM observations WHERE namespace = ?"+andIn("entity_name", filter)+current+" AND "+notExpired("expires_at")+" ORDER BY id",
+		append(slices.Clip(args), formatTime(time.Now()))...)
+	if err != nil {
+		return graph, err
+	}
+	for rows.Next() {
+		var name string
+		o, err := h.scanObservation(rows, &name)
+		if err != nil {
+			rows.Close()
+			return graph, err
+		}
+		if i, ok := index[name]; ok {
+			e := &graph.Entities[i]
+			e.Observations = append(e.Observations, o.Content)
+			if opts.provenance {
+				e.ObservationDetails = append(e.ObservationDetails, o)
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return graph, err
+	}
+
+	rows, err = h.db.Query("SELECT entity_name, alias FROM aliases WHERE namespace = ?"+andIn("entity_name", filter)+" AND alias <> entity_name ORDER BY alias", args...)
+	if err != nil {
+		return graph, err
+	}
+	for rows.Next() {
+		var name, alias string
+		if err := rows.Scan(&name, &alias); err != nil {
+			rows.Close()
+			return graph, err
+		}
+		if i, ok := index[name]; ok {
+			graph.Entities[i].Aliases = append(graph.Entities[i].Aliases, alias)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return graph, err
+	}
+
+	relFilter := ""
+	relArgs := args
+	switch {
+	case names != nil && opts.outgoing:
+		relFilter = " AND from_name IN " + filter
+	case names != nil:
+		relFilter = " AND from_name IN " + filter + " AND to_name IN " + filter
+		relArgs = append(append([]interface{}{}, args...), args[1:]...)
+	}
+	rows, err = h.db.Query("SELECT from_name, to_name, type FROM relations WHERE namespace = ?"+relFilter+" ORDER BY from_name, to_name, type", relArgs...)
+	if err != nil {
+		return graph, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r models.Relation
+		if err := rows.Scan(&r.From, &r.To, &r.RelationType); err != nil {
+			return graph, err
+		}
+		graph.Relations = append(graph.Relations, r)
+	}
+	return graph, rows.Err()
+}
+
+// inClause returns a parenthesized placeholder list and its arguments.
+func inClause(values []string) (string, []interface{}) {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return "(" + strings.TrimSuffix(strings.Repeat("?,", len(values)), ",") + ")", args
+}
+
+// andIn returns a condition restricting column to an IN list, or nothing without a list.
+func andIn(column, in string) string {
+	if in == "" {
+		return ""
+	}
+	return " AND " + column + " IN " + in
+}
+
+// scanStrings reads a single string column from all rows and closes them.
+func scanStrings(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+	out := []string{}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// scanInt64s reads a single integer column from all rows and closes them.
+func scanInt64s(rows *sql.Rows) ([]int64, error) {
+	defer rows.Close()
+	out := []int64{}
+	for rows.Next() {
+		var n int64
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+func TestReadGraph_Pages(t *testing.T) {
+	h := newTestHandler(t)
+	var entities []string
+	for i := 0; i < 25; i++ {
+		entities = append(entities, fmt.Sprintf(`{"name": "E%02d", "entityType": "item", "observations": ["number %d"]}`, i, i))
+	}
+	mustCall(t, h.CreateEntities, `{"entities": [`+strings.Join(entities, ",")+`]}`)
+	// Relations pointing backwards cross page boundaries.
+	mustCall(t, h.CreateRelations, `{"relations": [
+		{"from": "E10", "to": "E00", "relationType": "follows"},
+		{"from": "E24", "to": "E09", "relationType": "follows"}
+	]}`)
+
+	var names []string
+	var relations []models.Relation
+	cursor, pages := "", 0
+	for {
+		page := mustCall(t, h.ReadGraph, `{"limit": 10, "cursor": "`+cursor+`"}`).(models.GraphPage)
+		pages++
+		for _, e := range page.Entities {
+			names = append(names, e.Name)
+			if len(e.Observations) != 1 {
+				t.Errorf("%s observations = %v", e.Name, e.Observations)
+			}
+		}
+		relations = append(relations, page.Relations...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if pages != 3 || len(names) != 25 || names[0] != "E00" || names[24] != "E24" {
+		t.Errorf("read %d pages with %v", pages, names)
+	}
+	if want := readGraph(t, h).Relations; !reflect.DeepEqual(relations, want) {
+		t.Errorf("relations = %+v, want each once: %+v", relations, want)
+	}
+
+	// A page that ends exactly at the last entity has no cursor.
+	if page := mustCall(t, h.ReadGraph, `{"limit": 25}`).(models.GraphPage); page.NextCursor != "" {
+		t.Errorf("NextCursor = %q on the last page", page.NextCursor)
+	}
+	if _, err := h.ReadGraph(args(t, `{"cursor": "not base64!"}`)); err == nil {
+		t.Error("an invalid cursor should fail")
+	}
+}
+
+func TestReadGraph_Filters(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	mustCall(t, h.CreateEntities, `{"entities": [{"name": "Bob", "entityType": "person", "observations": []}]}`)
+
+	page := mustCall(t, h.ReadGraph, `{"entityTypes": ["person"]}`).(models.GraphPage)
+	if len(page.Entities) != 2 || page.Entities[0].Name != "Alice" || page.Entities[1].Name != "Bob" {
+		t.Errorf("entities = %+v", page.Entities)
+	}
+	// Relations to entities outside the filter are kept.
+	if len(page.Relations) != 1 || page.Relations[0].To != "Acme" {
+		t.Errorf("relations = %+v", page.Relations)
+	}
+
+	// Only Alice changed after the cut-off.
+	if _, err := h.db.Exec("UPDATE entities SET updated_at = '2024-01-01T00:00:00Z'"); err != nil {
+		t.Fatal(err)
+	}
+	mustCall(t, h.AddObservations, `{"observations": [{"entityName": "Alice", "contents": ["speaks French"]}]}`)
+	page = mustCall(t, h.ReadGraph, `{"updatedSince": "2025-01-01T00:00:00+02:00", "provenance": true}`).(models.GraphPage)
+	if len(page.Entities) != 1 || page.Entities[0].Name != "Alice" || page.Entities[0].UpdatedAt <= "2025" {
+		t.Errorf("entities = %+v", page.Entities)
+	}
+	if page := mustCall(t, h.ReadGraph, `{"updatedSince": "2023-12-31T00:00:00Z"}`).(models.GraphPage); len(page.Entities) != 3 {
+		t.Errorf("entities = %+v", page.Entities)
+	}
+	if _, err := h.ReadGraph(args(t, `{"updatedSince": "yesterday"}`)); err == nil || !strings.Contains(err.Error(), "RFC 3339") {
+		t.Errorf("ReadGraph() error = %v", err)
+	}
+}
+
+func TestEntityTimestamps(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	updatedAt := func(name string) string {
+		t.Helper()
+		var ts string
+		if err := h.db.QueryRow("SELECT COALESCE(updated_at, '') FROM entities WHERE name = ?", name).Scan(&ts); err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+	if updatedAt("Alice") == "" {
+		t.Fatal("new entity has no updated_at")
+	}
+
+	// Every kind of change touches the entity.
+	for _, change := range []func(){
+		func() {
+			mustCall(t, h.AddObservations, `{"observations": [{"entityName": "Alice", "contents": ["speaks French"]}]}`)
+		},
+		func() {
+			mustCall(t, h.DeleteObservations, `{"deletions": [{"entityName": "Alice", "observations": ["speaks French"]}]}`)
+		},
+		func() {
+			mustCall(t, h.CorrectObservation, `{"entityName": "Alice", "oldObservation": "likes Go", "newObservation": "likes Rust"}`)
+		},
+		func() { h.db.Exec("UPDATE entities SET type = 'engineer' WHERE name = 'Alice'") },
+	} {
+		h.db.Exec("UPDATE entities SET updated_at = '2024-01-01T00:00:00Z'")
+		change()
+		if got := updatedAt("Alice"); got <= "2024-01-01T00:00:00Z" {
+			t.Errorf("updated_at = %q after a change", got)
+		}
+		if got := updatedAt("Acme"); got != "2024-01-01T00:00:00Z" {
+			t.Errorf("unchanged Acme updated_at = %q", got)
+		}
+	}
+}
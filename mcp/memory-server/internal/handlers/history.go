@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+// provenanceSchema records when and by whom a fact was stated, how sure they were,
+// and which later observation replaced it. Rows from before have no timestamp.
+// Deleting a correction brings back the fact it replaced.
+const provenanceSchema = `
+ALTER TABLE observations ADD COLUMN created_at TEXT;
+ALTER TABLE observations ADD COLUMN source TEXT;
+ALTER TABLE observations ADD COLUMN confidence REAL;
+ALTER TABLE observations ADD COLUMN superseded_by INTEGER;
+CREATE INDEX observations_superseded ON observations(superseded_by);
+CREATE TRIGGER observations_supersede_delete AFTER DELETE ON observations BEGIN
+	UPDATE observations SET superseded_by = NULL WHERE superseded_by = old.id;
+END;
+`
+
+// execer is implemented by *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// provenance describes who stated a fact, how sure they were and until when the
+// fact holds.
+type provenance struct {
+	source     string
+	confidence sql.NullFloat64
+	expiresAt  sql.NullString
+}
+
+// provenanceOf reads the source, confidence, expiresAt and ttl arguments, falling
+// back to def for the ones that are missing.
+func provenanceOf(m map[string]interface{}, def provenance) (provenance, error) {
+	p := def
+	if source := getField(m, "source", "agent"); source != "" {
+		p.source = source
+	}
+	if v, ok := m["confidence"].(float64); ok {
+		if v < 0 || v > 1 {
+			return p, fmt.Errorf("confidence must be between 0 and 1, got %v", v)
+		}
+		p.confidence = sql.NullFloat64{Float64: v, Valid: true}
+	}
+	expires, err := expiryOf(m)
+	if err != nil {
+		return p, err
+	}
+	if !expires.IsZero() {
+		p.expiresAt = sql.NullString{String: formatTime(expires), Valid: true}
+	}
+	return p, nil
+}
+
+// insertObservation stores a new observation and returns its id.
+func (h *MemoryHandler) insertObservation(ex execer, ns, name, content string, p provenance) (int64, error) {
+	res, err := ex.Exec(`INSERT INTO observations (namespace, entity_name, content, created_at, source, confidence, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		ns, name, h.seal(content), formatTime(time.Now()), sql.NullString{String: p.source, Valid: p.source != ""}, p.confidence, p.expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// CorrectObservation replaces a fact about an entity with a corrected one. The old
+// observation is kept as history: it is marked as superseded and hidden from reads
+// and searches unless they ask for superseded facts. The entity name is resolved
+// through aliases.
+func (h *MemoryHandler) CorrectObservation(args map[string]interface{}) (interface{}, error) {
+	name := getField(args, "entityName", "entity_name", "name")
+	oldContent := getField(args, "oldObservation", "old_observation")
+	newContent := getField(args, "newObservation", "new_observation")
+	if name == "" || oldContent == "" || newContent == "" {
+		return nil, fmt.Errorf("entityName, oldObservation and newObservation are required")
+	}
+	prov, err := provenanceOf(args, provenance{})
+	if err != nil {
+		return nil, err
+	}
+	newContent, warning, err := h.redact(newContent)
+	if err != nil {
+		return nil, fmt.Errorf("newObservation %w", err)
+	}
+	// Compared after redaction: a fact must not supersede itself.
+	if oldContent == newContent {
+		return nil, fmt.Errorf("newObservation is the same as oldObservation")
+	}
+	ns := h.namespaceOf(args)
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	names, err := resolveNames(tx, ns, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	name = names[0]
+	oldID, err := h.currentObservation(tx, ns, name, oldContent)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("entity %q has no current observation %q", name, oldContent)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// A correction to a fact the entity already has just retires the old one.
+	newID, err := h.currentObservation(tx, ns, name, newContent)
+	if errors.Is(err, sql.ErrNoRows) {
+		newID, err = h.insertObservation(tx, ns, name, newContent, prov)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("UPDATE observations SET superseded_by = ? WHERE id = ?", newID, oldID); err != nil {
+		return nil, err
+	}
+
+	superseded, err := h.observationByID(tx, oldID)
+	if err != nil {
+		return nil, err
+	}
+	current, err := h.observationByID(tx, newID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return models.CorrectionResult{EntityName: name, Superseded: superseded, Current: current, Warning: warning}, nil
+}
+
+// currentObservation returns the id of the newest observation of an entity with
+// the given content that has neither been superseded nor expired.
+func (h *MemoryHandler) currentObservation(tx *sql.Tx, ns, name, content string) (int64, error) {
+	var id int64
+	err := tx.QueryRow(`SELECT id FROM observations
+		WHERE namespace = ? AND entity_name = ? AND content = ? AND superseded_by IS NULL AND `+notExpired("expires_at")+`
+		ORDER BY id DESC LIMIT 1`, ns, name, h.seal(content), formatTime(time.Now())).Scan(&id)
+	return id, err
+}
+
+// observationColumns are the columns read by scanObservation.
+const observationColumns = "id, content, created_at, source, confidence, superseded_by, pinned, expires_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanObservation reads the observationColumns of a row, followed by any extra
+// columns, and decrypts the content.
+func (h *MemoryHandler) scanObservation(row rowScanner, extra ...interface{}) (models.Observation, error) {
+	var o models.Observation
+	var createdAt, source, expiresAt sql.NullString
+	var confidence sql.NullFloat64
+	var supersededBy sql.NullInt64
+	dest := append([]interface{}{&o.ID, &o.Content, &createdAt, &source, &confidence, &supersededBy, &o.Pinned, &expiresAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return o, err
+	}
+	var err error
+	if o.Content, err = h.unseal(o.Content); err != nil {
+		return o, err
+	}
+	o.CreatedAt = createdAt.String
+	o.Source = source.String
+	o.ExpiresAt = expiresAt.String
+	if confidence.Valid {
+		o.Confidence = &confidence.Float64
+	}
+	if supersededBy.Valid {
+		o.SupersededBy = &supersededBy.Int64
+	}
+	return o, nil
+}
+
+func (h *MemoryHandler) observationByID(tx *sql.Tx, id int64) (models.Observation, error) {
+	return h.scanObservation(tx.QueryRow("SELECT "+observationColumns+" FROM observations WHERE id = ?", id))
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+func TestCorrectObservation_KeepsHistory(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+
+	res := mustCall(t, h.CorrectObservation, `{"entityName": "Alice", "oldObservation": "lives in Berlin",
+		"newObservation": "lives in Munich", "source": "chat-42", "confidence": 0.8}`).(models.CorrectionResult)
+	if res.Superseded.Content != "lives in Berlin" || res.Superseded.SupersededBy == nil || *res.Superseded.SupersededBy != res.Current.ID {
+		t.Errorf("superseded = %+v, current = %+v", res.Superseded, res.Current)
+	}
+	if res.Current.Source != "chat-42" || res.Current.Confidence == nil || *res.Current.Confidence != 0.8 || res.Current.CreatedAt == "" {
+		t.Errorf("current = %+v", res.Current)
+	}
+
+	// Reads and searches see only the correction.
+	if got := readGraph(t, h).Entities[1].Observations; !reflect.DeepEqual(got, []string{"likes Go", "lives in Munich"}) {
+		t.Errorf("observations = %v", got)
+	}
+	if r := mustCall(t, h.SearchNodes, `{"query": "Berlin"}`).(models.SearchResult); r.Total != 0 {
+		t.Errorf("search found a superseded fact: %+v", r.Matches)
+	}
+	if r := mustCall(t, h.SearchNodes, `{"query": "Berlin", "includeSuperseded": true}`).(models.SearchResult); r.Total != 1 {
+		t.Errorf("search with includeSuperseded = %+v", r.Matches)
+	}
+
+	// The history is still there on request.
+	graph := mustCall(t, h.OpenNodes, `{"names": ["Alice"], "includeSuperseded": true, "provenance": true}`).(models.KnowledgeGraph)
+	details := graph.Entities[0].ObservationDetails
+	if len(details) != 3 || details[1].Content != "lives in Berlin" || details[1].SupersededBy == nil {
+		t.Errorf("details = %+v", details)
+	}
+
+	// The old fact can no longer be corrected, and re-adding it is a new fact.
+	if _, err := h.CorrectObservation(args(t, `{"entityName": "Alice", "oldObservation": "lives in Berlin", "newObservation": "x"}`)); err == nil {
+		t.Error("correcting a superseded fact should fail")
+	}
+	added := mustCall(t, h.AddObservations, `{"observations": [{"entityName": "Alice", "contents": ["lives in Berlin"]}]}`).(models.AddObservationsResult)
+	if len(added.Results[0].AddedObservations) != 1 {
+		t.Errorf("AddObservations() = %+v, want the fact added again", added)
+	}
+}
+
+func TestCorrectObservation_DeletingCorrectionRestoresFact(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	mustCall(t, h.CorrectObservation, `{"entityName": "Alice", "oldObservation": "lives in Berlin", "newObservation": "lives in Munich"}`)
+	mustCall(t, h.DeleteObservations, `{"deletions": [{"entityName": "Alice", "observations": ["lives in Munich"]}]}`)
+
+	if got := readGraph(t, h).Entities[1].Observations; !reflect.DeepEqual(got, []string{"likes Go", "lives in Berlin"}) {
+		t.Errorf("observations = %v", got)
+	}
+}
+
+func TestCorrectObservation_Errors(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	for _, tc := range []struct{ args, want string }{
+		{`{"entityName": "Alice", "oldObservation": "likes Go"}`, "required"},
+		{`{"entityName": "Alice", "oldObservation": "likes Go", "newObservation": "likes Go"}`, "same"},
+		{`{"entityName": "Alice", "oldObservation": "likes Rust", "newObservation": "likes Go"}`, "no current observation"},
+		{`{"entityName": "Alice", "oldObservation": "likes Go", "newObservation": "likes Rust", "confidence": 2}`, "confidence"},
+	} {
+		_, err := h.CorrectObservation(args(t, tc.args))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("CorrectObservation(%s) error = %v, want %q", tc.args, err, tc.want)
+		}
+	}
+}
+
+func TestMergeEntities_KeepsCorrections(t *testing.T) {
+	h := newTestHandler(t)
+	mustCall(t, h.CreateEntities, `{"entities": [
+		{"name": "Alice", "entityType": "person", "observations": ["lives in Munich"]},
+		{"name": "A. Smith", "entityType": "person", "observations": ["lives in Berlin"]}
+	]}`)
+	mustCall(t, h.CorrectObservation, `{"entityName": "A. Smith", "oldObservation": "lives in Berlin", "newObservation": "lives in Munich"}`)
+	mustCall(t, h.MergeEntities, `{"targetName": "Alice", "sourceNames": ["A. Smith"]}`)
+
+	graph := mustCall(t, h.OpenNodes, `{"names": ["Alice"], "includeSuperseded": true, "provenance": true}`).(models.KnowledgeGraph)
+	details := graph.Entities[0].ObservationDetails
+	if len(details) != 2 || details[1].Content != "lives in Berlin" || details[1].SupersededBy == nil || *details[1].SupersededBy != details[0].ID {
+		t.Errorf("details = %+v", details)
+	}
+}
+
+func TestSemanticSearch_SkipsSupersededFacts(t *testing.T) {
+	h := newTestHandler(t)
+	h.SetEmbedder(&conceptEmbedder{})
+	mustCall(t, h.CreateEntities, `{"entities": [{"name": "Oly", "entityType": "person", "observations": ["likes coffee"]}]}`)
+	mustCall(t, h.CorrectObservation, `{"entityName": "Oly", "oldObservation": "likes coffee", "newObservation": "plays chess"}`)
+
+	res := mustCall(t, h.SemanticSearch, `{"query": "beverage", "hybrid": false, "minScore": 0.5}`).(models.SearchResult)
+	if res.Total != 0 {
+		t.Errorf("SemanticSearch() = %+v, want no match", res.Matches)
+	}
+}
@@ -3,46 +3,95 @@ package handlers
 import (
 	"database/sql"
 	"fmt"
+	"net/url"
 	"strings"
+	"time"
+
+	"github.com/mlcmcp/memory-server/internal/embedding"
+	"github.com/mlcmcp/memory-server/internal/models"
+	"github.com/mlcmcp/memory-server/internal/ontology"
+	"github.com/mlcmcp/memory-server/internal/privacy"
+	"github.com/mlcmcp/memory-server/internal/summarize"
 
 	_ "modernc.org/sqlite"
 )
 
 type MemoryHandler struct {
-	db *sql.DB
+	db         *sql.DB
+	embedder   embedding.Embedder
+	summarizer summarize.Summarizer
+	retention  map[string]time.Duration
+	ontology   *ontology.Ontology
+	redaction  privacy.Policy
+	cipher     *privacy.Cipher
+	namespace  string
+	auditHook  func(AuditEvent)
 }
 
 func NewMemoryHandler(dbPath string) (*MemoryHandler, error) {
-	db, err := sql.Open("sqlite", dbPath)
-	if err != nil {
+	// Migrations rebuild tables, which must not cascade, so they run on a
+	// connection without foreign key enforcement.
+	if _, _, err := Migrate(dbPath); err != nil {
 		return nil, err
 	}
 
-	schema := `
-	CREATE TABLE IF NOT EXISTS entities (
-		name TEXT PRIMARY KEY,
-		type TEXT
-	);
-	CREATE TABLE IF NOT EXISTS observations (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		entity_name TEXT,
-		content TEXT,
-		FOREIGN KEY(entity_name) REFERENCES entities(name) ON DELETE CASCADE
-	);
-	CREATE TABLE IF NOT EXISTS relations (
-		from_name TEXT,
-		to_name TEXT,
-		type TEXT,
-		PRIMARY KEY(from_name, to_name, type),
-		FOREIGN KEY(from_name) REFERENCES entities(name) ON DELETE CASCADE,
-		FOREIGN KEY(to_name) REFERENCES entities(name) ON DELETE CASCADE
-	);
-	`
-	if _, err := db.Exec(schema); err != nil {
+	// Tool calls of several clients and background sweeps and consolidation run
+	// concurrently. In WAL mode readers do not block the writer; writers wait for
+	// each other, and transactions take the write lock up front so that a
+	// transaction that reads first cannot fail on a snapshot that went stale.
+	db, err := sql.Open("sqlite", withPragmas(dbPath, "foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)")+"&_txlock=immediate")
+	if err != nil {
 		return nil, err
 	}
+	return &MemoryHandler{db: db, summarizer: summarize.Extractive{}, namespace: DefaultNamespace}, nil
+}
+
+// withPragmas adds pragmas to a database path. They are applied to every connection.
+func withPragmas(dbPath string, pragmas ...string) string {
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	for _, p := range pragmas {
+		dbPath += sep + "_pragma=" + url.QueryEscape(p)
+		sep = "&"
+	}
+	return dbPath
+}
+
+// inTx runs fn in a transaction. It is committed if fn succeeds and rolled back otherwise.
+func (h *MemoryHandler) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-	return &MemoryHandler{db: db}, nil
+// Close closes the underlying database.
+func (h *MemoryHandler) Close() error {
+	return h.db.Close()
+}
+
+// SetDefaultNamespace sets the namespace used by tool calls without a namespace argument.
+func (h *MemoryHandler) SetDefaultNamespace(namespace string) {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	h.namespace = namespace
+}
+
+// namespaceOf returns the namespace a tool call works in.
+func (h *MemoryHandler) namespaceOf(args map[string]interface{}) string {
+	if ns := strings.TrimSpace(getField(args, "namespace")); ns != "" {
+		return ns
+	}
+	return h.namespace
 }
 
 // getField returns a string value from a map, checking both CamelCase and snake_case
@@ -55,141 +104,382 @@ func getField(m map[string]interface{}, keys ...string) string {
 	return ""
 }
 
+// getStrings returns the string elements of an array value, checking several keys
+func getStrings(m map[string]interface{}, keys ...string) []string {
+	for _, k := range keys {
+		if list, ok := m[k].([]interface{}); ok {
+			var out []string
+			for _, v := range list {
+				if str, ok := v.(string); ok && str != "" {
+					out = append(out, str)
+				}
+			}
+			return out
+		}
+	}
+	return nil
+}
+
+// getObjects returns the object elements of an array value
+func getObjects(m map[string]interface{}, key string) []map[string]interface{} {
+	list, _ := m[key].([]interface{})
+	var out []map[string]interface{}
+	for _, v := range list {
+		if obj, ok := v.(map[string]interface{}); ok {
+			out = append(out, obj)
+		}
+	}
+	return out
+}
+
+// parseRelation reads a relation, accepting the aliases used by other memory servers
+func parseRelation(rel map[string]interface{}) models.Relation {
+	return models.Relation{
+		From:         getField(rel, "from", "source", "from_name"),
+		To:           getField(rel, "to", "target", "to_name"),
+		RelationType: getField(rel, "relationType", "relation_type", "type"),
+	}
+}
+
+// Memorize stores a single fact about an entity, creating the entity if needed.
+// The name is resolved like in CreateEntities, and a fact the entity already has
+// is not stored again.
+func (h *MemoryHandler) Memorize(args map[string]interface{}) (interface{}, error) {
+	name := getField(args, "entity", "name")
+	entType := getField(args, "category", "entityType", "entity_type")
+	obs := getField(args, "observation", "content")
+	if name == "" || obs == "" {
+		return nil, fmt.Errorf("entity and observation are required")
+	}
+	if entType == "" {
+		entType = ontology.Unknown
+	}
+	entType, _, err := h.checkEntityType(entType)
+	if err != nil {
+		return nil, err
+	}
+	if obs, _, err = h.redact(obs); err != nil {
+		return nil, fmt.Errorf("observation %w", err)
+	}
+	prov, err := provenanceOf(args, provenance{})
+	if err != nil {
+		return nil, err
+	}
+	ns := h.namespaceOf(args)
+
+	err = h.inTx(func(tx *sql.Tx) error {
+		var err error
+		if name, _, err = ensureEntity(tx, ns, name, entType); err != nil {
+			return err
+		}
+		_, _, err = h.addObservation(tx, ns, name, obs, prov)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	graph, err := h.loadGraph(ns, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	return graph.Entities[0], nil
+}
+
+// CreateEntities creates the given entities in one transaction. Entities that
+// already exist are skipped; invalid entities are reported as failed and the
+// others are still created. A name refers to an existing entity if it matches its
+// name or one of its aliases apart from case and spacing, and results report the
+// existing entity's name. Created entities whose names nearly match others, such
+// as "Oly" and "Oliver (Oly)", list them as similar. Repeated observations are
+// stored once.
 func (h *MemoryHandler) CreateEntities(args map[string]interface{}) (interface{}, error) {
-	entities, ok := args["entities"].([]interface{})
-	if !ok {
+	if _, ok := args["entities"].([]interface{}); !ok {
 		return nil, fmt.Errorf("invalid arguments: entities array missing")
 	}
+	prov, err := provenanceOf(args, provenance{})
+	if err != nil {
+		return nil, err
+	}
+
+	ns := h.namespaceOf(args)
+	result := models.CreateEntitiesResult{Entities: []models.Entity{}, Results: []models.EntityStatus{}}
+	err = h.inTx(func(tx *sql.Tx) error {
+		for _, ent := range getObjects(args, "entities") {
+			name := getField(ent, "name", "entity_name")
+			entType := getField(ent, "entityType", "entity_type", "type")
+
+			if name == "" {
+				result.Results = append(result.Results, models.EntityStatus{ItemStatus: models.Failed("name is required")})
+				continue
+			}
+			if entType == "" {
+				entType = ontology.Unknown
+			}
+			entType, warning, err := h.checkEntityType(entType)
+			if err != nil {
+				result.Results = append(result.Results, models.EntityStatus{Name: name, ItemStatus: models.Failed(err.Error())})
+				continue
+			}
+			observations, redacted, err := h.redactAll(getStrings(ent, "observations"))
+			if err != nil {
+				result.Results = append(result.Results, models.EntityStatus{Name: name, ItemStatus: models.Failed(err.Error())})
+				continue
+			}
+			warning = joinWarnings(warning, redacted)
+
+			canonical, created, err := ensureEntity(tx, ns, name, entType)
+			if err != nil {
+				return fmt.Errorf("failed to create entity %q: %w", name, err)
+			}
+			name = canonical
+			if !created {
+				result.Results = append(result.Results, models.EntityStatus{Name: name, ItemStatus: models.ItemStatus{Status: models.StatusExists}})
+				continue
+			}
 
-	for _, e := range entities {
-		ent, ok := e.(map[string]interface{})
-		if !ok { continue }
-		
-		name := getField(ent, "name", "entity_name")
-		entType := getField(ent, "entityType", "entity_type", "type")
-		
-		if name == "" { continue }
-		if entType == "" { entType = "unknown" }
-		
-		h.db.Exec("INSERT OR IGNORE INTO entities (name, type) VALUES (?, ?)", name, entType)
-
-		// Handle observations inside entity
-		if obs, ok := ent["observations"].([]interface{}); ok {
-			for _, o := range obs {
-				if str, ok := o.(string); ok {
-					h.db.Exec("INSERT INTO observations (entity_name, content) VALUES (?, ?)", name, str)
+			// Handle observations inside entity
+			obs := []string{}
+			for _, o := range observations {
+				_, added, err := h.addObservation(tx, ns, name, o, prov)
+				if err != nil {
+					return fmt.Errorf("failed to add observation to %q: %w", name, err)
 				}
+				if added {
+					obs = append(obs, o)
+				}
+			}
+			similar, err := similarEntities(tx, ns, name)
+			if err != nil {
+				return err
 			}
+			result.Entities = append(result.Entities, models.Entity{Name: name, EntityType: entType, Observations: obs})
+			result.Results = append(result.Results, models.EntityStatus{Name: name, ItemStatus: models.ItemStatus{Status: models.StatusCreated, Warning: warning}, Similar: similar})
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	return "Entities processed", nil
+	return result, nil
 }
 
+// CreateRelations creates the given relations in one transaction. Endpoints are
+// resolved like entity names in CreateEntities; missing entities are created with
+// type "unknown". Existing relations are skipped; invalid ones are
+// reported as failed and the others are still created.
 func (h *MemoryHandler) CreateRelations(args map[string]interface{}) (interface{}, error) {
-	relations, ok := args["relations"].([]interface{})
-	if !ok { return "No relations provided", nil }
-
-	for _, r := range relations {
-		rel, ok := r.(map[string]interface{})
-		if !ok { continue }
-		
-		from := getField(rel, "from", "source", "from_name")
-		to := getField(rel, "to", "target", "to_name")
-		relType := getField(rel, "relationType", "relation_type", "type")
-
-		if from != "" && to != "" {
-			if relType == "" { relType = "related_to" }
-			h.db.Exec("INSERT OR IGNORE INTO entities (name, type) VALUES (?, 'unknown')", from)
-			h.db.Exec("INSERT OR IGNORE INTO entities (name, type) VALUES (?, 'unknown')", to)
-			h.db.Exec("INSERT OR IGNORE INTO relations (from_name, to_name, type) VALUES (?, ?, ?)", from, to, relType)
+	ns := h.namespaceOf(args)
+	result := models.CreateRelationsResult{Relations: []models.Relation{}, Results: []models.RelationStatus{}}
+
+	err := h.inTx(func(tx *sql.Tx) error {
+		for _, r := range getObjects(args, "relations") {
+			rel := parseRelation(r)
+			if rel.From == "" || rel.To == "" {
+				result.Results = append(result.Results, models.RelationStatus{Relation: rel, ItemStatus: models.Failed("from and to are required")})
+				continue
+			}
+			if rel.RelationType == "" {
+				rel.RelationType = "related_to"
+			}
+			rel, warning, err := h.checkRelation(tx, ns, rel)
+			if err != nil {
+				result.Results = append(result.Results, models.RelationStatus{Relation: rel, ItemStatus: models.Failed(err.Error())})
+				continue
+			}
+
+			for _, name := range []*string{&rel.From, &rel.To} {
+				canonical, _, err := ensureEntity(tx, ns, *name, ontology.Unknown)
+				if err != nil {
+					return fmt.Errorf("failed to create entity %q: %w", *name, err)
+				}
+				*name = canonical
+			}
+			res, err := tx.Exec("INSERT OR IGNORE INTO relations (namespace, from_name, to_name, type) VALUES (?, ?, ?, ?)", ns, rel.From, rel.To, rel.RelationType)
+			if err != nil {
+				return fmt.Errorf("failed to create relation %s -%s-> %s: %w", rel.From, rel.RelationType, rel.To, err)
+			}
+			status := models.StatusExists
+			if n, _ := res.RowsAffected(); n > 0 {
+				status = models.StatusCreated
+				result.Relations = append(result.Relations, rel)
+			}
+			result.Results = append(result.Results, models.RelationStatus{Relation: rel, ItemStatus: models.ItemStatus{Status: status, Warning: warning}})
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	return "Relations processed", nil
+	return result, nil
 }
 
+// AddObservations adds observations to entities in one transaction. Observations an
+// entity already has, apart from case, punctuation and spacing, are skipped. Source and confidence can be given for the whole
+// call or per entity; an entity with invalid arguments is reported as failed and the
+// others are still updated.
 func (h *MemoryHandler) AddObservations(args map[string]interface{}) (interface{}, error) {
-	observations, ok := args["observations"].([]interface{})
-	if !ok { return "No observations provided", nil }
-
-	for _, o := range observations {
-		obs, ok := o.(map[string]interface{})
-		if !ok { continue }
-		
-		name := getField(obs, "entityName", "entity_name", "name")
-		contents, _ := obs["contents"].([]interface{})
-
-		if name != "" {
-			h.db.Exec("INSERT OR IGNORE INTO entities (name, type) VALUES (?, 'unknown')", name)
-			for _, c := range contents {
-				if str, ok := c.(string); ok {
-					h.db.Exec("INSERT INTO observations (entity_name, content) VALUES (?, ?)", name, str)
+	callProv, err := provenanceOf(args, provenance{})
+	if err != nil {
+		return nil, err
+	}
+	ns := h.namespaceOf(args)
+	result := models.AddObservationsResult{Results: []models.ObservationsAdded{}}
+
+	err = h.inTx(func(tx *sql.Tx) error {
+		for _, obs := range getObjects(args, "observations") {
+			name := getField(obs, "entityName", "entity_name", "name")
+			contents := getStrings(obs, "contents", "observations")
+			added := models.ObservationsAdded{EntityName: name, AddedObservations: []string{}, Statuses: []models.ObservationStatus{}}
+
+			prov, err := provenanceOf(obs, callProv)
+			if name == "" {
+				err = fmt.Errorf("entityName is required")
+			}
+			if err != nil {
+				for _, content := range contents {
+					added.Statuses = append(added.Statuses, models.ObservationStatus{Content: content, ItemStatus: models.Failed(err.Error())})
+				}
+				if len(contents) == 0 {
+					added.Statuses = append(added.Statuses, models.ObservationStatus{ItemStatus: models.Failed(err.Error())})
+				}
+				result.Results = append(result.Results, added)
+				continue
+			}
+
+			canonical, _, err := ensureEntity(tx, ns, name, "unknown")
+			if err != nil {
+				return fmt.Errorf("failed to create entity %q: %w", name, err)
+			}
+			name = canonical
+			added.EntityName = name
+
+			for _, content := range contents {
+				content, warning, err := h.redact(content)
+				if err != nil {
+					added.Statuses = append(added.Statuses, models.ObservationStatus{ItemStatus: models.Failed("observation " + err.Error())})
+					continue
+				}
+				dup, ok, err := h.addObservation(tx, ns, name, content, prov)
+				if err != nil {
+					return fmt.Errorf("failed to add observation to %q: %w", name, err)
+				}
+				if !ok {
+					status := models.ObservationStatus{Content: content, ItemStatus: models.ItemStatus{Status: models.StatusExists, Warning: warning}}
+					if dup != content {
+						status.DuplicateOf = dup
+					}
+					added.Statuses = append(added.Statuses, status)
+					continue
 				}
+				added.AddedObservations = append(added.AddedObservations, content)
+				added.Statuses = append(added.Statuses, models.ObservationStatus{Content: content, ItemStatus: models.ItemStatus{Status: models.StatusCreated, Warning: warning}})
 			}
+			result.Results = append(result.Results, added)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// OpenNodes returns the named entities and the relations between them. Names are
+// resolved through aliases.
+func (h *MemoryHandler) OpenNodes(args map[string]interface{}) (interface{}, error) {
+	names := getStrings(args, "names", "entityNames")
+	if names == nil {
+		names = []string{}
+	}
+	ns := h.namespaceOf(args)
+	names, err := resolveNames(h.db, ns, names)
+	if err != nil {
+		return nil, err
 	}
+	return h.loadGraphWith(ns, names, graphOptionsOf(args))
+}
 
-	return "Observations processed", nil
-}
-
-func (h *MemoryHandler) SearchNodes(args map[string]interface{}) (interface{}, error) {
-	query, _ := args["query"].(string)
-	if query == "" { return "Empty query", nil }
-
-	rows, err := h.db.Query(`
-		SELECT DISTINCT e.name, e.type 
-		FROM entities e
-		LEFT JOIN observations o ON e.name = o.entity_name
-		WHERE e.name LIKE ? OR o.content LIKE ? OR e.type LIKE ?`,
-		"%"+query+"%", "%"+query+"%", "%"+query+"%")
-	if err != nil { return nil, err }
-	defer rows.Close()
-
-	var results []string
-	for rows.Next() {
-		var name, entType string
-		rows.Scan(&name, &entType)
-		results = append(results, fmt.Sprintf("- %s (%s)", name, entType))
-	}
-
-	if len(results) == 0 { return "No matches found", nil }
-	return "Search results:\n" + strings.Join(results, "\n"), nil
-}
-
-func (h *MemoryHandler) ReadGraph(args map[string]interface{}) (interface{}, error) {
-	entRows, _ := h.db.Query("SELECT name, type FROM entities")
-	defer entRows.Close()
-
-	var sb strings.Builder
-	sb.WriteString("KNOWLEDGE GRAPH CONTENT:\n")
-	
-	count := 0
-	for entRows.Next() {
-		count++
-		var name, entType string
-		entRows.Scan(&name, &entType)
-		sb.WriteString(fmt.Sprintf("\nEntity: %s [%s]\n", name, entType))
-		
-		obsRows, _ := h.db.Query("SELECT content FROM observations WHERE entity_name = ?", name)
-		for obsRows.Next() {
-			var content string
-			obsRows.Scan(&content)
-			sb.WriteString(fmt.Sprintf("  - Observation: %s\n", content))
+// DeleteEntities deletes entities. Their observations and relations are deleted
+// by the foreign keys. Names are resolved through aliases.
+func (h *MemoryHandler) DeleteEntities(args map[string]interface{}) (interface{}, error) {
+	ns := h.namespaceOf(args)
+	deleted := 0
+	err := h.inTx(func(tx *sql.Tx) error {
+		names, err := resolveNames(tx, ns, getStrings(args, "entityNames", "names"))
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			res, err := tx.Exec("DELETE FROM entities WHERE namespace = ? AND name = ?", ns, name)
+			if err != nil {
+				return err
+			}
+			n, _ := res.RowsAffected()
+			deleted += int(n)
 		}
-		obsRows.Close()
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return models.DeleteResult{Deleted: deleted, Message: fmt.Sprintf("Deleted %d entities", deleted)}, nil
+}
 
-	if count == 0 { return "Memory is currently empty.", nil }
-	return sb.String(), nil
+// DeleteObservations deletes specific observations of entities, including superseded
+// ones with the same content. Deleting a correction brings back the fact it replaced.
+// Entity names are resolved through aliases.
+func (h *MemoryHandler) DeleteObservations(args map[string]interface{}) (interface{}, error) {
+	ns := h.namespaceOf(args)
+	deleted := 0
+	err := h.inTx(func(tx *sql.Tx) error {
+		for _, d := range getObjects(args, "deletions") {
+			names, err := resolveNames(tx, ns, []string{getField(d, "entityName", "entity_name", "name")})
+			if err != nil {
+				return err
+			}
+			name := names[0]
+			for _, content := range getStrings(d, "observations", "contents") {
+				res, err := tx.Exec("DELETE FROM observations WHERE namespace = ? AND entity_name = ? AND content = ?", ns, name, h.seal(content))
+				if err != nil {
+					return err
+				}
+				n, _ := res.RowsAffected()
+				deleted += int(n)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return models.DeleteResult{Deleted: deleted, Message: fmt.Sprintf("Deleted %d observations", deleted)}, nil
 }
 
-func (h *MemoryHandler) DeleteEntities(args map[string]interface{}) (interface{}, error) {
-	names, _ := args["entityNames"].([]interface{})
-	for _, n := range names {
-		if str, ok := n.(string); ok {
-			h.db.Exec("DELETE FROM entities WHERE name = ?", str)
+// DeleteRelations deletes specific relations. Endpoints are resolved through aliases,
+// and types are normalized through the ontology like on creation.
+func (h *MemoryHandler) DeleteRelations(args map[string]interface{}) (interface{}, error) {
+	ns := h.namespaceOf(args)
+	deleted := 0
+	err := h.inTx(func(tx *sql.Tx) error {
+		for _, r := range getObjects(args, "relations") {
+			rel, _ := h.normalizeRelation(parseRelation(r))
+			ends, err := resolveNames(tx, ns, []string{rel.From, rel.To})
+			if err != nil {
+				return err
+			}
+			rel.From, rel.To = ends[0], ends[1]
+			res, err := tx.Exec("DELETE FROM relations WHERE namespace = ? AND from_name = ? AND to_name = ? AND type = ?", ns, rel.From, rel.To, rel.RelationType)
+			if err != nil {
+				return err
+			}
+			n, _ := res.RowsAffected()
+			deleted += int(n)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return "Deleted", nil
+	return models.DeleteResult{Deleted: deleted, Message: fmt.Sprintf("Deleted %d relations", deleted)}, nil
 }
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+func newTestHandler(t *testing.T) *MemoryHandler {
+	t.Helper()
+	h, err := NewMemoryHandler(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatalf("NewMemoryHandler() error = %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+// args decodes a JSON literal the way the MCP server passes tool arguments.
+func args(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func mustCall(t *testing.T, fn func(map[string]interface{}) (interface{}, error), a string) interface{} {
+	t.Helper()
+	res, err := fn(args(t, a))
+	if err != nil {
+		t.Fatalf("call(%s) error = %v", a, err)
+	}
+	return res
+}
+
+func readGraph(t *testing.T, h *MemoryHandler) models.KnowledgeGraph {
+	t.Helper()
+	return mustCall(t, h.ReadGraph, `{}`).(models.GraphPage).KnowledgeGraph
+}
+
+func seed(t *testing.T, h *MemoryHandler) {
+	t.Helper()
+	mustCall(t, h.CreateEntities, `{"entities": [
+		{"name": "Alice", "entityType": "person", "observations": ["likes Go", "lives in Berlin"]},
+		{"name": "Acme", "entityType": "company", "observations": ["builds rockets"]}
+	]}`)
+	mustCall(t, h.CreateRelations, `{"relations": [{"from": "Alice", "to": "Acme", "relationType": "works_at"}]}`)
+}
+
+func TestCreateEntities_SkipsExisting(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+
+	res := mustCall(t, h.CreateEntities, `{"entities": [
+		{"name": "Alice", "entityType": "person", "observations": ["duplicate"]},
+		{"name": "Bob", "entityType": "person", "observations": []}
+	]}`).(models.CreateEntitiesResult)
+
+	want := []models.Entity{{Name: "Bob", EntityType: "person", Observations: []string{}}}
+	if !reflect.DeepEqual(res.Entities, want) {
+		t.Errorf("CreateEntities() = %+v, want %+v", res.Entities, want)
+	}
+
+	graph := readGraph(t, h)
+	if got := graph.Entities[1]; got.Name != "Alice" || len(got.Observations) != 2 {
+		t.Errorf("existing entity changed: %+v", got)
+	}
+}
+
+func TestReadGraph(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+
+	want := models.KnowledgeGraph{
+		Entities: []models.Entity{
+			{Name: "Acme", EntityType: "company", Observations: []string{"builds rockets"}},
+			{Name: "Alice", EntityType: "person", Observations: []string{"likes Go", "lives in Berlin"}},
+		},
+		Relations: []models.Relation{{From: "Alice", To: "Acme", RelationType: "works_at"}},
+	}
+	if got := readGraph(t, h); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadGraph() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSearchAndOpenNodes(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	mustCall(t, h.CreateEntities, `{"entities": [{"name": "Bob", "entityType": "person", "observations": ["lives in Berlin"]}]}`)
+
+	search := mustCall(t, h.SearchNodes, `{"query": "berlin"}`).(models.SearchResult)
+	if len(search.Entities) != 2 || search.Total != 2 {
+		t.Errorf("SearchNodes() entities = %+v", search.Entities)
+	}
+	if len(search.Relations) != 0 {
+		t.Errorf("SearchNodes() relations = %+v, want none", search.Relations)
+	}
+
+	graph := mustCall(t, h.OpenNodes, `{"names": ["Alice", "Acme", "Missing"]}`).(models.KnowledgeGraph)
+	if len(graph.Entities) != 2 || len(graph.Relations) != 1 {
+		t.Errorf("OpenNodes() = %+v", graph)
+	}
+
+	if _, err := h.SearchNodes(args(t, `{"query": ""}`)); err == nil {
+		t.Error("SearchNodes() with empty query should fail")
+	}
+}
+
+func TestAddAndDeleteObservations(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+
+	res := mustCall(t, h.AddObservations, `{"observations": [{"entityName": "Alice", "contents": ["likes Go", "plays chess"]}]}`).(models.AddObservationsResult)
+	want := []models.ObservationsAdded{{
+		EntityName:        "Alice",
+		AddedObservations: []string{"plays chess"},
+		Statuses: []models.ObservationStatus{
+			{Content: "likes Go", ItemStatus: models.ItemStatus{Status: models.StatusExists}},
+			{Content: "plays chess", ItemStatus: models.ItemStatus{Status: models.StatusCreated}},
+		},
+	}}
+	if !reflect.DeepEqual(res.Results, want) {
+		t.Errorf("AddObservations() = %+v, want %+v", res.Results, want)
+	}
+
+	del := mustCall(t, h.DeleteObservations, `{"deletions": [{"entityName": "Alice", "observations": ["likes Go", "unknown"]}]}`).(models.DeleteResult)
+	if del.Deleted != 1 {
+		t.Errorf("DeleteObservations() deleted %d, want 1", del.Deleted)
+	}
+	if got := readGraph(t, h).Entities[1].Observations; !reflect.DeepEqual(got, []string{"lives in Berlin", "plays chess"}) {
+		t.Errorf("observations = %v", got)
+	}
+}
+
+func TestDeleteEntitiesAndRelations(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+
+	del := mustCall(t, h.DeleteRelations, `{"relations": [{"from": "Alice", "to": "Acme", "relationType": "works_at"}]}`).(models.DeleteResult)
+	if del.Deleted != 1 || len(readGraph(t, h).Relations) != 0 {
+		t.Errorf("DeleteRelations() = %+v", del)
+	}
+
+	mustCall(t, h.CreateRelations, `{"relations": [{"from": "Alice", "to": "Acme", "relationType": "works_at"}]}`)
+	del = mustCall(t, h.DeleteEntities, `{"entityNames": ["Acme"]}`).(models.DeleteResult)
+	if del.Deleted != 1 {
+		t.Errorf("DeleteEntities() deleted %d, want 1", del.Deleted)
+	}
+	graph := readGraph(t, h)
+	if len(graph.Entities) != 1 || len(graph.Relations) != 0 {
+		t.Errorf("graph after delete = %+v", graph)
+	}
+
+	var orphans int
+	h.db.QueryRow("SELECT COUNT(*) FROM observations WHERE entity_name = 'Acme'").Scan(&orphans)
+	if orphans != 0 {
+		t.Errorf("DeleteEntities() left %d observations behind", orphans)
+	}
+}
+
+func TestRenameEntity(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+
+	mustCall(t, h.RenameEntity, `{"oldName": "Acme", "newName": "Acme Corp"}`)
+
+	want := models.KnowledgeGraph{
+		Entities: []models.Entity{
+			{Name: "Acme Corp", EntityType: "company", Observations: []string{"builds rockets"}, Aliases: []string{"Acme"}},
+			{Name: "Alice", EntityType: "person", Observations: []string{"likes Go", "lives in Berlin"}},
+		},
+		Relations: []models.Relation{{From: "Alice", To: "Acme Corp", RelationType: "works_at"}},
+	}
+	if got := readGraph(t, h); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadGraph() = %+v, want %+v", got, want)
+	}
+
+	if _, err := h.RenameEntity(args(t, `{"oldName": "Alice", "newName": "Acme Corp"}`)); err == nil {
+		t.Error("RenameEntity() onto an existing entity should fail")
+	}
+	if _, err := h.RenameEntity(args(t, `{"oldName": "Missing", "newName": "Other"}`)); err == nil {
+		t.Error("RenameEntity() of a missing entity should fail")
+	}
+}
+
+func TestMergeEntities(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	mustCall(t, h.CreateEntities, `{"entities": [
+		{"name": "ACME Inc", "entityType": "company", "observations": ["builds rockets", "based in Texas"]},
+		{"name": "Bob", "entityType": "person", "observations": []}
+	]}`)
+	mustCall(t, h.CreateRelations, `{"relations": [
+		{"from": "Bob", "to": "ACME Inc", "relationType": "works_at"},
+		{"from": "Alice", "to": "ACME Inc", "relationType": "works_at"},
+		{"from": "ACME Inc", "to": "Acme", "relationType": "same_as"}
+	]}`)
+
+	res := mustCall(t, h.MergeEntities, `{"targetName": "Acme", "sourceNames": ["ACME Inc"]}`).(models.MergeResult)
+
+	wantEntity := models.Entity{Name: "Acme", EntityType: "company", Observations: []string{"builds rockets", "based in Texas"}, Aliases: []string{"ACME Inc"}}
+	if !reflect.DeepEqual(res.Entity, wantEntity) || !reflect.DeepEqual(res.Merged, []string{"ACME Inc"}) {
+		t.Errorf("MergeEntities() = %+v", res)
+	}
+
+	wantRelations := []models.Relation{
+		{From: "Alice", To: "Acme", RelationType: "works_at"},
+		{From: "Bob", To: "Acme", RelationType: "works_at"},
+	}
+	graph := readGraph(t, h)
+	if !reflect.DeepEqual(graph.Relations, wantRelations) {
+		t.Errorf("relations = %+v, want %+v", graph.Relations, wantRelations)
+	}
+	if len(graph.Entities) != 3 {
+		t.Errorf("entities = %+v", graph.Entities)
+	}
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// migration is one step of the schema history. Applied migrations are recorded in
+// the schema_version table. Released migrations never change; a schema change
+// always gets a new migration at the end of the list.
+type migration struct {
+	version     int
+	description string
+	up          func(tx *sql.Tx) error
+}
+
+var migrations = []migration{
+	{1, "graph tables", execAll(baseSchema)},
+	{2, "namespaces", execAll(
+		// Tables of very old databases may be missing; create them empty first.
+		"CREATE TABLE IF NOT EXISTS observations (id INTEGER PRIMARY KEY AUTOINCREMENT, entity_name TEXT, content TEXT)",
+		"CREATE TABLE IF NOT EXISTS relations (from_name TEXT, to_name TEXT, type TEXT)",
+		namespaceUpgrade,
+	)},
+	{3, "full-text search index", execAll(searchSchema, searchBackfill)},
+	{4, "embeddings", execAll(embeddingSchema)},
+	{5, "observation provenance and history", execAll(provenanceSchema)},
+	{6, "enforce foreign keys", execAll(orphanCleanup)},
+	{7, "entity timestamps", execAll(entityTimestamps)},
+	{8, "entity aliases", migrateAliases},
+	{9, "pinned observations", execAll(pinnedSchema)},
+	{10, "observation expiry", execAll(expirySchema)},
+	{11, "encrypted observations", execAll(encryptionSchema)},
+	{12, "change events", execAll(eventSchema)},
+}
+
+// foreignKeysVersion is the first version whose data satisfies the foreign keys.
+// Later migrations are checked against them before they are committed.
+const foreignKeysVersion = 6
+
+// preVersioningVersion is the schema of databases that have namespaces but no
+// schema_version table yet.
+const preVersioningVersion = 4
+
+const versionSchema = `
+CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER PRIMARY KEY,
+	description TEXT NOT NULL,
+	applied_at TEXT NOT NULL
+);
+`
+
+// execAll returns a migration step that runs the statements in order.
+func execAll(stmts ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Migrate brings the schema of the database at dbPath up to date without
+// starting a server. It returns the schema version before and after.
+func Migrate(dbPath string) (from, to int, err error) {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer db.Close()
+	return migrate(db)
+}
+
+// latestVersion is the schema version this build migrates to.
+func latestVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrate brings the database schema up to date. Each migration runs in its own
+// transaction together with its schema_version row, so a failed migration leaves
+// the database at the previous version. Databases written by a newer build are
+// refused rather than opened with a schema this build does not know.
+func migrate(db *sql.DB) (from, to int, err error) {
+	from, err = schemaVersion(db)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if from > latestVersion() {
+		return from, from, fmt.Errorf("database schema version %d is newer than the supported version %d; upgrade memory-server", from, latestVersion())
+	}
+	to = from
+	for _, m := range migrations {
+		if m.version <= from {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return from, to, fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+		}
+		to = m.version
+	}
+	return from, to, nil
+}
+
+// applyMigration runs one migration and records it.
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	if m.version >= foreignKeysVersion {
+		if err := checkForeignKeys(tx); err != nil {
+			return err
+		}
+	}
+	if err := recordVersion(tx, m); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func recordVersion(tx *sql.Tx, m migration) error {
+	_, err := tx.Exec("INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)",
+		m.version, m.description, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// schemaVersion returns the version of the database schema, creating the
+// schema_version table if needed. Databases from before versioning are
+// recognized by their tables: with namespaces they are at preVersioningVersion,
+// without they start from scratch, which migration 1 tolerates.
+func schemaVersion(db *sql.DB) (int, error) {
+	if _, err := db.Exec(versionSchema); err != nil {
+		return 0, err
+	}
+	var version sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	if version.Valid {
+		return int(version.Int64), nil
+	}
+
+	namespaced, err := hasColumn(db, "entities", "namespace")
+	if err != nil || !namespaced {
+		return 0, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	for _, m := range migrations[:preVersioningVersion] {
+		if err := recordVersion(tx, m); err != nil {
+			return 0, err
+		}
+	}
+	return preVersioningVersion, tx.Commit()
+}
+
+// checkForeignKeys fails if a row references a missing row.
+func checkForeignKeys(tx *sql.Tx) error {
+	var table string
+	var rowid sql.NullInt64
+	var parent string
+	var fkid int
+	err := tx.QueryRow("PRAGMA foreign_key_check").Scan(&table, &rowid, &parent, &fkid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("row %d of %s references a missing row of %s", rowid.Int64, table, parent)
+}
+
+// hasColumn reports whether a table has a column. A missing table has no columns.
+func hasColumn(db *sql.DB, table, column string) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM pragma_table_info(?) WHERE name = ?)", table, column).Scan(&exists)
+	return exists, err
+}
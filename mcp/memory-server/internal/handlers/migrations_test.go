@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+func appliedVersions(t *testing.T, db *sql.DB) []int {
+	t.Helper()
+	rows, err := db.Query("SELECT version FROM schema_version ORDER BY version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var versions []int
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+func allVersions() []int {
+	var versions []int
+	for _, m := range migrations {
+		versions = append(versions, m.version)
+	}
+	return versions
+}
+
+func TestMigrate_NewDatabase(t *testing.T) {
+	h := newTestHandler(t)
+	if got := appliedVersions(t, h.db); !reflect.DeepEqual(got, allVersions()) {
+		t.Errorf("applied versions = %v, want %v", got, allVersions())
+	}
+	// Migrating again is a no-op.
+	if from, to, err := migrate(h.db); err != nil || from != to {
+		t.Fatalf("migrate() again = %d, %d, %v", from, to, err)
+	}
+	if got := appliedVersions(t, h.db); !reflect.DeepEqual(got, allVersions()) {
+		t.Errorf("applied versions after second run = %v", got)
+	}
+}
+
+func TestMigrate_DetectsUnversionedDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.db")
+
+	// A database with namespaces but from before schema_version existed.
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations[:preVersioningVersion] {
+		tx, _ := db.Begin()
+		if err := m.up(tx); err != nil {
+			t.Fatal(err)
+		}
+		tx.Commit()
+	}
+	_, err = db.Exec(`
+		INSERT INTO entities (namespace, name, type) VALUES ('default', 'Oly', 'person');
+		INSERT INTO observations (namespace, entity_name, content) VALUES ('default', 'Oly', 'likes espresso');`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := NewMemoryHandler(path)
+	if err != nil {
+		t.Fatalf("NewMemoryHandler() error = %v", err)
+	}
+	defer h.Close()
+
+	if got := appliedVersions(t, h.db); !reflect.DeepEqual(got, allVersions()) {
+		t.Errorf("applied versions = %v, want %v", got, allVersions())
+	}
+	if got := readGraph(t, h).Entities; len(got) != 1 || got[0].Observations[0] != "likes espresso" {
+		t.Errorf("entities = %+v", got)
+	}
+}
+
+func TestMigrate_FailedMigrationRollsBack(t *testing.T) {
+	h := newTestHandler(t)
+
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	next := saved[len(saved)-1].version + 1
+	migrations = append(saved[:len(saved):len(saved)], migration{next, "broken", func(tx *sql.Tx) error {
+		if _, err := tx.Exec("CREATE TABLE half_done (id INTEGER)"); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	}})
+
+	if _, _, err := migrate(h.db); err == nil {
+		t.Fatal("migrate() should fail")
+	}
+	if got := appliedVersions(t, h.db); !reflect.DeepEqual(got, allVersions()[:len(saved)]) {
+		t.Errorf("applied versions = %v", got)
+	}
+	exists, err := hasColumn(h.db, "half_done", "id")
+	if err != nil || exists {
+		t.Errorf("table of the failed migration exists = %v, %v", exists, err)
+	}
+}
+
+func TestMigrate_CreatesIndexes(t *testing.T) {
+	h := newTestHandler(t)
+	for _, name := range []string{"observations_entity", "observations_superseded"} {
+		var exists bool
+		h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'index' AND name = ?)", name).Scan(&exists)
+		if !exists {
+			t.Errorf("index %s is missing", name)
+		}
+	}
+}
+
+// The fixtures in testdata were written by earlier releases with the same data:
+// Oly (likes espresso, lives in Bonn) likes Coffee (contains caffeine; "is brewed
+// hot" was deleted where the release could delete observations).
+//
+//	base.db        first release, no namespaces
+//	search.db      with the full-text index
+//	embeddings.db  with semantic search vectors
+//	namespaces.db  with namespaces, before schema_version; Alice in "work"
+//	provenance.db  schema version 5; "lives in Bonn" corrected to "lives in Köln"
+var fixtures = []struct {
+	file        string
+	fromVersion int
+	coffee      []string
+	oly         []string
+	vectors     int
+}{
+	{"base.db", 0, []string{"is brewed hot", "contains caffeine"}, []string{"likes espresso", "lives in Bonn"}, 0},
+	{"search.db", 0, []string{"contains caffeine"}, []string{"likes espresso", "lives in Bonn"}, 0},
+	{"embeddings.db", 0, []string{"contains caffeine"}, []string{"likes espresso", "lives in Bonn"}, 3},
+	{"namespaces.db", preVersioningVersion, []string{"contains caffeine"}, []string{"likes espresso", "lives in Bonn"}, 3},
+	{"provenance.db", 5, []string{"contains caffeine"}, []string{"likes espresso", "lives in Köln"}, 3},
+}
+
+// copyFixture copies a fixture database to a temporary file.
+func copyFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// schemaOf describes the tables, indexes and triggers of a database with the
+// columns of each table.
+func schemaOf(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query("SELECT type, name FROM sqlite_master WHERE name NOT LIKE 'sqlite_autoindex%' ORDER BY type, name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var objects [][2]string
+	for rows.Next() {
+		var typ, name string
+		if err := rows.Scan(&typ, &name); err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, [2]string{typ, name})
+	}
+	rows.Close()
+
+	var out []string
+	for _, o := range objects {
+		desc := o[0] + " " + o[1]
+		if o[0] == "table" {
+			cols, err := db.Query("SELECT name FROM pragma_table_info(?) ORDER BY cid", o[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			names, err := scanStrings(cols)
+			if err != nil {
+				t.Fatal(err)
+			}
+			desc += "(" + strings.Join(names, ", ") + ")"
+		}
+		out = append(out, desc)
+	}
+	return out
+}
+
+func TestMigrate_UpgradesFixtures(t *testing.T) {
+	fresh := schemaOf(t, newTestHandler(t).db)
+
+	for _, fx := range fixtures {
+		t.Run(fx.file, func(t *testing.T) {
+			path := copyFixture(t, fx.file)
+			from, to, err := Migrate(path)
+			if err != nil {
+				t.Fatalf("Migrate() error = %v", err)
+			}
+			if from != fx.fromVersion || to != latestVersion() {
+				t.Errorf("Migrate() = %d → %d, want %d → %d", from, to, fx.fromVersion, latestVersion())
+			}
+
+			h, err := NewMemoryHandler(path)
+			if err != nil {
+				t.Fatalf("NewMemoryHandler() error = %v", err)
+			}
+			defer h.Close()
+
+			if got := schemaOf(t, h.db); !reflect.DeepEqual(got, fresh) {
+				t.Errorf("schema differs from a new database:\n got %v\nwant %v", got, fresh)
+			}
+			if got := appliedVersions(t, h.db); !reflect.DeepEqual(got, allVersions()) {
+				t.Errorf("applied versions = %v", got)
+			}
+
+			want := models.KnowledgeGraph{
+				Entities: []models.Entity{
+					{Name: "Coffee", EntityType: "drink", Observations: fx.coffee},
+					{Name: "Oly", EntityType: "person", Observations: fx.oly},
+				},
+				Relations: []models.Relation{{From: "Oly", To: "Coffee", RelationType: "likes"}},
+			}
+			if got := readGraph(t, h); !reflect.DeepEqual(got, want) {
+				t.Errorf("ReadGraph() = %+v, want %+v", got, want)
+			}
+			if res := mustCall(t, h.SearchNodes, `{"query": "caffeine"}`).(models.SearchResult); res.Total != 1 {
+				t.Errorf("search after upgrade = %+v", res.Matches)
+			}
+			// The history starts with the graph as it was upgraded.
+			if got := mustCall(t, h.GraphAt, `{"at": "0s"}`).(models.GraphSlice); !reflect.DeepEqual(got.KnowledgeGraph, want) {
+				t.Errorf("GraphAt() after upgrade = %+v, want %+v", got.KnowledgeGraph, want)
+			}
+
+			// Vectors survive the upgrade because observation ids are kept.
+			var vectors int
+			h.db.QueryRow("SELECT COUNT(*) FROM embeddings").Scan(&vectors)
+			if vectors != fx.vectors {
+				t.Errorf("embeddings = %d, want %d", vectors, fx.vectors)
+			}
+
+			// Writes work and correcting a fact from before provenance existed keeps its history.
+			mustCall(t, h.CorrectObservation, `{"entityName": "Oly", "oldObservation": "likes espresso", "newObservation": "likes cappuccino"}`)
+			graph := mustCall(t, h.OpenNodes, `{"names": ["Oly"], "includeSuperseded": true, "provenance": true}`).(models.KnowledgeGraph)
+			if d := graph.Entities[0].ObservationDetails; d[0].Content != "likes espresso" || d[0].SupersededBy == nil {
+				t.Errorf("details = %+v", d)
+			}
+		})
+	}
+}
+
+func TestMigrate_RefusesNewerSchema(t *testing.T) {
+	path := copyFixture(t, "provenance.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("INSERT INTO schema_version (version, description, applied_at) VALUES (99, 'future', '2099-01-01T00:00:00Z')")
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewMemoryHandler(path); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("NewMemoryHandler() error = %v, want a newer schema error", err)
+	}
+}
+
+func TestMigrate_RemovesOrphans(t *testing.T) {
+	path := copyFixture(t, "base.db")
+
+	// The first release deleted entities without their observations and relations.
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO observations (entity_name, content) VALUES ('Ghost', 'was deleted');
+		INSERT INTO relations VALUES ('Oly', 'Ghost', 'knows');`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := NewMemoryHandler(path)
+	if err != nil {
+		t.Fatalf("NewMemoryHandler() error = %v", err)
+	}
+	defer h.Close()
+
+	var orphans int
+	h.db.QueryRow(`SELECT (SELECT COUNT(*) FROM observations WHERE entity_name = 'Ghost')
+		+ (SELECT COUNT(*) FROM relations WHERE to_name = 'Ghost')`).Scan(&orphans)
+	if orphans != 0 {
+		t.Errorf("%d orphaned rows survived the migration", orphans)
+	}
+	if got := readGraph(t, h); len(got.Entities) != 2 || len(got.Relations) != 1 {
+		t.Errorf("ReadGraph() = %+v", got)
+	}
+}
+
+func TestMigrate_BackfillsEntityTimestamps(t *testing.T) {
+	for file, want := range map[string]bool{"base.db": false, "provenance.db": true} {
+		path := copyFixture(t, file)
+		h, err := NewMemoryHandler(path)
+		if err != nil {
+			t.Fatalf("NewMemoryHandler(%s) error = %v", file, err)
+		}
+		var updatedAt sql.NullString
+		h.db.QueryRow("SELECT updated_at FROM entities WHERE name = 'Oly'").Scan(&updatedAt)
+		h.Close()
+		// Without observation times the last change is unknown.
+		if updatedAt.Valid != want {
+			t.Errorf("%s: updated_at = %+v", file, updatedAt)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+	"github.com/mlcmcp/memory-server/internal/ontology"
+)
+
+// SetOntology sets the vocabulary that entity and relation writes are normalized
+// and validated against. A nil ontology accepts any type.
+func (h *MemoryHandler) SetOntology(o *ontology.Ontology) {
+	h.ontology = o
+}
+
+// GetOntology returns the configured ontology, so that agents know the vocabulary.
+func (h *MemoryHandler) GetOntology(args map[string]interface{}) (interface{}, error) {
+	if h.ontology == nil {
+		return models.OntologyResult{EntityTypes: map[string]ontology.EntityType{}, RelationTypes: map[string]ontology.RelationType{}}, nil
+	}
+	return models.OntologyResult{
+		Configured:    true,
+		Strict:        h.ontology.Strict,
+		EntityTypes:   h.ontology.EntityTypes,
+		RelationTypes: h.ontology.RelationTypes,
+	}, nil
+}
+
+// checkEntityType returns the declared entity type that entType refers to. An
+// undeclared type is rejected in strict mode and otherwise kept with a warning.
+func (h *MemoryHandler) checkEntityType(entType string) (string, string, error) {
+	if h.ontology == nil {
+		return entType, "", nil
+	}
+	declared, ok := h.ontology.EntityType(entType)
+	if ok {
+		return declared, "", nil
+	}
+	warning, err := h.violation(fmt.Sprintf("entity type %q is not in the ontology", entType))
+	return entType, warning, err
+}
+
+// checkRelation normalizes the type of a relation, reversing it if the type was
+// the inverse of a declared one, and checks the types of its endpoints. Entities
+// that do not exist yet count as unknown. Violations are rejected in strict mode
+// and otherwise reported as a warning.
+func (h *MemoryHandler) checkRelation(tx *sql.Tx, ns string, rel models.Relation) (models.Relation, string, error) {
+	if h.ontology == nil {
+		return rel, "", nil
+	}
+	rel, ok := h.normalizeRelation(rel)
+	if !ok {
+		warning, err := h.violation(fmt.Sprintf("relation type %q is not in the ontology", rel.RelationType))
+		return rel, warning, err
+	}
+
+	var types [2]string
+	for i, name := range []string{rel.From, rel.To} {
+		types[i] = ontology.Unknown
+		canonical, ok, err := resolveEntity(tx, ns, name)
+		if err != nil {
+			return rel, "", err
+		}
+		if ok {
+			if types[i], err = entityType(tx, ns, canonical); err != nil {
+				return rel, "", err
+			}
+		}
+	}
+	if err := h.ontology.CheckEndpoints(rel.RelationType, types[0], types[1]); err != nil {
+		warning, err := h.violation(err.Error())
+		return rel, warning, err
+	}
+	return rel, "", nil
+}
+
+// normalizeRelation maps the type of a relation to the declared one, reversing
+// the relation if the type was the inverse of a declared one, without checking
+// its endpoints. It reports false for undeclared types, which are kept.
+func (h *MemoryHandler) normalizeRelation(rel models.Relation) (models.Relation, bool) {
+	if h.ontology == nil {
+		return rel, false
+	}
+	relType, inverse, ok := h.ontology.RelationType(rel.RelationType)
+	rel.RelationType = relType
+	if inverse {
+		rel.From, rel.To = rel.To, rel.From
+	}
+	return rel, ok
+}
+
+// relationTypes maps relation types, aliases and inverses to the declared types
+// relations are stored with, for filters.
+func (h *MemoryHandler) relationTypes(types []string) []string {
+	if h.ontology == nil || len(types) == 0 {
+		return types
+	}
+	out := make([]string, len(types))
+	for i, t := range types {
+		out[i], _, _ = h.ontology.RelationType(t)
+	}
+	return out
+}
+
+// violation turns a violation of the ontology into an error in strict mode and
+// into a warning otherwise.
+func (h *MemoryHandler) violation(msg string) (string, error) {
+	if h.ontology.Strict {
+		return "", errors.New(msg)
+	}
+	return msg, nil
+}
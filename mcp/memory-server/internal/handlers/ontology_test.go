@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+	"github.com/mlcmcp/memory-server/internal/ontology"
+)
+
+func newOntologyHandler(t *testing.T, strict bool) *MemoryHandler {
+	t.Helper()
+	o, err := ontology.ParseYAML([]byte(`
+entityTypes:
+  person: {}
+  organization: {aliases: [company]}
+relationTypes:
+  works_at:
+    domain: [person]
+    range: [organization]
+    inverse: employs
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Strict = strict
+	h := newTestHandler(t)
+	h.SetOntology(o)
+	return h
+}
+
+func TestOntology_Normalizes(t *testing.T) {
+	h := newOntologyHandler(t, true)
+	mustCall(t, h.CreateEntities, `{"entities": [
+		{"name": "Alice", "entityType": "Person"},
+		{"name": "Acme", "entityType": "company"}
+	]}`)
+	res := mustCall(t, h.CreateRelations, `{"relations": [
+		{"from": "Acme", "to": "Alice", "relationType": "employs"},
+		{"from": "Alice", "to": "Acme", "relationType": "worksAt"}
+	]}`).(models.CreateRelationsResult)
+
+	want := models.Relation{From: "Alice", To: "Acme", RelationType: "works_at"}
+	if len(res.Relations) != 1 || res.Relations[0] != want || res.Results[1].Status != models.StatusExists {
+		t.Errorf("CreateRelations() = %+v", res)
+	}
+	graph := readGraph(t, h)
+	if graph.Entities[0].EntityType != "organization" || graph.Entities[1].EntityType != "person" {
+		t.Errorf("entities = %+v", graph.Entities)
+	}
+	if !reflect.DeepEqual(graph.Relations, []models.Relation{want}) {
+		t.Errorf("relations = %+v", graph.Relations)
+	}
+}
+
+func TestOntology_DeletesAndFilters(t *testing.T) {
+	h := newOntologyHandler(t, false)
+	mustCall(t, h.CreateEntities, `{"entities": [
+		{"name": "Alice", "entityType": "person"},
+		{"name": "Acme", "entityType": "organization"}
+	]}`)
+	mustCall(t, h.CreateRelations, `{"relations": [{"from": "Acme", "to": "Alice", "relationType": "employs"}]}`)
+
+	// Filters by alias or inverse find the stored works_at relation.
+	if res := mustCall(t, h.ListRelations, `{"relationTypes": ["worksAt"]}`).(models.RelationsResult); res.Total != 1 {
+		t.Errorf("ListRelations() = %+v", res)
+	}
+	hood := mustCall(t, h.Neighborhood, `{"name": "Alice", "relationTypes": ["employs"]}`).(models.NeighborhoodResult)
+	if len(hood.Relations) != 1 || hood.Hops["Acme"] != 1 {
+		t.Errorf("Neighborhood() = %+v", hood)
+	}
+	if path := mustCall(t, h.ShortestPath, `{"from": "Alice", "to": "Acme", "relationTypes": ["Works At"]}`).(models.PathResult); !path.Found {
+		t.Errorf("ShortestPath() = %+v", path)
+	}
+
+	// Deletes are normalized like the write that stored the relation.
+	for _, rel := range []string{
+		`{"from": "Acme", "to": "Alice", "relationType": "employs"}`,
+		`{"from": "Alice", "to": "Acme", "relationType": "worksAt"}`,
+	} {
+		mustCall(t, h.CreateRelations, `{"relations": [{"from": "Alice", "to": "Acme", "relationType": "works_at"}]}`)
+		if del := mustCall(t, h.DeleteRelations, `{"relations": [`+rel+`]}`).(models.DeleteResult); del.Deleted != 1 {
+			t.Errorf("DeleteRelations(%s) deleted %d, want 1", rel, del.Deleted)
+		}
+	}
+}
+
+func TestOntology_Strict(t *testing.T) {
+	h := newOntologyHandler(t, true)
+	seed(t, h)
+	ents := mustCall(t, h.CreateEntities, `{"entities": [{"name": "Paris", "entityType": "city"}]}`).(models.CreateEntitiesResult)
+	if r := ents.Results[0]; r.Status != models.StatusFailed || r.Error != `entity type "city" is not in the ontology` {
+		t.Errorf("result = %+v", r)
+	}
+
+	res := mustCall(t, h.CreateRelations, `{"relations": [
+		{"from": "Alice", "to": "Alice", "relationType": "works_at"},
+		{"from": "Alice", "to": "Bob", "relationType": "knows"},
+		{"from": "Alice", "to": "Initech", "relationType": "works_at"}
+	]}`).(models.CreateRelationsResult)
+	statuses := []string{res.Results[0].Status, res.Results[1].Status, res.Results[2].Status}
+	if want := []string{models.StatusFailed, models.StatusFailed, models.StatusCreated}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("statuses = %v, want %v", statuses, want)
+	}
+	if got := res.Results[0].Error; got != "works_at must end at organization, not person" {
+		t.Errorf("error = %q", got)
+	}
+
+	if _, err := h.Memorize(args(t, `{"entity": "Paris", "category": "city", "observation": "is big"}`)); err == nil {
+		t.Error("Memorize() with an undeclared type should fail")
+	}
+}
+
+func TestOntology_Warns(t *testing.T) {
+	h := newOntologyHandler(t, false)
+	res := mustCall(t, h.CreateEntities, `{"entities": [{"name": "Paris", "entityType": "city"}]}`).(models.CreateEntitiesResult)
+	if r := res.Results[0]; r.Status != models.StatusCreated || r.Warning != `entity type "city" is not in the ontology` {
+		t.Errorf("result = %+v", r)
+	}
+
+	rels := mustCall(t, h.CreateRelations, `{"relations": [
+		{"from": "Paris", "to": "Paris", "relationType": "works_at"},
+		{"from": "Paris", "to": "Lyon", "relationType": "twinned_with"}
+	]}`).(models.CreateRelationsResult)
+	if len(rels.Relations) != 2 || rels.Results[0].Warning != "works_at must start at person, not city" ||
+		rels.Results[1].Warning != `relation type "twinned_with" is not in the ontology` {
+		t.Errorf("CreateRelations() = %+v", rels)
+	}
+}
+
+// ontologyImportDoc has a declared type by its alias, an undeclared type, a
+// relation by its inverse and a relation between the wrong types.
+const ontologyImportDoc = `{"type":"entity","name":"Alice","entityType":"Person"}
+{"type":"entity","name":"Acme","entityType":"company"}
+{"type":"entity","name":"Paris","entityType":"city"}
+{"type":"relation","from":"Acme","to":"Alice","relationType":"employs"}
+{"type":"relation","from":"Paris","to":"Acme","relationType":"works_at"}`
+
+func TestOntology_Import(t *testing.T) {
+	h := newOntologyHandler(t, false)
+	res := mustCall(t, h.ImportGraph, `{"content": `+quote(ontologyImportDoc)+`}`).(models.ImportResult)
+	want := []models.ImportIssue{
+		{Item: `entity "Paris"`, ItemStatus: models.ItemStatus{Status: models.StatusCreated, Warning: `entity type "city" is not in the ontology`}},
+		{Item: "relation Acme -employs-> Alice", ItemStatus: models.ItemStatus{Status: models.StatusCreated, Warning: "stored reversed as Alice -works_at-> Acme"}},
+		{Item: "relation Paris -works_at-> Acme", ItemStatus: models.ItemStatus{Status: models.StatusCreated, Warning: "works_at must start at person, not city"}},
+	}
+	if !reflect.DeepEqual(res.Issues, want) || res.EntitiesCreated != 3 || res.RelationsCreated != 2 {
+		t.Errorf("ImportGraph() = %+v", res)
+	}
+	graph := readGraph(t, h)
+	if graph.Entities[0].EntityType != "organization" || graph.Entities[1].EntityType != "person" {
+		t.Errorf("entities = %+v", graph.Entities)
+	}
+	if r := graph.Relations[0]; r != (models.Relation{From: "Alice", To: "Acme", RelationType: "works_at"}) {
+		t.Errorf("relations = %+v", graph.Relations)
+	}
+
+	h = newOntologyHandler(t, true)
+	res = mustCall(t, h.ImportGraph, `{"content": `+quote(ontologyImportDoc)+`}`).(models.ImportResult)
+	if !res.HasFailures() || len(res.Issues) != 2 {
+		t.Errorf("ImportGraph(strict) = %+v", res)
+	}
+	if issue := res.Issues[0]; issue.Item != `entity "Paris"` || issue.Error != `entity type "city" is not in the ontology` {
+		t.Errorf("issue = %+v", issue)
+	}
+	// Like a relation to a new entity, the relation creates Paris as unknown.
+	if got := readGraph(t, h).Entities[2]; got.Name != "Paris" || got.EntityType != ontology.Unknown {
+		t.Errorf("Paris = %+v", got)
+	}
+}
+
+func TestGetOntology(t *testing.T) {
+	res := mustCall(t, newTestHandler(t).GetOntology, `{}`).(models.OntologyResult)
+	if res.Configured || len(res.EntityTypes) != 0 {
+		t.Errorf("GetOntology() without ontology = %+v", res)
+	}
+
+	res = mustCall(t, newOntologyHandler(t, true).GetOntology, `{}`).(models.OntologyResult)
+	if !res.Configured || !res.Strict || res.RelationTypes["works_at"].Inverse != "employs" || len(res.EntityTypes) != 2 {
+		t.Errorf("GetOntology() = %+v", res)
+	}
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mlcmcp/memory-server/internal/embedding"
+	"github.com/mlcmcp/memory-server/internal/privacy"
+)
+
+// encryptedInPlace holds in update triggers when a plaintext observation was
+// replaced by its encryption, which is no change to the fact.
+const encryptedInPlace = `old.entity_name = new.entity_name AND old.superseded_by IS new.superseded_by
+	AND old.content NOT LIKE '` + privacy.Prefix + `%' AND new.content LIKE '` + privacy.Prefix + `%'`
+
+// encryptionSchema keeps encrypted observations out of the search index, which
+// would otherwise hold them in plain or index their ciphertext, and lets
+// encrypting an existing fact leave its entity's timestamp and its vector alone.
+const encryptionSchema = `
+DROP TRIGGER observations_fts_insert;
+CREATE TRIGGER observations_fts_insert AFTER INSERT ON observations
+WHEN new.content NOT LIKE '` + privacy.Prefix + `%' BEGIN
+	INSERT INTO memory_fts (namespace, entity_name, kind, obs_id, content)
+	VALUES (new.namespace, new.entity_name, 'observation', new.id, new.content);
+END;
+DROP TRIGGER observations_fts_update;
+CREATE TRIGGER observations_fts_update AFTER UPDATE ON observations BEGIN
+	DELETE FROM memory_fts WHERE kind = 'observation' AND obs_id = old.id;
+	INSERT INTO memory_fts (namespace, entity_name, kind, obs_id, content)
+	SELECT new.namespace, new.entity_name, 'observation', new.id, new.content
+	WHERE new.content NOT LIKE '` + privacy.Prefix + `%';
+END;
+DROP TRIGGER observations_touch_update;
+CREATE TRIGGER observations_touch_update AFTER UPDATE OF entity_name, content, superseded_by ON observations
+WHEN NOT (` + encryptedInPlace + `) BEGIN
+	UPDATE entities SET updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE namespace = new.namespace AND name = new.entity_name;
+END;
+DROP TRIGGER observations_embedding_update;
+CREATE TRIGGER observations_embedding_update AFTER UPDATE OF entity_name, content ON observations
+WHEN NOT (` + encryptedInPlace + `) BEGIN
+	DELETE FROM embeddings WHERE obs_id = old.id;
+END;
+`
+
+// SetRedaction sets the policy for sensitive values in new observations. The
+// zero policy stores them as given.
+func (h *MemoryHandler) SetRedaction(p privacy.Policy) {
+	h.redaction = p
+}
+
+// redact applies the redaction policy to a fact. It returns the fact to store and
+// a warning naming the masked kinds, or an error if the policy rejects the fact.
+func (h *MemoryHandler) redact(content string) (string, string, error) {
+	redacted, masked, err := h.redaction.Apply(content)
+	if err != nil {
+		return "", "", err
+	}
+	return redacted, redactionWarning(masked), nil
+}
+
+// redactAll redacts the facts of one item, which fails as a whole if the policy
+// rejects any of them.
+func (h *MemoryHandler) redactAll(contents []string) ([]string, string, error) {
+	out := make([]string, len(contents))
+	seen := make(map[privacy.Kind]bool)
+	var masked []privacy.Kind
+	for i, content := range contents {
+		redacted, kinds, err := h.redaction.Apply(content)
+		if err != nil {
+			return nil, "", fmt.Errorf("observation %d %w", i+1, err)
+		}
+		out[i] = redacted
+		for _, k := range kinds {
+			if !seen[k] {
+				seen[k] = true
+				masked = append(masked, k)
+			}
+		}
+	}
+	return out, redactionWarning(masked), nil
+}
+
+func redactionWarning(masked []privacy.Kind) string {
+	if len(masked) == 0 {
+		return ""
+	}
+	kinds := make([]string, len(masked))
+	for i, k := range masked {
+		kinds[i] = string(k)
+	}
+	return "redacted " + strings.Join(kinds, ", ")
+}
+
+// joinWarnings joins the non-empty warnings about one item.
+func joinWarnings(warnings ...string) string {
+	var out []string
+	for _, w := range warnings {
+		if w != "" {
+			out = append(out, w)
+		}
+	}
+	return strings.Join(out, "; ")
+}
+
+// UseCipher encrypts the content and vectors of new observations with c, and
+// encrypts the observations, their history and vectors still stored in plain. It returns the
+// number of observations. It fails if the database holds observations that c
+// cannot decrypt, or encrypted ones while c is nil.
+func (h *MemoryHandler) UseCipher(c *privacy.Cipher) (int, error) {
+	var sample string
+	err := h.db.QueryRow("SELECT content FROM observations WHERE content LIKE ? LIMIT 1", privacy.Prefix+"%").Scan(&sample)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return 0, err
+	case c == nil:
+		return 0, errors.New("the database contains encrypted observations; set the encryption key")
+	default:
+		if _, err := c.Decrypt(sample); err != nil {
+			return 0, err
+		}
+	}
+	h.cipher = c
+	if c == nil {
+		return 0, nil
+	}
+
+	encrypted, events, vectors := 0, 0, 0
+	err = h.inTx(func(tx *sql.Tx) error {
+		var err error
+		if encrypted, err = encryptPlain(tx, c, "observations"); err != nil {
+			return err
+		}
+		if events, err = encryptPlain(tx, c, "events"); err != nil {
+			return err
+		}
+		vectors, err = encryptVectors(tx, c)
+		return err
+	})
+	if err != nil || encrypted+events+vectors == 0 {
+		return encrypted, err
+	}
+	// The plaintext may linger in free pages and the write-ahead log.
+	if _, err := h.db.Exec("VACUUM"); err != nil {
+		return encrypted, err
+	}
+	_, err = h.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+	return encrypted, err
+}
+
+// encryptPlain encrypts the content stored in plain in a table and returns the
+// number of rows.
+func encryptPlain(tx *sql.Tx, c *privacy.Cipher, table string) (int, error) {
+	rows, err := tx.Query("SELECT id, content FROM "+table+" WHERE content NOT LIKE ?", privacy.Prefix+"%")
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	var contents []string
+	for rows.Next() {
+		var id int64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		contents = append(contents, content)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		if _, err := tx.Exec("UPDATE "+table+" SET content = ? WHERE id = ?", c.Encrypt(contents[i]), id); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+// encryptVectors encrypts the vectors stored in plain and returns their number.
+// Encrypted vectors are stored as text, plain ones as blobs.
+func encryptVectors(tx *sql.Tx, c *privacy.Cipher) (int, error) {
+	rows, err := tx.Query("SELECT obs_id, vector FROM embeddings WHERE typeof(vector) = 'blob'")
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	var blobs [][]byte
+	for rows.Next() {
+		var id int64
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		blobs = append(blobs, blob)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		if _, err := tx.Exec("UPDATE embeddings SET vector = ? WHERE obs_id = ?", c.Encrypt(string(blobs[i])), id); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+// seal returns a fact as it is stored: encrypted if a cipher is set. Encryption
+// is deterministic, so sealed facts can be compared in queries.
+func (h *MemoryHandler) seal(content string) string {
+	if h.cipher == nil {
+		return content
+	}
+	return h.cipher.Encrypt(content)
+}
+
+// unseal returns a stored fact in plain.
+func (h *MemoryHandler) unseal(content string) (string, error) {
+	if h.cipher == nil {
+		return content, nil
+	}
+	return h.cipher.Decrypt(content)
+}
+
+// sealVector returns a vector as it is stored: encrypted if a cipher is set,
+// because the vectors of the hash embedder are hashes of the words of a fact,
+// and those of a model can be inverted.
+func (h *MemoryHandler) sealVector(v []float32) interface{} {
+	blob := embedding.Encode(v)
+	if h.cipher == nil {
+		return blob
+	}
+	return h.cipher.Encrypt(string(blob))
+}
+
+// unsealVector decodes a stored vector.
+func (h *MemoryHandler) unsealVector(stored []byte) ([]float32, error) {
+	blob, err := h.unseal(string(stored))
+	if err != nil {
+		return nil, err
+	}
+	return embedding.Decode([]byte(blob)), nil
+}
+
+// sealAll seals facts for comparison in queries.
+func (h *MemoryHandler) sealAll(contents []string) []string {
+	out := make([]string, len(contents))
+	for i, c := range contents {
+		out[i] = h.seal(c)
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import "github.com/mlcmcp/memory-server/internal/models"
+
+// DefaultNamespace holds memories that were stored without a namespace.
+const DefaultNamespace = "default"
+
+// baseSchema is the schema of the first release: one graph without namespaces.
+const baseSchema = `
+CREATE TABLE IF NOT EXISTS entities (
+	name TEXT PRIMARY KEY,
+	type TEXT
+);
+CREATE TABLE IF NOT EXISTS observations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	entity_name TEXT,
+	content TEXT,
+	FOREIGN KEY(entity_name) REFERENCES entities(name) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS relations (
+	from_name TEXT,
+	to_name TEXT,
+	type TEXT,
+	PRIMARY KEY(from_name, to_name, type),
+	FOREIGN KEY(from_name) REFERENCES entities(name) ON DELETE CASCADE,
+	FOREIGN KEY(to_name) REFERENCES entities(name) ON DELETE CASCADE
+);
+`
+
+// namespaceUpgrade moves the graph into the default namespace. Every row belongs
+// to a namespace, so the same entity name can exist in several projects without
+// their memories mixing. The search index is dropped and rebuilt.
+const namespaceUpgrade = `
+CREATE TABLE entities_new (
+	namespace TEXT NOT NULL DEFAULT 'default',
+	name TEXT NOT NULL,
+	type TEXT,
+	PRIMARY KEY(namespace, name)
+);
+INSERT INTO entities_new (namespace, name, type) SELECT 'default', name, type FROM entities;
+
+CREATE TABLE observations_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	namespace TEXT NOT NULL DEFAULT 'default',
+	entity_name TEXT,
+	content TEXT,
+	FOREIGN KEY(namespace, entity_name) REFERENCES entities(namespace, name) ON DELETE CASCADE
+);
+INSERT INTO observations_new (id, namespace, entity_name, content) SELECT id, 'default', entity_name, content FROM observations;
+
+CREATE TABLE relations_new (
+	namespace TEXT NOT NULL DEFAULT 'default',
+	from_name TEXT,
+	to_name TEXT,
+	type TEXT,
+	PRIMARY KEY(namespace, from_name, to_name, type),
+	FOREIGN KEY(namespace, from_name) REFERENCES entities(namespace, name) ON DELETE CASCADE,
+	FOREIGN KEY(namespace, to_name) REFERENCES entities(namespace, name) ON DELETE CASCADE
+);
+INSERT INTO relations_new (namespace, from_name, to_name, type) SELECT 'default', from_name, to_name, type FROM relations;
+
+DROP TABLE relations;
+DROP TABLE observations;
+DROP TABLE entities;
+DROP TABLE IF EXISTS memory_fts;
+ALTER TABLE entities_new RENAME TO entities;
+ALTER TABLE observations_new RENAME TO observations;
+ALTER TABLE relations_new RENAME TO relations;
+CREATE INDEX observations_entity ON observations(namespace, entity_name);
+`
+
+// orphanCleanup deletes the rows that foreign keys would have deleted had they
+// been enforced: observations and relations of deleted entities, and vectors of
+// deleted observations.
+const orphanCleanup = `
+DELETE FROM observations WHERE NOT EXISTS (
+	SELECT 1 FROM entities e WHERE e.namespace = observations.namespace AND e.name = observations.entity_name);
+DELETE FROM relations WHERE NOT EXISTS (
+	SELECT 1 FROM entities e WHERE e.namespace = relations.namespace AND e.name = relations.from_name)
+	OR NOT EXISTS (
+	SELECT 1 FROM entities e WHERE e.namespace = relations.namespace AND e.name = relations.to_name);
+DELETE FROM embeddings WHERE obs_id NOT IN (SELECT id FROM observations);
+`
+
+// ListNamespaces lists the namespaces that hold memories.
+func (h *MemoryHandler) ListNamespaces(args map[string]interface{}) (interface{}, error) {
+	rows, err := h.db.Query(`
+		SELECT namespace,
+			SUM(kind = 'entity'), SUM(kind = 'observation'), SUM(kind = 'relation')
+		FROM (
+			SELECT namespace, 'entity' AS kind FROM entities
+			UNION ALL SELECT namespace, 'observation' FROM observations
+			UNION ALL SELECT namespace, 'relation' FROM relations
+		)
+		GROUP BY namespace
+		ORDER BY namespace`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := models.NamespacesResult{Namespaces: []models.NamespaceInfo{}, Default: h.namespace}
+	for rows.Next() {
+		var info models.NamespaceInfo
+		if err := rows.Scan(&info.Name, &info.Entities, &info.Observations, &info.Relations); err != nil {
+			return nil, err
+		}
+		result.Namespaces = append(result.Namespaces, info)
+	}
+	return result, rows.Err()
+}
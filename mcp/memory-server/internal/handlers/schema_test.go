@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+func TestNamespaces_Isolation(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+	mustCall(t, h.CreateEntities, `{"namespace": "work", "entities": [
+		{"name": "Alice", "entityType": "colleague", "observations": ["reviews Go code"]}
+	]}`)
+	mustCall(t, h.CreateRelations, `{"namespace": "work", "relations": [{"from": "Alice", "to": "Bob", "relationType": "works_with"}]}`)
+
+	def := readGraph(t, h)
+	work := mustCall(t, h.ReadGraph, `{"namespace": "work"}`).(models.GraphPage).KnowledgeGraph
+	if len(def.Entities) != 2 || len(def.Relations) != 1 {
+		t.Errorf("default namespace = %+v", def)
+	}
+	wantWork := models.KnowledgeGraph{
+		Entities: []models.Entity{
+			{Name: "Alice", EntityType: "colleague", Observations: []string{"reviews Go code"}},
+			{Name: "Bob", EntityType: "unknown", Observations: []string{}},
+		},
+		Relations: []models.Relation{{From: "Alice", To: "Bob", RelationType: "works_with"}},
+	}
+	if !reflect.DeepEqual(work, wantWork) {
+		t.Errorf("work namespace = %+v, want %+v", work, wantWork)
+	}
+
+	if res := mustCall(t, h.SearchNodes, `{"query": "rockets", "namespace": "work"}`).(models.SearchResult); res.Total != 0 {
+		t.Errorf("search leaked across namespaces: %+v", res.Matches)
+	}
+	if res := mustCall(t, h.ListRelations, `{"namespace": "work"}`).(models.RelationsResult); res.Total != 1 {
+		t.Errorf("ListRelations(work) = %+v", res)
+	}
+
+	// Deleting in one namespace leaves the other alone.
+	mustCall(t, h.DeleteEntities, `{"namespace": "work", "entityNames": ["Alice"]}`)
+	if got := readGraph(t, h); len(got.Entities) != 2 || len(got.Entities[1].Observations) != 2 {
+		t.Errorf("default namespace after delete in work = %+v", got)
+	}
+
+	// The handler default applies to calls without a namespace.
+	h.SetDefaultNamespace("work")
+	if got := readGraph(t, h); len(got.Entities) != 1 || got.Entities[0].Name != "Bob" {
+		t.Errorf("ReadGraph() with default work = %+v", got)
+	}
+
+	list := mustCall(t, h.ListNamespaces, `{}`).(models.NamespacesResult)
+	wantList := models.NamespacesResult{
+		Namespaces: []models.NamespaceInfo{
+			{Name: "default", Entities: 2, Observations: 3, Relations: 1},
+			{Name: "work", Entities: 1},
+		},
+		Default: "work",
+	}
+	if !reflect.DeepEqual(list, wantList) {
+		t.Errorf("ListNamespaces() = %+v, want %+v", list, wantList)
+	}
+}
+
+func TestCreateSchema_UpgradesLegacyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.db")
+
+	// The schema before namespaces were introduced.
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE entities (name TEXT PRIMARY KEY, type TEXT);
+		CREATE TABLE observations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT, entity_name TEXT, content TEXT,
+			FOREIGN KEY(entity_name) REFERENCES entities(name) ON DELETE CASCADE);
+		CREATE TABLE relations (
+			from_name TEXT, to_name TEXT, type TEXT, PRIMARY KEY(from_name, to_name, type));
+		INSERT INTO entities VALUES ('Oly', 'person'), ('Coffee', 'drink');
+		INSERT INTO observations (entity_name, content) VALUES ('Oly', 'likes espresso');
+		INSERT INTO relations VALUES ('Oly', 'Coffee', 'likes');`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := NewMemoryHandler(path)
+	if err != nil {
+		t.Fatalf("NewMemoryHandler() error = %v", err)
+	}
+	defer h.Close()
+
+	want := models.KnowledgeGraph{
+		Entities: []models.Entity{
+			{Name: "Coffee", EntityType: "drink", Observations: []string{}},
+			{Name: "Oly", EntityType: "person", Observations: []string{"likes espresso"}},
+		},
+		Relations: []models.Relation{{From: "Oly", To: "Coffee", RelationType: "likes"}},
+	}
+	if got := readGraph(t, h); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadGraph() = %+v, want %+v", got, want)
+	}
+	if res := mustCall(t, h.SearchNodes, `{"query": "espresso"}`).(models.SearchResult); res.Total != 1 {
+		t.Errorf("search index not rebuilt: %+v", res)
+	}
+
+	// New rows get fresh ids after the copied ones.
+	mustCall(t, h.AddObservations, `{"observations": [{"entityName": "Oly", "contents": ["drinks tea"]}]}`)
+	var maxID int
+	h.db.QueryRow("SELECT MAX(id) FROM observations").Scan(&maxID)
+	if maxID != 2 {
+		t.Errorf("max observation id = %d, want 2", maxID)
+	}
+
+	// Opening the upgraded database again is a no-op.
+	h2, err := NewMemoryHandler(path)
+	if err != nil {
+		t.Fatalf("NewMemoryHandler() reopen error = %v", err)
+	}
+	h2.Close()
+}
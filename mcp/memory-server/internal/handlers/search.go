@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// searchSchema creates an FTS5 index over entity names, types and observations.
+// Each entity has one row of kind 'entity' and one row per observation; the
+// porter tokenizer lets "drinks" match "drinking".
+const searchSchema = `
+CREATE VIRTUAL TABLE IF NOT EXISTS memory_fts USING fts5(
+	namespace UNINDEXED,
+	entity_name UNINDEXED,
+	kind UNINDEXED,
+	obs_id UNINDEXED,
+	content,
+	tokenize = 'porter unicode61'
+);
+
+CREATE TRIGGER IF NOT EXISTS entities_fts_insert AFTER INSERT ON entities BEGIN
+	INSERT INTO memory_fts (namespace, entity_name, kind, obs_id, content)
+	VALUES (new.namespace, new.name, 'entity', NULL, new.name || ' ' || COALESCE(new.type, ''));
+END;
+CREATE TRIGGER IF NOT EXISTS entities_fts_delete AFTER DELETE ON entities BEGIN
+	DELETE FROM memory_fts WHERE kind = 'entity' AND namespace = old.namespace AND entity_name = old.name;
+END;
+CREATE TRIGGER IF NOT EXISTS entities_fts_update AFTER UPDATE ON entities BEGIN
+	DELETE FROM memory_fts WHERE kind = 'entity' AND namespace = old.namespace AND entity_name = old.name;
+	INSERT INTO memory_fts (namespace, entity_name, kind, obs_id, content)
+	VALUES (new.namespace, new.name, 'entity', NULL, new.name || ' ' || COALESCE(new.type, ''));
+END;
+
+CREATE TRIGGER IF NOT EXISTS observations_fts_insert AFTER INSERT ON observations BEGIN
+	INSERT INTO memory_fts (namespace, entity_name, kind, obs_id, content)
+	VALUES (new.namespace, new.entity_name, 'observation', new.id, new.content);
+END;
+CREATE TRIGGER IF NOT EXISTS observations_fts_delete AFTER DELETE ON observations BEGIN
+	DELETE FROM memory_fts WHERE kind = 'observation' AND obs_id = old.id;
+END;
+CREATE TRIGGER IF NOT EXISTS observations_fts_update AFTER UPDATE ON observations BEGIN
+	DELETE FROM memory_fts WHERE kind = 'observation' AND obs_id = old.id;
+	INSERT INTO memory_fts (namespace, entity_name, kind, obs_id, content)
+	VALUES (new.namespace, new.entity_name, 'observation', new.id, new.content);
+END;
+`
+
+// searchBackfill indexes the rows that existed before the index.
+const searchBackfill = `
+INSERT INTO memory_fts (namespace, entity_name, kind, obs_id, content)
+SELECT namespace, name, 'entity', NULL, name || ' ' || COALESCE(type, '') FROM entities;
+INSERT INTO memory_fts (namespace, entity_name, kind, obs_id, content)
+SELECT namespace, entity_name, 'observation', id, content FROM observations;
+`
+
+// ftsQuery turns free text into an FTS5 query that matches any of its words.
+// Words are quoted so that FTS5 operators in user input are taken literally.
+func ftsQuery(text string) string {
+	return strings.Join(ftsTerms(text), " OR ")
+}
+
+// ftsTerms returns the words of text as quoted FTS5 strings.
+func ftsTerms(text string) []string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		words[i] = `"` + w + `"`
+	}
+	return words
+}
+
+// getInt returns an integer argument; JSON numbers arrive as float64.
+func getInt(m map[string]interface{}, key string, def int) int {
+	if v, ok := m[key].(float64); ok {
+		return int(v)
+	}
+	return def
+}
+
+// SearchNodes returns the entities whose name, type or observations match the query,
+// ranked by BM25, together with the relations between them. Each match carries a
+// snippet of the best matching text with the matched words in **bold**. Superseded
+// facts are not searched unless includeSuperseded is set.
+func (h *MemoryHandler) SearchNodes(args map[string]interface{}) (interface{}, error) {
+	query, _ := args["query"].(string)
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	if ftsQuery(query) == "" {
+		return nil, fmt.Errorf("query %q contains no searchable words", query)
+	}
+
+	limit := getInt(args, "limit", defaultSearchLimit)
+	if limit <= 0 || limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	offset := max(getInt(args, "offset", 0), 0)
+
+	ns := h.namespaceOf(args)
+	includeSuperseded, _ := args["includeSuperseded"].(bool)
+	matches, err := h.ftsMatches(ns, query, includeSuperseded)
+	if err != nil {
+		return nil, err
+	}
+	return h.searchResult(ns, matches, limit, offset)
+}
+
+// ftsMatches returns the best full-text match per entity of a namespace, ordered by relevance.
+func (h *MemoryHandler) ftsMatches(ns, query string, includeSuperseded bool) ([]models.SearchMatch, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+	hidden := "superseded_by IS NOT NULL OR NOT " + notExpired("expires_at")
+	if includeSuperseded {
+		hidden = "NOT " + notExpired("expires_at")
+	}
+	current := " AND (kind = 'entity' OR obs_id NOT IN (SELECT id FROM observations WHERE " + hidden + "))"
+
+	rows, err := h.db.Query(`
+		SELECT entity_name, kind, snippet(memory_fts, 4, '**', '**', '…', 12), bm25(memory_fts)
+		FROM memory_fts
+		WHERE memory_fts MATCH ? AND namespace = ?`+current+`
+		ORDER BY bm25(memory_fts)`, match, ns, formatTime(time.Now()))
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	defer rows.Close()
+
+	// Rows are ordered by relevance; keep the best one per entity.
+	seen := make(map[string]bool)
+	var matches []models.SearchMatch
+	for rows.Next() {
+		var m models.SearchMatch
+		var rank float64
+		if err := rows.Scan(&m.EntityName, &m.Kind, &m.Snippet, &rank); err != nil {
+			return nil, err
+		}
+		if seen[m.EntityName] {
+			continue
+		}
+		seen[m.EntityName] = true
+		// bm25() is lower for better matches
+		m.Score = -rank
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// searchResult pages ranked matches and loads their entities in ranking order.
+func (h *MemoryHandler) searchResult(ns string, matches []models.SearchMatch, limit, offset int) (models.SearchResult, error) {
+	result := models.SearchResult{Total: len(matches), Matches: []models.SearchMatch{}}
+	if offset < len(matches) {
+		result.Matches = matches[offset:min(offset+limit, len(matches))]
+	}
+
+	names := make([]string, len(result.Matches))
+	for i, m := range result.Matches {
+		names[i] = m.EntityName
+	}
+	graph, err := h.loadGraph(ns, names)
+	if err != nil {
+		return result, err
+	}
+
+	byName := make(map[string]models.Entity, len(graph.Entities))
+	for _, e := range graph.Entities {
+		byName[e.Name] = e
+	}
+	result.Entities = make([]models.Entity, 0, len(names))
+	for _, name := range names {
+		if e, ok := byName[name]; ok {
+			result.Entities = append(result.Entities, e)
+		}
+	}
+	result.Relations = graph.Relations
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+func TestSearchNodes_RankingAndSnippets(t *testing.T) {
+	h := newTestHandler(t)
+	mustCall(t, h.CreateEntities, `{"entities": [
+		{"name": "Oly", "entityType": "person", "observations": ["drinks coffee every morning", "coffee with oat milk, strong coffee"]},
+		{"name": "Cafe", "entityType": "place", "observations": ["serves tea"]},
+		{"name": "Coffee Machine", "entityType": "device", "observations": []}
+	]}`)
+
+	res := mustCall(t, h.SearchNodes, `{"query": "coffee"}`).(models.SearchResult)
+	if res.Total != 2 || len(res.Matches) != 2 {
+		t.Fatalf("SearchNodes() = %+v, want 2 matches", res)
+	}
+	for i := 1; i < len(res.Matches); i++ {
+		if res.Matches[i].Score > res.Matches[i-1].Score {
+			t.Errorf("matches not ordered by score: %+v", res.Matches)
+		}
+	}
+	for i, m := range res.Matches {
+		if res.Entities[i].Name != m.EntityName {
+			t.Errorf("entity %d = %s, want %s", i, res.Entities[i].Name, m.EntityName)
+		}
+		if !strings.Contains(m.Snippet, "**") {
+			t.Errorf("snippet %q has no highlight", m.Snippet)
+		}
+	}
+
+	// Stemming: "drinking" matches "drinks".
+	res = mustCall(t, h.SearchNodes, `{"query": "drinking"}`).(models.SearchResult)
+	if res.Total != 1 || res.Matches[0].EntityName != "Oly" || res.Matches[0].Kind != "observation" {
+		t.Errorf("SearchNodes(drinking) = %+v", res.Matches)
+	}
+	if res.Matches[0].Snippet != "**drinks** coffee every morning" {
+		t.Errorf("snippet = %q", res.Matches[0].Snippet)
+	}
+
+	// FTS operators in the query are taken literally.
+	if _, err := h.SearchNodes(args(t, `{"query": "coffee AND \"tea OR"}`)); err != nil {
+		t.Errorf("SearchNodes() with operators error = %v", err)
+	}
+}
+
+func TestSearchNodes_Pagination(t *testing.T) {
+	h := newTestHandler(t)
+	mustCall(t, h.CreateEntities, `{"entities": [
+		{"name": "A", "entityType": "note", "observations": ["alpha"]},
+		{"name": "B", "entityType": "note", "observations": ["alpha"]},
+		{"name": "C", "entityType": "note", "observations": ["alpha"]}
+	]}`)
+
+	all := mustCall(t, h.SearchNodes, `{"query": "alpha"}`).(models.SearchResult)
+	page := mustCall(t, h.SearchNodes, `{"query": "alpha", "limit": 2, "offset": 1}`).(models.SearchResult)
+	if page.Total != 3 || len(page.Matches) != 2 {
+		t.Fatalf("page = %+v", page)
+	}
+	if page.Matches[0] != all.Matches[1] || page.Matches[1] != all.Matches[2] {
+		t.Errorf("page = %+v, want %+v", page.Matches, all.Matches[1:])
+	}
+
+	past := mustCall(t, h.SearchNodes, `{"query": "alpha", "offset": 5}`).(models.SearchResult)
+	if len(past.Matches) != 0 || len(past.Entities) != 0 {
+		t.Errorf("offset past the end = %+v", past)
+	}
+}
+
+func TestSearchNodes_FollowsEdits(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+
+	mustCall(t, h.RenameEntity, `{"oldName": "Acme", "newName": "Initech"}`)
+	if res := mustCall(t, h.SearchNodes, `{"query": "acme"}`).(models.SearchResult); res.Total != 0 {
+		t.Errorf("old name still indexed: %+v", res.Matches)
+	}
+	res := mustCall(t, h.SearchNodes, `{"query": "rockets"}`).(models.SearchResult)
+	if res.Total != 1 || res.Matches[0].EntityName != "Initech" {
+		t.Errorf("observation not re-pointed in index: %+v", res.Matches)
+	}
+
+	mustCall(t, h.DeleteEntities, `{"entityNames": ["Initech"]}`)
+	if res := mustCall(t, h.SearchNodes, `{"query": "rockets"}`).(models.SearchResult); res.Total != 0 {
+		t.Errorf("deleted entity still indexed: %+v", res.Matches)
+	}
+}
+
+func TestSearchIndex_BackfillsExistingDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.db")
+
+	// A database written before the search index existed.
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE entities (name TEXT PRIMARY KEY, type TEXT);
+		CREATE TABLE observations (id INTEGER PRIMARY KEY AUTOINCREMENT, entity_name TEXT, content TEXT);
+		INSERT INTO entities VALUES ('Oly', 'person');
+		INSERT INTO observations (entity_name, content) VALUES ('Oly', 'likes espresso');`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := NewMemoryHandler(path)
+	if err != nil {
+		t.Fatalf("NewMemoryHandler() error = %v", err)
+	}
+	defer h.Close()
+
+	res := mustCall(t, h.SearchNodes, `{"query": "espresso"}`).(models.SearchResult)
+	if res.Total != 1 || res.Entities[0].Name != "Oly" {
+		t.Errorf("SearchNodes() = %+v", res)
+	}
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mlcmcp/memory-server/internal/embedding"
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+const (
+	defaultSemanticLimit = 10
+	// embedBatchSize is the number of observations embedded per request.
+	embedBatchSize = 64
+	// rrfK dampens the influence of top ranks in reciprocal rank fusion.
+	rrfK = 60
+)
+
+// embeddingSchema stores one vector per observation. Vectors are dropped when the
+// observation changes and recomputed on the next semantic search.
+const embeddingSchema = `
+CREATE TABLE IF NOT EXISTS embeddings (
+	obs_id INTEGER PRIMARY KEY,
+	model TEXT NOT NULL,
+	vector BLOB NOT NULL,
+	FOREIGN KEY(obs_id) REFERENCES observations(id) ON DELETE CASCADE
+);
+CREATE TRIGGER IF NOT EXISTS observations_embedding_delete AFTER DELETE ON observations BEGIN
+	DELETE FROM embeddings WHERE obs_id = old.id;
+END;
+CREATE TRIGGER IF NOT EXISTS observations_embedding_update AFTER UPDATE OF entity_name, content ON observations BEGIN
+	DELETE FROM embeddings WHERE obs_id = old.id;
+END;
+`
+
+// SetEmbedder enables semantic search. A nil embedder disables it.
+func (h *MemoryHandler) SetEmbedder(e embedding.Embedder) {
+	h.embedder = e
+}
+
+// SemanticSearch returns the entities whose observations are closest in meaning to
+// the query. With hybrid ranking (the default), the result is fused with the
+// full-text ranking so that exact keyword hits are not lost. Superseded facts are
+// not searched.
+func (h *MemoryHandler) SemanticSearch(args map[string]interface{}) (interface{}, error) {
+	if h.embedder == nil {
+		return nil, fmt.Errorf("semantic search is disabled; start the server with -embedder hash or -embedder http")
+	}
+	query, _ := args["query"].(string)
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	limit := getInt(args, "limit", defaultSemanticLimit)
+	if limit <= 0 || limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	hybrid := true
+	if v, ok := args["hybrid"].(bool); ok {
+		hybrid = v
+	}
+	minScore := 0.0
+	if v, ok := args["minScore"].(float64); ok {
+		minScore = v
+	}
+
+	ns := h.namespaceOf(args)
+	ctx := context.Background()
+	if err := h.embedPending(ctx, ns); err != nil {
+		return nil, err
+	}
+	vectors, err := h.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := h.vectorMatches(ns, vectors[0], minScore)
+	if err != nil {
+		return nil, err
+	}
+	if hybrid {
+		keyword, err := h.ftsMatches(ns, query, false)
+		if err != nil {
+			return nil, err
+		}
+		matches = fuseRankings(matches, keyword)
+	}
+	return h.searchResult(ns, matches, limit, 0)
+}
+
+// embedPending embeds the current observations of a namespace that have no vector for the current model yet.
+func (h *MemoryHandler) embedPending(ctx context.Context, ns string) error {
+	model := h.embedder.Name()
+	for {
+		rows, err := h.db.Query(`
+			SELECT o.id, o.entity_name, o.content
+			FROM observations o
+			LEFT JOIN embeddings e ON e.obs_id = o.id AND e.model = ?
+			WHERE o.namespace = ? AND o.superseded_by IS NULL AND e.obs_id IS NULL
+			LIMIT ?`, model, ns, embedBatchSize)
+		if err != nil {
+			return err
+		}
+		var ids []int64
+		var texts []string
+		for rows.Next() {
+			var id int64
+			var name, content string
+			if err := rows.Scan(&id, &name, &content); err != nil {
+				rows.Close()
+				return err
+			}
+			if content, err = h.unseal(content); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+			// The entity name gives short facts their subject.
+			texts = append(texts, name+": "+content)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		vectors, err := h.embedder.Embed(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("failed to embed observations: %w", err)
+		}
+		if len(vectors) != len(ids) {
+			return fmt.Errorf("failed to embed observations: got %d vectors for %d texts", len(vectors), len(ids))
+		}
+		if err := h.storeVectors(model, ids, vectors); err != nil {
+			return err
+		}
+	}
+}
+
+// storeVectors saves a batch of vectors in one transaction.
+func (h *MemoryHandler) storeVectors(model string, ids []int64, vectors [][]float32) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, id := range ids {
+		embedding.Normalize(vectors[i])
+		_, err := tx.Exec("INSERT OR REPLACE INTO embeddings (obs_id, model, vector) VALUES (?, ?, ?)", id, model, h.sealVector(vectors[i]))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// vectorMatches returns the most similar observation per entity, best first.
+func (h *MemoryHandler) vectorMatches(ns string, query []float32, minScore float64) ([]models.SearchMatch, error) {
+	rows, err := h.db.Query(`
+		SELECT o.entity_name, o.content, e.vector
+		FROM embeddings e
+		JOIN observations o ON o.id = e.obs_id
+		WHERE e.model = ? AND o.namespace = ? AND o.superseded_by IS NULL AND `+notExpired("o.expires_at"),
+		h.embedder.Name(), ns, formatTime(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	best := make(map[string]models.SearchMatch)
+	for rows.Next() {
+		var name, content string
+		var blob []byte
+		if err := rows.Scan(&name, &content, &blob); err != nil {
+			return nil, err
+		}
+		vector, err := h.unsealVector(blob)
+		if err != nil {
+			return nil, err
+		}
+		score := embedding.Cosine(query, vector)
+		if score <= minScore {
+			continue
+		}
+		if content, err = h.unseal(content); err != nil {
+			return nil, err
+		}
+		if m, ok := best[name]; !ok || score > m.Score {
+			best[name] = models.SearchMatch{EntityName: name, Kind: "observation", Snippet: content, Score: score}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	matches := make([]models.SearchMatch, 0, len(best))
+	for _, m := range best {
+		matches = append(matches, m)
+	}
+	sortMatches(matches)
+	return matches, nil
+}
+
+// fuseRankings combines a semantic and a keyword ranking with reciprocal rank
+// fusion. Keyword snippets are preferred since they highlight the matched words.
+func fuseRankings(semantic, keyword []models.SearchMatch) []models.SearchMatch {
+	fused := make(map[string]models.SearchMatch)
+	for _, ranking := range [][]models.SearchMatch{semantic, keyword} {
+		for rank, m := range ranking {
+			score := 1 / float64(rrfK+rank+1)
+			if prev, ok := fused[m.EntityName]; ok {
+				score += prev.Score
+			}
+			m.Score = score
+			fused[m.EntityName] = m
+		}
+	}
+
+	matches := make([]models.SearchMatch, 0, len(fused))
+	for _, m := range fused {
+		matches = append(matches, m)
+	}
+	sortMatches(matches)
+	return matches
+}
+
+// sortMatches orders matches by descending score, then by name for stable output.
+func sortMatches(matches []models.SearchMatch) {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].EntityName < matches[j].EntityName
+	})
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mlcmcp/memory-server/internal/embedding"
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+// conceptEmbedder maps words to concepts, standing in for a real model that
+// knows that coffee is a beverage.
+type conceptEmbedder struct {
+	calls int
+}
+
+var concepts = map[string]int{
+	"coffee": 0, "tea": 0, "beverage": 0, "drinks": 0,
+	"chess": 1, "games": 1,
+	"berlin": 2, "city": 2,
+}
+
+func (e *conceptEmbedder) Name() string { return "concepts" }
+
+func (e *conceptEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.calls++
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, 4)
+		for _, w := range strings.Fields(strings.ToLower(strings.Trim(text, ".:"))) {
+			if c, ok := concepts[strings.Trim(w, ".:,")]; ok {
+				v[c]++
+			}
+		}
+		// Unknown words share a dimension with little weight.
+		v[3] = 0.1
+		out[i] = v
+	}
+	return out, nil
+}
+
+var _ embedding.Embedder = (*conceptEmbedder)(nil)
+
+func TestSemanticSearch_FindsParaphrases(t *testing.T) {
+	h := newTestHandler(t)
+	h.SetEmbedder(&conceptEmbedder{})
+	mustCall(t, h.CreateEntities, `{"entities": [
+		{"name": "Oly", "entityType": "person", "observations": ["likes coffee"]},
+		{"name": "Max", "entityType": "person", "observations": ["plays chess"]},
+		{"name": "Home", "entityType": "place", "observations": ["in Berlin"]}
+	]}`)
+
+	res := mustCall(t, h.SemanticSearch, `{"query": "beverage preferences", "hybrid": false}`).(models.SearchResult)
+	if len(res.Matches) == 0 || res.Matches[0].EntityName != "Oly" {
+		t.Fatalf("SemanticSearch() = %+v, want Oly first", res.Matches)
+	}
+	if res.Matches[0].Snippet != "likes coffee" {
+		t.Errorf("snippet = %q", res.Matches[0].Snippet)
+	}
+
+	// Keyword search alone misses the paraphrase.
+	if kw := mustCall(t, h.SearchNodes, `{"query": "beverage preferences"}`).(models.SearchResult); kw.Total != 0 {
+		t.Errorf("SearchNodes() = %+v, want no matches", kw.Matches)
+	}
+}
+
+func TestSemanticSearch_Hybrid(t *testing.T) {
+	h := newTestHandler(t)
+	h.SetEmbedder(&conceptEmbedder{})
+	mustCall(t, h.CreateEntities, `{"entities": [
+		{"name": "Oly", "entityType": "person", "observations": ["likes tea"]},
+		{"name": "Kettle", "entityType": "device", "observations": ["boils water for the beverage"]}
+	]}`)
+
+	res := mustCall(t, h.SemanticSearch, `{"query": "beverage", "limit": 1}`).(models.SearchResult)
+	if res.Total != 2 || len(res.Matches) != 1 {
+		t.Fatalf("SemanticSearch() = %+v", res)
+	}
+	// Kettle matches both semantically and by keyword, so fusion ranks it first.
+	if res.Matches[0].EntityName != "Kettle" || !strings.Contains(res.Matches[0].Snippet, "**beverage**") {
+		t.Errorf("top match = %+v", res.Matches[0])
+	}
+}
+
+func TestSemanticSearch_EmbedsIncrementally(t *testing.T) {
+	h := newTestHandler(t)
+	e := &conceptEmbedder{}
+	h.SetEmbedder(e)
+	seed(t, h)
+
+	mustCall(t, h.SemanticSearch, `{"query": "city"}`)
+	var stored int
+	h.db.QueryRow("SELECT COUNT(*) FROM embeddings").Scan(&stored)
+	if stored != 3 {
+		t.Errorf("stored %d vectors, want 3", stored)
+	}
+
+	// Nothing new: only the query is embedded.
+	calls := e.calls
+	mustCall(t, h.SemanticSearch, `{"query": "city"}`)
+	if e.calls != calls+1 {
+		t.Errorf("Embed() called %d times, want 1", e.calls-calls)
+	}
+
+	// Changed and deleted observations lose their vectors.
+	mustCall(t, h.RenameEntity, `{"oldName": "Acme", "newName": "Initech"}`)
+	mustCall(t, h.DeleteObservations, `{"deletions": [{"entityName": "Alice", "observations": ["likes Go"]}]}`)
+	h.db.QueryRow("SELECT COUNT(*) FROM embeddings").Scan(&stored)
+	if stored != 1 {
+		t.Errorf("stored %d vectors after edits, want 1", stored)
+	}
+
+	res := mustCall(t, h.SemanticSearch, `{"query": "berlin city", "hybrid": false}`).(models.SearchResult)
+	if len(res.Matches) == 0 || res.Matches[0].EntityName != "Alice" {
+		t.Errorf("SemanticSearch() = %+v", res.Matches)
+	}
+}
+
+func TestSemanticSearch_Disabled(t *testing.T) {
+	h := newTestHandler(t)
+	if _, err := h.SemanticSearch(args(t, `{"query": "x"}`)); err == nil {
+		t.Error("SemanticSearch() without embedder should fail")
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+func TestCreateEntities_ItemStatuses(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+
+	res := mustCall(t, h.CreateEntities, `{"entities": [
+		{"name": "Alice", "entityType": "person"},
+		{"entityType": "person"},
+		{"name": "Bob", "entityType": "person"}
+	]}`).(models.CreateEntitiesResult)
+	want := []models.EntityStatus{
+		{Name: "Alice", ItemStatus: models.ItemStatus{Status: models.StatusExists}},
+		{ItemStatus: models.Failed("name is required")},
+		{Name: "Bob", ItemStatus: models.ItemStatus{Status: models.StatusCreated}},
+	}
+	if !reflect.DeepEqual(res.Results, want) {
+		t.Errorf("Results = %+v, want %+v", res.Results, want)
+	}
+	if !res.HasFailures() {
+		t.Error("HasFailures() = false")
+	}
+	if len(readGraph(t, h).Entities) != 3 {
+		t.Error("valid entities of a batch with a failed item were not created")
+	}
+}
+
+func TestCreateRelations_ItemStatuses(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+
+	res := mustCall(t, h.CreateRelations, `{"relations": [
+		{"from": "Alice", "to": "Acme", "relationType": "works_at"},
+		{"from": "Alice", "relationType": "knows"},
+		{"from": "Alice", "to": "Bob", "relationType": "knows"}
+	]}`).(models.CreateRelationsResult)
+	var got []string
+	for _, r := range res.Results {
+		got = append(got, r.Status)
+	}
+	if !reflect.DeepEqual(got, []string{models.StatusExists, models.StatusFailed, models.StatusCreated}) {
+		t.Errorf("statuses = %v", got)
+	}
+	if len(res.Relations) != 1 || res.Relations[0].To != "Bob" {
+		t.Errorf("Relations = %+v", res.Relations)
+	}
+}
+
+func TestAddObservations_ItemStatuses(t *testing.T) {
+	h := newTestHandler(t)
+	seed(t, h)
+
+	res := mustCall(t, h.AddObservations, `{"observations": [
+		{"entityName": "Alice", "contents": ["plays chess"], "confidence": 3},
+		{"contents": ["orphan"]},
+		{"entityName": "Acme", "contents": ["builds rockets", "sells satellites"]}
+	]}`).(models.AddObservationsResult)
+	if s := res.Results[0].Statuses[0]; s.Status != models.StatusFailed || !strings.Contains(s.Error, "confidence") {
+		t.Errorf("invalid confidence status = %+v", s)
+	}
+	if s := res.Results[1].Statuses[0]; s.Status != models.StatusFailed || s.Content != "orphan" {
+		t.Errorf("missing entity status = %+v", s)
+	}
+	if got := res.Results[2].AddedObservations; !reflect.DeepEqual(got, []string{"sells satellites"}) {
+		t.Errorf("AddedObservations = %v", got)
+	}
+	if !res.HasFailures() {
+		t.Error("HasFailures() = false")
+	}
+}
+
+func TestWrites_RollBackOnError(t *testing.T) {
+	h := newTestHandler(t)
+	if _, err := h.db.Exec(`CREATE TRIGGER reject_boom BEFORE INSERT ON entities WHEN new.name = 'Boom'
+		BEGIN SELECT RAISE(ABORT, 'boom rejected'); END`); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := h.CreateEntities(args(t, `{"entities": [
+		{"name": "Alice", "entityType": "person", "observations": ["likes Go"]},
+		{"name": "Boom", "entityType": "thing"}
+	]}`))
+	if err == nil || !strings.Contains(err.Error(), "boom rejected") {
+		t.Fatalf("CreateEntities() error = %v", err)
+	}
+	if got := readGraph(t, h); len(got.Entities) != 0 {
+		t.Errorf("failed batch was partly applied: %+v", got)
+	}
+
+	if _, err := h.CreateRelations(args(t, `{"relations": [{"from": "Alice", "to": "Boom"}]}`)); err == nil {
+		t.Fatal("CreateRelations() should fail")
+	}
+	if got := readGraph(t, h); len(got.Entities) != 0 {
+		t.Errorf("failed relation left entities behind: %+v", got)
+	}
+}
+
+func TestDeleteEntities_CascadesThroughForeignKeys(t *testing.T) {
+	h := newTestHandler(t)
+	h.SetEmbedder(&conceptEmbedder{})
+	seed(t, h)
+	mustCall(t, h.SemanticSearch, `{"query": "rockets"}`)
+
+	mustCall(t, h.DeleteEntities, `{"entityNames": ["Acme"]}`)
+
+	for table, want := range map[string]int{
+		"observations": 2,
+		"relations":    0,
+		"embeddings":   2,
+		"memory_fts":   3, // Alice and her two observations
+	} {
+		var n int
+		if err := h.db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Errorf("%s has %d rows, want %d", table, n, want)
+		}
+	}
+
+	// Rows referencing a missing entity are rejected.
+	if _, err := h.db.Exec("INSERT INTO observations (namespace, entity_name, content) VALUES ('default', 'Ghost', 'boo')"); err == nil {
+		t.Error("foreign keys are not enforced")
+	}
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+const (
+	defaultNeighborhoodDepth = 1
+	maxNeighborhoodDepth     = 5
+	defaultPathDepth         = 4
+	maxPathDepth             = 6
+	defaultRelationsLimit    = 100
+	maxRelationsLimit        = 1000
+)
+
+// edgesCTE returns a common table expression "edges(src, dst, rel)" with one row
+// per traversable relation of a namespace in the given direction ("out", "in"
+// or "both"). rel is the rowid of the relation. types are the relation types as
+// stored, see relationTypes.
+func edgesCTE(ns, direction string, types []string) (string, []interface{}, error) {
+	filter, args := " WHERE namespace = ?", []interface{}{ns}
+	if len(types) > 0 {
+		in, typeArgs := inClause(types)
+		filter += " AND type IN " + in
+		args = append(args, typeArgs...)
+	}
+
+	out := "SELECT from_name, to_name, rowid FROM relations" + filter
+	in := "SELECT to_name, from_name, rowid FROM relations" + filter
+	switch direction {
+	case "", "both":
+		return "edges(src, dst, rel) AS (" + out + " UNION ALL " + in + ")", append(args, args...), nil
+	case "out":
+		return "edges(src, dst, rel) AS (" + out + ")", args, nil
+	case "in":
+		return "edges(src, dst, rel) AS (" + in + ")", args, nil
+	default:
+		return "", nil, fmt.Errorf("invalid direction %q (want out, in or both)", direction)
+	}
+}
+
+// clampInt returns an integer argument limited to [1, maxValue].
+func clampInt(args map[string]interface{}, key string, def, maxValue int) int {
+	return min(max(getInt(args, key, def), 1), maxValue)
+}
+
+// requireEntity fails if the entity does not exist in the namespace.
+func (h *MemoryHandler) requireEntity(ns, name string) error {
+	var exists bool
+	if err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM entities WHERE namespace = ? AND name = ?)", ns, name).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("entity %q not found", name)
+	}
+	return nil
+}
+
+// Neighborhood returns the entities within a number of hops of an entity, with
+// the relations between them.
+func (h *MemoryHandler) Neighborhood(args map[string]interface{}) (interface{}, error) {
+	name := getField(args, "name", "entityName")
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	ns := h.namespaceOf(args)
+	if err := h.requireEntity(ns, name); err != nil {
+		return nil, err
+	}
+	depth := clampInt(args, "depth", defaultNeighborhoodDepth, maxNeighborhoodDepth)
+	types := h.relationTypes(getStrings(args, "relationTypes"))
+	edges, edgeArgs, err := edgesCTE(ns, getField(args, "direction"), types)
+	if err != nil {
+		return nil, err
+	}
+
+	// UNION drops repeated (name, depth) rows, and the depth bound ends cycles.
+	rows, err := h.db.Query(`
+		WITH RECURSIVE `+edges+`,
+		walk(name, depth) AS (
+			SELECT ?, 0
+			UNION
+			SELECT e.dst, w.depth + 1 FROM walk w JOIN edges e ON e.src = w.name
+			WHERE w.depth < ?
+		)
+		SELECT name, MIN(depth) FROM walk GROUP BY name`,
+		append(edgeArgs, name, depth)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hops := make(map[string]int)
+	var names []string
+	for rows.Next() {
+		var n string
+		var d int
+		if err := rows.Scan(&n, &d); err != nil {
+			return nil, err
+		}
+		hops[n] = d
+		names = append(names, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	graph, err := h.loadGraph(ns, names)
+	if err != nil {
+		return nil, err
+	}
+	if len(types) > 0 {
+		graph.Relations = filterRelations(graph.Relations, types)
+	}
+	return models.NeighborhoodResult{KnowledgeGraph: graph, Center: name, Hops: hops}, nil
+}
+
+// ShortestPath finds a path with the fewest hops between two entities.
+func (h *MemoryHandler) ShortestPath(args map[string]interface{}) (interface{}, error) {
+	return h.ShortestPathContext(context.Background(), args)
+}
+
+// pathStep records the relation an entity was first reached by and the entity
+// at its other end.
+type pathStep struct {
+	from string
+	rel  int64
+}
+
+// ShortestPathContext is ShortestPath with a context that cancels the search.
+func (h *MemoryHandler) ShortestPathContext(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	from := getField(args, "from", "source")
+	to := getField(args, "to", "target")
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("from and to are required")
+	}
+	ns := h.namespaceOf(args)
+	for _, name := range []string{from, to} {
+		if err := h.requireEntity(ns, name); err != nil {
+			return nil, err
+		}
+	}
+	maxDepth := clampInt(args, "maxDepth", defaultPathDepth, maxPathDepth)
+	edges, edgeArgs, err := edgesCTE(ns, getField(args, "direction"), h.relationTypes(getStrings(args, "relationTypes")))
+	if err != nil {
+		return nil, err
+	}
+
+	result := models.PathResult{Path: []string{}, Entities: []models.Entity{}, Relations: []models.Relation{}}
+
+	// Breadth first, one query per level: every entity is expanded once, at
+	// the depth it is first reached, so dense graphs cost no more than their
+	// relations.
+	prev := map[string]pathStep{from: {}}
+	frontier := []string{from}
+	for depth := 0; depth < maxDepth && len(frontier) > 0; depth++ {
+		if _, ok := prev[to]; ok {
+			break
+		}
+		if frontier, err = h.expandFrontier(ctx, edges, edgeArgs, frontier, prev); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := prev[to]; !ok {
+		return result, nil
+	}
+
+	var rels []int64
+	for name := to; name != from; name = prev[name].from {
+		result.Path = append(result.Path, name)
+		rels = append(rels, prev[name].rel)
+	}
+	result.Path = append(result.Path, from)
+	slices.Reverse(result.Path)
+	slices.Reverse(rels)
+	result.Found = true
+	result.Length = len(rels)
+
+	graph, err := h.loadGraph(ns, result.Path)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]models.Entity, len(graph.Entities))
+	for _, e := range graph.Entities {
+		byName[e.Name] = e
+	}
+	for _, name := range result.Path {
+		result.Entities = append(result.Entities, byName[name])
+	}
+
+	for _, rowid := range rels {
+		var r models.Relation
+		err := h.db.QueryRowContext(ctx, "SELECT from_name, to_name, type FROM relations WHERE rowid = ?", rowid).Scan(&r.From, &r.To, &r.RelationType)
+		if err != nil {
+			return nil, err
+		}
+		result.Relations = append(result.Relations, r)
+	}
+	return result, nil
+}
+
+// expandFrontier follows the edges out of the frontier to entities not reached
+// before, records how they were reached in prev and returns them as the next
+// frontier.
+func (h *MemoryHandler) expandFrontier(ctx context.Context, edges string, edgeArgs []interface{}, frontier []string, prev map[string]pathStep) ([]string, error) {
+	var next []string
+	for start := 0; start < len(frontier); start += deleteBatch {
+		in, inArgs := inClause(frontier[start:min(start+deleteBatch, len(frontier))])
+		rows, err := h.db.QueryContext(ctx, "WITH "+edges+" SELECT src, dst, rel FROM edges WHERE src IN "+in+" ORDER BY rel",
+			append(slices.Clip(edgeArgs), inArgs...)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var src, dst string
+			var rel int64
+			if err := rows.Scan(&src, &dst, &rel); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if _, seen := prev[dst]; !seen {
+				prev[dst] = pathStep{from: src, rel: rel}
+				next = append(next, dst)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return next, nil
+}
+
+// ListRelations lists relations, optionally only those of an entity in a
+// direction and of certain types.
+func (h *MemoryHandler) ListRelations(args map[string]interface{}) (interface{}, error) {
+	conds := []string{"namespace = ?"}
+	params := []interface{}{h.namespaceOf(args)}
+
+	if name := getField(args, "name", "entityName"); name != "" {
+		switch direction := getField(args, "direction"); direction {
+		case "", "both":
+			conds = append(conds, "(from_name = ? OR to_name = ?)")
+			params = append(params, name, name)
+		case "out":
+			conds = append(conds, "from_name = ?")
+			params = append(params, name)
+		case "in":
+			conds = append(conds, "to_name = ?")
+			params = append(params, name)
+		default:
+			return nil, fmt.Errorf("invalid direction %q (want out, in or both)", direction)
+		}
+	}
+	if types := h.relationTypes(getStrings(args, "relationTypes")); len(types) > 0 {
+		in, typeArgs := inClause(types)
+		conds = append(conds, "type IN "+in)
+		params = append(params, typeArgs...)
+	}
+
+	filter := " WHERE " + strings.Join(conds, " AND ")
+
+	result := models.RelationsResult{Relations: []models.Relation{}}
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM relations"+filter, params...).Scan(&result.Total); err != nil {
+		return nil, err
+	}
+
+	limit := clampInt(args, "limit", defaultRelationsLimit, maxRelationsLimit)
+	offset := max(getInt(args, "offset", 0), 0)
+	rows, err := h.db.Query("SELECT from_name, to_name, type FROM relations"+filter+" ORDER BY from_name, to_name, type LIMIT ? OFFSET ?",
+		append(params, limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r models.Relation
+		if err := rows.Scan(&r.From, &r.To, &r.RelationType); err != nil {
+			return nil, err
+		}
+		result.Relations = append(result.Relations, r)
+	}
+	return result, rows.Err()
+}
+
+// filterRelations keeps the relations of the given types.
+func filterRelations(relations []models.Relation, types []string) []models.Relation {
+	keep := make(map[string]bool, len(types))
+	for _, t := range types {
+		keep[t] = true
+	}
+	out := []models.Relation{}
+	for _, r := range relations {
+		if keep[r.RelationType] {
+			out = append(out, r)
+		}
+	}
+	return out
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mlcmcp/memory-server/internal/models"
+)
+
+// seedChain creates Alice -works_at-> Acme -located_in-> Berlin <-lives_in- Bob,
+// plus Alice -knows-> Bob.
+func seedChain(t *testing.T, h *MemoryHandler) {
+	t.Helper()
+	mustCall(t, h.CreateRelations, `{"relations": [
+		{"from": "Alice", "to": "Acme", "relationType": "works_at"},
+		{"from": "Acme", "to": "Berlin", "relationType": "located_in"},
+		{"from": "Bob", "to": "Berlin", "relationType": "lives_in"},
+		{"from": "Alice", "to": "Bob", "relationType": "knows"},
+		{"from": "Berlin", "to": "Alice", "relationType": "home_of"}
+	]}`)
+}
+
+func TestNeighborhood(t *testing.T) {
+	h := newTestHandler(t)
+	seedChain(t, h)
+
+	tests := []struct {
+		name string
+		args string
+		want map[string]int
+	}{
+		{"one hop", `{"name": "Acme"}`, map[string]int{"Acme": 0, "Alice": 1, "Berlin": 1}},
+		{"two hops", `{"name": "Acme", "depth": 2}`, map[string]int{"Acme": 0, "Alice": 1, "Berlin": 1, "Bob": 2}},
+		{"outgoing", `{"name": "Acme", "depth": 2, "direction": "out"}`, map[string]int{"Acme": 0, "Berlin": 1, "Alice": 2}},
+		{"incoming", `{"name": "Berlin", "direction": "in"}`, map[string]int{"Berlin": 0, "Acme": 1, "Bob": 1}},
+		{"by type", `{"name": "Alice", "depth": 3, "relationTypes": ["works_at", "located_in"]}`, map[string]int{"Alice": 0, "Acme": 1, "Berlin": 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := mustCall(t, h.Neighborhood, tt.args).(models.NeighborhoodResult)
+			if !reflect.DeepEqual(res.Hops, tt.want) {
+				t.Errorf("hops = %v, want %v", res.Hops, tt.want)
+			}
+			if len(res.Entities) != len(tt.want) {
+				t.Errorf("entities = %+v", res.Entities)
+			}
+		})
+	}
+
+	res := mustCall(t, h.Neighborhood, `{"name": "Alice", "relationTypes": ["knows"]}`).(models.NeighborhoodResult)
+	want := []models.Relation{{From: "Alice", To: "Bob", RelationType: "knows"}}
+	if !reflect.DeepEqual(res.Relations, want) {
+		t.Errorf("relations = %+v, want %+v", res.Relations, want)
+	}
+
+	if _, err := h.Neighborhood(args(t, `{"name": "Missing"}`)); err == nil {
+		t.Error("Neighborhood() of a missing entity should fail")
+	}
+	if _, err := h.Neighborhood(args(t, `{"name": "Acme", "direction": "up"}`)); err == nil {
+		t.Error("Neighborhood() with an invalid direction should fail")
+	}
+}
+
+func TestShortestPath(t *testing.T) {
+	h := newTestHandler(t)
+	seedChain(t, h)
+
+	res := mustCall(t, h.ShortestPath, `{"from": "Acme", "to": "Bob"}`).(models.PathResult)
+	if !res.Found || res.Length != 2 {
+		t.Fatalf("ShortestPath() = %+v", res)
+	}
+	// Both Acme-Alice-Bob and Acme-Berlin-Bob have two hops.
+	if res.Path[0] != "Acme" || res.Path[2] != "Bob" || len(res.Relations) != 2 || len(res.Entities) != 3 {
+		t.Errorf("path = %v, relations %+v", res.Path, res.Relations)
+	}
+	for i, e := range res.Entities {
+		if e.Name != res.Path[i] {
+			t.Errorf("entity %d = %s, want %s", i, e.Name, res.Path[i])
+		}
+	}
+
+	// Following the stored direction: Bob -> Berlin -> Alice -> Acme.
+	res = mustCall(t, h.ShortestPath, `{"from": "Bob", "to": "Acme", "direction": "out"}`).(models.PathResult)
+	wantPath := []string{"Bob", "Berlin", "Alice", "Acme"}
+	wantRels := []models.Relation{
+		{From: "Bob", To: "Berlin", RelationType: "lives_in"},
+		{From: "Berlin", To: "Alice", RelationType: "home_of"},
+		{From: "Alice", To: "Acme", RelationType: "works_at"},
+	}
+	if !reflect.DeepEqual(res.Path, wantPath) || !reflect.DeepEqual(res.Relations, wantRels) {
+		t.Errorf("ShortestPath(out) = %v %+v", res.Path, res.Relations)
+	}
+
+	res = mustCall(t, h.ShortestPath, `{"from": "Bob", "to": "Acme", "direction": "out", "maxDepth": 2}`).(models.PathResult)
+	if res.Found {
+		t.Errorf("ShortestPath() beyond maxDepth = %+v", res)
+	}
+
+	res = mustCall(t, h.ShortestPath, `{"from": "Alice", "to": "Alice"}`).(models.PathResult)
+	if !res.Found || res.Length != 0 || len(res.Entities) != 1 {
+		t.Errorf("ShortestPath(self) = %+v", res)
+	}
+}
+
+func TestShortestPath_DenseGraph(t *testing.T) {
+	h := newTestHandler(t)
+	var relations []string
+	for i := range 16 {
+		for j := range 16 {
+			if i != j {
+				relations = append(relations, fmt.Sprintf(`{"from": "n%d", "to": "n%d", "relationType": "knows"}`, i, j))
+			}
+		}
+	}
+	mustCall(t, h.CreateRelations, `{"relations": [`+strings.Join(relations, ",")+`]}`)
+	mustCall(t, h.CreateEntities, `{"entities": [{"name": "Loner", "entityType": "person"}]}`)
+
+	done := make(chan models.PathResult, 1)
+	go func() {
+		res, err := h.ShortestPath(args(t, `{"from": "n0", "to": "Loner", "maxDepth": 6}`))
+		if err != nil {
+			t.Error(err)
+		}
+		done <- res.(models.PathResult)
+	}()
+	select {
+	case res := <-done:
+		if res.Found {
+			t.Errorf("ShortestPath() to an unreachable entity = %+v", res)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("ShortestPath() on a dense graph did not finish")
+	}
+
+	res := mustCall(t, h.ShortestPath, `{"from": "n3", "to": "n12", "maxDepth": 6}`).(models.PathResult)
+	if !res.Found || res.Length != 1 {
+		t.Errorf("ShortestPath() = %+v", res)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := h.ShortestPathContext(ctx, args(t, `{"from": "n0", "to": "Loner"}`)); !errors.Is(err, context.Canceled) {
+		t.Errorf("ShortestPathContext() with a cancelled context error = %v", err)
+	}
+}
+
+func TestListRelations(t *testing.T) {
+	h := newTestHandler(t)
+	seedChain(t, h)
+
+	tests := []struct {
+		name  string
+		args  string
+		total int
+	}{
+		{"all", `{}`, 5},
+		{"entity both", `{"name": "Berlin"}`, 3},
+		{"entity out", `{"name": "Berlin", "direction": "out"}`, 1},
+		{"entity in", `{"name": "Berlin", "direction": "in"}`, 2},
+		{"by type", `{"relationTypes": ["knows", "lives_in"]}`, 2},
+		{"entity and type", `{"name": "Alice", "relationTypes": ["knows"]}`, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := mustCall(t, h.ListRelations, tt.args).(models.RelationsResult)
+			if res.Total != tt.total || len(res.Relations) != tt.total {
+				t.Errorf("ListRelations() = %+v, want %d", res, tt.total)
+			}
+		})
+	}
+
+	page := mustCall(t, h.ListRelations, `{"limit": 2, "offset": 4}`).(models.RelationsResult)
+	if page.Total != 5 || len(page.Relations) != 1 {
+		t.Errorf("page = %+v", page)
+	}
+}
@@ -0,0 +1,348 @@
+package models
+
+import "github.com/mlcmcp/memory-server/internal/ontology"
+
+// Entity is a node of the knowledge graph with the facts known about it.
+// ObservationDetails and UpdatedAt are only filled when provenance is requested.
+// Aliases are other names that refer to the entity.
+type Entity struct {
+	Name               string        `json:"name"`
+	EntityType         string        `json:"entityType"`
+	Observations       []string      `json:"observations"`
+	ObservationDetails []Observation `json:"observationDetails,omitempty"`
+	UpdatedAt          string        `json:"updatedAt,omitempty"`
+	Aliases            []string      `json:"aliases,omitempty"`
+}
+
+// Observation is a fact with its provenance. SupersededBy is the id of the
+// observation that corrected it; ExpiresAt is when the fact will be forgotten.
+type Observation struct {
+	ID           int64    `json:"id"`
+	Content      string   `json:"content"`
+	CreatedAt    string   `json:"createdAt,omitempty"`
+	Source       string   `json:"source,omitempty"`
+	Confidence   *float64 `json:"confidence,omitempty"`
+	SupersededBy *int64   `json:"supersededBy,omitempty"`
+	Pinned       bool     `json:"pinned,omitempty"`
+	ExpiresAt    string   `json:"expiresAt,omitempty"`
+}
+
+// Relation is a directed, typed edge between two entities.
+type Relation struct {
+	From         string `json:"from"`
+	To           string `json:"to"`
+	RelationType string `json:"relationType"`
+}
+
+// KnowledgeGraph is a set of entities and the relations between them.
+type KnowledgeGraph struct {
+	Entities  []Entity   `json:"entities"`
+	Relations []Relation `json:"relations"`
+}
+
+// Outcomes of the items of a batch write.
+const (
+	StatusCreated = "created"
+	StatusExists  = "exists"
+	StatusFailed  = "failed"
+)
+
+// ItemStatus is the outcome of one item of a batch write. Error is the reason
+// a failed item was not written; Warning reports a written item that does not
+// fit the ontology.
+type ItemStatus struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Warning string `json:"warning,omitempty"`
+}
+
+// Failed returns a failed status with the reason.
+func Failed(reason string) ItemStatus {
+	return ItemStatus{Status: StatusFailed, Error: reason}
+}
+
+// EntityStatus is the outcome of creating one entity. Similar lists existing
+// entities whose names nearly match the created one's and that may be the same
+// entity, to be merged if they are.
+type EntityStatus struct {
+	Name string `json:"name"`
+	ItemStatus
+	Similar []string `json:"similar,omitempty"`
+}
+
+// RelationStatus is the outcome of creating one relation.
+type RelationStatus struct {
+	Relation
+	ItemStatus
+}
+
+// ObservationStatus is the outcome of adding one observation.
+// DuplicateOf is the stored fact it was recognized as a near duplicate of.
+type ObservationStatus struct {
+	Content string `json:"content"`
+	ItemStatus
+	DuplicateOf string `json:"duplicateOf,omitempty"`
+}
+
+// CreateEntitiesResult lists the entities that did not exist before, and the
+// outcome of every requested entity in request order.
+type CreateEntitiesResult struct {
+	Entities []Entity       `json:"entities"`
+	Results  []EntityStatus `json:"results"`
+}
+
+// HasFailures reports whether an entity could not be created.
+func (r CreateEntitiesResult) HasFailures() bool {
+	for _, s := range r.Results {
+		if s.Status == StatusFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateRelationsResult lists the relations that did not exist before, and the
+// outcome of every requested relation in request order.
+type CreateRelationsResult struct {
+	Relations []Relation       `json:"relations"`
+	Results   []RelationStatus `json:"results"`
+}
+
+// HasFailures reports whether a relation could not be created.
+func (r CreateRelationsResult) HasFailures() bool {
+	for _, s := range r.Results {
+		if s.Status == StatusFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// ObservationsAdded lists the new observations of one entity, and the outcome
+// of every requested observation.
+type ObservationsAdded struct {
+	EntityName        string              `json:"entityName"`
+	AddedObservations []string            `json:"addedObservations"`
+	Statuses          []ObservationStatus `json:"statuses"`
+}
+
+// AddObservationsResult is the result of adding observations to several entities.
+type AddObservationsResult struct {
+	Results []ObservationsAdded `json:"results"`
+}
+
+// HasFailures reports whether an observation could not be added.
+func (r AddObservationsResult) HasFailures() bool {
+	for _, res := range r.Results {
+		for _, s := range res.Statuses {
+			if s.Status == StatusFailed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DeleteResult reports how many items were deleted.
+type DeleteResult struct {
+	Deleted int    `json:"deleted"`
+	Message string `json:"message"`
+}
+
+// RenameResult is the result of renaming an entity.
+type RenameResult struct {
+	OldName string `json:"oldName"`
+	NewName string `json:"newName"`
+}
+
+// MergeResult is the result of merging entities into a target entity.
+type MergeResult struct {
+	Entity Entity   `json:"entity"`
+	Merged []string `json:"merged"`
+}
+
+// CorrectionResult is the result of correcting an observation. Warning reports
+// values masked in the corrected fact.
+type CorrectionResult struct {
+	EntityName string      `json:"entityName"`
+	Superseded Observation `json:"superseded"`
+	Current    Observation `json:"current"`
+	Warning    string      `json:"warning,omitempty"`
+}
+
+// ExportResult holds an exported graph document.
+type ExportResult struct {
+	Format    string `json:"format"`
+	Content   string `json:"content"`
+	Entities  int    `json:"entities"`
+	Relations int    `json:"relations"`
+}
+
+// ImportResult counts what an import changed.
+type ImportResult struct {
+	Strategy          string `json:"strategy"`
+	EntitiesCreated   int    `json:"entitiesCreated"`
+	EntitiesUpdated   int    `json:"entitiesUpdated"`
+	EntitiesSkipped   int    `json:"entitiesSkipped"`
+	ObservationsAdded int    `json:"observationsAdded"`
+	RelationsCreated  int    `json:"relationsCreated"`
+	RelationsSkipped  int    `json:"relationsSkipped"`
+	// Issues lists the items that failed the ontology or were stored with a warning.
+	Issues []ImportIssue `json:"issues,omitempty"`
+}
+
+// ImportIssue is an imported entity or relation, as written in the document,
+// that failed or was stored with a warning.
+type ImportIssue struct {
+	Item string `json:"item"`
+	ItemStatus
+}
+
+// HasFailures reports whether an item was not imported.
+func (r ImportResult) HasFailures() bool {
+	for _, issue := range r.Issues {
+		if issue.Status == StatusFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// ConsolidatedEntity is the summary that replaced the observations of an entity.
+type ConsolidatedEntity struct {
+	EntityName string      `json:"entityName"`
+	Summary    Observation `json:"summary"`
+	Archived   int         `json:"archived"`
+	Groups     int         `json:"groups"`
+}
+
+// ConsolidationResult lists the consolidated entities. Skipped are entities with
+// too few observations and entities that changed while they were summarized.
+type ConsolidationResult struct {
+	Summarizer   string               `json:"summarizer"`
+	Consolidated []ConsolidatedEntity `json:"consolidated"`
+	Skipped      []string             `json:"skipped"`
+}
+
+// ForgottenObservation is an observation that was or would be forgotten. Namespace
+// and Reason are set by sweeps, which span namespaces and policies.
+type ForgottenObservation struct {
+	Namespace  string `json:"namespace,omitempty"`
+	EntityName string `json:"entityName"`
+	ID         int64  `json:"id"`
+	Content    string `json:"content"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// ForgetResult lists what was forgotten, or what would be on a dry run.
+type ForgetResult struct {
+	DryRun       bool                   `json:"dryRun"`
+	Entities     []string               `json:"entities"`
+	Observations []ForgottenObservation `json:"observations"`
+}
+
+// OntologyResult is the vocabulary of the graph. Configured is false if the
+// server accepts any type.
+type OntologyResult struct {
+	Configured    bool                             `json:"configured"`
+	Strict        bool                             `json:"strict"`
+	EntityTypes   map[string]ontology.EntityType   `json:"entityTypes"`
+	RelationTypes map[string]ontology.RelationType `json:"relationTypes"`
+}
+
+// SearchMatch is a ranked search hit for one entity.
+type SearchMatch struct {
+	EntityName string  `json:"entityName"`
+	Kind       string  `json:"kind"`
+	Snippet    string  `json:"snippet"`
+	Score      float64 `json:"score"`
+}
+
+// GraphPage is one page of a namespace. NextCursor continues after the page and
+// is empty on the last page.
+type GraphPage struct {
+	KnowledgeGraph
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// SearchResult holds the matched entities in ranking order. Total is the number
+// of matching entities before limit and offset are applied.
+type SearchResult struct {
+	KnowledgeGraph
+	Matches []SearchMatch `json:"matches"`
+	Total   int           `json:"total"`
+}
+
+// NeighborhoodResult is the part of the graph reachable from an entity. Hops maps
+// each entity to its distance from the center.
+type NeighborhoodResult struct {
+	KnowledgeGraph
+	Center string         `json:"center"`
+	Hops   map[string]int `json:"hops"`
+}
+
+// PathResult is a shortest path between two entities. Entities and relations
+// are listed in path order; relations keep their stored direction.
+type PathResult struct {
+	Found     bool       `json:"found"`
+	Length    int        `json:"length"`
+	Path      []string   `json:"path"`
+	Entities  []Entity   `json:"entities"`
+	Relations []Relation `json:"relations"`
+}
+
+// RelationsResult is a page of relations. Total counts all matching relations.
+type RelationsResult struct {
+	Relations []Relation `json:"relations"`
+	Total     int        `json:"total"`
+}
+
+// NamespaceInfo summarizes the memories stored in a namespace.
+type NamespaceInfo struct {
+	Name         string `json:"name"`
+	Entities     int    `json:"entities"`
+	Observations int    `json:"observations"`
+	Relations    int    `json:"relations"`
+}
+
+// NamespacesResult lists the namespaces and the one used by default.
+type NamespacesResult struct {
+	Namespaces []NamespaceInfo `json:"namespaces"`
+	Default    string          `json:"default"`
+}
+
+// Event is one change in the history of a namespace. Entity events carry the
+// entity's name and type, observation events the fact after the change, and
+// relation events the relation. Forgotten is set when the fact was erased from
+// the history.
+type Event struct {
+	ID            int64  `json:"id"`
+	At            string `json:"at"`
+	Kind          string `json:"kind"`
+	Action        string `json:"action"`
+	EntityName    string `json:"entityName,omitempty"`
+	EntityType    string `json:"entityType,omitempty"`
+	ObservationID int64  `json:"observationId,omitempty"`
+	Content       string `json:"content,omitempty"`
+	SupersededBy  *int64 `json:"supersededBy,omitempty"`
+	Forgotten     bool   `json:"forgotten,omitempty"`
+	From          string `json:"from,omitempty"`
+	To            string `json:"to,omitempty"`
+	RelationType  string `json:"relationType,omitempty"`
+}
+
+// ChangesResult is a page of the change feed. NextCursor continues after the
+// last event, also on the last page, so that later changes can be fetched.
+type ChangesResult struct {
+	Events     []Event `json:"events"`
+	NextCursor string  `json:"nextCursor"`
+	HasMore    bool    `json:"hasMore"`
+}
+
+// GraphSlice is the graph as it was at a time. Truncated is set when more
+// entities than the limit existed then.
+type GraphSlice struct {
+	At string `json:"at"`
+	KnowledgeGraph
+	Truncated bool `json:"truncated,omitempty"`
+}
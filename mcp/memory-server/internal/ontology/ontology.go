@@ -0,0 +1,229 @@
+// Package ontology declares the vocabulary of a knowledge graph: its entity
+// types and the relation types allowed between them.
+package ontology
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Unknown is the type of entities created without one, e.g. as the endpoint of
+// a relation. Such entities satisfy every domain and range.
+const Unknown = "unknown"
+
+// EntityType declares a type of entities.
+type EntityType struct {
+	Description string   `json:"description,omitempty" yaml:"description"`
+	Aliases     []string `json:"aliases,omitempty" yaml:"aliases"`
+}
+
+// RelationType declares a type of relations. Domain and Range list the entity
+// types allowed at the start and end; empty means any. Inverse names the same
+// relation read in the other direction ("employs" for "works_at"); writes using
+// it are stored in this direction.
+type RelationType struct {
+	Description string   `json:"description,omitempty" yaml:"description"`
+	Domain      []string `json:"domain,omitempty" yaml:"domain"`
+	Range       []string `json:"range,omitempty" yaml:"range"`
+	Inverse     string   `json:"inverse,omitempty" yaml:"inverse"`
+	Aliases     []string `json:"aliases,omitempty" yaml:"aliases"`
+}
+
+// Ontology is the vocabulary of a graph. In strict mode, writes that use unknown
+// types or violate a domain or range are rejected; otherwise they are stored with
+// a warning.
+type Ontology struct {
+	Strict        bool                    `json:"strict" yaml:"strict"`
+	EntityTypes   map[string]EntityType   `json:"entityTypes" yaml:"entityTypes"`
+	RelationTypes map[string]RelationType `json:"relationTypes" yaml:"relationTypes"`
+
+	// entityKeys and relationKeys map normalized names and aliases to the declared
+	// names; inverseKeys maps normalized inverse names to their relation type.
+	entityKeys   map[string]string
+	relationKeys map[string]string
+	inverseKeys  map[string]string
+}
+
+// Load reads an ontology from a YAML or JSON file, chosen by its extension.
+func Load(path string) (*Ontology, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var o *Ontology
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		o, err = ParseJSON(data)
+	case ".yaml", ".yml":
+		o, err = ParseYAML(data)
+	default:
+		return nil, fmt.Errorf("unknown ontology format %q (want .yaml, .yml or .json)", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return o, nil
+}
+
+// ParseYAML parses and validates an ontology in YAML.
+func ParseYAML(data []byte) (*Ontology, error) {
+	var o Ontology
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&o); err != nil {
+		return nil, fmt.Errorf("invalid ontology: %w", err)
+	}
+	return &o, o.init()
+}
+
+// ParseJSON parses and validates an ontology in JSON.
+func ParseJSON(data []byte) (*Ontology, error) {
+	var o Ontology
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&o); err != nil {
+		return nil, fmt.Errorf("invalid ontology: %w", err)
+	}
+	return &o, o.init()
+}
+
+// key folds case and separators, so that "works_at", "worksAt" and "Works At"
+// are the same type.
+func key(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// init validates the ontology and indexes its names.
+func (o *Ontology) init() error {
+	o.entityKeys = make(map[string]string)
+	o.relationKeys = make(map[string]string)
+	o.inverseKeys = make(map[string]string)
+	register := func(keys map[string]string, kind, name, owner string) error {
+		k := key(name)
+		if k == "" {
+			return fmt.Errorf("%s %q has no letters or digits", kind, name)
+		}
+		if prev, ok := keys[k]; ok && prev != owner {
+			return fmt.Errorf("%s %q of %q clashes with %q", kind, name, owner, prev)
+		}
+		keys[k] = owner
+		return nil
+	}
+
+	for _, name := range sortedKeys(o.EntityTypes) {
+		if err := register(o.entityKeys, "entity type", name, name); err != nil {
+			return err
+		}
+		for _, alias := range o.EntityTypes[name].Aliases {
+			if err := register(o.entityKeys, "alias", alias, name); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range sortedKeys(o.RelationTypes) {
+		if err := register(o.relationKeys, "relation type", name, name); err != nil {
+			return err
+		}
+		for _, alias := range o.RelationTypes[name].Aliases {
+			if err := register(o.relationKeys, "alias", alias, name); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range sortedKeys(o.RelationTypes) {
+		rt := o.RelationTypes[name]
+		if rt.Inverse != "" {
+			if _, ok := o.relationKeys[key(rt.Inverse)]; ok {
+				return fmt.Errorf("inverse %q of %q is also a relation type or alias", rt.Inverse, name)
+			}
+			if err := register(o.inverseKeys, "inverse", rt.Inverse, name); err != nil {
+				return err
+			}
+		}
+		for _, list := range [][]string{rt.Domain, rt.Range} {
+			for _, t := range list {
+				if _, ok := o.EntityTypes[t]; !ok {
+					return fmt.Errorf("relation type %q refers to undeclared entity type %q", name, t)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// EntityType returns the declared entity type a name or alias refers to. It
+// reports false for undeclared types, which are returned unchanged. The unknown
+// type is always accepted.
+func (o *Ontology) EntityType(name string) (string, bool) {
+	if name == Unknown {
+		return name, true
+	}
+	if t, ok := o.entityKeys[key(name)]; ok {
+		return t, true
+	}
+	return name, false
+}
+
+// RelationType returns the declared relation type a name, alias or inverse
+// refers to, and whether the relation has to be reversed because the name was an
+// inverse. It reports false for undeclared types, which are returned unchanged.
+func (o *Ontology) RelationType(name string) (string, bool, bool) {
+	k := key(name)
+	if t, ok := o.relationKeys[k]; ok {
+		return t, false, true
+	}
+	if t, ok := o.inverseKeys[k]; ok {
+		return t, true, true
+	}
+	return name, false, false
+}
+
+// CheckEndpoints reports a violation of the domain or range of a declared
+// relation type by the types of its endpoints.
+func (o *Ontology) CheckEndpoints(relType, fromType, toType string) error {
+	rt, ok := o.RelationTypes[relType]
+	if !ok {
+		return nil
+	}
+	if !allows(rt.Domain, fromType) {
+		return fmt.Errorf("%s must start at %s, not %s", relType, strings.Join(rt.Domain, " or "), fromType)
+	}
+	if !allows(rt.Range, toType) {
+		return fmt.Errorf("%s must end at %s, not %s", relType, strings.Join(rt.Range, " or "), toType)
+	}
+	return nil
+}
+
+func allows(types []string, t string) bool {
+	if len(types) == 0 || t == Unknown {
+		return true
+	}
+	for _, allowed := range types {
+		if allowed == t {
+			return true
+		}
+	}
+	return false
+}
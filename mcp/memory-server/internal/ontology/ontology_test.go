@@ -0,0 +1,139 @@
+package ontology
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testYAML = `
+strict: true
+entityTypes:
+  person:
+    aliases: [human]
+  organization:
+    description: A company or institution
+    aliases: [company]
+relationTypes:
+  works_at:
+    domain: [person]
+    range: [organization]
+    inverse: employs
+    aliases: [employed_by]
+  knows: {}
+`
+
+func TestParseYAML(t *testing.T) {
+	o, err := ParseYAML([]byte(testYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !o.Strict || o.EntityTypes["organization"].Description != "A company or institution" {
+		t.Errorf("ontology = %+v", o)
+	}
+
+	for name, want := range map[string]string{"Person": "person", "Human": "person", "company": "organization", Unknown: Unknown} {
+		if got, ok := o.EntityType(name); !ok || got != want {
+			t.Errorf("EntityType(%q) = %q, %v, want %q", name, got, ok, want)
+		}
+	}
+	if got, ok := o.EntityType("city"); ok || got != "city" {
+		t.Errorf("EntityType(city) = %q, %v", got, ok)
+	}
+
+	tests := []struct {
+		name    string
+		want    string
+		inverse bool
+		ok      bool
+	}{
+		{"works_at", "works_at", false, true},
+		{"worksAt", "works_at", false, true},
+		{"Works At", "works_at", false, true},
+		{"employed-by", "works_at", false, true},
+		{"employs", "works_at", true, true},
+		{"likes", "likes", false, false},
+	}
+	for _, tt := range tests {
+		got, inverse, ok := o.RelationType(tt.name)
+		if got != tt.want || inverse != tt.inverse || ok != tt.ok {
+			t.Errorf("RelationType(%q) = %q, %v, %v, want %q, %v, %v", tt.name, got, inverse, ok, tt.want, tt.inverse, tt.ok)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	o, err := ParseJSON([]byte(`{"entityTypes": {"person": {}}, "relationTypes": {"knows": {"domain": ["person"], "range": ["person"]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Strict {
+		t.Error("ontology should not be strict by default")
+	}
+	if _, err := ParseJSON([]byte(`{"entityTypes": {}, "relationTypez": {}}`)); err == nil {
+		t.Error("unknown field should be rejected")
+	}
+}
+
+func TestCheckEndpoints(t *testing.T) {
+	o, err := ParseYAML([]byte(testYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		from, to string
+		wantErr  string
+	}{
+		{"person", "organization", ""},
+		{Unknown, Unknown, ""},
+		{"organization", "organization", "works_at must start at person, not organization"},
+		{"person", "person", "works_at must end at organization, not person"},
+	}
+	for _, tt := range tests {
+		err := o.CheckEndpoints("works_at", tt.from, tt.to)
+		if (err == nil) != (tt.wantErr == "") || err != nil && err.Error() != tt.wantErr {
+			t.Errorf("CheckEndpoints(%s, %s) = %v, want %q", tt.from, tt.to, err, tt.wantErr)
+		}
+	}
+	if err := o.CheckEndpoints("knows", "organization", "person"); err != nil {
+		t.Errorf("CheckEndpoints(knows) = %v", err)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"clash":           "relationTypes: {works_at: {}, worksAt: {}}",
+		"alias clash":     "entityTypes: {person: {}, human: {aliases: [Person]}}",
+		"inverse is type": "relationTypes: {works_at: {inverse: employs}, employs: {}}",
+		"undeclared":      "relationTypes: {works_at: {domain: [person]}}",
+		"empty name":      "entityTypes: {'--': {}}",
+		"unknown field":   "relationTypes: {works_at: {domian: [person]}}",
+	}
+	for name, data := range tests {
+		if _, err := ParseYAML([]byte(data)); err == nil {
+			t.Errorf("%s: ParseYAML(%q) should fail", name, data)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ontology.yml")
+	if err := os.WriteFile(path, []byte(testYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err != nil {
+		t.Errorf("Load() = %v", err)
+	}
+	if _, err := Load(filepath.Join(dir, "ontology.toml")); err == nil {
+		t.Error("Load() of a missing file should fail")
+	}
+	toml := filepath.Join(dir, "ontology.toml")
+	if err := os.WriteFile(toml, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(toml); err == nil || !strings.Contains(err.Error(), "unknown ontology format") {
+		t.Errorf("Load(.toml) = %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package privacy
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Prefix marks encrypted text. Text without it is stored in plain.
+const Prefix = "enc:v1:"
+
+// KeySize is the size of encryption keys in bytes.
+const KeySize = 32
+
+// Cipher encrypts facts with AES-256-GCM. Encryption is deterministic: the nonce
+// is derived from the plaintext, so equal facts have equal ciphertexts and the
+// database can still compare them. This reveals which facts are equal, but
+// nothing else about them.
+type Cipher struct {
+	aead     cipher.AEAD
+	nonceKey []byte
+}
+
+// ParseKey decodes a key given in base64 or hex, e.g. generated with
+// "openssl rand -base64 32".
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	for _, decode := range []func(string) ([]byte, error){base64.StdEncoding.DecodeString, base64.RawStdEncoding.DecodeString, hex.DecodeString} {
+		if key, err := decode(s); err == nil && len(key) == KeySize {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("encryption key must be %d bytes, encoded in base64 or hex", KeySize)
+}
+
+// NewCipher returns a cipher for a key of KeySize bytes. Separate keys for
+// encryption and nonces are derived from it.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(derive(key, "memory-server encryption"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead, nonceKey: derive(key, "memory-server nonce")}, nil
+}
+
+func derive(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// Encrypted reports whether text was encrypted by a Cipher.
+func Encrypted(text string) bool {
+	return strings.HasPrefix(text, Prefix)
+}
+
+// Encrypt returns the encrypted form of plaintext.
+func (c *Cipher) Encrypt(plaintext string) string {
+	mac := hmac.New(sha256.New, c.nonceKey)
+	mac.Write([]byte(plaintext))
+	nonce := mac.Sum(nil)[:c.aead.NonceSize()]
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return Prefix + base64.RawStdEncoding.EncodeToString(sealed)
+}
+
+// ErrWrongKey is returned for text encrypted with a different key.
+var ErrWrongKey = errors.New("cannot decrypt: wrong encryption key or corrupted data")
+
+// Decrypt returns the plaintext of encrypted text. Text that is not encrypted is
+// returned unchanged.
+func (c *Cipher) Decrypt(text string) (string, error) {
+	if !Encrypted(text) {
+		return text, nil
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(text[len(Prefix):])
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", ErrWrongKey
+	}
+	n := c.aead.NonceSize()
+	plaintext, err := c.aead.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return "", ErrWrongKey
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,228 @@
+// Package privacy keeps personal data out of memory: it redacts sensitive values
+// from facts before they are stored and encrypts facts at rest.
+package privacy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kind is a kind of sensitive value.
+type Kind string
+
+// Kinds of sensitive values, in the order in which they are detected. A value
+// found by an earlier detector is not examined by later ones, so that e.g. the
+// digits of an IBAN are not also reported as a phone number.
+const (
+	APIKey Kind = "apikey"
+	IBAN   Kind = "iban"
+	Email  Kind = "email"
+	Phone  Kind = "phone"
+)
+
+// Kinds lists the detected kinds.
+var Kinds = []Kind{APIKey, IBAN, Email, Phone}
+
+// descriptions name the kinds in messages.
+var descriptions = map[Kind]string{
+	APIKey: "an API key",
+	IBAN:   "an IBAN",
+	Email:  "an email address",
+	Phone:  "a phone number",
+}
+
+// Action is what happens to a fact that contains a sensitive value.
+type Action string
+
+// Actions of a policy. Allow stores the value as is, Mask replaces it with a
+// placeholder such as "[redacted email]" and Reject refuses the fact.
+const (
+	Allow  Action = "allow"
+	Mask   Action = "mask"
+	Reject Action = "reject"
+)
+
+var (
+	apiKeyPattern = regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_-]{20,}|gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,}|glpat-[A-Za-z0-9_-]{20,}|xox[abposr]-[A-Za-z0-9-]{10,}|AKIA[0-9A-Z]{16}|AIza[0-9A-Za-z_-]{35})`)
+	ibanPattern   = regexp.MustCompile(`\b[A-Z]{2}[0-9]{2}(?: ?[A-Z0-9]){11,30}\b`)
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	// Phone numbers in international form, national form with a leading 0 or
+	// the North American 555-123-4567 form; dates and versions do not match.
+	phonePattern = regexp.MustCompile(`(?:\+|\b00)[1-9][0-9 ()./-]{5,20}[0-9]|\(?\b0[0-9]{2,5}\)?[ /-]?[0-9][0-9 -]{3,12}[0-9]\b|\b[0-9]{3}[-. ][0-9]{3}[-. ][0-9]{4}\b`)
+)
+
+// Finding is a sensitive value at text[Start:End].
+type Finding struct {
+	Kind       Kind
+	Start, End int
+}
+
+// Detect returns the sensitive values in text, in order of their position.
+func Detect(text string) []Finding {
+	var found []Finding
+	overlaps := func(start, end int) bool {
+		for _, f := range found {
+			if start < f.End && f.Start < end {
+				return true
+			}
+		}
+		return false
+	}
+	add := func(kind Kind, pattern *regexp.Regexp, valid func(string) (int, bool)) {
+		for _, m := range pattern.FindAllStringIndex(text, -1) {
+			end, ok := valid(text[m[0]:m[1]])
+			if ok && !overlaps(m[0], m[0]+end) {
+				found = append(found, Finding{Kind: kind, Start: m[0], End: m[0] + end})
+			}
+		}
+	}
+	whole := func(s string) (int, bool) { return len(s), true }
+	add(APIKey, apiKeyPattern, whole)
+	add(IBAN, ibanPattern, validIBAN)
+	add(Email, emailPattern, whole)
+	add(Phone, phonePattern, validPhone)
+
+	sort.Slice(found, func(i, j int) bool { return found[i].Start < found[j].Start })
+	return found
+}
+
+// validIBAN returns the length of the longest prefix of s that is an IBAN with a
+// valid checksum; the pattern may have taken in a word that follows it.
+func validIBAN(s string) (int, bool) {
+	for end := len(s); end >= 15; end-- {
+		if s[end-1] == ' ' {
+			continue
+		}
+		if ibanChecksum(strings.ReplaceAll(s[:end], " ", "")) {
+			return end, true
+		}
+	}
+	return 0, false
+}
+
+// ibanChecksum reports whether an IBAN without spaces passes the ISO 7064
+// mod 97 check.
+func ibanChecksum(iban string) bool {
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	rearranged := iban[4:] + iban[:4]
+	rest := 0
+	for _, r := range rearranged {
+		switch {
+		case r >= '0' && r <= '9':
+			rest = (rest*10 + int(r-'0')) % 97
+		case r >= 'A' && r <= 'Z':
+			rest = (rest*100 + int(r-'A'+10)) % 97
+		default:
+			return false
+		}
+	}
+	return rest == 1
+}
+
+// validPhone accepts numbers with 7 to 15 digits, the range of ITU E.164.
+func validPhone(s string) (int, bool) {
+	digits := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return len(s), digits >= 7 && digits <= 15
+}
+
+// Policy assigns an action to kinds of sensitive values. Kinds without an
+// action are allowed. The zero policy allows everything.
+type Policy map[Kind]Action
+
+// ParsePolicy parses a policy of the form "email=mask,phone=mask,iban=reject".
+// The kind "all" sets the action of every kind not listed explicitly.
+func ParsePolicy(s string) (Policy, error) {
+	p := make(Policy)
+	var all Action
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		kind, action, ok := strings.Cut(part, "=")
+		kind, action = strings.TrimSpace(kind), strings.TrimSpace(action)
+		if !ok {
+			return nil, fmt.Errorf("invalid redaction policy %q (want kind=action)", part)
+		}
+		a := Action(action)
+		if a != Allow && a != Mask && a != Reject {
+			return nil, fmt.Errorf("invalid redaction action %q for %s (want allow, mask or reject)", action, kind)
+		}
+		if kind == "all" {
+			all = a
+			continue
+		}
+		if _, ok := descriptions[Kind(kind)]; !ok {
+			return nil, fmt.Errorf("unknown kind of sensitive data %q (want apikey, iban, email, phone or all)", kind)
+		}
+		p[Kind(kind)] = a
+	}
+	if all != "" {
+		for _, k := range Kinds {
+			if _, ok := p[k]; !ok {
+				p[k] = all
+			}
+		}
+	}
+	return p, nil
+}
+
+// RejectedError reports sensitive values that the policy rejects.
+type RejectedError struct {
+	Kinds []Kind
+}
+
+func (e *RejectedError) Error() string {
+	names := make([]string, len(e.Kinds))
+	for i, k := range e.Kinds {
+		names[i] = descriptions[k]
+	}
+	return "contains " + strings.Join(names, " and ") + ", which may not be stored"
+}
+
+// Apply redacts text. It returns the text with masked values replaced and the
+// kinds that were masked, or a *RejectedError if it contains a rejected kind.
+func (p Policy) Apply(text string) (string, []Kind, error) {
+	if len(p) == 0 {
+		return text, nil, nil
+	}
+	var masked, rejected []Kind
+	var b strings.Builder
+	last := 0
+	for _, f := range Detect(text) {
+		switch p[f.Kind] {
+		case Reject:
+			rejected = appendKind(rejected, f.Kind)
+		case Mask:
+			masked = appendKind(masked, f.Kind)
+			b.WriteString(text[last:f.Start])
+			b.WriteString("[redacted " + string(f.Kind) + "]")
+			last = f.End
+		}
+	}
+	if len(rejected) > 0 {
+		return "", nil, &RejectedError{Kinds: rejected}
+	}
+	if len(masked) == 0 {
+		return text, nil, nil
+	}
+	b.WriteString(text[last:])
+	return b.String(), masked, nil
+}
+
+func appendKind(kinds []Kind, k Kind) []Kind {
+	for _, existing := range kinds {
+		if existing == k {
+			return kinds
+		}
+	}
+	return append(kinds, k)
+}
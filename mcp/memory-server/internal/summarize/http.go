@@ -0,0 +1,114 @@
+package summarize
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPSummarizer asks a model behind an OpenAI-compatible chat completions
+// endpoint, such as the ones served by Ollama, llama.cpp, LM Studio or vLLM.
+type HTTPSummarizer struct {
+	url    string
+	model  string
+	apiKey string
+	client *http.Client
+}
+
+// NewHTTPSummarizer creates a summarizer for a base URL (e.g. http://localhost:11434/v1).
+// The /chat/completions path is appended unless the URL already ends with it.
+func NewHTTPSummarizer(baseURL, model, apiKey string) *HTTPSummarizer {
+	url := strings.TrimRight(baseURL, "/")
+	if !strings.HasSuffix(url, "/chat/completions") {
+		url += "/chat/completions"
+	}
+	return &HTTPSummarizer{
+		url:    url,
+		model:  model,
+		apiKey: apiKey,
+		client: &http.Client{Timeout: 120 * time.Second},
+	}
+}
+
+func (s *HTTPSummarizer) Name() string {
+	return "http:" + s.model
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+const systemPrompt = "You condense notes of a knowledge graph. Rewrite the facts you are given into one compact summary " +
+	"in the language of the facts. Keep every distinct fact, names and numbers, drop repetitions and do not add anything. " +
+	"Answer with the summary only."
+
+// prompt lists the facts, one group of related facts per paragraph.
+func prompt(entity string, groups [][]string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Facts about %s:\n", entity)
+	for _, g := range groups {
+		b.WriteString("\n")
+		for _, o := range g {
+			fmt.Fprintf(&b, "- %s\n", o)
+		}
+	}
+	return b.String()
+}
+
+func (s *HTTPSummarizer) Summarize(ctx context.Context, entity string, groups [][]string) (string, error) {
+	body, err := json.Marshal(chatRequest{
+		Model: s.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: prompt(entity, groups)},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("summary request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("summary request failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var res chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return "", fmt.Errorf("invalid summary response: %w", err)
+	}
+	if len(res.Choices) == 0 || strings.TrimSpace(res.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("invalid summary response: no summary")
+	}
+	return strings.TrimSpace(res.Choices[0].Message.Content), nil
+}
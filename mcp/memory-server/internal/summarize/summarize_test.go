@@ -0,0 +1,92 @@
+package summarize
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	got := Group([]string{
+		"likes green tea",
+		"lives in Bonn",
+		"likes black tea",
+		"works in Bonn",
+		"plays chess",
+	})
+	want := [][]string{
+		{"likes green tea", "likes black tea"},
+		{"lives in Bonn", "works in Bonn"},
+		{"plays chess"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Group() = %q, want %q", got, want)
+	}
+}
+
+func TestExtractive(t *testing.T) {
+	got, err := Extractive{}.Summarize(context.Background(), "Oly", [][]string{
+		{"likes tea.", "Likes coffee", "likes tea"},
+		{"lives in Bonn", "moved to Bonn in 2019", "lives in Bonn since 2019"},
+		{" plays chess "},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "likes tea, coffee; lives in Bonn since 2019; plays chess"; got != want {
+		t.Errorf("Summarize() = %q, want %q", got, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if s, err := New("", "", "", ""); err != nil || s.Name() != "extractive" {
+		t.Errorf("New(\"\") = %v, %v", s, err)
+	}
+	if _, err := New("http", "", "llama3", ""); err == nil {
+		t.Error("New(http) without a URL should fail")
+	}
+	if _, err := New("magic", "", "", ""); err == nil {
+		t.Error("New(magic) should fail")
+	}
+}
+
+func TestHTTPSummarizer(t *testing.T) {
+	var gotAuth string
+	var gotReq chatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		gotAuth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&gotReq)
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": " Likes tea and coffee. "}}]}`))
+	}))
+	defer server.Close()
+
+	s := NewHTTPSummarizer(server.URL+"/v1/", "llama3", "secret")
+	got, err := s.Summarize(context.Background(), "Oly", [][]string{{"likes tea", "likes coffee"}})
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if got != "Likes tea and coffee." || s.Name() != "http:llama3" {
+		t.Errorf("Summarize() = %q, Name() = %q", got, s.Name())
+	}
+	if gotAuth != "Bearer secret" || gotReq.Model != "llama3" || len(gotReq.Messages) != 2 ||
+		!strings.Contains(gotReq.Messages[1].Content, "- likes coffee") {
+		t.Errorf("request = %+v, auth %q", gotReq, gotAuth)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not found", http.StatusNotFound)
+	}))
+	defer failing.Close()
+	if _, err := NewHTTPSummarizer(failing.URL, "llama3", "").Summarize(context.Background(), "Oly", nil); err == nil ||
+		!strings.Contains(err.Error(), "model not found") {
+		t.Errorf("Summarize() error = %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+// Package summarize condenses the observations of an entity into a summary.
+package summarize
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Summarizer turns groups of related observations about an entity into one
+// compact summary.
+type Summarizer interface {
+	// Name identifies the summarizer; it is recorded as the source of summaries.
+	Name() string
+	Summarize(ctx context.Context, entity string, groups [][]string) (string, error)
+}
+
+// New returns the summarizer for a kind: "extractive" (offline, deterministic) or
+// "http" (an OpenAI-compatible /chat/completions endpoint, e.g. a local LLM).
+func New(kind, url, model, apiKey string) (Summarizer, error) {
+	switch kind {
+	case "", "extractive":
+		return Extractive{}, nil
+	case "http":
+		if url == "" || model == "" {
+			return nil, fmt.Errorf("http summarizer needs an endpoint URL and a model")
+		}
+		return NewHTTPSummarizer(url, model, apiKey), nil
+	default:
+		return nil, fmt.Errorf("unknown summarizer %q (want extractive or http)", kind)
+	}
+}
+
+// relatedThreshold is the word overlap (Jaccard index) above which two
+// observations are about the same thing.
+const relatedThreshold = 0.3
+
+// Group puts related observations together, keeping the order of first
+// appearance. Observations are related if they share enough significant words,
+// directly or through other observations.
+func Group(observations []string) [][]string {
+	words := make([]map[string]bool, len(observations))
+	for i, o := range observations {
+		words[i] = significantWords(o)
+	}
+
+	parent := make([]int, len(observations))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range observations {
+		for j := i + 1; j < len(observations); j++ {
+			if jaccard(words[i], words[j]) >= relatedThreshold {
+				// The earlier observation stays the root, so groups keep their order.
+				if ri, rj := find(i), find(j); ri != rj {
+					parent[max(ri, rj)] = min(ri, rj)
+				}
+			}
+		}
+	}
+
+	var groups [][]string
+	index := make(map[int]int)
+	for i, o := range observations {
+		root := find(i)
+		g, ok := index[root]
+		if !ok {
+			g = len(groups)
+			index[root] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], o)
+	}
+	return groups
+}
+
+// stopWords carry no meaning of their own.
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "from": true, "that": true,
+	"this": true, "has": true, "have": true, "was": true, "are": true, "but": true,
+	"not": true, "der": true, "die": true, "das": true, "und": true, "ist": true,
+	"mit": true, "von": true, "ein": true, "eine": true,
+}
+
+// significantWords returns the lower-cased words of at least three letters that
+// are not stop words.
+func significantWords(s string) map[string]bool {
+	out := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(w)) >= 3 && !stopWords[w] {
+			out[w] = true
+		}
+	}
+	return out
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// Extractive summarizes without a model: observations of a group that start with
+// the same words are joined ("likes tea, coffee"), other groups are represented
+// by their most central observation. Groups are separated by semicolons.
+type Extractive struct{}
+
+func (Extractive) Name() string {
+	return "extractive"
+}
+
+func (Extractive) Summarize(_ context.Context, _ string, groups [][]string) (string, error) {
+	parts := make([]string, 0, len(groups))
+	for _, g := range groups {
+		parts = append(parts, summarizeGroup(g))
+	}
+	return strings.Join(parts, "; "), nil
+}
+
+func summarizeGroup(group []string) string {
+	if len(group) == 1 {
+		return strings.TrimSpace(group[0])
+	}
+	if joined, ok := joinCommonPrefix(group); ok {
+		return joined
+	}
+	return central(group)
+}
+
+// joinCommonPrefix joins observations that share their leading words and differ
+// in the rest: "likes tea", "likes coffee" becomes "likes tea, coffee".
+func joinCommonPrefix(group []string) (string, bool) {
+	fields := make([][]string, len(group))
+	for i, o := range group {
+		fields[i] = strings.Fields(strings.TrimRight(strings.TrimSpace(o), ".!;"))
+	}
+	n := 0
+	for n < len(fields[0]) && sameWordAt(fields, n) {
+		n++
+	}
+	if n == 0 {
+		return "", false
+	}
+
+	var rests []string
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		rest := strings.Join(f[n:], " ")
+		if rest == "" {
+			return "", false
+		}
+		if key := strings.ToLower(rest); !seen[key] {
+			seen[key] = true
+			rests = append(rests, rest)
+		}
+	}
+	return strings.Join(fields[0][:n], " ") + " " + strings.Join(rests, ", "), true
+}
+
+// sameWordAt reports whether all word lists have the same word at position n.
+func sameWordAt(fields [][]string, n int) bool {
+	for _, f := range fields[1:] {
+		if n >= len(f) || !strings.EqualFold(f[n], fields[0][n]) {
+			return false
+		}
+	}
+	return true
+}
+
+// central returns the observation that shares the most words with the others,
+// the earliest on ties.
+func central(group []string) string {
+	words := make([]map[string]bool, len(group))
+	for i, o := range group {
+		words[i] = significantWords(o)
+	}
+	best, bestScore := 0, -1.0
+	for i := range group {
+		score := 0.0
+		for j := range group {
+			if i != j {
+				score += jaccard(words[i], words[j])
+			}
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return strings.TrimSpace(group[best])
+}
@@ -7,119 +7,166 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/mlcmcp/memory-server/internal/embedding"
 	"github.com/mlcmcp/memory-server/internal/handlers"
+	"github.com/mlcmcp/memory-server/internal/ontology"
+	"github.com/mlcmcp/memory-server/internal/privacy"
+	"github.com/mlcmcp/memory-server/internal/summarize"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+				if err == flag.ErrHelp {
+					return
+				}
+				log.Fatalf("%s: %v", os.Args[1], err)
+			}
+			return
+		}
+	}
+
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: memory-server [options]\n")
+		fmt.Fprintf(os.Stderr, "       memory-server export [options]\n")
+		fmt.Fprintf(os.Stderr, "       memory-server import [options] <file|->\n")
+		fmt.Fprintf(os.Stderr, "       memory-server sweep [options]\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
 	}
 
 	dump := flag.Bool("dump", false, "Dump tool definitions as JSON and exit")
+	dbFlag := flag.String("db", "", "Path to the SQLite database (default $MEMORY_DB or ~/.local/share/mcp-proxy/memory.db)")
+	namespace := flag.String("namespace", "", "Default memory namespace (default $MEMORY_NAMESPACE or \"default\")")
+	embedderKind := flag.String("embedder", "hash", "Embedder for semantic search: hash (offline), http or none")
+	embedURL := flag.String("embed-url", "", "Base URL of an OpenAI-compatible embeddings API (e.g. http://localhost:11434/v1)")
+	embedModel := flag.String("embed-model", "", "Embedding model name for -embedder http")
+	summarizerKind := flag.String("summarizer", "extractive", "Summarizer for consolidation: extractive (offline) or http")
+	summarizeURL := flag.String("summarize-url", "", "Base URL of an OpenAI-compatible chat completions API (e.g. http://localhost:11434/v1)")
+	summarizeModel := flag.String("summarize-model", "", "Chat model name for -summarizer http")
+	consolidateEvery := flag.Duration("consolidate-every", 0, "Consolidate entities with many observations at this interval (e.g. 24h; 0 disables)")
+	retention := flag.String("retention", "", "Maximum age of observations per entity type, e.g. event=30d,session=24h")
+	sweepEvery := flag.Duration("sweep-every", 10*time.Minute, "Forget expired observations and enforce -retention at this interval (0 disables)")
+	transport := flag.String("transport", "stdio", "Transport: stdio, or streamable for streamable HTTP (bearer token in $"+envAuthToken+")")
+	addr := flag.String("addr", "localhost:3000", "Address to listen on for -transport streamable")
+	ontologyPath := flag.String("ontology", "", "YAML or JSON file declaring the entity and relation types")
+	redact := flag.String("redact", "", "What to do with sensitive data in new observations, e.g. email=mask,phone=mask,iban=reject,apikey=reject or all=mask")
+	keyFile := flag.String("encryption-key-file", "", "File with the key for encrypting observations (default $"+envEncryptionKeyFile+", or the key in $"+envEncryptionKey+")")
+	auditPath := flag.String("audit-log", "", "Append a JSON line for every read and write to this file (- for stderr)")
+	migrateOnly := flag.Bool("migrate-only", false, "Upgrade the database schema and exit")
 	flag.Parse()
 
-	home, _ := os.UserHomeDir()
-	dbDir := filepath.Join(home, ".local", "share", "mcp-proxy")
-	os.MkdirAll(dbDir, 0755)
-	dbPath := filepath.Join(dbDir, "memory.db")
+	if *dump {
+		json.NewEncoder(os.Stdout).Encode(toolDefinitions(memoryTools(nil)))
+		return
+	}
+
+	if *transport != "stdio" && *transport != "streamable" {
+		log.Fatalf("Invalid -transport %q: must be stdio or streamable", *transport)
+	}
+
+	dbPath, err := resolveDBPath(*dbFlag)
+	if err != nil {
+		log.Fatalf("Failed to locate the database: %v", err)
+	}
+
+	if *migrateOnly {
+		from, to, err := handlers.Migrate(dbPath)
+		if err != nil {
+			log.Fatalf("Failed to migrate %s: %v", dbPath, err)
+		}
+		if from == to {
+			log.Printf("%s is up to date at schema version %d", dbPath, to)
+		} else {
+			log.Printf("Migrated %s from schema version %d to %d", dbPath, from, to)
+		}
+		return
+	}
 
 	handler, err := handlers.NewMemoryHandler(dbPath)
 	if err != nil {
-		log.Fatalf("Failed to initialize SQLite: %v", err)
+		log.Fatalf("Failed to initialize SQLite at %s: %v", dbPath, err)
+	}
+	handler.SetDefaultNamespace(resolveNamespace(*namespace))
+
+	encrypted, err := setupEncryption(handler, *keyFile)
+	if err != nil {
+		log.Fatalf("Failed to set up encryption: %v", err)
 	}
+	if encrypted > 0 {
+		log.Printf("Encrypted %d existing observations", encrypted)
+	}
+	policy, err := privacy.ParsePolicy(*redact)
+	if err != nil {
+		log.Fatalf("Invalid -redact: %v", err)
+	}
+	handler.SetRedaction(policy)
+
+	embedder, err := embedding.New(*embedderKind, *embedURL, *embedModel, os.Getenv("MEMORY_EMBED_API_KEY"))
+	if err != nil {
+		log.Fatalf("Failed to configure embedder: %v", err)
+	}
+	handler.SetEmbedder(embedder)
+
+	if *ontologyPath != "" {
+		o, err := ontology.Load(*ontologyPath)
+		if err != nil {
+			log.Fatalf("Failed to load ontology: %v", err)
+		}
+		handler.SetOntology(o)
+	}
+
+	summarizer, err := summarize.New(*summarizerKind, *summarizeURL, *summarizeModel, os.Getenv("MEMORY_SUMMARIZE_API_KEY"))
+	if err != nil {
+		log.Fatalf("Failed to configure summarizer: %v", err)
+	}
+	handler.SetSummarizer(summarizer)
+	policies, err := handlers.ParseRetention(*retention)
+	if err != nil {
+		log.Fatalf("Invalid -retention: %v", err)
+	}
+	handler.SetRetention(policies)
 
 	server := mcp.NewServer(&mcp.Implementation{
 		Name:    "memory-server",
-		Version: "1.1.2",
+		Version: "1.2.0",
 	}, nil)
 
-	// FLAT TOOLS - Maximum compatibility
-	tools := []mcp.Tool{
-		{
-			Name:        "memory__memorize__mlc",
-			Description: "Store a new fact or observation about an entity",
-			InputSchema: map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"entity":      map[string]interface{}{"type": "string", "description": "The name of the thing (e.g. 'Oly' or 'Project')"},
-					"category":    map[string]interface{}{"type": "string", "description": "Category (e.g. 'Person', 'Setting')"},
-					"observation": map[string]interface{}{"type": "string", "description": "The actual fact to remember"},
-				},
-				"required":             []string{"entity", "observation"},
-			},
-		},
-		{
-			Name:        "memory__search_nodes__mlc",
-			Description: "Search for stored facts in the knowledge graph",
-			InputSchema: map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"query": map[string]interface{}{"type": "string", "description": "The search term"},
-				},
-				"required":             []string{"query"},
-			},
-		},
-		{
-			Name:        "memory__read_graph__mlc",
-			Description: "Read all stored memories",
-			InputSchema: map[string]interface{}{
-				"type":                 "object",
-				"properties":           map[string]interface{}{},
-			},
-		},
-	}
-
-	textResult := func(text string) *mcp.CallToolResult {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				&mcp.TextContent{Text: text},
-			},
+	tools := memoryTools(handler)
+	registerTools(server, tools)
+	registerResources(server, handler)
+	if *auditPath != "" {
+		w, err := openAuditLog(*auditPath)
+		if err != nil {
+			log.Fatalf("Failed to open the audit log: %v", err)
 		}
+		defer w.Close()
+		audit := newAuditLog(w, resolveNamespace(*namespace), tools)
+		server.AddReceivingMiddleware(audit.middleware)
+		handler.SetAuditHook(audit.hook(originScheduled))
+	}
+	if *sweepEvery > 0 {
+		go runSweeper(context.Background(), handler, *sweepEvery)
+	}
+	if *consolidateEvery > 0 {
+		go runConsolidation(context.Background(), handler, *consolidateEvery)
 	}
 
-	// Simplified Handlers
-	mcp.AddTool(server, &tools[0], func(ctx context.Context, req *mcp.CallToolRequest, args map[string]interface{}) (*mcp.CallToolResult, any, error) {
-		entity, _ := args["entity"].(string)
-		entType, _ := args["category"].(string)
-		obs, _ := args["observation"].(string)
-
-		hArgs := map[string]interface{}{
-			"entities": []interface{}{
-				map[string]interface{}{
-					"name": entity,
-					"entityType": entType,
-					"observations": []interface{}{obs},
-				},
-			},
+	if *transport == "streamable" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := serveHTTP(ctx, server, *addr, os.Getenv(envAuthToken)); err != nil {
+			log.Fatalf("Server failed: %v", err)
 		}
-		res, err := handler.CreateEntities(hArgs)
-		if err != nil { return nil, nil, err }
-		return textResult(res.(string)), nil, nil
-	})
-
-	mcp.AddTool(server, &tools[1], func(ctx context.Context, req *mcp.CallToolRequest, args map[string]interface{}) (*mcp.CallToolResult, any, error) {
-		res, err := handler.SearchNodes(args)
-		if err != nil { return nil, nil, err }
-		return textResult(res.(string)), nil, nil
-	})
-
-	mcp.AddTool(server, &tools[2], func(ctx context.Context, req *mcp.CallToolRequest, args map[string]interface{}) (*mcp.CallToolResult, any, error) {
-		res, err := handler.ReadGraph(args)
-		if err != nil { return nil, nil, err }
-		return textResult(res.(string)), nil, nil
-	})
-
-	if *dump {
-		json.NewEncoder(os.Stdout).Encode(tools)
 		return
 	}
-
 	if err := server.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
 		log.Fatalf("Server failed: %v", err)
 	}
@@ -1,7 +1,632 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mlcmcp/memory-server/internal/handlers"
+	"github.com/mlcmcp/memory-server/internal/models"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
 
 func TestMainCompile(t *testing.T) {
 	// This test just ensures the package compiles and the test runner finds it.
 }
+
+// newTestSession connects a client to a server on a fresh database. configure
+// can add to the server before the client connects.
+func newTestSession(t *testing.T, configure ...func(*mcp.Server, []memoryTool)) *mcp.ClientSession {
+	t.Helper()
+	handler, err := handlers.NewMemoryHandler(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatalf("NewMemoryHandler() error = %v", err)
+	}
+	t.Cleanup(func() { handler.Close() })
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "memory-server", Version: "test"}, nil)
+	tools := memoryTools(handler)
+	registerTools(server, tools)
+	registerResources(server, handler)
+	for _, c := range configure {
+		c(server, tools)
+	}
+
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := server.Connect(ctx, serverTransport, nil); err != nil {
+		t.Fatal(err)
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "test"}, nil)
+	session, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+func TestTools_ReferenceSurface(t *testing.T) {
+	session := newTestSession(t)
+
+	res, err := session.ListTools(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("ListTools() error = %v", err)
+	}
+	names := make(map[string]bool)
+	for _, tool := range res.Tools {
+		names[tool.Name] = true
+		if tool.OutputSchema == nil {
+			t.Errorf("tool %s has no output schema", tool.Name)
+		}
+		props, _ := tool.InputSchema.(map[string]any)["properties"].(map[string]any)
+		if _, ok := props["namespace"]; !ok && tool.Name != "memory__list_namespaces__mlc" && tool.Name != "memory__get_ontology__mlc" {
+			t.Errorf("tool %s has no namespace argument", tool.Name)
+		}
+	}
+	for _, name := range []string{
+		"create_entities", "create_relations", "add_observations",
+		"delete_entities", "delete_observations", "delete_relations",
+		"read_graph", "search_nodes", "open_nodes",
+		"memorize", "rename_entity", "merge_entities", "semantic_search",
+		"neighborhood", "shortest_path", "list_relations", "list_namespaces",
+		"correct_observation", "export", "import", "consolidate", "forget", "get_ontology",
+		"changes", "graph_at",
+	} {
+		if !names["memory__"+name+"__mlc"] {
+			t.Errorf("tool memory__%s__mlc is not registered", name)
+		}
+	}
+}
+
+func TestTools_StructuredContent(t *testing.T) {
+	session := newTestSession(t)
+	ctx := context.Background()
+
+	call := func(name string, args map[string]any) *mcp.CallToolResult {
+		t.Helper()
+		res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: name, Arguments: args})
+		if err != nil {
+			t.Fatalf("CallTool(%s) error = %v", name, err)
+		}
+		return res
+	}
+
+	call("memory__memorize__mlc", map[string]any{"entity": "Alice", "category": "person", "observation": "likes Go"})
+	res := call("memory__read_graph__mlc", map[string]any{})
+	if res.IsError {
+		t.Fatalf("read_graph returned an error: %+v", res.Content)
+	}
+
+	var graph struct {
+		Entities []struct {
+			Name         string   `json:"name"`
+			Observations []string `json:"observations"`
+		} `json:"entities"`
+	}
+	data, _ := json.Marshal(res.StructuredContent)
+	if err := json.Unmarshal(data, &graph); err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.Entities) != 1 || graph.Entities[0].Name != "Alice" || graph.Entities[0].Observations[0] != "likes Go" {
+		t.Errorf("structured content = %s", data)
+	}
+
+	res = call("memory__rename_entity__mlc", map[string]any{"oldName": "Missing", "newName": "Other"})
+	if !res.IsError {
+		t.Error("rename of a missing entity should be a tool error")
+	}
+
+	// A batch with a failed item is a tool error that still reports every item.
+	res = call("memory__create_entities__mlc", map[string]any{"entities": []any{
+		map[string]any{"name": "Bob", "entityType": "person", "observations": []string{}},
+		map[string]any{"name": "", "entityType": "person", "observations": []string{}},
+	}})
+	var created struct {
+		Results []struct {
+			Name   string `json:"name"`
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		} `json:"results"`
+	}
+	data, _ = json.Marshal(res.StructuredContent)
+	if err := json.Unmarshal(data, &created); err != nil {
+		t.Fatal(err)
+	}
+	if !res.IsError || len(created.Results) != 2 || created.Results[0].Status != "created" || created.Results[1].Error == "" {
+		t.Errorf("create_entities with a failed item = %v, %s", res.IsError, data)
+	}
+}
+
+func TestTools_ProvenanceDefaultsToClient(t *testing.T) {
+	session := newTestSession(t)
+	ctx := context.Background()
+
+	call := func(name string, args map[string]any) []byte {
+		t.Helper()
+		res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: name, Arguments: args})
+		if err != nil || res.IsError {
+			t.Fatalf("CallTool(%s) = %+v, %v", name, res, err)
+		}
+		data, _ := json.Marshal(res.StructuredContent)
+		return data
+	}
+
+	call("memory__memorize__mlc", map[string]any{"entity": "Alice", "observation": "lives in Bonn"})
+	call("memory__correct_observation__mlc", map[string]any{
+		"entityName": "Alice", "oldObservation": "lives in Bonn", "newObservation": "lives in Köln", "source": "import", "confidence": 0.9,
+	})
+	data := call("memory__open_nodes__mlc", map[string]any{"names": []string{"Alice"}, "includeSuperseded": true, "provenance": true})
+
+	var graph struct {
+		Entities []struct {
+			ObservationDetails []struct {
+				Content      string   `json:"content"`
+				Source       string   `json:"source"`
+				Confidence   *float64 `json:"confidence"`
+				SupersededBy *int64   `json:"supersededBy"`
+			} `json:"observationDetails"`
+		} `json:"entities"`
+	}
+	if err := json.Unmarshal(data, &graph); err != nil {
+		t.Fatal(err)
+	}
+	details := graph.Entities[0].ObservationDetails
+	if len(details) != 2 || details[0].Source != "client" || details[0].SupersededBy == nil ||
+		details[1].Source != "import" || details[1].Confidence == nil || *details[1].Confidence != 0.9 {
+		t.Errorf("open_nodes = %s", data)
+	}
+}
+
+func TestTools_History(t *testing.T) {
+	session := newTestSession(t)
+	ctx := context.Background()
+
+	call := func(name string, args map[string]any) []byte {
+		t.Helper()
+		res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: name, Arguments: args})
+		if err != nil || res.IsError {
+			t.Fatalf("CallTool(%s) = %+v, %v", name, res, err)
+		}
+		data, _ := json.Marshal(res.StructuredContent)
+		return data
+	}
+
+	call("memory__memorize__mlc", map[string]any{"entity": "Alice", "observation": "lives in Bonn"})
+	call("memory__correct_observation__mlc", map[string]any{"entityName": "Alice", "oldObservation": "lives in Bonn", "newObservation": "lives in Köln"})
+
+	var changes struct {
+		Events []struct {
+			Kind         string `json:"kind"`
+			Action       string `json:"action"`
+			Content      string `json:"content"`
+			SupersededBy *int64 `json:"supersededBy"`
+		} `json:"events"`
+		NextCursor string `json:"nextCursor"`
+	}
+	data := call("memory__changes__mlc", map[string]any{"kinds": []string{"observation"}})
+	if err := json.Unmarshal(data, &changes); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Events) != 3 || changes.Events[2].Action != "updated" || changes.Events[2].SupersededBy == nil || changes.NextCursor == "" {
+		t.Errorf("changes = %s", data)
+	}
+
+	var slice struct {
+		Entities []struct {
+			Observations []string `json:"observations"`
+		} `json:"entities"`
+	}
+	data = call("memory__graph_at__mlc", map[string]any{"at": "0s"})
+	if err := json.Unmarshal(data, &slice); err != nil {
+		t.Fatal(err)
+	}
+	if len(slice.Entities) != 1 || len(slice.Entities[0].Observations) != 1 || slice.Entities[0].Observations[0] != "lives in Köln" {
+		t.Errorf("graph_at = %s", data)
+	}
+}
+
+func TestResolveDBPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv(envDB, "")
+
+	got, err := resolveDBPath("")
+	if err != nil {
+		t.Fatalf("resolveDBPath() error = %v", err)
+	}
+	if want := filepath.Join(dir, ".local", "share", "mcp-proxy", "memory.db"); got != want {
+		t.Errorf("resolveDBPath() = %q, want %q", got, want)
+	}
+
+	t.Setenv(envDB, filepath.Join(dir, "env", "memory.db"))
+	if got, _ := resolveDBPath(""); got != filepath.Join(dir, "env", "memory.db") {
+		t.Errorf("resolveDBPath() with %s = %q", envDB, got)
+	}
+
+	flagPath := filepath.Join(dir, "flag", "nested", "memory.db")
+	if got, _ := resolveDBPath(flagPath); got != flagPath {
+		t.Errorf("resolveDBPath(flag) = %q", got)
+	}
+	if info, err := os.Stat(filepath.Dir(flagPath)); err != nil || !info.IsDir() {
+		t.Errorf("database directory not created: %v", err)
+	}
+
+	// A file in place of the directory is reported.
+	blocker := filepath.Join(dir, "file")
+	os.WriteFile(blocker, nil, 0644)
+	if _, err := resolveDBPath(filepath.Join(blocker, "memory.db")); err == nil {
+		t.Error("resolveDBPath() below a file should fail")
+	}
+}
+
+func TestResolveNamespace(t *testing.T) {
+	t.Setenv(envNamespace, "")
+	if got := resolveNamespace(""); got != "default" {
+		t.Errorf("resolveNamespace() = %q, want default", got)
+	}
+	t.Setenv(envNamespace, "project-a")
+	if got := resolveNamespace(""); got != "project-a" {
+		t.Errorf("resolveNamespace() = %q, want project-a", got)
+	}
+	if got := resolveNamespace("project-b"); got != "project-b" {
+		t.Errorf("resolveNamespace(flag) = %q, want project-b", got)
+	}
+}
+
+func TestSubcommands_ExportImport(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "src.db"), filepath.Join(dir, "dst.db")
+	doc := `{"type":"entity","name":"Alice","entityType":"person","observations":["likes Go"]}
+{"type":"relation","from":"Alice","to":"Acme","relationType":"works_at"}
+`
+	var out bytes.Buffer
+	if err := runImport([]string{"-db", src, "-namespace", "work", "-"}, strings.NewReader(doc), &out); err != nil {
+		t.Fatalf("import error = %v", err)
+	}
+	if !strings.Contains(out.String(), "2 entities (2 created") {
+		t.Errorf("import output = %q", out.String())
+	}
+
+	file := filepath.Join(dir, "work.graphml")
+	if err := runExport([]string{"-db", src, "-namespace", "work", "-o", file}, nil, &out); err != nil {
+		t.Fatalf("export error = %v", err)
+	}
+	if err := runImport([]string{"-db", dst, file}, nil, &out); err != nil {
+		t.Fatalf("import of the export error = %v", err)
+	}
+
+	out.Reset()
+	if err := runExport([]string{"-db", dst}, nil, &out); err != nil {
+		t.Fatalf("export error = %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, `"name":"Alice"`) || !strings.Contains(got, `"name":"Acme","entityType":"unknown"`) || !strings.Contains(got, `"relationType":"works_at"`) {
+		t.Errorf("export = %q", got)
+	}
+
+	if err := runImport([]string{"-db", dst, filepath.Join(dir, "graph.csv")}, nil, &out); err == nil {
+		t.Error("import of an unknown format should fail")
+	}
+}
+
+func TestSubcommands_ImportChecksOntology(t *testing.T) {
+	dir := t.TempDir()
+	ontologyFile := filepath.Join(dir, "ontology.yaml")
+	if err := os.WriteFile(ontologyFile, []byte("strict: true\nentityTypes:\n  person: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	doc := `{"type":"entity","name":"Alice","entityType":"Person"}
+{"type":"entity","name":"Paris","entityType":"city"}
+`
+	var out bytes.Buffer
+	err := runImport([]string{"-db", filepath.Join(dir, "memory.db"), "-ontology", ontologyFile, "-"}, strings.NewReader(doc), &out)
+	if err == nil || !strings.Contains(out.String(), `failed entity "Paris": entity type "city" is not in the ontology`) {
+		t.Errorf("import = %v, output %q", err, out.String())
+	}
+	if err := runImport([]string{"-db", filepath.Join(dir, "memory.db"), "-ontology", filepath.Join(dir, "missing.yaml"), "-"}, strings.NewReader(doc), &out); err == nil {
+		t.Error("import with a missing ontology should fail")
+	}
+}
+
+func TestRunConsolidation(t *testing.T) {
+	handler, err := handlers.NewMemoryHandler(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatalf("NewMemoryHandler() error = %v", err)
+	}
+	defer handler.Close()
+	facts := make([]interface{}, 20)
+	for i := range facts {
+		facts[i] = fmt.Sprintf("note %d", i)
+	}
+	if _, err := handler.CreateEntities(map[string]interface{}{"entities": []interface{}{
+		map[string]interface{}{"name": "Oly", "entityType": "person", "observations": facts},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runConsolidation(ctx, handler, 10*time.Millisecond)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		graph, err := handler.OpenNodes(map[string]interface{}{"names": []interface{}{"Oly"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if obs := graph.(models.KnowledgeGraph).Entities[0].Observations; len(obs) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("observations were not consolidated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestSubcommands_Sweep(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "memory.db")
+	var out bytes.Buffer
+	doc := `{"type":"entity","name":"Standup","entityType":"event","observations":["is daily"]}` + "\n"
+	if err := runImport([]string{"-db", db, "-"}, strings.NewReader(doc), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	if err := runSweep([]string{"-db", db, "-retention", "event=0s", "-dry-run"}, nil, &out); err != nil {
+		t.Fatalf("sweep error = %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "default\tStandup\t1\tretention:event\tis daily\n") || !strings.Contains(got, "Would forget 1 observations") {
+		t.Errorf("dry run output = %q", got)
+	}
+
+	out.Reset()
+	if err := runSweep([]string{"-db", db, "-retention", "event=0s"}, nil, &out); err != nil {
+		t.Fatalf("sweep error = %v", err)
+	}
+	out.Reset()
+	if err := runSweep([]string{"-db", db, "-retention", "event=0s", "-dry-run"}, nil, &out); err != nil || out.String() != "Would forget 0 observations\n" {
+		t.Errorf("second sweep = %q, %v", out.String(), err)
+	}
+
+	if err := runSweep([]string{"-db", db, "-retention", "event"}, nil, &out); err == nil {
+		t.Error("sweep with an invalid policy should fail")
+	}
+}
+
+func TestSubcommands_AuditLog(t *testing.T) {
+	dir := t.TempDir()
+	db, auditFile := filepath.Join(dir, "memory.db"), filepath.Join(dir, "audit.log")
+	var out bytes.Buffer
+	doc := `{"type":"entity","name":"Standup","entityType":"event","observations":["is daily"]}` + "\n"
+	if err := runImport([]string{"-db", db, "-audit-log", auditFile, "-"}, strings.NewReader(doc), &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := runSweep([]string{"-db", db, "-retention", "event=0s", "-audit-log", auditFile}, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(auditFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "is daily") {
+		t.Error("the audit log contains an observation")
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var e auditEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("invalid audit line %q: %v", line, err)
+		}
+		got = append(got, fmt.Sprintf("%s %s %s %s %s %v", e.Access, e.Method, e.Name, e.Namespace, strings.Join(e.Entities, ","), e.OK))
+	}
+	want := []string{"write import cli default Standup true", "write sweep cli default Standup true"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("audit log = %q, want %q", got, want)
+	}
+}
+
+// bearer adds a bearer token to requests.
+type bearer struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (b bearer) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+b.token)
+	return b.next.RoundTrip(r)
+}
+
+func TestHTTPTransport(t *testing.T) {
+	handler, err := handlers.NewMemoryHandler(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatalf("NewMemoryHandler() error = %v", err)
+	}
+	defer handler.Close()
+	server := mcp.NewServer(&mcp.Implementation{Name: "memory-server", Version: "test"}, nil)
+	registerTools(server, memoryTools(handler))
+	ts := httptest.NewServer(httpHandler(server, "secret"))
+	defer ts.Close()
+
+	for _, token := range []string{"", "wrong"} {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+mcpPath, strings.NewReader(`{}`))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("token %q: status = %d, want 401", token, resp.StatusCode)
+		}
+	}
+
+	// Several clients write concurrently to the shared database.
+	const clients, writes = 4, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, clients*writes)
+	for c := 0; c < clients; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			transport := &mcp.StreamableClientTransport{
+				Endpoint:             ts.URL + mcpPath,
+				HTTPClient:           &http.Client{Transport: bearer{"secret", http.DefaultTransport}},
+				DisableStandaloneSSE: true,
+			}
+			client := mcp.NewClient(&mcp.Implementation{Name: fmt.Sprintf("agent-%d", c), Version: "test"}, nil)
+			session, err := client.Connect(context.Background(), transport, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer session.Close()
+			for i := 0; i < writes; i++ {
+				res, err := session.CallTool(context.Background(), &mcp.CallToolParams{
+					Name:      "memory__memorize__mlc",
+					Arguments: map[string]any{"entity": "Project", "observation": fmt.Sprintf("note %d from agent %d", i, c)},
+				})
+				if err == nil && res.IsError {
+					err = fmt.Errorf("%v", res.Content)
+				}
+				if err != nil {
+					errs <- err
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("client error: %v", err)
+	}
+
+	graph, err := handler.OpenNodes(map[string]interface{}{"names": []interface{}{"Project"}, "provenance": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	details := graph.(models.KnowledgeGraph).Entities[0].ObservationDetails
+	if len(details) != clients*writes || !strings.HasPrefix(details[0].Source, "agent-") {
+		t.Errorf("stored %d observations, want %d; first = %+v", len(details), clients*writes, details[0])
+	}
+}
+
+func TestResourcesAndPrompt(t *testing.T) {
+	session := newTestSession(t)
+	ctx := context.Background()
+	for _, args := range []map[string]any{
+		{"entity": "Oly Smith", "category": "person", "observation": "drinks espresso every morning"},
+		{"entity": "Acme", "category": "company", "observation": "builds rockets"},
+	} {
+		if _, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "memory__memorize__mlc", Arguments: args}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "memory://entities/oly%20smith"})
+	if err != nil {
+		t.Fatalf("ReadResource(entity) error = %v", err)
+	}
+	if text := res.Contents[0].Text; res.Contents[0].MIMEType != "application/json" || !strings.Contains(text, `"name": "Oly Smith"`) || !strings.Contains(text, "drinks espresso") {
+		t.Errorf("entity resource = %+v", res.Contents[0])
+	}
+	if _, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "memory://entities/Nobody"}); err == nil {
+		t.Error("ReadResource() of an unknown entity should fail")
+	}
+
+	res, err = session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "memory://recent"})
+	if err != nil {
+		t.Fatalf("ReadResource(recent) error = %v", err)
+	}
+	if text := res.Contents[0].Text; !strings.Contains(text, "Acme") || !strings.Contains(text, "Oly Smith") {
+		t.Errorf("recent resource = %s", text)
+	}
+
+	prompt, err := session.GetPrompt(ctx, &mcp.GetPromptParams{Name: "memory__context__mlc", Arguments: map[string]string{"topic": "espresso"}})
+	if err != nil {
+		t.Fatalf("GetPrompt() error = %v", err)
+	}
+	text := prompt.Messages[0].Content.(*mcp.TextContent).Text
+	if !strings.Contains(text, "## Oly Smith (person)\n- drinks espresso every morning") || strings.Contains(text, "Acme") {
+		t.Errorf("prompt = %q", text)
+	}
+	if _, err := session.GetPrompt(ctx, &mcp.GetPromptParams{Name: "memory__context__mlc", Arguments: map[string]string{}}); err == nil {
+		t.Error("GetPrompt() without a topic should fail")
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	var buf bytes.Buffer
+	session := newTestSession(t, func(server *mcp.Server, tools []memoryTool) {
+		server.AddReceivingMiddleware(newAuditLog(&buf, "default", tools).middleware)
+	})
+	ctx := context.Background()
+	calls := []*mcp.CallToolParams{
+		{Name: "memory__memorize__mlc", Arguments: map[string]any{"entity": "Oly", "observation": "mail oly@example.com"}},
+		{Name: "memory__open_nodes__mlc", Arguments: map[string]any{"names": []string{"Oly", "Acme"}, "namespace": "work"}},
+		{Name: "memory__create_relations__mlc", Arguments: map[string]any{"relations": []map[string]any{{"from": "Oly", "to": "Acme", "relationType": "works_at"}}}},
+		{Name: "memory__correct_observation__mlc", Arguments: map[string]any{"entityName": "Nobody", "oldObservation": "a", "newObservation": "b"}},
+	}
+	for _, params := range calls {
+		if _, err := session.CallTool(ctx, params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "memory://entities/Oly"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []auditEntry
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var e auditEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("invalid audit line %q: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if strings.Contains(buf.String(), "oly@example.com") {
+		t.Error("the audit log contains an observation")
+	}
+	type summary struct {
+		Access, Name, Namespace, Entities string
+		OK                                bool
+	}
+	want := []summary{
+		{"write", "memory__memorize__mlc", "default", "Oly", true},
+		{"read", "memory__open_nodes__mlc", "work", "Oly,Acme", true},
+		{"write", "memory__create_relations__mlc", "default", "Oly,Acme", true},
+		{"write", "memory__correct_observation__mlc", "default", "Nobody", false},
+		{"read", "memory://entities/Oly", "default", "Oly", true},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d audit entries, want %d:\n%s", len(entries), len(want), buf.String())
+	}
+	for i, e := range entries {
+		got := summary{e.Access, e.Name, e.Namespace, strings.Join(e.Entities, ","), e.OK}
+		if got != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, got, want[i])
+		}
+		if e.Client != "client" || e.Time == "" {
+			t.Errorf("entry %d = %+v", i, e)
+		}
+	}
+}
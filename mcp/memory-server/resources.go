@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mlcmcp/memory-server/internal/handlers"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Memory resources. Both read the server's default namespace.
+const (
+	entityURIPrefix = "memory://entities/"
+	recentURI       = "memory://recent"
+	contextPrompt   = "memory__context__mlc"
+)
+
+// registerResources exposes memory as MCP resources and the context prompt, so
+// that clients can inject memory without calling a tool.
+func registerResources(server *mcp.Server, h *handlers.MemoryHandler) {
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		Name:        "entity",
+		Title:       "Memory entity",
+		Description: "An entity with its observations, aliases and outgoing relations. The name may also be an alias.",
+		MIMEType:    "application/json",
+		URITemplate: entityURIPrefix + "{name}",
+	}, func(_ context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		uri := req.Params.URI
+		name, err := url.PathUnescape(strings.TrimPrefix(uri, entityURIPrefix))
+		if err != nil {
+			return nil, mcp.ResourceNotFoundError(uri)
+		}
+		graph, ok, err := h.Entity("", name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, mcp.ResourceNotFoundError(uri)
+		}
+		return jsonResource(uri, graph)
+	})
+
+	server.AddResource(&mcp.Resource{
+		Name:        "recent",
+		Title:       "Recent memory",
+		Description: "The 20 most recently changed entities, newest first, with the relations between them",
+		MIMEType:    "application/json",
+		URI:         recentURI,
+	}, func(_ context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		graph, err := h.Recent("", 0)
+		if err != nil {
+			return nil, err
+		}
+		return jsonResource(req.Params.URI, graph)
+	})
+
+	server.AddPrompt(&mcp.Prompt{
+		Name:        contextPrompt,
+		Title:       "Memory context",
+		Description: "The entities and observations most relevant to a topic, as a preamble for the start of a conversation",
+		Arguments: []*mcp.PromptArgument{
+			{Name: "topic", Description: "What the conversation is about", Required: true},
+			{Name: "limit", Description: "Maximum number of entities (default 10)"},
+			{Name: "namespace", Description: "The memory namespace. Defaults to the server's namespace."},
+		},
+	}, func(_ context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		args := req.Params.Arguments
+		limit, _ := strconv.Atoi(args["limit"])
+		text, err := h.Context(args["namespace"], args["topic"], limit)
+		if err != nil {
+			return nil, err
+		}
+		if text == "" {
+			text = "There is no memory about " + strconv.Quote(args["topic"]) + " yet."
+		}
+		return &mcp.GetPromptResult{
+			Description: "Memory about " + args["topic"],
+			Messages:    []*mcp.PromptMessage{{Role: "user", Content: &mcp.TextContent{Text: text}}},
+		}, nil
+	})
+}
+
+// jsonResource returns v as the JSON contents of a resource.
+func jsonResource(uri string, v interface{}) (*mcp.ReadResourceResult, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{{URI: uri, MIMEType: "application/json", Text: string(data)}}}, nil
+}
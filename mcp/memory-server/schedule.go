@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mlcmcp/memory-server/internal/handlers"
+)
+
+// runConsolidation consolidates the entities with many observations every
+// interval until ctx is done. Failures are logged and retried at the next tick.
+func runConsolidation(ctx context.Context, h *handlers.MemoryHandler, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			entities, archived, err := h.ConsolidateAll(ctx, 0)
+			if err != nil {
+				log.Printf("Consolidation failed: %v", err)
+			}
+			if entities > 0 {
+				log.Printf("Consolidated %d entities, archived %d observations", entities, archived)
+			}
+		}
+	}
+}
+
+// runSweeper forgets expired observations and enforces the retention policies
+// every interval until ctx is done.
+func runSweeper(ctx context.Context, h *handlers.MemoryHandler, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			res, err := h.Sweep(false)
+			if err != nil {
+				log.Printf("Sweep failed: %v", err)
+			} else if len(res.Observations) > 0 {
+				log.Printf("Forgot %d observations that expired or exceeded their retention", len(res.Observations))
+			}
+		}
+	}
+}
@@ -0,0 +1,761 @@
+package main
+
+import (
+	"context"
+
+	"github.com/mlcmcp/memory-server/internal/exchange"
+	"github.com/mlcmcp/memory-server/internal/handlers"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// toolHandler is the signature of the MemoryHandler methods exposed as tools.
+type toolHandler func(args map[string]interface{}) (interface{}, error)
+
+// contextHandler is the signature of the tools whose queries can be cancelled
+// with the request.
+type contextHandler func(ctx context.Context, args map[string]interface{}) (interface{}, error)
+
+// memoryTool pairs a tool definition with the handler method implementing it.
+type memoryTool struct {
+	tool   mcp.Tool
+	handle toolHandler
+	// handleContext replaces handle if set.
+	handleContext contextHandler
+}
+
+// Shared schema fragments
+var (
+	stringSchema = map[string]interface{}{"type": "string"}
+
+	stringArraySchema = map[string]interface{}{"type": "array", "items": stringSchema}
+
+	// readOnly marks the tools that do not change memory.
+	readOnly = &mcp.ToolAnnotations{ReadOnlyHint: true}
+
+	entitySchema = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name":         map[string]interface{}{"type": "string", "description": "The name of the entity"},
+			"entityType":   map[string]interface{}{"type": "string", "description": "The type of the entity (e.g. 'person', 'project')"},
+			"observations": map[string]interface{}{"type": "array", "items": stringSchema, "description": "Facts about the entity"},
+		},
+		"required": []string{"name", "entityType", "observations"},
+	}
+
+	relationSchema = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"from":         map[string]interface{}{"type": "string", "description": "The name of the entity where the relation starts"},
+			"to":           map[string]interface{}{"type": "string", "description": "The name of the entity where the relation ends"},
+			"relationType": map[string]interface{}{"type": "string", "description": "The type of the relation in active voice (e.g. 'works_at')"},
+		},
+		"required": []string{"from", "to", "relationType"},
+	}
+
+	observationSchema = objectSchema(map[string]interface{}{
+		"id":           map[string]interface{}{"type": "integer"},
+		"content":      stringSchema,
+		"createdAt":    map[string]interface{}{"type": "string", "description": "When the fact was stored (RFC 3339); empty for old memories"},
+		"source":       map[string]interface{}{"type": "string", "description": "Who stated the fact"},
+		"confidence":   map[string]interface{}{"type": "number"},
+		"supersededBy": map[string]interface{}{"type": "integer", "description": "The id of the observation that corrected or summarized this one"},
+		"pinned":       map[string]interface{}{"type": "boolean", "description": "Whether consolidation leaves the fact alone, e.g. because it is a summary"},
+		"expiresAt":    map[string]interface{}{"type": "string", "description": "When the fact will be forgotten (RFC 3339)"},
+	}, "id", "content")
+
+	entityOutputSchema = objectSchema(map[string]interface{}{
+		"name":               stringSchema,
+		"entityType":         stringSchema,
+		"observations":       stringArraySchema,
+		"observationDetails": map[string]interface{}{"type": "array", "items": observationSchema},
+		"updatedAt":          map[string]interface{}{"type": "string", "description": "When the entity or its observations last changed (RFC 3339); empty for old memories"},
+		"aliases":            map[string]interface{}{"type": "array", "items": stringSchema, "description": "Other names that refer to the entity, e.g. of merged duplicates"},
+	}, "name", "entityType", "observations")
+
+	graphOutputSchema = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"entities":  map[string]interface{}{"type": "array", "items": entityOutputSchema},
+			"relations": map[string]interface{}{"type": "array", "items": relationSchema},
+		},
+		"required": []string{"entities", "relations"},
+	}
+
+	searchOutputSchema = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"entities":  map[string]interface{}{"type": "array", "items": entitySchema},
+			"relations": map[string]interface{}{"type": "array", "items": relationSchema},
+			"matches": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"entityName": stringSchema,
+						"kind":       map[string]interface{}{"type": "string", "enum": []string{"entity", "observation"}},
+						"snippet":    stringSchema,
+						"score":      map[string]interface{}{"type": "number"},
+					},
+				},
+			},
+			"total": map[string]interface{}{"type": "integer"},
+		},
+		"required": []string{"entities", "relations", "matches", "total"},
+	}
+
+	directionSchema = map[string]interface{}{
+		"type":        "string",
+		"enum":        []string{"out", "in", "both"},
+		"default":     "both",
+		"description": "Follow outgoing relations (out), incoming relations (in) or both",
+	}
+
+	// itemStatusProperties describe the outcome of one item of a batch write.
+	itemStatusProperties = map[string]interface{}{
+		"status":  map[string]interface{}{"type": "string", "enum": []string{"created", "exists", "failed"}},
+		"error":   map[string]interface{}{"type": "string", "description": "Why the item failed"},
+		"warning": map[string]interface{}{"type": "string", "description": "How the written item deviates from the ontology"},
+	}
+
+	formatSchema = map[string]interface{}{
+		"type":        "string",
+		"enum":        exchange.Formats,
+		"default":     exchange.JSONL,
+		"description": "The document format: jsonl (reference memory server), jsonld or graphml",
+	}
+
+	deleteOutputSchema = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"deleted": map[string]interface{}{"type": "integer"},
+			"message": stringSchema,
+		},
+		"required": []string{"deleted", "message"},
+	}
+)
+
+// withProvenance adds the source and confidence arguments of tools that store facts.
+func withProvenance(properties map[string]interface{}) map[string]interface{} {
+	properties["source"] = map[string]interface{}{"type": "string", "description": "Who stated the facts, e.g. an agent or session id. Defaults to the client's name."}
+	properties["confidence"] = map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1, "description": "How certain the facts are, from 0 to 1"}
+	properties["expiresAt"] = map[string]interface{}{"type": "string", "description": "Forget the facts at this time (RFC 3339), e.g. for temporary states"}
+	properties["ttl"] = map[string]interface{}{"type": "string", "description": "Forget the facts after this time, e.g. 72h or 7d. Alternative to expiresAt."}
+	return properties
+}
+
+// withHistory adds the arguments of tools that read facts.
+func withHistory(properties map[string]interface{}) map[string]interface{} {
+	properties["includeSuperseded"] = map[string]interface{}{"type": "boolean", "default": false, "description": "Also return facts that were corrected later"}
+	properties["provenance"] = map[string]interface{}{"type": "boolean", "default": false, "description": "Return observationDetails with the id, time, source and confidence of each fact, and when each entity last changed"}
+	return properties
+}
+
+// withItemStatus adds the status properties of a batch write item.
+func withItemStatus(properties map[string]interface{}) map[string]interface{} {
+	for k, v := range itemStatusProperties {
+		properties[k] = v
+	}
+	return properties
+}
+
+// objectSchema returns an object schema with the given properties and required fields.
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// memoryTools returns the tool set. It mirrors the reference memory server,
+// plus memorize, rename and merge.
+func memoryTools(h *handlers.MemoryHandler) []memoryTool {
+	return withNamespace([]memoryTool{
+		{
+			tool: mcp.Tool{
+				Name:        "memory__memorize__mlc",
+				Description: "Store a new fact or observation about an entity",
+				InputSchema: objectSchema(withProvenance(map[string]interface{}{
+					"entity":      map[string]interface{}{"type": "string", "description": "The name of the thing (e.g. 'Oly' or 'Project')"},
+					"category":    map[string]interface{}{"type": "string", "description": "Category (e.g. 'Person', 'Setting')"},
+					"observation": map[string]interface{}{"type": "string", "description": "The actual fact to remember"},
+				}), "entity", "observation"),
+				OutputSchema: entitySchema,
+			},
+			handle: h.Memorize,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__create_entities__mlc",
+				Description: "Create multiple new entities in the knowledge graph. Entities that already exist are skipped; names are matched regardless of case and spacing and through aliases. The result reports for each entity whether it was created, already existed or failed, and lists existing entities with nearly matching names, e.g. \"Oly\" for \"Oliver (Oly)\", as similar; merge them if they are the same.",
+				InputSchema: objectSchema(withProvenance(map[string]interface{}{
+					"entities": map[string]interface{}{"type": "array", "items": entitySchema},
+				}), "entities"),
+				OutputSchema: objectSchema(map[string]interface{}{
+					"entities": map[string]interface{}{"type": "array", "items": entitySchema},
+					"results": map[string]interface{}{
+						"type": "array",
+						"items": objectSchema(withItemStatus(map[string]interface{}{
+							"name":    stringSchema,
+							"similar": map[string]interface{}{"type": "array", "items": stringSchema, "description": "Existing entities with nearly matching names"},
+						}), "name", "status"),
+					},
+				}, "entities", "results"),
+			},
+			handle: h.CreateEntities,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__create_relations__mlc",
+				Description: "Create multiple new relations between entities in the knowledge graph. Relations should be in active voice. The result reports for each relation whether it was created, already existed or failed.",
+				InputSchema: objectSchema(map[string]interface{}{
+					"relations": map[string]interface{}{"type": "array", "items": relationSchema},
+				}, "relations"),
+				OutputSchema: objectSchema(map[string]interface{}{
+					"relations": map[string]interface{}{"type": "array", "items": relationSchema},
+					"results": map[string]interface{}{
+						"type": "array",
+						"items": objectSchema(withItemStatus(map[string]interface{}{
+							"from": stringSchema, "to": stringSchema, "relationType": stringSchema,
+						}), "from", "to", "relationType", "status"),
+					},
+				}, "relations", "results"),
+			},
+			handle: h.CreateRelations,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__add_observations__mlc",
+				Description: "Add new observations to existing entities in the knowledge graph",
+				InputSchema: objectSchema(withProvenance(map[string]interface{}{
+					"observations": map[string]interface{}{
+						"type": "array",
+						"items": objectSchema(withProvenance(map[string]interface{}{
+							"entityName": map[string]interface{}{"type": "string", "description": "The name of the entity to add the observations to"},
+							"contents":   map[string]interface{}{"type": "array", "items": stringSchema, "description": "The observations to add"},
+						}), "entityName", "contents"),
+					},
+				}), "observations"),
+				OutputSchema: objectSchema(map[string]interface{}{
+					"results": map[string]interface{}{
+						"type": "array",
+						"items": objectSchema(map[string]interface{}{
+							"entityName":        stringSchema,
+							"addedObservations": stringArraySchema,
+							"statuses": map[string]interface{}{
+								"type": "array",
+								"items": objectSchema(withItemStatus(map[string]interface{}{
+									"content":     stringSchema,
+									"duplicateOf": map[string]interface{}{"type": "string", "description": "The stored fact this one repeats apart from case, punctuation and spacing"},
+								}), "content", "status"),
+							},
+						}, "entityName", "addedObservations", "statuses"),
+					},
+				}, "results"),
+			},
+			handle: h.AddObservations,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__delete_entities__mlc",
+				Description: "Delete multiple entities and their associated relations from the knowledge graph",
+				InputSchema: objectSchema(map[string]interface{}{
+					"entityNames": map[string]interface{}{"type": "array", "items": stringSchema, "description": "The names of the entities to delete"},
+				}, "entityNames"),
+				OutputSchema: deleteOutputSchema,
+			},
+			handle: h.DeleteEntities,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__delete_observations__mlc",
+				Description: "Delete specific observations from entities in the knowledge graph",
+				InputSchema: objectSchema(map[string]interface{}{
+					"deletions": map[string]interface{}{
+						"type": "array",
+						"items": objectSchema(map[string]interface{}{
+							"entityName":   map[string]interface{}{"type": "string", "description": "The name of the entity containing the observations"},
+							"observations": map[string]interface{}{"type": "array", "items": stringSchema, "description": "The observations to delete"},
+						}, "entityName", "observations"),
+					},
+				}, "deletions"),
+				OutputSchema: deleteOutputSchema,
+			},
+			handle: h.DeleteObservations,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__delete_relations__mlc",
+				Description: "Delete multiple relations from the knowledge graph",
+				InputSchema: objectSchema(map[string]interface{}{
+					"relations": map[string]interface{}{"type": "array", "items": relationSchema, "description": "The relations to delete"},
+				}, "relations"),
+				OutputSchema: deleteOutputSchema,
+			},
+			handle: h.DeleteRelations,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__read_graph__mlc",
+				Annotations: readOnly,
+				Description: "Read the knowledge graph page by page: entities in name order with their observations and the relations starting at them. Pass nextCursor as cursor to get the next page.",
+				InputSchema: objectSchema(withHistory(map[string]interface{}{
+					"limit":        map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 1000, "default": 100, "description": "Entities per page"},
+					"cursor":       map[string]interface{}{"type": "string", "description": "The nextCursor of the previous page"},
+					"entityTypes":  map[string]interface{}{"type": "array", "items": stringSchema, "description": "Only entities of these types"},
+					"updatedSince": map[string]interface{}{"type": "string", "format": "date-time", "description": "Only entities whose type or observations changed at or after this time (RFC 3339)"},
+				})),
+				OutputSchema: objectSchema(map[string]interface{}{
+					"entities":   map[string]interface{}{"type": "array", "items": entityOutputSchema},
+					"relations":  map[string]interface{}{"type": "array", "items": relationSchema},
+					"nextCursor": map[string]interface{}{"type": "string", "description": "Cursor of the next page; missing on the last page"},
+				}, "entities", "relations"),
+			},
+			handle: h.ReadGraph,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__search_nodes__mlc",
+				Annotations: readOnly,
+				Description: "Search for nodes in the knowledge graph by entity name, type or observation content. Results are ranked by relevance (BM25); words match regardless of inflection.",
+				InputSchema: objectSchema(map[string]interface{}{
+					"query":             map[string]interface{}{"type": "string", "description": "The search terms; entities matching any of them are returned"},
+					"limit":             map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 100, "default": 20, "description": "Maximum number of entities to return"},
+					"offset":            map[string]interface{}{"type": "integer", "minimum": 0, "default": 0, "description": "Number of ranked entities to skip"},
+					"includeSuperseded": map[string]interface{}{"type": "boolean", "default": false, "description": "Also search facts that were corrected later"},
+				}, "query"),
+				OutputSchema: searchOutputSchema,
+			},
+			handle: h.SearchNodes,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__semantic_search__mlc",
+				Annotations: readOnly,
+				Description: "Search for entities whose observations are similar in meaning to the query, e.g. to find \"likes coffee\" when asking about beverage preferences. By default the result is combined with the keyword ranking.",
+				InputSchema: objectSchema(map[string]interface{}{
+					"query":    map[string]interface{}{"type": "string", "description": "A natural language description of what to find"},
+					"limit":    map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 100, "default": 10, "description": "Number of entities to return (top-k)"},
+					"hybrid":   map[string]interface{}{"type": "boolean", "default": true, "description": "Rerank by fusing the semantic ranking with the full-text ranking"},
+					"minScore": map[string]interface{}{"type": "number", "minimum": -1, "maximum": 1, "default": 0, "description": "Ignore observations with a lower cosine similarity"},
+				}, "query"),
+				OutputSchema: searchOutputSchema,
+			},
+			handle: h.SemanticSearch,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__neighborhood__mlc",
+				Annotations: readOnly,
+				Description: "Get the entities connected to an entity within a number of hops, with the relations between them",
+				InputSchema: objectSchema(map[string]interface{}{
+					"name":          map[string]interface{}{"type": "string", "description": "The entity at the center"},
+					"depth":         map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 5, "default": 1, "description": "Maximum number of hops"},
+					"direction":     directionSchema,
+					"relationTypes": map[string]interface{}{"type": "array", "items": stringSchema, "description": "Only follow relations of these types"},
+				}, "name"),
+				OutputSchema: objectSchema(map[string]interface{}{
+					"entities":  map[string]interface{}{"type": "array", "items": entitySchema},
+					"relations": map[string]interface{}{"type": "array", "items": relationSchema},
+					"center":    stringSchema,
+					"hops":      map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "integer"}},
+				}, "entities", "relations", "center", "hops"),
+			},
+			handle: h.Neighborhood,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__shortest_path__mlc",
+				Annotations: readOnly,
+				Description: "Find how two entities are connected: the path with the fewest relations between them",
+				InputSchema: objectSchema(map[string]interface{}{
+					"from":          map[string]interface{}{"type": "string", "description": "The entity to start from"},
+					"to":            map[string]interface{}{"type": "string", "description": "The entity to reach"},
+					"maxDepth":      map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 6, "default": 4, "description": "Maximum path length"},
+					"direction":     directionSchema,
+					"relationTypes": map[string]interface{}{"type": "array", "items": stringSchema, "description": "Only follow relations of these types"},
+				}, "from", "to"),
+				OutputSchema: objectSchema(map[string]interface{}{
+					"found":     map[string]interface{}{"type": "boolean"},
+					"length":    map[string]interface{}{"type": "integer"},
+					"path":      stringArraySchema,
+					"entities":  map[string]interface{}{"type": "array", "items": entitySchema},
+					"relations": map[string]interface{}{"type": "array", "items": relationSchema},
+				}, "found", "length", "path", "entities", "relations"),
+			},
+			handleContext: h.ShortestPathContext,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__list_relations__mlc",
+				Annotations: readOnly,
+				Description: "List relations, optionally those of one entity, in one direction or of certain types",
+				InputSchema: objectSchema(map[string]interface{}{
+					"name":          map[string]interface{}{"type": "string", "description": "Only relations of this entity"},
+					"direction":     directionSchema,
+					"relationTypes": map[string]interface{}{"type": "array", "items": stringSchema, "description": "Only relations of these types"},
+					"limit":         map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 1000, "default": 100},
+					"offset":        map[string]interface{}{"type": "integer", "minimum": 0, "default": 0},
+				}),
+				OutputSchema: objectSchema(map[string]interface{}{
+					"relations": map[string]interface{}{"type": "array", "items": relationSchema},
+					"total":     map[string]interface{}{"type": "integer"},
+				}, "relations", "total"),
+			},
+			handle: h.ListRelations,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__open_nodes__mlc",
+				Annotations: readOnly,
+				Description: "Open specific nodes in the knowledge graph by their names",
+				InputSchema: objectSchema(withHistory(map[string]interface{}{
+					"names": map[string]interface{}{"type": "array", "items": stringSchema, "description": "The names of the entities to retrieve"},
+				}), "names"),
+				OutputSchema: graphOutputSchema,
+			},
+			handle: h.OpenNodes,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__rename_entity__mlc",
+				Description: "Rename an entity, keeping its observations and relations",
+				InputSchema: objectSchema(map[string]interface{}{
+					"oldName": map[string]interface{}{"type": "string", "description": "The current name of the entity"},
+					"newName": map[string]interface{}{"type": "string", "description": "The new name; must not exist yet"},
+				}, "oldName", "newName"),
+				OutputSchema: objectSchema(map[string]interface{}{
+					"oldName": stringSchema,
+					"newName": stringSchema,
+				}, "oldName", "newName"),
+			},
+			handle: h.RenameEntity,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__merge_entities__mlc",
+				Description: "Merge duplicate entities into a target entity. Observations and relations are moved to the target, the duplicates are deleted and their names are kept as aliases of the target.",
+				InputSchema: objectSchema(map[string]interface{}{
+					"targetName":  map[string]interface{}{"type": "string", "description": "The entity to keep"},
+					"sourceNames": map[string]interface{}{"type": "array", "items": stringSchema, "description": "The entities to merge into the target"},
+				}, "targetName", "sourceNames"),
+				OutputSchema: objectSchema(map[string]interface{}{
+					"entity": entityOutputSchema,
+					"merged": stringArraySchema,
+				}, "entity", "merged"),
+			},
+			handle: h.MergeEntities,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__correct_observation__mlc",
+				Description: "Correct a fact about an entity. The old fact is kept as history but hidden from reads and searches.",
+				InputSchema: objectSchema(withProvenance(map[string]interface{}{
+					"entityName":     map[string]interface{}{"type": "string", "description": "The entity the fact is about"},
+					"oldObservation": map[string]interface{}{"type": "string", "description": "The current fact, exactly as stored"},
+					"newObservation": map[string]interface{}{"type": "string", "description": "The corrected fact"},
+				}), "entityName", "oldObservation", "newObservation"),
+				OutputSchema: objectSchema(map[string]interface{}{
+					"entityName": stringSchema,
+					"superseded": observationSchema,
+					"current":    observationSchema,
+					"warning":    map[string]interface{}{"type": "string", "description": "Values masked in the corrected fact"},
+				}, "entityName", "superseded", "current"),
+			},
+			handle: h.CorrectObservation,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__consolidate__mlc",
+				Description: "Condense the observations of entities into one summary each. The originals are archived: hidden from reads like corrected facts, shown with includeSuperseded and restored if the summary is deleted.",
+				InputSchema: objectSchema(map[string]interface{}{
+					"entityNames":     map[string]interface{}{"type": "array", "items": stringSchema, "description": "The entities to consolidate. Defaults to all entities with at least minObservations observations."},
+					"minObservations": map[string]interface{}{"type": "integer", "minimum": 2, "description": "Consolidate only entities with at least this many unsummarized observations (default 20, or 2 for named entities)"},
+				}),
+				OutputSchema: objectSchema(map[string]interface{}{
+					"summarizer": stringSchema,
+					"consolidated": map[string]interface{}{
+						"type": "array",
+						"items": objectSchema(map[string]interface{}{
+							"entityName": stringSchema,
+							"summary":    observationSchema,
+							"archived":   map[string]interface{}{"type": "integer"},
+							"groups":     map[string]interface{}{"type": "integer"},
+						}, "entityName", "summary", "archived", "groups"),
+					},
+					"skipped": stringArraySchema,
+				}, "summarizer", "consolidated", "skipped"),
+			},
+			handle: h.Consolidate,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__forget__mlc",
+				Description: "Permanently delete memories, also from the history: whole entities by name, or observations by id, by query (all words must match) or by age. Criteria are combined; entityNames alone deletes the entities with their observations and relations. Forgetting a corrected fact also deletes the facts it replaced.",
+				InputSchema: objectSchema(map[string]interface{}{
+					"entityNames":    map[string]interface{}{"type": "array", "items": stringSchema, "description": "Entities to delete, or whose observations to consider when combined with other criteria"},
+					"observationIds": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}, "description": "Observations to delete, as returned in observationDetails"},
+					"query":          map[string]interface{}{"type": "string", "description": "Delete the observations containing all of these words"},
+					"olderThan":      map[string]interface{}{"type": "string", "description": "Delete the observations stored before this time (RFC 3339) or longer ago than this age (e.g. 30d)"},
+					"dryRun":         map[string]interface{}{"type": "boolean", "default": false, "description": "Only report what would be deleted"},
+				}),
+				OutputSchema: objectSchema(map[string]interface{}{
+					"dryRun":   map[string]interface{}{"type": "boolean"},
+					"entities": stringArraySchema,
+					"observations": map[string]interface{}{
+						"type": "array",
+						"items": objectSchema(map[string]interface{}{
+							"entityName": stringSchema,
+							"id":         map[string]interface{}{"type": "integer"},
+							"content":    stringSchema,
+							"reason":     map[string]interface{}{"type": "string", "description": "superseded for a fact replaced by a forgotten observation"},
+						}, "entityName", "id", "content"),
+					},
+				}, "dryRun", "entities", "observations"),
+			},
+			handle: h.Forget,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__changes__mlc",
+				Annotations: readOnly,
+				Description: "List the changes to the knowledge graph in the order they happened: entities, observations and relations that were created, updated or deleted. Pass nextCursor as cursor later to get the changes made since.",
+				InputSchema: objectSchema(map[string]interface{}{
+					"since":       map[string]interface{}{"type": "string", "description": "Only changes at or after this time (RFC 3339) or within this age (e.g. 7d)"},
+					"until":       map[string]interface{}{"type": "string", "description": "Only changes at or before this time (RFC 3339) or longer ago than this age"},
+					"entityNames": map[string]interface{}{"type": "array", "items": stringSchema, "description": "Only changes to these entities, their observations and their relations"},
+					"kinds":       map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "enum": []string{"entity", "observation", "relation"}}, "description": "Only changes of these kinds"},
+					"limit":       map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 1000, "default": 100},
+					"cursor":      map[string]interface{}{"type": "string", "description": "The nextCursor of a previous call"},
+				}),
+				OutputSchema: objectSchema(map[string]interface{}{
+					"events": map[string]interface{}{
+						"type": "array",
+						"items": objectSchema(map[string]interface{}{
+							"id":            map[string]interface{}{"type": "integer"},
+							"at":            map[string]interface{}{"type": "string", "description": "When the change happened (RFC 3339)"},
+							"kind":          map[string]interface{}{"type": "string", "enum": []string{"entity", "observation", "relation"}},
+							"action":        map[string]interface{}{"type": "string", "enum": []string{"created", "updated", "deleted"}},
+							"entityName":    stringSchema,
+							"entityType":    stringSchema,
+							"observationId": map[string]interface{}{"type": "integer"},
+							"content":       map[string]interface{}{"type": "string", "description": "The observation after the change"},
+							"supersededBy":  map[string]interface{}{"type": "integer", "description": "The observation that replaced this one"},
+							"forgotten":     map[string]interface{}{"type": "boolean", "description": "The observation was forgotten and its content erased"},
+							"from":          stringSchema,
+							"to":            stringSchema,
+							"relationType":  stringSchema,
+						}, "id", "at", "kind", "action"),
+					},
+					"nextCursor": map[string]interface{}{"type": "string", "description": "Cursor after the last change"},
+					"hasMore":    map[string]interface{}{"type": "boolean", "description": "Whether more changes follow right away"},
+				}, "events", "nextCursor", "hasMore"),
+			},
+			handle: h.Changes,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__graph_at__mlc",
+				Annotations: readOnly,
+				Description: "Read the knowledge graph as it was at a past time: what was known then, without the facts that were corrected or deleted before and with the ones added later left out",
+				InputSchema: objectSchema(map[string]interface{}{
+					"at":          map[string]interface{}{"type": "string", "description": "The time (RFC 3339) or how long ago (e.g. 30d)"},
+					"entityNames": map[string]interface{}{"type": "array", "items": stringSchema, "description": "Only these entities, by the names they had then"},
+					"limit":       map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 1000, "default": 100, "description": "Maximum number of entities"},
+				}, "at"),
+				OutputSchema: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"at":        stringSchema,
+						"entities":  map[string]interface{}{"type": "array", "items": entitySchema},
+						"relations": map[string]interface{}{"type": "array", "items": relationSchema},
+						"truncated": map[string]interface{}{"type": "boolean", "description": "More entities existed than the limit"},
+					},
+					"required": []string{"at", "entities", "relations"},
+				},
+			},
+			handle: h.GraphAt,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__export__mlc",
+				Annotations: readOnly,
+				Description: "Export the current facts of the namespace as a JSONL (reference memory server), JSON-LD or GraphML document",
+				InputSchema: objectSchema(map[string]interface{}{
+					"format": formatSchema,
+				}),
+				OutputSchema: objectSchema(map[string]interface{}{
+					"format":    stringSchema,
+					"content":   stringSchema,
+					"entities":  map[string]interface{}{"type": "integer"},
+					"relations": map[string]interface{}{"type": "integer"},
+				}, "format", "content", "entities", "relations"),
+			},
+			handle: h.ExportGraph,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__import__mlc",
+				Description: "Import a JSONL (reference memory server), JSON-LD or GraphML document into the namespace in one transaction. Relations to unknown entities create them.",
+				InputSchema: objectSchema(map[string]interface{}{
+					"format":  formatSchema,
+					"content": map[string]interface{}{"type": "string", "description": "The document to import"},
+					"strategy": map[string]interface{}{
+						"type":        "string",
+						"enum":        handlers.Strategies,
+						"default":     handlers.StrategySkip,
+						"description": "What to do with entities that exist already: leave them (skip), replace their type and observations (overwrite) or add the missing observations (append)",
+					},
+					"source": map[string]interface{}{"type": "string", "description": "Who the imported facts come from. Defaults to the client's name."},
+				}, "content"),
+				OutputSchema: objectSchema(map[string]interface{}{
+					"strategy":          stringSchema,
+					"entitiesCreated":   map[string]interface{}{"type": "integer"},
+					"entitiesUpdated":   map[string]interface{}{"type": "integer"},
+					"entitiesSkipped":   map[string]interface{}{"type": "integer"},
+					"observationsAdded": map[string]interface{}{"type": "integer"},
+					"relationsCreated":  map[string]interface{}{"type": "integer"},
+					"relationsSkipped":  map[string]interface{}{"type": "integer"},
+					"issues": map[string]interface{}{"type": "array", "items": objectSchema(withItemStatus(map[string]interface{}{
+						"item": map[string]interface{}{"type": "string", "description": "The entity or relation as written in the document"},
+					}), "item", "status")},
+				}, "strategy", "entitiesCreated", "entitiesUpdated", "entitiesSkipped", "observationsAdded", "relationsCreated", "relationsSkipped"),
+			},
+			handle: h.ImportGraph,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__get_ontology__mlc",
+				Annotations: readOnly,
+				Description: "Get the vocabulary of the graph: the entity types and the relation types with the entity types they connect, their inverses and aliases. Use these types when writing.",
+				InputSchema: objectSchema(map[string]interface{}{}),
+				OutputSchema: objectSchema(map[string]interface{}{
+					"configured": map[string]interface{}{"type": "boolean", "description": "False if the server accepts any type"},
+					"strict":     map[string]interface{}{"type": "boolean", "description": "Whether writes outside the vocabulary are rejected instead of stored with a warning"},
+					"entityTypes": map[string]interface{}{
+						"type": "object",
+						"additionalProperties": objectSchema(map[string]interface{}{
+							"description": stringSchema,
+							"aliases":     stringArraySchema,
+						}),
+					},
+					"relationTypes": map[string]interface{}{
+						"type": "object",
+						"additionalProperties": objectSchema(map[string]interface{}{
+							"description": stringSchema,
+							"domain":      map[string]interface{}{"type": "array", "items": stringSchema, "description": "Allowed types of the source entity; empty means any"},
+							"range":       map[string]interface{}{"type": "array", "items": stringSchema, "description": "Allowed types of the target entity; empty means any"},
+							"inverse":     map[string]interface{}{"type": "string", "description": "The name of the relation in the other direction; writes using it are stored reversed"},
+							"aliases":     stringArraySchema,
+						}),
+					},
+				}, "configured", "strict", "entityTypes", "relationTypes"),
+			},
+			handle: h.GetOntology,
+		},
+		{
+			tool: mcp.Tool{
+				Name:        "memory__list_namespaces__mlc",
+				Annotations: readOnly,
+				Description: "List the memory namespaces (e.g. one per project) with their sizes, and the default namespace",
+				InputSchema: objectSchema(map[string]interface{}{}),
+				OutputSchema: objectSchema(map[string]interface{}{
+					"namespaces": map[string]interface{}{
+						"type": "array",
+						"items": objectSchema(map[string]interface{}{
+							"name":         stringSchema,
+							"entities":     map[string]interface{}{"type": "integer"},
+							"observations": map[string]interface{}{"type": "integer"},
+							"relations":    map[string]interface{}{"type": "integer"},
+						}, "name", "entities", "observations", "relations"),
+					},
+					"default": stringSchema,
+				}, "namespaces", "default"),
+			},
+			handle: h.ListNamespaces,
+		},
+	})
+}
+
+// namespaceSchema is the namespace argument shared by the graph tools.
+var namespaceSchema = map[string]interface{}{
+	"type":        "string",
+	"description": "The memory namespace (e.g. a project name). Defaults to the server's namespace.",
+}
+
+// withNamespace adds the namespace argument to the tools that work on a namespace.
+func withNamespace(tools []memoryTool) []memoryTool {
+	for _, t := range tools {
+		if t.tool.Name == "memory__list_namespaces__mlc" || t.tool.Name == "memory__get_ontology__mlc" {
+			continue
+		}
+		if schema, ok := t.tool.InputSchema.(map[string]interface{}); ok {
+			schema["properties"].(map[string]interface{})["namespace"] = namespaceSchema
+		}
+	}
+	return tools
+}
+
+// registerTools adds the tools to the server. Results are returned as structured
+// content; handler errors become tool errors. Results of batch writes in which
+// some items failed are returned as tool errors too, with the per-item outcome.
+// Tools that store facts record the client's name as their source unless the
+// call names one.
+func registerTools(server *mcp.Server, tools []memoryTool) {
+	for i := range tools {
+		handle := tools[i].handleContext
+		if handle == nil {
+			plain := tools[i].handle
+			handle = func(_ context.Context, args map[string]interface{}) (interface{}, error) { return plain(args) }
+		}
+		hasSource := hasProperty(tools[i].tool.InputSchema, "source")
+		mcp.AddTool(server, &tools[i].tool, func(ctx context.Context, req *mcp.CallToolRequest, args map[string]interface{}) (*mcp.CallToolResult, any, error) {
+			if _, ok := args["source"]; hasSource && !ok && args != nil {
+				if name := clientName(req); name != "" {
+					args["source"] = name
+				}
+			}
+			res, err := handle(ctx, args)
+			if err != nil {
+				return nil, nil, err
+			}
+			if f, ok := res.(interface{ HasFailures() bool }); ok && f.HasFailures() {
+				return &mcp.CallToolResult{IsError: true}, res, nil
+			}
+			return nil, res, nil
+		})
+	}
+}
+
+// hasProperty reports whether an object schema has a property.
+func hasProperty(schema any, name string) bool {
+	m, _ := schema.(map[string]interface{})
+	properties, _ := m["properties"].(map[string]interface{})
+	_, ok := properties[name]
+	return ok
+}
+
+// clientName returns the name the client gave when connecting.
+func clientName(req *mcp.CallToolRequest) string {
+	if req == nil || req.Session == nil {
+		return ""
+	}
+	params := req.Session.InitializeParams()
+	if params == nil || params.ClientInfo == nil {
+		return ""
+	}
+	return params.ClientInfo.Name
+}
+
+// toolDefinitions returns the plain tool definitions, e.g. for -dump.
+func toolDefinitions(tools []memoryTool) []mcp.Tool {
+	defs := make([]mcp.Tool, len(tools))
+	for i, t := range tools {
+		defs[i] = t.tool
+	}
+	return defs
+}